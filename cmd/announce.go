@@ -18,7 +18,7 @@ var announceCmd = &cobra.Command{
 		fp := args[0]
 		f, err := os.Open(fp)
 		if err != nil {
-      fmt.Printf("open file err: %w\n", err)
+      fmt.Printf("open file err: %v\n", err)
 			os.Exit(1)
 		}
 		defer f.Close()
@@ -26,9 +26,12 @@ var announceCmd = &cobra.Command{
 		var buf bufio.Reader
 		buf.Reset(f)
 
-    s, err := torrent.NewSession(torrent.DefaultConfig, *log)
+    sessionCfg := torrent.DefaultConfig
+    sessionCfg.RPCEnabled = false
+    s, err := torrent.NewSession(sessionCfg, *log)
     if err != nil {
-      fmt.Printf("new session error: %w\n", err)
+      fmt.Printf("new session error: %v\n", err)
+      os.Exit(1)
     }
 
     defer s.Close()
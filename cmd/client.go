@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/rpcclient"
+	"github.com/al002/zbittorrent/torrent"
+	"github.com/spf13/cobra"
+)
+
+var (
+	clientURL        string
+	clientUnixSocket string
+	clientToken      string
+	clientTimeout    time.Duration
+	clientJSON       bool
+
+	client *rpcclient.Client
+
+	clientCmd = &cobra.Command{
+		Use:   "client",
+		Short: "Control a running zbittorrent daemon",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			client = newClient(cmd)
+		},
+	}
+)
+
+func init() {
+	flags := clientCmd.PersistentFlags()
+	flags.StringVar(&clientURL, "url", "", "URL of the JSON-RPC endpoint (default from session config)")
+	flags.StringVar(&clientUnixSocket, "unix-socket", "", "connect over Unix socket (default from session config)")
+	flags.StringVar(&clientToken, "token", "", "RPC token (default from session config)")
+	flags.DurationVar(&clientTimeout, "timeout", 30*time.Second, "timeout of requests")
+	flags.BoolVar(&clientJSON, "json", false, "print output as JSON")
+
+	clientCmd.AddCommand(clientAddCmd)
+	clientCmd.AddCommand(clientListCmd)
+	clientCmd.AddCommand(clientStatsCmd)
+	clientCmd.AddCommand(clientStartCmd)
+	clientCmd.AddCommand(clientStopCmd)
+	clientCmd.AddCommand(clientRemoveCmd)
+	clientCmd.AddCommand(clientVerifyCmd)
+	clientCmd.AddCommand(clientPeersCmd)
+	clientCmd.AddCommand(clientTrackersCmd)
+	clientCmd.AddCommand(clientFilesCmd)
+	clientCmd.AddCommand(clientSequentialCmd)
+	clientCmd.AddCommand(clientDeadlineCmd)
+	clientCmd.AddCommand(clientIPFilterCmd)
+	clientCmd.AddCommand(clientLimitsCmd)
+	clientCmd.AddCommand(clientSeedLimitsCmd)
+	clientCmd.AddCommand(clientQueueCmd)
+	clientCmd.AddCommand(clientAltSpeedCmd)
+}
+
+// newClient creates a client for the daemon. Values that are not given in flags are taken from the session config.
+func newClient(cmd *cobra.Command) *rpcclient.Client {
+	sessionCfg := torrent.DefaultConfig
+	if err := cfgRegistry.UnmarshalKey("session", &sessionCfg); err != nil {
+		exitWithError(fmt.Errorf("invalid session config: %v", err))
+	}
+
+	flags := cmd.Flags()
+	opts := &rpcclient.Options{
+		URL:        clientURL,
+		UnixSocket: clientUnixSocket,
+		Token:      clientToken,
+		Timeout:    clientTimeout,
+	}
+	if !flags.Changed("url") {
+		opts.URL = (&url.URL{
+			Scheme: "http",
+			Host:   net.JoinHostPort(sessionCfg.RPCHost, strconv.Itoa(sessionCfg.RPCPort)),
+			Path:   "/rpc",
+		}).String()
+	}
+	if !flags.Changed("unix-socket") && !flags.Changed("url") {
+		opts.UnixSocket = sessionCfg.RPCUnixSocket
+	}
+	if !flags.Changed("token") {
+		opts.Token = sessionCfg.RPCToken
+	}
+	if opts.UnixSocket != "" && !flags.Changed("url") {
+		opts.URL = "http://unix/rpc"
+	}
+
+	return rpcclient.New(opts)
+}
+
+func exitWithError(err error) {
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	os.Exit(1)
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		exitWithError(err)
+	}
+}
+
+// printTable writes rows to stdout aligned in columns under the header. Header is not printed if nil.
+func printTable(header []string, rows [][]string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if header != nil {
+		rows = append([][]string{header}, rows...)
+	}
+	for _, row := range rows {
+		for i, c := range row {
+			if i > 0 {
+				fmt.Fprint(w, "\t")
+			}
+			fmt.Fprint(w, c)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
+
+// formatBytes formats n with binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatSpeed(bps int) string {
+	return formatBytes(int64(bps)) + "/s"
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format(time.DateTime)
+}
@@ -0,0 +1,808 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/rpctypes"
+	"github.com/spf13/cobra"
+)
+
+var (
+	clientAddOptions rpctypes.AddTorrentOptions
+
+	clientLimitDownload     int64
+	clientLimitUpload       int64
+	clientLimitPeerDownload int64
+	clientLimitPeerUpload   int64
+
+	clientSeedRatio    float64
+	clientSeedTime     time.Duration
+	clientSeedIdleTime time.Duration
+	clientSeedAction   string
+
+	clientQueueLimits   rpctypes.QueueLimits
+	clientQueueSlowTime time.Duration
+
+	clientAltSpeedMode     string
+	clientAltSpeedSchedule []string
+
+	clientIPFilterAllow []string
+	clientIPFilterDeny  []string
+
+	// File indexes for each priority name given to the files command
+	clientFilePriorities = map[string]*[]int{
+		"skip":   new([]int),
+		"low":    new([]int),
+		"normal": new([]int),
+		"high":   new([]int),
+	}
+
+	clientAddCmd = &cobra.Command{
+		Use:   "add <file|uri>",
+		Short: "Add a torrent file, a magnet link or URL of a torrent file",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var t *rpctypes.Torrent
+			var err error
+			if u, err2 := url.Parse(args[0]); err2 == nil && (u.Scheme == "magnet" || u.Scheme == "http" || u.Scheme == "https") {
+				t, err = client.AddURI(args[0], &clientAddOptions)
+			} else {
+				var f *os.File
+				f, err = os.Open(args[0])
+				if err != nil {
+					exitWithError(err)
+				}
+				defer f.Close()
+				t, err = client.AddTorrent(f, &clientAddOptions)
+			}
+			if err != nil {
+				exitWithError(err)
+			}
+
+			if clientJSON {
+				printJSON(t)
+				return
+			}
+			printTorrents([]rpctypes.Torrent{*t})
+		},
+	}
+
+	clientListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List torrents in the session",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			torrents, err := client.ListTorrents()
+			if err != nil {
+				exitWithError(err)
+			}
+
+			if clientJSON {
+				printJSON(torrents)
+				return
+			}
+			printTorrents(torrents)
+		},
+	}
+
+	clientStatsCmd = &cobra.Command{
+		Use:   "stats [id]",
+		Short: "Show statistics of the session or a torrent",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				s, err := client.GetSessionStats()
+				if err != nil {
+					exitWithError(err)
+				}
+				if clientJSON {
+					printJSON(s)
+					return
+				}
+				printSessionStats(s)
+				return
+			}
+
+			s, err := client.GetTorrentStats(args[0])
+			if err != nil {
+				exitWithError(err)
+			}
+			if clientJSON {
+				printJSON(s)
+				return
+			}
+			printTorrentStats(s)
+		},
+	}
+
+	clientStartCmd = &cobra.Command{
+		Use:   "start <id>...",
+		Short: "Start torrents",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			forEachTorrent(args, client.StartTorrent)
+		},
+	}
+
+	clientStopCmd = &cobra.Command{
+		Use:   "stop <id>...",
+		Short: "Stop torrents",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			forEachTorrent(args, client.StopTorrent)
+		},
+	}
+
+	clientRemoveCmd = &cobra.Command{
+		Use:   "remove <id>...",
+		Short: "Remove torrents from the session, downloaded files are kept",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			forEachTorrent(args, client.RemoveTorrent)
+		},
+	}
+
+	clientVerifyCmd = &cobra.Command{
+		Use:   "verify <id>...",
+		Short: "Check the hashes of the downloaded pieces again",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			forEachTorrent(args, client.VerifyTorrent)
+		},
+	}
+
+	clientPeersCmd = &cobra.Command{
+		Use:   "peers <id>",
+		Short: "List connected peers of a torrent",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			peers, err := client.GetTorrentPeers(args[0])
+			if err != nil {
+				exitWithError(err)
+			}
+
+			if clientJSON {
+				printJSON(peers)
+				return
+			}
+			rows := make([][]string, len(peers))
+			for i, p := range peers {
+				rows[i] = []string{p.Addr, p.Source, p.ID, formatTime(p.ConnectedAt)}
+			}
+			printTable([]string{"ADDRESS", "SOURCE", "PEER ID", "CONNECTED AT"}, rows)
+		},
+	}
+
+	clientTrackersCmd = &cobra.Command{
+		Use:   "trackers <id>",
+		Short: "List trackers of a torrent",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			trackers, err := client.GetTorrentTrackers(args[0])
+			if err != nil {
+				exitWithError(err)
+			}
+
+			if clientJSON {
+				printJSON(trackers)
+				return
+			}
+			rows := make([][]string, len(trackers))
+			for i, t := range trackers {
+				msg := t.Error
+				if msg == "" {
+					msg = t.Warning
+				}
+				rows[i] = []string{
+					t.URL,
+					t.Status,
+					strconv.Itoa(t.Seeders),
+					strconv.Itoa(t.Leechers),
+					formatTime(t.LastAnnounce),
+					formatTime(t.NextAnnounce),
+					msg,
+				}
+			}
+			printTable([]string{"URL", "STATUS", "SEEDERS", "LEECHERS", "LAST ANNOUNCE", "NEXT ANNOUNCE", "MESSAGE"}, rows)
+		},
+	}
+
+	clientFilesCmd = &cobra.Command{
+		Use:   "files <id>",
+		Short: "List files of a torrent or change their priorities",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			files, err := client.GetTorrentFiles(args[0])
+			if err != nil {
+				exitWithError(err)
+			}
+
+			flags := cmd.Flags()
+			changed := false
+			priorities := make([]string, len(files))
+			for i, f := range files {
+				priorities[i] = f.Priority
+			}
+			for _, name := range []string{"skip", "low", "normal", "high"} {
+				if !flags.Changed(name) {
+					continue
+				}
+				changed = true
+				for _, i := range *clientFilePriorities[name] {
+					if i < 0 || i >= len(files) {
+						exitWithError(fmt.Errorf("invalid file index: %d", i))
+					}
+					priorities[i] = name
+				}
+			}
+			if changed {
+				err = client.SetTorrentFilePriorities(args[0], priorities)
+				if err != nil {
+					exitWithError(err)
+				}
+				return
+			}
+
+			if clientJSON {
+				printJSON(files)
+				return
+			}
+			rows := make([][]string, len(files))
+			for i, f := range files {
+				progress := 100.0
+				if f.Length > 0 {
+					progress = float64(f.BytesCompleted) / float64(f.Length) * 100
+				}
+				rows[i] = []string{strconv.Itoa(i), f.Path, formatBytes(f.Length), fmt.Sprintf("%.2f%%", progress), f.Priority}
+			}
+			printTable([]string{"#", "PATH", "SIZE", "PROGRESS", "PRIORITY"}, rows)
+		},
+	}
+
+	clientIPFilterCmd = &cobra.Command{
+		Use:   "ipfilter <id>",
+		Short: "Show or change allow and deny rules for peer addresses of a torrent",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			flags := cmd.Flags()
+			if flags.Changed("allow") || flags.Changed("deny") {
+				filter, err := client.GetTorrentIPFilter(args[0])
+				if err != nil {
+					exitWithError(err)
+				}
+				if flags.Changed("allow") {
+					filter.Allow = clientIPFilterAllow
+				}
+				if flags.Changed("deny") {
+					filter.Deny = clientIPFilterDeny
+				}
+				err = client.SetTorrentIPFilter(args[0], *filter)
+				if err != nil {
+					exitWithError(err)
+				}
+			}
+
+			filter, err := client.GetTorrentIPFilter(args[0])
+			if err != nil {
+				exitWithError(err)
+			}
+
+			if clientJSON {
+				printJSON(filter)
+				return
+			}
+			if len(filter.Allow) == 0 && len(filter.Deny) == 0 {
+				fmt.Println("No rules, all peers are allowed")
+				return
+			}
+			printList("Allow", filter.Allow)
+			printList("Deny", filter.Deny)
+		},
+	}
+
+	clientLimitsCmd = &cobra.Command{
+		Use:   "limits [id]",
+		Short: "Show or change global speed limits or the speed limits of a torrent",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			get := client.GetSpeedLimits
+			set := client.SetSpeedLimits
+			if len(args) == 1 {
+				get = func() (*rpctypes.SpeedLimits, error) { return client.GetTorrentSpeedLimits(args[0]) }
+				set = func(l rpctypes.SpeedLimits) error { return client.SetTorrentSpeedLimits(args[0], l) }
+			}
+
+			flags := cmd.Flags()
+			if flags.Changed("download") || flags.Changed("upload") || flags.Changed("peer-download") || flags.Changed("peer-upload") {
+				limits, err := get()
+				if err != nil {
+					exitWithError(err)
+				}
+				if flags.Changed("download") {
+					limits.Download = clientLimitDownload
+				}
+				if flags.Changed("upload") {
+					limits.Upload = clientLimitUpload
+				}
+				if flags.Changed("peer-download") {
+					limits.PeerDownload = clientLimitPeerDownload
+				}
+				if flags.Changed("peer-upload") {
+					limits.PeerUpload = clientLimitPeerUpload
+				}
+				err = set(*limits)
+				if err != nil {
+					exitWithError(err)
+				}
+			}
+
+			limits, err := get()
+			if err != nil {
+				exitWithError(err)
+			}
+
+			if clientJSON {
+				printJSON(limits)
+				return
+			}
+			fmt.Printf("Download:      %s\n", formatLimit(limits.Download))
+			fmt.Printf("Upload:        %s\n", formatLimit(limits.Upload))
+			fmt.Printf("Peer download: %s\n", formatLimit(limits.PeerDownload))
+			fmt.Printf("Peer upload:   %s\n", formatLimit(limits.PeerUpload))
+		},
+	}
+
+	clientSeedLimitsCmd = &cobra.Command{
+		Use:   "seedlimits [id]",
+		Short: "Show or change global seed limits or the seed limits of a torrent",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			get := client.GetSeedLimits
+			set := client.SetSeedLimits
+			if len(args) == 1 {
+				get = func() (*rpctypes.SeedLimits, error) { return client.GetTorrentSeedLimits(args[0]) }
+				set = func(l rpctypes.SeedLimits) error { return client.SetTorrentSeedLimits(args[0], l) }
+			}
+
+			flags := cmd.Flags()
+			if flags.Changed("ratio") || flags.Changed("time") || flags.Changed("idle") || flags.Changed("action") {
+				limits, err := get()
+				if err != nil {
+					exitWithError(err)
+				}
+				if flags.Changed("ratio") {
+					limits.Ratio = clientSeedRatio
+				}
+				if flags.Changed("time") {
+					limits.Time = int64(clientSeedTime / time.Second)
+				}
+				if flags.Changed("idle") {
+					limits.IdleTime = int64(clientSeedIdleTime / time.Second)
+				}
+				if flags.Changed("action") {
+					limits.Action = clientSeedAction
+				}
+				err = set(*limits)
+				if err != nil {
+					exitWithError(err)
+				}
+			}
+
+			limits, err := get()
+			if err != nil {
+				exitWithError(err)
+			}
+
+			if clientJSON {
+				printJSON(limits)
+				return
+			}
+			global := len(args) == 0
+			action := limits.Action
+			if action == "" {
+				action = "default"
+			}
+			fmt.Printf("Ratio:     %s\n", formatSeedLimit(fmt.Sprintf("%.2f", limits.Ratio), limits.Ratio, global))
+			fmt.Printf("Time:      %s\n", formatSeedLimit((time.Duration(limits.Time)*time.Second).String(), float64(limits.Time), global))
+			fmt.Printf("Idle time: %s\n", formatSeedLimit((time.Duration(limits.IdleTime)*time.Second).String(), float64(limits.IdleTime), global))
+			fmt.Printf("Action:    %s\n", action)
+		},
+	}
+
+	clientQueueCmd = &cobra.Command{
+		Use:   "queue",
+		Short: "Show or change the limits of running torrents",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			flags := cmd.Flags()
+			changed := false
+			for _, name := range []string{"downloads", "seeds", "torrents", "exclude-slow", "slow-download", "slow-upload", "slow-time"} {
+				changed = changed || flags.Changed(name)
+			}
+			if changed {
+				limits, err := client.GetQueueLimits()
+				if err != nil {
+					exitWithError(err)
+				}
+				if flags.Changed("downloads") {
+					limits.Downloads = clientQueueLimits.Downloads
+				}
+				if flags.Changed("seeds") {
+					limits.Seeds = clientQueueLimits.Seeds
+				}
+				if flags.Changed("torrents") {
+					limits.Torrents = clientQueueLimits.Torrents
+				}
+				if flags.Changed("exclude-slow") {
+					limits.ExcludeSlow = clientQueueLimits.ExcludeSlow
+				}
+				if flags.Changed("slow-download") {
+					limits.SlowDownloadRate = clientQueueLimits.SlowDownloadRate
+				}
+				if flags.Changed("slow-upload") {
+					limits.SlowUploadRate = clientQueueLimits.SlowUploadRate
+				}
+				if flags.Changed("slow-time") {
+					limits.SlowTime = int64(clientQueueSlowTime / time.Second)
+				}
+				err = client.SetQueueLimits(*limits)
+				if err != nil {
+					exitWithError(err)
+				}
+			}
+
+			limits, err := client.GetQueueLimits()
+			if err != nil {
+				exitWithError(err)
+			}
+
+			if clientJSON {
+				printJSON(limits)
+				return
+			}
+			fmt.Printf("Downloads:     %s\n", formatCount(limits.Downloads))
+			fmt.Printf("Seeds:         %s\n", formatCount(limits.Seeds))
+			fmt.Printf("Torrents:      %s\n", formatCount(limits.Torrents))
+			slow := "counted"
+			if limits.ExcludeSlow {
+				slow = fmt.Sprintf("excluded below down %d KB/s, up %d KB/s after %s",
+					limits.SlowDownloadRate, limits.SlowUploadRate, time.Duration(limits.SlowTime)*time.Second)
+			}
+			fmt.Printf("Slow torrents: %s\n", slow)
+		},
+	}
+
+	clientQueueMoveCmd = &cobra.Command{
+		Use:       "move <id> <up|down|top|bottom>",
+		Short:     "Change the queue position of a torrent",
+		Args:      cobra.ExactArgs(2),
+		ValidArgs: []string{"up", "down", "top", "bottom"},
+		Run: func(cmd *cobra.Command, args []string) {
+			err := client.MoveTorrentInQueue(args[0], args[1])
+			if err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+
+	clientSequentialCmd = &cobra.Command{
+		Use:       "sequential <id> <on|off>",
+		Short:     "Download the pieces of a torrent in order instead of rarest first",
+		Args:      cobra.ExactArgs(2),
+		ValidArgs: []string{"on", "off"},
+		Run: func(cmd *cobra.Command, args []string) {
+			var value bool
+			switch args[1] {
+			case "on":
+				value = true
+			case "off":
+			default:
+				exitWithError(fmt.Errorf("invalid value %q, must be on or off", args[1]))
+			}
+
+			err := client.SetTorrentSequential(args[0], value)
+			if err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+
+	clientDeadlineCmd = &cobra.Command{
+		Use:   "deadline <id> <piece> <duration>",
+		Short: "Download a piece of a torrent before the others, within the duration from now (0 clears the deadline)",
+		Args:  cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			piece, err := strconv.ParseUint(args[1], 10, 32)
+			if err != nil {
+				exitWithError(fmt.Errorf("invalid piece index: %q", args[1]))
+			}
+			d, err := time.ParseDuration(args[2])
+			if err != nil {
+				exitWithError(err)
+			}
+
+			var deadline time.Time
+			if d > 0 {
+				deadline = time.Now().Add(d)
+			}
+			err = client.SetTorrentPieceDeadline(args[0], uint32(piece), deadline)
+			if err != nil {
+				exitWithError(err)
+			}
+		},
+	}
+
+	clientAltSpeedCmd = &cobra.Command{
+		Use:   "altspeed",
+		Short: "Show or change the alternative speed limits and their schedule",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			flags := cmd.Flags()
+			changed := false
+			for _, name := range []string{"mode", "schedule", "download", "upload", "peer-download", "peer-upload"} {
+				changed = changed || flags.Changed(name)
+			}
+			if changed {
+				a, err := client.GetAltSpeed()
+				if err != nil {
+					exitWithError(err)
+				}
+				if flags.Changed("mode") {
+					a.Mode = clientAltSpeedMode
+				}
+				if flags.Changed("download") {
+					a.Limits.Download = clientLimitDownload
+				}
+				if flags.Changed("upload") {
+					a.Limits.Upload = clientLimitUpload
+				}
+				if flags.Changed("peer-download") {
+					a.Limits.PeerDownload = clientLimitPeerDownload
+				}
+				if flags.Changed("peer-upload") {
+					a.Limits.PeerUpload = clientLimitPeerUpload
+				}
+				if flags.Changed("schedule") {
+					a.Schedule = nil
+					for _, s := range clientAltSpeedSchedule {
+						if s == "" {
+							continue
+						}
+						w, err := parseAltSpeedWindow(s)
+						if err != nil {
+							exitWithError(err)
+						}
+						a.Schedule = append(a.Schedule, w)
+					}
+				}
+				err = client.SetAltSpeed(*a)
+				if err != nil {
+					exitWithError(err)
+				}
+			}
+
+			a, err := client.GetAltSpeed()
+			if err != nil {
+				exitWithError(err)
+			}
+
+			if clientJSON {
+				printJSON(a)
+				return
+			}
+			fmt.Printf("Mode:          %s\n", a.Mode)
+			fmt.Printf("Active:        %v\n", a.Active)
+			fmt.Printf("Download:      %s\n", formatLimit(a.Limits.Download))
+			fmt.Printf("Upload:        %s\n", formatLimit(a.Limits.Upload))
+			fmt.Printf("Peer download: %s\n", formatLimit(a.Limits.PeerDownload))
+			fmt.Printf("Peer upload:   %s\n", formatLimit(a.Limits.PeerUpload))
+			schedule := make([]string, len(a.Schedule))
+			for i, w := range a.Schedule {
+				schedule[i] = formatAltSpeedWindow(w)
+			}
+			printList("Schedule", schedule)
+		},
+	}
+)
+
+func init() {
+	flags := clientAddCmd.Flags()
+	flags.StringVar(&clientAddOptions.ID, "id", "", "id of the torrent (generated if empty)")
+	flags.BoolVar(&clientAddOptions.Stopped, "stopped", false, "do not start the torrent")
+	flags.BoolVar(&clientAddOptions.StopAfterDownload, "stop-after-download", false, "stop the torrent when download completes")
+	flags.BoolVar(&clientAddOptions.StopAfterMetadata, "stop-after-metadata", false, "stop the torrent when metadata is downloaded")
+
+	flags = clientFilesCmd.Flags()
+	flags.IntSliceVar(clientFilePriorities["skip"], "skip", nil, "do not download the files with these indexes")
+	flags.IntSliceVar(clientFilePriorities["low"], "low", nil, "download the files with these indexes at low priority")
+	flags.IntSliceVar(clientFilePriorities["normal"], "normal", nil, "download the files with these indexes at normal priority")
+	flags.IntSliceVar(clientFilePriorities["high"], "high", nil, "download the files with these indexes at high priority")
+
+	flags = clientIPFilterCmd.Flags()
+	flags.StringSliceVar(&clientIPFilterAllow, "allow", nil, "only allow peers matching these addresses, CIDRs or ranges (empty allows all)")
+	flags.StringSliceVar(&clientIPFilterDeny, "deny", nil, "deny peers matching these addresses, CIDRs or ranges")
+
+	flags = clientSeedLimitsCmd.Flags()
+	flags.Float64Var(&clientSeedRatio, "ratio", 0, "stop seeding at this share ratio, 0 is unlimited or the global limit for torrents, negative is unlimited")
+	flags.DurationVar(&clientSeedTime, "time", 0, "stop seeding after this duration, 0 is unlimited or the global limit for torrents, negative is unlimited")
+	flags.DurationVar(&clientSeedIdleTime, "idle", 0, "stop seeding after not uploading for this duration, 0 is unlimited or the global limit for torrents, negative is unlimited")
+	flags.StringVar(&clientSeedAction, "action", "", `"stop" or "remove" when a limit is reached, empty for the default`)
+
+	flags = clientQueueCmd.Flags()
+	flags.IntVar(&clientQueueLimits.Downloads, "downloads", 0, "maximum number of running downloads, 0 is unlimited")
+	flags.IntVar(&clientQueueLimits.Seeds, "seeds", 0, "maximum number of running seeds, 0 is unlimited")
+	flags.IntVar(&clientQueueLimits.Torrents, "torrents", 0, "maximum number of running torrents, 0 is unlimited")
+	flags.BoolVar(&clientQueueLimits.ExcludeSlow, "exclude-slow", false, "do not count slow torrents in the limits")
+	flags.Int64Var(&clientQueueLimits.SlowDownloadRate, "slow-download", 0, "torrents downloading slower than this rate in KB/s are slow")
+	flags.Int64Var(&clientQueueLimits.SlowUploadRate, "slow-upload", 0, "torrents uploading slower than this rate in KB/s are slow")
+	flags.DurationVar(&clientQueueSlowTime, "slow-time", 0, "time a torrent runs before it can be slow")
+	clientQueueCmd.AddCommand(clientQueueMoveCmd)
+
+	flags = clientAltSpeedCmd.Flags()
+	flags.StringVar(&clientAltSpeedMode, "mode", "", "scheduled, on or off")
+	flags.StringArrayVar(&clientAltSpeedSchedule, "schedule", nil, `time window as "[days ]15:04-15:04", days are comma separated like "sat,sun" (replaces the schedule, empty clears it)`)
+	flags.Int64Var(&clientLimitDownload, "download", 0, "alternative download speed limit in KB/s, 0 is unlimited")
+	flags.Int64Var(&clientLimitUpload, "upload", 0, "alternative upload speed limit in KB/s, 0 is unlimited")
+	flags.Int64Var(&clientLimitPeerDownload, "peer-download", 0, "alternative download speed limit of each peer in KB/s, 0 is unlimited")
+	flags.Int64Var(&clientLimitPeerUpload, "peer-upload", 0, "alternative upload speed limit of each peer in KB/s, 0 is unlimited")
+
+	flags = clientLimitsCmd.Flags()
+	flags.Int64Var(&clientLimitDownload, "download", 0, "download speed limit in KB/s, 0 is unlimited")
+	flags.Int64Var(&clientLimitUpload, "upload", 0, "upload speed limit in KB/s, 0 is unlimited")
+	flags.Int64Var(&clientLimitPeerDownload, "peer-download", 0, "download speed limit of each peer in KB/s, 0 is unlimited or the global limit for torrents")
+	flags.Int64Var(&clientLimitPeerUpload, "peer-upload", 0, "upload speed limit of each peer in KB/s, 0 is unlimited or the global limit for torrents")
+}
+
+// forEachTorrent calls fn for each id and exits with failure if any of them fails.
+func forEachTorrent(ids []string, fn func(id string) error) {
+	failed := false
+	for _, id := range ids {
+		if err := fn(id); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func printTorrents(torrents []rpctypes.Torrent) {
+	rows := make([][]string, len(torrents))
+	for i, t := range torrents {
+		rows[i] = []string{t.ID, t.Name, t.InfoHash, strconv.Itoa(t.Port), formatTime(t.AddedAt)}
+	}
+	printTable([]string{"ID", "NAME", "INFO HASH", "PORT", "ADDED AT"}, rows)
+}
+
+func printTorrentStats(s *rpctypes.Stats) {
+	eta := "-"
+	if s.ETA != nil {
+		eta = (time.Duration(*s.ETA) * time.Second).String()
+	}
+	status := s.Status
+	if s.Error != "" {
+		status += ": " + s.Error
+	} else if s.StopReason != "" && s.Status == "stopped" {
+		status += " (" + s.StopReason + ")"
+	}
+
+	rows := [][]string{
+		{"Status", status},
+		{"Progress", fmt.Sprintf("%.2f%%", s.Progress*100)},
+		{"Pieces", fmt.Sprintf("%d/%d (checked %d)", s.Pieces.Have, s.Pieces.Total, s.Pieces.Checked)},
+		{"Size", fmt.Sprintf("%s/%s", formatBytes(s.Bytes.Completed), formatBytes(s.Bytes.Total))},
+		{"Allocated", formatBytes(s.Bytes.Allocated)},
+		{"Downloaded", formatBytes(s.Bytes.Downloaded)},
+		{"Uploaded", formatBytes(s.Bytes.Uploaded)},
+		{"Wasted", formatBytes(s.Bytes.Wasted)},
+		{"Speed", fmt.Sprintf("down %s, up %s", formatSpeed(s.Speed.Download), formatSpeed(s.Speed.Upload))},
+		{"Peers", strconv.Itoa(s.Peers.Total)},
+		{"ETA", eta},
+		{"Ratio", fmt.Sprintf("%.2f", s.Ratio)},
+		{"Seeded for", (time.Duration(s.SeededFor) * time.Second).String()},
+	}
+	printKeyValues(rows)
+}
+
+func printSessionStats(s *rpctypes.SessionStats) {
+	rows := [][]string{
+		{"Torrents", strconv.Itoa(s.Torrents)},
+	}
+	for _, status := range []string{"stopped", "queued", "allocating", "verifying", "downloading", "seeding", "error"} {
+		if n := s.TorrentsByStatus[status]; n > 0 {
+			rows = append(rows, []string{"  " + status, strconv.Itoa(n)})
+		}
+	}
+	rows = append(rows,
+		[]string{"Peers", strconv.Itoa(s.Peers)},
+		[]string{"Ports", fmt.Sprintf("%d in use, %d available", s.PortsInUse, s.PortsAvailable)},
+		[]string{"Blocklist rules", strconv.Itoa(s.BlockListRules)},
+		[]string{"Downloaded", formatBytes(s.BytesDownloaded)},
+		[]string{"Uploaded", formatBytes(s.BytesUploaded)},
+		[]string{"Wasted", formatBytes(s.BytesWasted)},
+		[]string{"Speed", fmt.Sprintf("down %s, up %s", formatSpeed(s.SpeedDownload), formatSpeed(s.SpeedUpload))},
+		[]string{"Alternative speed", strconv.FormatBool(s.AltSpeedActive)},
+		[]string{"Uptime", (time.Duration(s.Uptime) * time.Second).String()},
+	)
+	printKeyValues(rows)
+}
+
+func printKeyValues(rows [][]string) {
+	for i := range rows {
+		rows[i][0] += ":"
+	}
+	printTable(nil, rows)
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// parseAltSpeedWindow parses a window in "[days ]15:04-15:04" format.
+func parseAltSpeedWindow(s string) (rpctypes.AltSpeedWindow, error) {
+	var w rpctypes.AltSpeedWindow
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return w, fmt.Errorf("invalid schedule %q", s)
+	}
+	if len(fields) == 2 {
+		for _, d := range strings.Split(fields[0], ",") {
+			i := slices.Index(weekdays, strings.ToLower(d))
+			if i < 0 {
+				return w, fmt.Errorf("invalid day of week %q", d)
+			}
+			w.Days = append(w.Days, i)
+		}
+	}
+	var ok bool
+	w.Start, w.End, ok = strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return w, fmt.Errorf("invalid schedule %q", s)
+	}
+	return w, nil
+}
+
+func formatAltSpeedWindow(w rpctypes.AltSpeedWindow) string {
+	days := make([]string, len(w.Days))
+	for i, d := range w.Days {
+		days[i] = weekdays[d]
+	}
+	if len(days) == 0 {
+		days = []string{"every day"}
+	}
+	return fmt.Sprintf("%s %s-%s", strings.Join(days, ","), w.Start, w.End)
+}
+
+// formatSeedLimit describes a seed limit whose value is v, formatted as s.
+func formatSeedLimit(s string, v float64, global bool) string {
+	switch {
+	case v < 0 || v == 0 && global:
+		return "unlimited"
+	case v == 0:
+		return "global"
+	default:
+		return s
+	}
+}
+
+func formatCount(n int) string {
+	if n <= 0 {
+		return "unlimited"
+	}
+
+	return strconv.Itoa(n)
+}
+
+func formatLimit(kbps int64) string {
+	if kbps <= 0 {
+		return "unlimited"
+	}
+
+	return fmt.Sprintf("%d KB/s", kbps)
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/al002/zbittorrent/pkg/metainfo"
+	"github.com/al002/zbittorrent/torrent"
+	"github.com/spf13/cobra"
+)
+
+var (
+	createBuilder     metainfo.Builder
+	createOutput      string
+	createTrackers    []string
+	createPieceLength string
+	createForce       bool
+	createQuiet       bool
+
+	createCmd = &cobra.Command{
+		Use:   "create <file|dir>",
+		Short: "Create a torrent file",
+		Long: `Create a torrent file from a file or directory.
+
+Each --tracker flag adds a new tier, trackers in the same tier are separated by commas:
+  zbittorrent create -t udp://a:80,udp://b:80 -t http://c/announce ./dir`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			b := createBuilder
+			b.Path = args[0]
+			for _, tier := range createTrackers {
+				var urls []string
+				for _, u := range strings.Split(tier, ",") {
+					if u = strings.TrimSpace(u); u != "" {
+						urls = append(urls, u)
+					}
+				}
+				b.Trackers = append(b.Trackers, urls)
+			}
+			if createPieceLength != "" {
+				n, err := parseSize(createPieceLength)
+				if err != nil {
+					exitWithError(fmt.Errorf("invalid piece length: %w", err))
+				}
+				b.PieceLength = n
+			}
+			if !createQuiet {
+				b.Progress = func(hashed, total int64) {
+					fmt.Fprintf(os.Stderr, "\rhashing %s / %s", formatBytes(hashed), formatBytes(total))
+				}
+			}
+
+			output := createOutput
+			if output == "" {
+				name := b.Name
+				if name == "" {
+					abs, err := filepath.Abs(b.Path)
+					if err != nil {
+						exitWithError(err)
+					}
+					name = filepath.Base(abs)
+				}
+				output = name + ".torrent"
+			}
+			flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+			if createForce {
+				flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			} else if _, err := os.Stat(output); err == nil {
+				exitWithError(fmt.Errorf("%s already exists, use --force to overwrite", output))
+			}
+
+			mi, err := b.Build()
+			if !createQuiet {
+				fmt.Fprintln(os.Stderr)
+			}
+			if err != nil {
+				exitWithError(err)
+			}
+
+			f, err := os.OpenFile(output, flags, 0o644)
+			if err != nil {
+				exitWithError(err)
+			}
+			if err = mi.Write(f); err != nil {
+				f.Close()
+				exitWithError(err)
+			}
+			if err = f.Close(); err != nil {
+				exitWithError(err)
+			}
+
+			fmt.Printf("created %s\n", output)
+			fmt.Printf("info hash:    %s\n", mi.Info.Hash.HexString())
+			fmt.Printf("piece length: %s\n", formatBytes(int64(mi.Info.PieceLength)))
+			fmt.Printf("pieces:       %d\n", mi.Info.NumPieces)
+		},
+	}
+)
+
+func init() {
+	f := createCmd.Flags()
+	f.StringVarP(&createOutput, "output", "o", "", "output file (default is <name>.torrent)")
+	f.BoolVarP(&createForce, "force", "f", false, "overwrite the output file if it exists")
+	f.StringVarP(&createBuilder.Name, "name", "n", "", "torrent name (default is the base name of the path)")
+	f.StringArrayVarP(&createTrackers, "tracker", "t", nil, "tracker tier, comma separated announce URLs")
+	f.StringArrayVarP(&createBuilder.WebSeeds, "webseed", "w", nil, "web seed URL")
+	f.StringVarP(&createPieceLength, "piece-length", "l", "", "piece length, e.g. 256K or 4M (default is automatic)")
+	f.BoolVarP(&createBuilder.Private, "private", "p", false, "set the private flag")
+	f.StringVarP(&createBuilder.Comment, "comment", "c", "", "comment")
+	f.StringVar(&createBuilder.CreatedBy, "created-by", "zbittorrent/"+torrent.Version, "created by")
+	f.StringVarP(&createBuilder.Source, "source", "s", "", "source tag, changes the info hash")
+	f.BoolVar(&createBuilder.NoCreationDate, "no-date", false, "omit the creation date")
+	f.BoolVar(&createBuilder.Padding, "pad", false, "align files to piece boundaries with padding files")
+	f.IntVarP(&createBuilder.Workers, "workers", "j", 0, "number of hashing goroutines (default is the number of CPUs)")
+	f.BoolVarP(&createQuiet, "quiet", "q", false, "do not print hashing progress")
+}
+
+// parseSize parses a byte count with an optional K, M or G binary suffix.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(s), "B"), "b"))
+	shift := 0
+	switch {
+	case strings.HasSuffix(s, "K"), strings.HasSuffix(s, "KI"):
+		shift = 10
+	case strings.HasSuffix(s, "M"), strings.HasSuffix(s, "MI"):
+		shift = 20
+	case strings.HasSuffix(s, "G"), strings.HasSuffix(s, "GI"):
+		shift = 30
+	}
+	s = strings.TrimRight(s, "KMGI")
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n << shift, nil
+}
@@ -1,32 +1,268 @@
 package cmd
 
 import (
+	"encoding/hex"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/al002/zbittorrent/pkg/magnet"
 	"github.com/al002/zbittorrent/pkg/metainfo"
 	"github.com/spf13/cobra"
 )
 
-var infoCmd = &cobra.Command{
-	Use:   "info",
-	Short: "Get torrent file metainfo",
-	Run: func(cmd *cobra.Command, args []string) {
-		mi, err := metainfo.LoadFromFile(args[0])
-		if err != nil {
-			log.Error("parse torrent file error", err)
-			os.Exit(1)
+var (
+	infoJSON bool
+
+	infoCmd = &cobra.Command{
+		Use:   "info <file|dir>...",
+		Short: "Show the contents of torrent files",
+		Long: `Show the contents of torrent files.
+
+Directories are searched for files with the .torrent extension.
+With --json a single torrent file is printed as an object, otherwise as an array.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			paths, err := torrentPaths(args)
+			if err != nil {
+				exitWithError(err)
+			}
+
+			var infos []torrentInfo
+			var failed bool
+			for _, p := range paths {
+				mi, err := metainfo.LoadFromFile(p)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "error: %s: %v\n", p, err)
+					failed = true
+					continue
+				}
+				infos = append(infos, newTorrentInfo(p, mi))
+			}
+
+			if infoJSON {
+				if len(args) == 1 && len(paths) == 1 && len(infos) == 1 && !isDir(args[0]) {
+					printJSON(infos[0])
+				} else {
+					if infos == nil {
+						infos = []torrentInfo{}
+					}
+					printJSON(infos)
+				}
+			} else {
+				for i := range infos {
+					if i > 0 {
+						fmt.Println()
+					}
+					printTorrentInfo(&infos[i])
+				}
+			}
+
+			if failed {
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	infoCmd.Flags().BoolVar(&infoJSON, "json", false, "print as JSON")
+}
+
+type torrentInfo struct {
+	File         string        `json:"file"`
+	Name         string        `json:"name"`
+	InfoHash     string        `json:"info_hash,omitempty"`
+	InfoHashV2   string        `json:"info_hash_v2,omitempty"`
+	MetaVersion  int           `json:"meta_version"`
+	Magnet       string        `json:"magnet"`
+	PieceLength  uint32        `json:"piece_length"`
+	Pieces       uint32        `json:"pieces"`
+	Size         int64         `json:"size"`
+	Private      bool          `json:"private"`
+	Source       string        `json:"source,omitempty"`
+	Trackers     [][]string    `json:"trackers"`
+	WebSeeds     []string      `json:"web_seeds"`
+	Nodes        []string      `json:"nodes"`
+	CreationDate *time.Time    `json:"creation_date,omitempty"`
+	CreatedBy    string        `json:"created_by,omitempty"`
+	Comment      string        `json:"comment,omitempty"`
+	Files        []torrentFile `json:"files"`
+}
+
+type torrentFile struct {
+	Path    string `json:"path"`
+	Length  int64  `json:"length"`
+	Padding bool   `json:"padding,omitempty"`
+}
+
+func newTorrentInfo(path string, mi *metainfo.MetaInfo) torrentInfo {
+	info := &mi.Info
+	ti := torrentInfo{
+		File:        path,
+		Name:        info.Name,
+		MetaVersion: info.MetaVersion,
+		Magnet:      magnet.FromMetaInfo(mi).String(),
+		PieceLength: info.PieceLength,
+		Pieces:      info.NumPieces,
+		Private:     info.Private,
+		Source:      info.Source,
+		Trackers:    mi.AnnounceList,
+		WebSeeds:    mi.URLList,
+		Nodes:       mi.Nodes,
+		CreatedBy:   mi.CreatedBy,
+		Comment:     mi.Comment,
+	}
+	if !info.IsV2Only() {
+		ti.InfoHash = info.Hash.HexString()
+	}
+	if info.MetaVersion == 2 {
+		ti.InfoHashV2 = hex.EncodeToString(info.HashV2[:])
+	}
+	if !mi.CreationDate.IsZero() {
+		ti.CreationDate = &mi.CreationDate
+	}
+	if ti.Trackers == nil {
+		ti.Trackers = [][]string{}
+	}
+	if ti.WebSeeds == nil {
+		ti.WebSeeds = []string{}
+	}
+	if ti.Nodes == nil {
+		ti.Nodes = []string{}
+	}
+
+	ti.Files = make([]torrentFile, len(info.Files))
+	for i, f := range info.Files {
+		ti.Files[i] = torrentFile{Path: filepath.ToSlash(f.Path), Length: f.Length, Padding: f.Padding}
+		if !f.Padding {
+			ti.Size += f.Length
 		}
+	}
+
+	return ti
+}
 
-    b := mi.HashInfoBytes()
-    fmt.Printf("info hash %s\n", b.HexString())
+func printTorrentInfo(ti *torrentInfo) {
+	rows := [][]string{
+		{"File:", ti.File},
+		{"Name:", ti.Name},
+	}
+	if ti.InfoHash != "" {
+		rows = append(rows, []string{"Info hash:", ti.InfoHash})
+	}
+	if ti.InfoHashV2 != "" {
+		rows = append(rows, []string{"Info hash v2:", ti.InfoHashV2})
+	}
+	version := "v1"
+	switch {
+	case ti.InfoHash != "" && ti.InfoHashV2 != "":
+		version = "hybrid"
+	case ti.InfoHashV2 != "":
+		version = "v2"
+	}
+	rows = append(rows,
+		[]string{"Version:", version},
+		[]string{"Magnet:", ti.Magnet},
+		[]string{"Size:", fmt.Sprintf("%s (%d bytes)", formatBytes(ti.Size), ti.Size)},
+		[]string{"Pieces:", fmt.Sprintf("%d x %s", ti.Pieces, formatBytes(int64(ti.PieceLength)))},
+		[]string{"Private:", strconv.FormatBool(ti.Private)},
+	)
+	if ti.Source != "" {
+		rows = append(rows, []string{"Source:", ti.Source})
+	}
+	if ti.CreationDate != nil {
+		rows = append(rows, []string{"Created:", formatTime(*ti.CreationDate)})
+	}
+	if ti.CreatedBy != "" {
+		rows = append(rows, []string{"Created by:", ti.CreatedBy})
+	}
+	if ti.Comment != "" {
+		rows = append(rows, []string{"Comment:", ti.Comment})
+	}
+	printTable(nil, rows)
 
-    info, err := mi.UnmarshalInfo()
-    if err != nil {
-      fmt.Printf("unmarshal info error %w\n", err)
-    }
+	if len(ti.Trackers) > 0 {
+		fmt.Println("Trackers:")
+		for i, tier := range ti.Trackers {
+			for _, tr := range tier {
+				fmt.Printf("  tier %d: %s\n", i+1, tr)
+			}
+		}
+	}
+	printList("Web seeds:", ti.WebSeeds)
+	printList("DHT nodes:", ti.Nodes)
 
+	fmt.Println("Files:")
+	printFileTree(ti.Files)
+}
+
+func printList(title string, l []string) {
+	if len(l) == 0 {
+		return
+	}
+	fmt.Println(title)
+	for _, s := range l {
+		fmt.Println("  " + s)
+	}
+}
+
+// printFileTree prints the files indented under their directories.
+// Files must be in torrent order, which keeps the files of a directory together in practice.
+func printFileTree(files []torrentFile) {
+	var dirs []string
+	for _, f := range files {
+		parts := strings.Split(f.Path, "/")
+		parent := parts[:len(parts)-1]
+
+		common := 0
+		for common < len(dirs) && common < len(parent) && dirs[common] == parent[common] {
+			common++
+		}
+		dirs = dirs[:common]
+		for ; common < len(parent); common++ {
+			fmt.Printf("%s%s/\n", strings.Repeat("  ", common+1), parent[common])
+			dirs = append(dirs, parent[common])
+		}
+
+		line := fmt.Sprintf("%s%s (%s)", strings.Repeat("  ", len(parent)+1), parts[len(parts)-1], formatBytes(f.Length))
+		if f.Padding {
+			line += " [padding]"
+		}
+		fmt.Println(line)
+	}
+}
+
+// torrentPaths expands directories in args to the torrent files in them.
+func torrentPaths(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		if !isDir(arg) {
+			paths = append(paths, arg)
+			continue
+		}
+
+		entries, err := os.ReadDir(arg)
+		if err != nil {
+			return nil, err
+		}
+		var found []string
+		for _, e := range entries {
+			if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ".torrent") {
+				found = append(found, filepath.Join(arg, e.Name()))
+			}
+		}
+		sort.Strings(found)
+		paths = append(paths, found...)
+	}
+	return paths, nil
+}
 
-    fmt.Printf("unmarshal info %v\n", info.Name)
-	},
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
 }
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/al002/zbittorrent/pkg/magnet"
+	"github.com/al002/zbittorrent/pkg/metainfo"
+	"github.com/spf13/cobra"
+)
+
+var (
+	magnetNoTrackers bool
+	magnetSelect     string
+	magnetJSON       bool
+
+	magnetCmd = &cobra.Command{
+		Use:   "magnet <file|link>...",
+		Short: "Print the magnet links of torrent files or the contents of magnet links",
+		Long: `Print the magnet links of torrent files.
+
+Arguments starting with "magnet:" are parsed and their contents are printed instead.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var selectOnly []magnet.FileRange
+			if magnetSelect != "" {
+				var err error
+				selectOnly, err = magnet.ParseFileRanges(magnetSelect)
+				if err != nil {
+					exitWithError(fmt.Errorf("invalid --select: %w", err))
+				}
+			}
+
+			var infos []magnetInfo
+			var failed bool
+			for _, arg := range args {
+				m, err := loadMagnet(arg)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "error: %s: %v\n", arg, err)
+					failed = true
+					continue
+				}
+				if magnetNoTrackers {
+					m.Trackers = nil
+				}
+				if selectOnly != nil {
+					m.SelectOnly = selectOnly
+				}
+
+				switch {
+				case magnetJSON:
+					infos = append(infos, newMagnetInfo(m))
+				case strings.HasPrefix(arg, "magnet:"):
+					printMagnet(m)
+				default:
+					fmt.Println(m.String())
+				}
+			}
+
+			if magnetJSON {
+				if len(args) == 1 && len(infos) == 1 {
+					printJSON(infos[0])
+				} else {
+					if infos == nil {
+						infos = []magnetInfo{}
+					}
+					printJSON(infos)
+				}
+			}
+
+			if failed {
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	magnetCmd.Flags().BoolVar(&magnetNoTrackers, "no-trackers", false, "leave out the trackers")
+	magnetCmd.Flags().StringVar(&magnetSelect, "select", "", `only select these file indexes, e.g. "0,2,4-6" (BEP 53)`)
+	magnetCmd.Flags().BoolVar(&magnetJSON, "json", false, "print as JSON")
+}
+
+func loadMagnet(arg string) (*magnet.Magnet, error) {
+	if strings.HasPrefix(arg, "magnet:") {
+		return magnet.New(arg)
+	}
+
+	mi, err := metainfo.LoadFromFile(arg)
+	if err != nil {
+		return nil, err
+	}
+	return magnet.FromMetaInfo(mi), nil
+}
+
+type magnetInfo struct {
+	Link       string   `json:"link"`
+	InfoHash   string   `json:"info_hash,omitempty"`
+	InfoHashV2 string   `json:"info_hash_v2,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	Length     int64    `json:"length,omitempty"`
+	Trackers   []string `json:"trackers"`
+	WebSeeds   []string `json:"web_seeds"`
+	Peers      []string `json:"peers"`
+	SelectOnly string   `json:"select_only,omitempty"`
+	Keywords   []string `json:"keywords"`
+}
+
+func newMagnetInfo(m *magnet.Magnet) magnetInfo {
+	mi := magnetInfo{
+		Link:       m.String(),
+		Name:       m.Name,
+		Length:     m.Length,
+		Trackers:   []string{},
+		WebSeeds:   []string{},
+		Peers:      []string{},
+		SelectOnly: formatSelectOnly(m.SelectOnly),
+		Keywords:   []string{},
+	}
+	if m.HasV1() {
+		mi.InfoHash = m.InfoHash.HexString()
+	}
+	if m.HasV2() {
+		mi.InfoHashV2 = m.InfoHashV2.HexString()
+	}
+	for _, tier := range m.Trackers {
+		mi.Trackers = append(mi.Trackers, tier...)
+	}
+	mi.WebSeeds = append(mi.WebSeeds, m.WebSeeds...)
+	mi.Peers = append(mi.Peers, m.Peers...)
+	mi.Keywords = append(mi.Keywords, m.Keywords...)
+	return mi
+}
+
+func formatSelectOnly(ranges []magnet.FileRange) string {
+	l := make([]string, len(ranges))
+	for i, r := range ranges {
+		l[i] = r.String()
+	}
+	return strings.Join(l, ",")
+}
+
+func printMagnet(m *magnet.Magnet) {
+	var rows [][]string
+	if m.HasV1() {
+		rows = append(rows, []string{"Info hash:", m.InfoHash.HexString()})
+	}
+	if m.HasV2() {
+		rows = append(rows, []string{"Info hash v2:", m.InfoHashV2.HexString()})
+	}
+	if m.Name != "" {
+		rows = append(rows, []string{"Name:", m.Name})
+	}
+	if m.Length > 0 {
+		rows = append(rows, []string{"Size:", formatBytes(m.Length) + " (" + strconv.FormatInt(m.Length, 10) + " bytes)"})
+	}
+	if len(m.SelectOnly) > 0 {
+		rows = append(rows, []string{"Select only:", formatSelectOnly(m.SelectOnly)})
+	}
+	if len(m.Keywords) > 0 {
+		rows = append(rows, []string{"Keywords:", strings.Join(m.Keywords, ", ")})
+	}
+	printTable(nil, rows)
+
+	var trackers []string
+	for _, tier := range m.Trackers {
+		trackers = append(trackers, tier...)
+	}
+	printList("Trackers:", trackers)
+	printList("Web seeds:", m.WebSeeds)
+	printList("Peers:", m.Peers)
+}
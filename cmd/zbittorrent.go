@@ -3,10 +3,13 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 
 	"github.com/al002/zbittorrent/internal/config"
 	logger "github.com/al002/zbittorrent/internal/log"
+	"github.com/al002/zbittorrent/torrent"
 	"github.com/spf13/cobra"
 )
 
@@ -21,6 +24,10 @@ var (
 		Short: "A simple bittorrent client",
 		Run: func(cmd *cobra.Command, args []string) {
 			log.Info("zbittorrent is starting...")
+			if err := runDaemon(); err != nil {
+				log.Error("daemon error", "error", err)
+				os.Exit(1)
+			}
 		},
 	}
 )
@@ -44,6 +51,10 @@ func init() {
 	rootCmd.AddCommand(validateCmd)
 	rootCmd.AddCommand(infoCmd)
   rootCmd.AddCommand(announceCmd)
+	rootCmd.AddCommand(createCmd)
+	rootCmd.AddCommand(magnetCmd)
+	rootCmd.AddCommand(bencodeCmd)
+	rootCmd.AddCommand(clientCmd)
 }
 
 func initConfig() {
@@ -94,3 +105,25 @@ func verifyDirectory(dir string) error {
 
 	return nil
 }
+
+// runDaemon runs a session with the RPC server until the process is interrupted.
+func runDaemon() error {
+	sessionCfg := torrent.DefaultConfig
+	sessionCfg.DataDir = cfg.DownloadDir
+	if err := cfgRegistry.UnmarshalKey("session", &sessionCfg); err != nil {
+		return fmt.Errorf("invalid session config: %v", err)
+	}
+
+	s, err := torrent.NewSession(sessionCfg, *log)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-ch
+	log.Info("zbittorrent is shutting down...", "signal", sig.String())
+
+	return nil
+}
@@ -1,10 +1,13 @@
 package allocator
 
 import (
-	"github.com/al002/zbittorrent/internal/metainfo"
 	"github.com/al002/zbittorrent/internal/storage"
+	"github.com/al002/zbittorrent/pkg/metainfo"
 )
 
+// PartFileSuffix is appended to the names of the files that hold the data of skipped files.
+const PartFileSuffix = ".part"
+
 type Allocator struct {
 	Files       []File
 	HasExisting bool
@@ -37,7 +40,10 @@ func (a *Allocator) Close() {
 	<-a.doneC
 }
 
-func (a *Allocator) Run(info *metainfo.Info, sto storage.Storage, progressC chan Progress, resultC chan *Allocator) {
+// Run opens the files of the torrent, creating the missing ones.
+// Files marked in skip are not created, the pieces that overlap them are stored in a part file instead.
+// An existing file is still opened when it is skipped so the data in it is not lost.
+func (a *Allocator) Run(info *metainfo.Info, sto storage.Storage, skip []bool, progressC chan Progress, resultC chan *Allocator) {
 	defer close(a.doneC)
 
 	defer func() {
@@ -63,6 +69,15 @@ func (a *Allocator) Run(info *metainfo.Info, sto storage.Storage, progressC chan
 		var exists bool
 		if f.Padding {
 			sf = storage.NewPaddingFile(f.Length)
+		} else if i < len(skip) && skip[i] {
+			sf, exists, a.Error = openSkipped(sto, f.Path, f.Length)
+			if a.Error != nil {
+				return
+			}
+
+			if exists {
+				a.HasExisting = true
+			}
 		} else {
 			sf, exists, a.Error = sto.Open(f.Path, f.Length)
 			if a.Error != nil {
@@ -85,6 +100,46 @@ func (a *Allocator) Run(info *metainfo.Info, sto storage.Storage, progressC chan
 	}
 }
 
+func openSkipped(sto storage.Storage, name string, length int64) (storage.File, bool, error) {
+	exists, err := sto.Exists(name)
+	if err != nil {
+		return nil, false, err
+	}
+	if exists {
+		return sto.Open(name, length)
+	}
+
+	f, exists, err := storage.NewPartFile(sto, name+PartFileSuffix, length)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return f, exists, nil
+}
+
+// OpenUnskipped opens a file that was skipped when the files were allocated and is wanted now.
+// The part file that holds its data is closed and renamed to the file, so the pieces written in it are kept.
+func OpenUnskipped(sto storage.Storage, name string, length int64, part *storage.PartFile) (storage.File, error) {
+	err := part.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := sto.Exists(name + PartFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		err = sto.Rename(name+PartFileSuffix, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f, _, err := sto.Open(name, length)
+	return f, err
+}
+
 func (a *Allocator) sendProgress(progressC chan Progress, size int64) {
 	select {
 	case progressC <- Progress{
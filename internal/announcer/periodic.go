@@ -53,9 +53,21 @@ type PeriodicalAnnouncer struct {
 
 	status        Status
 	statsCommandC chan statsRequest
+	notify        func(Event)
 }
 
-func NewPeriodicalAnnouncer(t tracker.Tracker, numWant int, minInterval time.Duration, getTorrent func() tracker.Torrent, completedC chan struct{}, newPeersC chan []*net.TCPAddr) *PeriodicalAnnouncer {
+// Event is passed to the notify function after each announce to the tracker.
+type Event struct {
+	Tracker string
+	// Set if the announce has failed
+	Error *AnnounceError
+	// Warning message in a successful announce response
+	Warning string
+}
+
+// NewPeriodicalAnnouncer returns a new announcer for the tracker.
+// notify is called from the announcer goroutine, it must not block.
+func NewPeriodicalAnnouncer(t tracker.Tracker, numWant int, minInterval time.Duration, getTorrent func() tracker.Torrent, completedC chan struct{}, newPeersC chan []*net.TCPAddr, notify func(Event)) *PeriodicalAnnouncer {
 	return &PeriodicalAnnouncer{
 		Tracker:        t,
 		status:         NotContactedYet,
@@ -64,6 +76,7 @@ func NewPeriodicalAnnouncer(t tracker.Tracker, numWant int, minInterval time.Dur
 		completedC:     completedC,
 		newPeersC:      newPeersC,
 		getTorrent:     getTorrent,
+		notify:         notify,
 		needMorePeersC: make(chan struct{}, 1),
 		responseC:      make(chan *tracker.AnnounceResponse),
 		errC:           make(chan error),
@@ -124,9 +137,7 @@ func (a *PeriodicalAnnouncer) Run() {
 			a.seeders = int(resp.Seeders)
 			a.leechers = int(resp.Leechers)
 			a.warningMsg = resp.WarningMessage
-			if a.warningMsg != "" {
-
-			}
+			a.notify(Event{Tracker: a.Tracker.URL(), Warning: a.warningMsg})
 			a.interval = resp.Interval
 			if resp.MinInterval > 0 {
 				a.minInterval = resp.MinInterval
@@ -145,11 +156,7 @@ func (a *PeriodicalAnnouncer) Run() {
 		case err := <-a.errC:
 			a.status = NotWorking
 			a.lastError = a.newAnnounceError(err)
-			if a.lastError.Unknown {
-
-			} else {
-
-			}
+			a.notify(Event{Tracker: a.Tracker.URL(), Error: a.lastError})
 			interval := a.getNextIntervalFromError(a.lastError)
 			resetTimer(interval)
 		case <-a.needMorePeersC:
@@ -10,17 +10,19 @@ import (
 type StopAnnouncer struct {
 	timeout  time.Duration
 	trackers []tracker.Tracker
-	torrent  tracker.Torrent
+	torrents []tracker.Torrent
 	resultC  chan struct{}
 	closeC   chan struct{}
 	doneC    chan struct{}
 }
 
-func NewStopAnnouncer(trackers []tracker.Tracker, tra tracker.Torrent, timeout time.Duration, resultC chan struct{}) *StopAnnouncer {
+// NewStopAnnouncer returns an announcer that sends the stopped event to each tracker for each of torrents.
+// Hybrid torrents are announced with both v1 and v2 info hashes.
+func NewStopAnnouncer(trackers []tracker.Tracker, torrents []tracker.Torrent, timeout time.Duration, resultC chan struct{}) *StopAnnouncer {
 	return &StopAnnouncer{
 		timeout:  timeout,
 		trackers: trackers,
-		torrent:  tra,
+		torrents: torrents,
 		resultC:  resultC,
 		closeC:   make(chan struct{}),
 		doneC:    make(chan struct{}),
@@ -46,17 +48,19 @@ func (a *StopAnnouncer) Run() {
 
 	doneC := make(chan struct{})
 	for _, trk := range a.trackers {
-		go func(t tracker.Tracker) {
-			req := tracker.AnnounceRequest{
-				Torrent: a.torrent,
-				Event:   tracker.EventStopped,
-			}
-			_, _ = trk.Announce(ctx, req)
-			doneC <- struct{}{}
-		}(trk)
+		for _, tor := range a.torrents {
+			go func(trk tracker.Tracker, tor tracker.Torrent) {
+				req := tracker.AnnounceRequest{
+					Torrent: tor,
+					Event:   tracker.EventStopped,
+				}
+				_, _ = trk.Announce(ctx, req)
+				doneC <- struct{}{}
+			}(trk, tor)
+		}
 	}
 
-	for range a.trackers {
+	for range len(a.trackers) * len(a.torrents) {
 		<-doneC
 	}
 
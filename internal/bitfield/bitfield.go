@@ -0,0 +1,127 @@
+// Package bitfield provides support for manipulating bits in a []byte.
+package bitfield
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/bits"
+)
+
+var errInvalidLength = errors.New("invalid bitfield length")
+
+// Bitfield is described in BEP 3.
+type Bitfield struct {
+	b      []byte
+	length uint32
+}
+
+// New creates a new Bitfield of length bits.
+func New(length uint32) *Bitfield {
+	return &Bitfield{
+		b:      make([]byte, (length+7)/8),
+		length: length,
+	}
+}
+
+// NewBytes returns a new Bitfield from bytes.
+// Bytes in b are not copied. Unused bits in last byte are cleared.
+func NewBytes(b []byte, length uint32) (*Bitfield, error) {
+	if uint32(len(b)) != (length+7)/8 {
+		return nil, errInvalidLength
+	}
+
+	bf := &Bitfield{
+		b:      b,
+		length: length,
+	}
+	bf.clearUnused()
+
+	return bf, nil
+}
+
+func (b *Bitfield) clearUnused() {
+	if b.length%8 != 0 {
+		b.b[len(b.b)-1] &= ^(0xff >> (b.length % 8))
+	}
+}
+
+// Copy returns a new copy of Bitfield.
+func (b *Bitfield) Copy() *Bitfield {
+	b2 := &Bitfield{
+		b:      make([]byte, len(b.b)),
+		length: b.length,
+	}
+	copy(b2.b, b.b)
+
+	return b2
+}
+
+// Bytes returns bytes in b. If you modify the returned slice the bits in b are modified too.
+func (b *Bitfield) Bytes() []byte {
+	return b.b
+}
+
+// Len returns the number of bits as given to New.
+func (b *Bitfield) Len() uint32 {
+	return b.length
+}
+
+// Hex returns bytes as string.
+func (b *Bitfield) Hex() string {
+	return hex.EncodeToString(b.b)
+}
+
+// Set bit i. 0 is the most significant bit. Panics if i >= b.Len().
+func (b *Bitfield) Set(i uint32) {
+	b.checkIndex(i)
+	b.b[i/8] |= 1 << (7 - i%8)
+}
+
+// SetTo sets bit i to value. Panics if i >= b.Len().
+func (b *Bitfield) SetTo(i uint32, value bool) {
+	if value {
+		b.Set(i)
+	} else {
+		b.Clear(i)
+	}
+}
+
+// Clear bit i. 0 is the most significant bit. Panics if i >= b.Len().
+func (b *Bitfield) Clear(i uint32) {
+	b.checkIndex(i)
+	b.b[i/8] &= ^(1 << (7 - i%8))
+}
+
+// ClearAll clears all bits.
+func (b *Bitfield) ClearAll() {
+	for i := range b.b {
+		b.b[i] = 0
+	}
+}
+
+// Test bit i. 0 is the most significant bit. Panics if i >= b.Len().
+func (b *Bitfield) Test(i uint32) bool {
+	b.checkIndex(i)
+	return b.b[i/8]&(1<<(7-i%8)) > 0
+}
+
+// Count returns the count of set bits.
+func (b *Bitfield) Count() uint32 {
+	var total int
+	for _, v := range b.b {
+		total += bits.OnesCount8(v)
+	}
+
+	return uint32(total)
+}
+
+// All returns true if all bits are set, false otherwise.
+func (b *Bitfield) All() bool {
+	return b.Count() == b.length
+}
+
+func (b *Bitfield) checkIndex(i uint32) {
+	if i >= b.length {
+		panic("index out of bound")
+	}
+}
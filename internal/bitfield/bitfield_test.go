@@ -0,0 +1,46 @@
+package bitfield
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetClear(t *testing.T) {
+	b := New(10)
+	assert.Equal(t, uint32(10), b.Len())
+	assert.Equal(t, 2, len(b.Bytes()))
+
+	b.Set(0)
+	b.Set(9)
+	assert.True(t, b.Test(0))
+	assert.True(t, b.Test(9))
+	assert.False(t, b.Test(1))
+	assert.Equal(t, "8040", b.Hex())
+	assert.Equal(t, uint32(2), b.Count())
+
+	b.Clear(0)
+	assert.False(t, b.Test(0))
+	assert.Equal(t, uint32(1), b.Count())
+}
+
+func TestAll(t *testing.T) {
+	b := New(3)
+	assert.False(t, b.All())
+	for i := uint32(0); i < b.Len(); i++ {
+		b.Set(i)
+	}
+	assert.True(t, b.All())
+}
+
+func TestNewBytes(t *testing.T) {
+	b, err := NewBytes([]byte{0xff, 0xff}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint32(10), b.Count())
+	assert.Equal(t, "ffc0", b.Hex())
+
+	_, err = NewBytes([]byte{0xff}, 10)
+	assert.Error(t, err)
+}
@@ -0,0 +1,98 @@
+package blocklist
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"testing"
+)
+
+const benchListSize = 1000000
+
+var benchLists = map[bool]*Blocklist{}
+
+func benchList(b *testing.B, ipv6 bool) *Blocklist {
+	if l, ok := benchLists[ipv6]; ok {
+		return l
+	}
+
+	l := New()
+	stats, err := l.Reload(bytes.NewReader(benchListData(ipv6)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if stats.Parsed != benchListSize {
+		b.Fatalf("parsed %d ranges", stats.Parsed)
+	}
+	benchLists[ipv6] = l
+	return l
+}
+
+// benchListData returns a list of random non-overlapping ranges in P2P format.
+// IPv6 lists have the same number of ranges in both families.
+func benchListData(ipv6 bool) []byte {
+	rnd := rand.New(rand.NewSource(1))
+	var buf bytes.Buffer
+	for i := 0; i < benchListSize; i++ {
+		// Ranges of up to 256 addresses in every 4096 addresses
+		first := uint32(i)<<12 | uint32(rnd.Intn(3840))
+		last := first + uint32(rnd.Intn(256))
+		if ipv6 && i%2 == 1 {
+			fmt.Fprintf(&buf, "range %d:2001:db8:%x:%x::-2001:db8:%x:%x:ffff::\n", i, first>>16, first&0xffff, last>>16, last&0xffff)
+			continue
+		}
+		fmt.Fprintf(&buf, "range %d:%s-%s\n", i, uint32ToIP(first), uint32ToIP(last))
+	}
+	return buf.Bytes()
+}
+
+func uint32ToIP(v uint32) net.IP {
+	return net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func benchAddrs(ipv6 bool) []net.IP {
+	rnd := rand.New(rand.NewSource(2))
+	addrs := make([]net.IP, 1024)
+	for i := range addrs {
+		v := uint32(rnd.Intn(benchListSize << 12))
+		if ipv6 {
+			addrs[i] = net.ParseIP(fmt.Sprintf("2001:db8:%x:%x::1", v>>16, v&0xffff))
+		} else {
+			addrs[i] = uint32ToIP(v)
+		}
+	}
+	return addrs
+}
+
+func BenchmarkBlockedIPv4(b *testing.B) {
+	l := benchList(b, false)
+	addrs := benchAddrs(false)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Blocked(addrs[i%len(addrs)])
+	}
+}
+
+func BenchmarkBlockedIPv6(b *testing.B) {
+	l := benchList(b, true)
+	addrs := benchAddrs(true)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Blocked(addrs[i%len(addrs)])
+	}
+}
+
+func BenchmarkReload(b *testing.B) {
+	data := benchListData(true)
+	l := New()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := l.Reload(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
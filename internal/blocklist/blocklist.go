@@ -1,122 +1,459 @@
+// Package blocklist filters peer and tracker addresses by IP ranges.
 package blocklist
 
 import (
+	"archive/zip"
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"sync"
+	"sync/atomic"
 
 	"github.com/al002/zbittorrent/internal/blocklist/stree"
 )
 
-var errNotIPv4Address = errors.New("address is not ipv4")
+// Maximum size of a decompressed blocklist.
+const maxUncompressedSize = 1 << 30
+
+var (
+	errNotIPv4Address = errors.New("address is not ipv4")
+	errInvalidAddress = errors.New("invalid address")
+	errInvalidRange   = errors.New("invalid range")
+	errNoValidRules   = errors.New("no valid rules")
+	errTooLarge       = errors.New("uncompressed blocklist is too large")
+)
 
 type Blocklist struct {
-	tree   stree.Stree
-	m      sync.RWMutex
-	count  int
+	tree  stree.Stree
+	tree6 ipv6Ranges
+	m     sync.RWMutex
+	stats LoadStats
+	hits  atomic.Uint64
+}
+
+// LoadStats describes the result of loading a blocklist.
+type LoadStats struct {
+	// Number of ranges added to the blocklist.
+	Parsed int
+	// Number of IPv6 ranges in Parsed.
+	ParsedIPv6 int
+	// Number of lines that cannot be parsed.
+	Rejected int
+	// Number of eMule DAT ranges with an access level that does not block.
+	Ignored int
 }
 
 func New() *Blocklist {
-  return &Blocklist{}
+	return &Blocklist{}
 }
 
 func (b *Blocklist) Len() int {
-  b.m.RLock()
-  defer b.m.RUnlock()
-  return b.count
+	b.m.RLock()
+	defer b.m.RUnlock()
+	return b.stats.Parsed
+}
+
+// NewFromRules returns a Blocklist of the given rules. Each rule is an
+// address, a CIDR or a "first-last" range. An error is returned for the
+// first invalid rule.
+func NewFromRules(rules []string) (*Blocklist, error) {
+	var rs ruleSet
+	stats := LoadStats{Parsed: len(rules)}
+	for _, rule := range rules {
+		r, _, err := parseLine(bytes.TrimSpace([]byte(rule)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", rule, err)
+		}
+		rs.add(r)
+		if !r.first.Is4() {
+			stats.ParsedIPv6++
+		}
+	}
+	rs.build()
+	return &Blocklist{tree: rs.tree, tree6: rs.tree6, stats: stats}, nil
+}
+
+// Stats returns the statistics of the last successful Reload.
+func (b *Blocklist) Stats() LoadStats {
+	b.m.RLock()
+	defer b.m.RUnlock()
+	return b.stats
 }
 
 func (b *Blocklist) Blocked(ip net.IP) bool {
-  b.m.RLock()
-  defer b.m.RUnlock()
+	b.m.RLock()
+	defer b.m.RUnlock()
+
+	var blocked bool
+	if ip4 := ip.To4(); ip4 != nil {
+		blocked = b.tree.Contains(stree.ValueType(binary.BigEndian.Uint32(ip4)))
+	} else if len(ip) == net.IPv6len {
+		blocked = b.tree6.contains(uint128FromBytes(ip))
+	}
+	if !blocked {
+		return false
+	}
 
-  ip = ip.To4()
-  if ip == nil {
-    return false
-  }
+	b.hits.Add(1)
+	return true
+}
 
-  val := binary.BigEndian.Uint32(ip)
-  return b.tree.Contains(stree.ValueType(val))
+// Hits returns the number of times Blocked returned true.
+func (b *Blocklist) Hits() uint64 {
+	return b.hits.Load()
 }
 
-func (b *Blocklist) Reload(r io.Reader) (int, error) {
-  b.m.Lock()
-  defer b.m.Unlock()
+// Reload replaces the rules with the list read from r.
+// Lines can be in CIDR, PeerGuardian P2P ("name:1.2.3.4-1.2.3.255") or
+// eMule DAT ("1.2.3.4 - 1.2.3.255 , 000 , name") format with IPv4 or IPv6
+// addresses and gzip or zip compressed lists are decompressed. The current rules are kept on error.
+func (b *Blocklist) Reload(r io.Reader) (LoadStats, error) {
+	r, err := decompress(r)
+	if err != nil {
+		return LoadStats{}, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
 
-  tree, n, err := load(r)
-  if err != nil {
-    return n, err
-  }
+	l, stats, err := load(r)
+	if err != nil {
+		return stats, err
+	}
 
-  b.tree = *tree
-  b.count = n
-  return n, nil
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.tree = l.tree
+	b.tree6 = l.tree6
+	b.stats = stats
+	return stats, nil
 }
 
-func load(r io.Reader) (*stree.Stree, int, error) {
-  var tree stree.Stree
-  var n int
-  var hasError bool
+// decompress returns a reader for the contents of gzip and zip files.
+// Other input is returned unchanged.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
 
-  scanner := bufio.NewScanner(r)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return limitReader(gr), nil
+	case bytes.Equal(magic, []byte("PK\x03\x04")):
+		return unzip(br)
+	default:
+		return br, nil
+	}
+}
 
-  for scanner.Scan() {
-    l := bytes.TrimSpace(scanner.Bytes())
-    if len(l) == 0 {
-      continue
-    }
+// unzip concatenates the files in the zip archive read from r.
+func unzip(r io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
 
-    if l[0] == '#' {
-      continue
-    }
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
 
-    r, err := parseCIDR(l)
-    if err != nil {
-      hasError = true
-      continue
-    }
+	var readers []io.Reader
+	var files zipFiles
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			files.Close()
+			return nil, err
+		}
+		files.closers = append(files.closers, rc)
+		// Files may not end with a newline
+		readers = append(readers, rc, bytes.NewReader([]byte{'\n'}))
+	}
+	files.Reader = limitReader(io.MultiReader(readers...))
+	return &files, nil
+}
 
-    tree.AddRange(stree.ValueType(r.first), stree.ValueType(r.last))
-    n++
-  }
+// zipFiles reads the files in a zip archive one after another and closes them all when it is closed.
+type zipFiles struct {
+	io.Reader
+	closers []io.Closer
+}
 
-  if err := scanner.Err(); err != nil {
-    return nil, 0, err
-  }
+func (z *zipFiles) Close() error {
+	for _, c := range z.closers {
+		c.Close()
+	}
+	z.closers = nil
+	return nil
+}
+
+func limitReader(r io.Reader) io.Reader {
+	return &limitedReader{r: io.LimitReader(r, maxUncompressedSize+1)}
+}
+
+// limitedReader returns an error instead of EOF when the limit is exceeded.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > maxUncompressedSize {
+		return n, errTooLarge
+	}
+	return n, err
+}
+
+// ruleSet collects the ranges of a blocklist.
+type ruleSet struct {
+	tree    stree.Stree
+	tree6   ipv6Ranges
+	ranges6 []ipv6Range
+}
 
-  if n == 0 && hasError {
-    return nil, 0, errors.New("no valid rules")
-  }
+func (rs *ruleSet) add(r ipRange) {
+	if r.first.Is4() {
+		first, last := r.first.As4(), r.last.As4()
+		rs.tree.AddRange(stree.ValueType(binary.BigEndian.Uint32(first[:])), stree.ValueType(binary.BigEndian.Uint32(last[:])))
+		return
+	}
+	rs.ranges6 = append(rs.ranges6, ipv6Range{uint128FromAddr(r.first), uint128FromAddr(r.last)})
+}
 
-  tree.Build()
-  return &tree, n, nil
+func (rs *ruleSet) build() {
+	rs.tree.Build()
+	rs.tree6 = newIPv6Ranges(rs.ranges6)
+	rs.ranges6 = nil
 }
 
+func load(r io.Reader) (*ruleSet, LoadStats, error) {
+	var rs ruleSet
+	var stats LoadStats
+
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] == '#' || bytes.HasPrefix(line, []byte("//")) {
+			continue
+		}
+
+		r, block, err := parseLine(line)
+		if err != nil {
+			stats.Rejected++
+			continue
+		}
+		if !block {
+			stats.Ignored++
+			continue
+		}
+
+		rs.add(r)
+		if !r.first.Is4() {
+			stats.ParsedIPv6++
+		}
+		stats.Parsed++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, stats, err
+	}
+
+	if stats.Parsed == 0 && stats.Rejected > 0 {
+		return nil, stats, errNoValidRules
+	}
+
+	rs.build()
+	return &rs, stats, nil
+}
+
+// ipRange is an inclusive range of addresses of the same family.
+// IPv4-mapped IPv6 addresses are stored as IPv4.
 type ipRange struct {
-  first, last uint32
+	first, last netip.Addr
+}
+
+// parseLine detects the format of a line and parses it.
+// block is false for eMule DAT ranges that are allowed by their access level.
+func parseLine(l []byte) (r ipRange, block bool, err error) {
+	switch {
+	case bytes.IndexByte(l, ',') >= 0:
+		// Names in P2P lines may contain commas too
+		r, block, err = parseDAT(l)
+		if err == nil {
+			return r, block, nil
+		}
+		r, err = parseP2P(l)
+	case bytes.IndexByte(l, '-') >= 0:
+		r, err = parseP2P(l)
+	case bytes.IndexByte(l, '/') >= 0:
+		r, err = parseCIDR(l)
+	default:
+		r.first, err = parseAddr(l)
+		r.last = r.first
+	}
+	return r, true, err
 }
 
 func parseCIDR(b []byte) (r ipRange, err error) {
-  _, ipnet, err := net.ParseCIDR(string(b[:]))
-  if err != nil {
-    return
-  }
-
-  if len(ipnet.IP) != 4 {
-    err = errNotIPv4Address
-    return
-  }
-
-  if len(ipnet.Mask) != 4 {
-    err = errNotIPv4Address
-    return 
-  }
-  
-  r.first = binary.BigEndian.Uint32(ipnet.IP)
-  r.last = r.first | ^binary.BigEndian.Uint32(ipnet.Mask)
-  return
+	p, err := netip.ParsePrefix(string(b))
+	if err != nil {
+		return
+	}
+	if p.Addr().Zone() != "" {
+		err = errInvalidAddress
+		return
+	}
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+
+	p = p.Masked()
+	r.first = p.Addr()
+	if r.first.Is4() {
+		first := r.first.As4()
+		last := binary.BigEndian.Uint32(first[:]) | ^uint32(0)>>p.Bits()
+		r.last = netip.AddrFrom4([4]byte(binary.BigEndian.AppendUint32(nil, last)))
+		return
+	}
+
+	last := uint128FromAddr(r.first)
+	if p.Bits() < 64 {
+		last.hi |= ^uint64(0) >> p.Bits()
+		last.lo = ^uint64(0)
+	} else {
+		last.lo |= ^uint64(0) >> (p.Bits() - 64)
+	}
+	var a [16]byte
+	binary.BigEndian.PutUint64(a[:8], last.hi)
+	binary.BigEndian.PutUint64(a[8:], last.lo)
+	r.last = netip.AddrFrom16(a)
+	return
+}
+
+// parseP2P parses a PeerGuardian text line "name:first-last".
+// The name may contain colons and dashes and is optional.
+func parseP2P(b []byte) (r ipRange, err error) {
+	i := bytes.LastIndexByte(b, '-')
+	if i < 0 {
+		return r, errInvalidRange
+	}
+	first, last := b[:i], b[i+1:]
+
+	// The name ends at the first colon that is followed by an address.
+	// IPv6 addresses contain colons too.
+	start := 0
+	for {
+		r, err = parseRange(first[start:], last)
+		if err == nil {
+			return r, nil
+		}
+		i := bytes.IndexByte(first[start:], ':')
+		if i < 0 {
+			return r, err
+		}
+		start += i + 1
+	}
+}
+
+// parseDAT parses an eMule DAT line "first - last , level , name".
+// Ranges with access level of 128 or more are not blocked.
+func parseDAT(b []byte) (r ipRange, block bool, err error) {
+	fields := bytes.SplitN(b, []byte(","), 3)
+	first, last, ok := bytes.Cut(fields[0], []byte("-"))
+	if !ok {
+		return r, false, errInvalidRange
+	}
+	r, err = parseRange(first, last)
+	if err != nil {
+		return
+	}
+
+	level := 0
+	if len(fields) > 1 {
+		_, err = fmt.Sscanf(string(bytes.TrimSpace(fields[1])), "%d", &level)
+		if err != nil {
+			return
+		}
+	}
+	return r, level < 128, nil
+}
+
+func parseRange(first, last []byte) (r ipRange, err error) {
+	r.first, err = parseAddr(first)
+	if err != nil {
+		return
+	}
+	r.last, err = parseAddr(last)
+	if err != nil {
+		return
+	}
+	if r.first.Is4() != r.last.Is4() || r.last.Less(r.first) {
+		err = errInvalidRange
+	}
+	return
+}
+
+// parseAddr parses an IPv4 or IPv6 address. IPv4-mapped IPv6 addresses are
+// converted to IPv4.
+func parseAddr(b []byte) (netip.Addr, error) {
+	b = bytes.TrimSpace(b)
+	if bytes.IndexByte(b, ':') < 0 {
+		ip, err := parseIPv4(b)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		return netip.AddrFrom4([4]byte(binary.BigEndian.AppendUint32(nil, ip))), nil
+	}
+
+	a, err := netip.ParseAddr(string(b))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if a.Zone() != "" {
+		return netip.Addr{}, errInvalidAddress
+	}
+	return a.Unmap(), nil
+}
+
+// parseIPv4 parses a dotted IPv4 address. Octets may have leading zeros as in eMule DAT files.
+func parseIPv4(b []byte) (uint32, error) {
+	b = bytes.TrimSpace(b)
+	var ip uint32
+	parts := bytes.Split(b, []byte("."))
+	if len(parts) != 4 {
+		return 0, errNotIPv4Address
+	}
+	for _, p := range parts {
+		if len(p) == 0 || len(p) > 3 {
+			return 0, errNotIPv4Address
+		}
+		var octet uint32
+		for _, c := range p {
+			if c < '0' || c > '9' {
+				return 0, errNotIPv4Address
+			}
+			octet = octet*10 + uint32(c-'0')
+		}
+		if octet > 255 {
+			return 0, errNotIPv4Address
+		}
+		ip = ip<<8 | octet
+	}
+	return ip, nil
 }
@@ -1,10 +1,14 @@
 package blocklist
 
 import (
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"net"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -16,8 +20,28 @@ func TestParseCIDR(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	assert.Equal(t, uint32(256), r.first)
-	assert.Equal(t, uint32(511), r.last)
+	assert.Equal(t, netip.MustParseAddr("0.0.1.0"), r.first)
+	assert.Equal(t, netip.MustParseAddr("0.0.1.255"), r.last)
+}
+
+func TestParseCIDRIPv6(t *testing.T) {
+	cases := []struct {
+		cidr, first, last string
+	}{
+		{"2001:db8::1/32", "2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{"2001:db8:1:2:3::/80", "2001:db8:1:2:3::", "2001:db8:1:2:3:ffff:ffff:ffff"},
+		{"2001:db8::1/128", "2001:db8::1", "2001:db8::1"},
+		{"::/0", "::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{"::ffff:1.2.3.0/120", "1.2.3.0", "1.2.3.255"},
+	}
+	for _, c := range cases {
+		r, err := parseCIDR([]byte(c.cidr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, netip.MustParseAddr(c.first), r.first, c.cidr)
+		assert.Equal(t, netip.MustParseAddr(c.last), r.last, c.cidr)
+	}
 }
 
 func TestContains(t *testing.T) {
@@ -27,11 +51,11 @@ func TestContains(t *testing.T) {
 		t.Fatal(err)
 	}
 	b := New()
-	n, err := b.Reload(f)
+	stats, err := b.Reload(f)
 	if err != nil {
 		t.Fatal(err)
 	}
-	t.Logf("loaded %d values", n)
+	t.Logf("loaded %d values", stats.Parsed)
 	assert.True(t, b.Blocked(net.ParseIP("6.1.2.3")))
 	assert.False(t, b.Blocked(net.ParseIP("176.240.195.107")))
 }
@@ -39,13 +63,221 @@ func TestContains(t *testing.T) {
 func TestEmptyList(t *testing.T) {
 	r := bytes.NewReader(make([]byte, 0))
 	b := New()
-	n, err := b.Reload(r)
+	stats, err := b.Reload(r)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != 0 {
-		t.Fatalf("loaded %d values", n)
+	if stats.Parsed != 0 {
+		t.Fatalf("loaded %d values", stats.Parsed)
 	}
 	assert.False(t, b.Blocked(net.ParseIP("0.0.0.0")))
 	assert.False(t, b.Blocked(net.ParseIP("176.240.195.107")))
 }
+
+func TestParseIPv4(t *testing.T) {
+	ip, err := parseIPv4([]byte(" 010.001.000.255 "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint32(10<<24|1<<16|255), ip)
+
+	for _, s := range []string{"", "1.2.3", "1.2.3.4.5", "1.2.3.256", "1.2.3.0004", "1.2.3.a"} {
+		_, err = parseIPv4([]byte(s))
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	cases := []struct {
+		line, first, last string
+		block             bool
+	}{
+		{"1.2.3.4", "1.2.3.4", "1.2.3.4", true},
+		{"1.2.3.0/24", "1.2.3.0", "1.2.3.255", true},
+		{"Acme:1.2.3.0-1.2.3.255", "1.2.3.0", "1.2.3.255", true},
+		{"Acme, Inc:1.2.3.0-1.2.3.255", "1.2.3.0", "1.2.3.255", true},
+		{"Acme, Inc, and others:2001:db8::-2001:db8::ff", "2001:db8::", "2001:db8::ff", true},
+		{"001.002.003.000 - 001.002.003.255 , 000 , Acme, Inc", "1.2.3.0", "1.2.3.255", true},
+		{"1.2.3.0 - 1.2.3.255 , 200 , Acme", "1.2.3.0", "1.2.3.255", false},
+	}
+	for _, c := range cases {
+		r, block, err := parseLine([]byte(c.line))
+		if !assert.NoError(t, err, c.line) {
+			continue
+		}
+		assert.Equal(t, netip.MustParseAddr(c.first), r.first, c.line)
+		assert.Equal(t, netip.MustParseAddr(c.last), r.last, c.line)
+		assert.Equal(t, c.block, block, c.line)
+	}
+
+	for _, l := range []string{"foo", "Acme, Inc", "1.2.3.4 - 1.2.3.5 , abc , name"} {
+		_, _, err := parseLine([]byte(l))
+		assert.Error(t, err, l)
+	}
+}
+
+func TestP2P(t *testing.T) {
+	b := loadFile(t, "blocklist.p2p")
+	assert.Equal(t, LoadStats{Parsed: 3, Rejected: 1}, b.Stats())
+	assert.True(t, b.Blocked(net.ParseIP("6.1.2.3")))
+	assert.True(t, b.Blocked(net.ParseIP("10.0.0.5")))
+	assert.False(t, b.Blocked(net.ParseIP("10.0.0.6")))
+	assert.True(t, b.Blocked(net.ParseIP("1.1.1.1")))
+	assert.False(t, b.Blocked(net.ParseIP("1.1.1.2")))
+}
+
+func TestDAT(t *testing.T) {
+	b := loadFile(t, "blocklist.dat")
+	assert.Equal(t, LoadStats{Parsed: 2, Rejected: 1, Ignored: 1}, b.Stats())
+	assert.True(t, b.Blocked(net.ParseIP("6.1.2.3")))
+	assert.True(t, b.Blocked(net.ParseIP("10.0.0.1")))
+	assert.False(t, b.Blocked(net.ParseIP("11.0.0.1")))
+	assert.False(t, b.Blocked(net.ParseIP("12.0.0.0")))
+}
+
+func TestNoValidRules(t *testing.T) {
+	b := New()
+	_, err := b.Reload(strings.NewReader("6.0.0.0/8\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := b.Reload(strings.NewReader("foo\nbar\n"))
+	assert.Equal(t, errNoValidRules, err)
+	assert.Equal(t, 2, stats.Rejected)
+	// Previous rules are kept
+	assert.Equal(t, 1, b.Len())
+	assert.True(t, b.Blocked(net.ParseIP("6.1.2.3")))
+}
+
+func TestCompressed(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "blocklist.p2p"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	_, _ = gw.Write(data)
+	if err = gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var zb bytes.Buffer
+	zw := zip.NewWriter(&zb)
+	if _, err = zw.Create("dir/"); err != nil {
+		t.Fatal(err)
+	}
+	w, err := zw.Create("dir/a.p2p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Missing newline at the end of the first file
+	_, _ = w.Write([]byte("a:6.0.0.0-6.255.255.255"))
+	w, err = zw.Create("b.cidr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("10.0.0.0/8\n"))
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := New()
+	stats, err := b.Reload(&gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, LoadStats{Parsed: 3, Rejected: 1}, stats)
+	assert.True(t, b.Blocked(net.ParseIP("6.1.2.3")))
+
+	stats, err = b.Reload(&zb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, LoadStats{Parsed: 2}, stats)
+	assert.True(t, b.Blocked(net.ParseIP("6.1.2.3")))
+	assert.True(t, b.Blocked(net.ParseIP("10.1.2.3")))
+	assert.False(t, b.Blocked(net.ParseIP("1.1.1.1")))
+}
+
+func TestIPv6(t *testing.T) {
+	list := `
+2001:db8::/32
+name:with:colons:2001:db9::10-2001:db9::20
+2001:dba::1 - 2001:dba::ff , 000 , DAT range
+2001:dbb::1
+Mixed:1.2.3.4-2001:db8::1
+::ffff:5.0.0.0/104
+`
+	b := New()
+	stats, err := b.Reload(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, LoadStats{Parsed: 5, ParsedIPv6: 4, Rejected: 1}, stats)
+
+	blocked := []string{"2001:db8::", "2001:db8:ffff::1", "2001:db9::10", "2001:db9::20", "2001:dba::80", "2001:dbb::1", "5.1.2.3", "::ffff:5.1.2.3"}
+	for _, ip := range blocked {
+		assert.True(t, b.Blocked(net.ParseIP(ip)), ip)
+	}
+	allowed := []string{"2001:db7:ffff::1", "2001:db9::21", "2001:dba::100", "2001:dbb::2", "::1", "6.1.2.3"}
+	for _, ip := range allowed {
+		assert.False(t, b.Blocked(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestIPv6Ranges(t *testing.T) {
+	u := func(hi, lo uint64) uint128 { return uint128{hi, lo} }
+	rs := newIPv6Ranges([]ipv6Range{
+		{u(0, 10), u(0, 20)},
+		{u(0, 15), u(0, 25)},
+		{u(0, 26), u(0, 30)},
+		{u(0, 40), u(1, 0)},
+		{u(0, 5), u(0, 5)},
+		{u(2, 0), maxUint128},
+		{u(3, 0), u(4, 0)},
+	})
+	assert.Equal(t, ipv6Ranges{
+		{u(0, 5), u(0, 5)},
+		{u(0, 10), u(0, 30)},
+		{u(0, 40), u(1, 0)},
+		{u(2, 0), maxUint128},
+	}, rs)
+
+	for _, v := range []uint128{u(0, 5), u(0, 10), u(0, 30), u(0, ^uint64(0)), u(1, 0), u(2, 0), maxUint128} {
+		assert.True(t, rs.contains(v), v)
+	}
+	for _, v := range []uint128{u(0, 0), u(0, 6), u(0, 31), u(1, 1), u(1, ^uint64(0))} {
+		assert.False(t, rs.contains(v), v)
+	}
+}
+
+func loadFile(t *testing.T, name string) *Blocklist {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b := New()
+	if _, err = b.Reload(f); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestNewFromRules(t *testing.T) {
+	b, err := NewFromRules([]string{"10.0.0.0/8", "192.168.1.1-192.168.1.10", "2001:db8::/32", " 1.2.3.4 "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, LoadStats{Parsed: 4, ParsedIPv6: 1}, b.Stats())
+	for _, ip := range []string{"10.1.2.3", "192.168.1.5", "2001:db8::1", "1.2.3.4"} {
+		assert.True(t, b.Blocked(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{"11.1.2.3", "192.168.1.11", "2001:db9::1"} {
+		assert.False(t, b.Blocked(net.ParseIP(ip)), ip)
+	}
+
+	_, err = NewFromRules([]string{"10.0.0.0/8", "foo"})
+	assert.ErrorContains(t, err, `"foo"`)
+}
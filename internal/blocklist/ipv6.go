@@ -0,0 +1,84 @@
+package blocklist
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"sort"
+)
+
+// uint128 is an IPv6 address as an integer.
+type uint128 struct {
+	hi, lo uint64
+}
+
+var maxUint128 = uint128{^uint64(0), ^uint64(0)}
+
+func uint128FromAddr(a netip.Addr) uint128 {
+	b := a.As16()
+	return uint128FromBytes(b[:])
+}
+
+func uint128FromBytes(b []byte) uint128 {
+	return uint128{binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])}
+}
+
+func (u uint128) less(v uint128) bool {
+	return u.hi < v.hi || u.hi == v.hi && u.lo < v.lo
+}
+
+func (u uint128) next() uint128 {
+	lo := u.lo + 1
+	hi := u.hi
+	if lo == 0 {
+		hi++
+	}
+	return uint128{hi, lo}
+}
+
+type ipv6Range struct {
+	first, last uint128
+}
+
+// ipv6Ranges is a sorted list of disjoint IPv6 ranges.
+// Overlapping and adjacent ranges are merged when it is built,
+// so a lookup is a single binary search.
+type ipv6Ranges []ipv6Range
+
+func newIPv6Ranges(ranges []ipv6Range) ipv6Ranges {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].first.less(ranges[j].first) })
+
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		top := &merged[len(merged)-1]
+		if top.last == maxUint128 || !top.last.next().less(r.first) {
+			if top.last.less(r.last) {
+				top.last = r.last
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return ipv6Ranges(merged).clip()
+}
+
+// clip releases the capacity of the merged ranges.
+func (rs ipv6Ranges) clip() ipv6Ranges {
+	return append(ipv6Ranges(nil), rs...)
+}
+
+func (rs ipv6Ranges) contains(v uint128) bool {
+	// Binary search for the first range that ends at or after v
+	i, j := 0, len(rs)
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if rs[h].last.less(v) {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	return i < len(rs) && !v.less(rs[i].first)
+}
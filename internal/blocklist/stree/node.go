@@ -42,6 +42,19 @@ func (n node) querySingle(from, to ValueType, result map[ValueType]interval) {
 	}
 }
 
+// contains returns true if any interval overlaps value without allocating.
+func (n *node) contains(value ValueType) bool {
+	if n.segment.Disjoint(value, value) {
+		return false
+	}
+	if len(n.overlap) > 0 {
+		return true
+	}
+	// Both children may contain a value that is a shared endpoint
+	return n.left != nil && n.left.contains(value) ||
+		n.right != nil && n.right.contains(value)
+}
+
 type interval struct {
 	ID ValueType // unique
 	segment
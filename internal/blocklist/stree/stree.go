@@ -21,8 +21,12 @@ type Stree struct {
 	min, max ValueType
 }
 
-// AddRange pushes new interval to stack
+// AddRange pushes new interval to stack.
+// Both ends are inclusive and may be given in any order.
 func (t *Stree) AddRange(from, to ValueType) {
+	if from > to {
+		from, to = to, from
+	}
 	t.base = append(t.base, interval{t.count, segment{from, to}})
 	t.count++
 }
@@ -111,9 +115,12 @@ func (t *Stree) insertNodes(leaves []segment) *node {
 	return n
 }
 
-// Contains returns truee if value is in segment tree.
+// Contains returns true if value is in segment tree.
 func (t Stree) Contains(value ValueType) bool {
-	return len(t.query(value, value)) > 0
+	if t.root == nil || value < t.min || value > t.max {
+		return false
+	}
+	return t.root.contains(value)
 }
 
 // query interval
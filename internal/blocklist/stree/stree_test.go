@@ -118,3 +118,37 @@ func TestDedup(t *testing.T) {
 		t.Errorf("item: %d", l2[3])
 	}
 }
+
+func TestArbitraryRanges(t *testing.T) {
+	var tree Stree
+	tree.AddRange(1, 1)
+	tree.AddRange(2, 2)
+	tree.AddRange(20, 10)
+	tree.AddRange(100, 4294967295)
+	tree.Build()
+	for _, v := range []ValueType{1, 2, 10, 15, 20, 100, 4294967295} {
+		if !tree.Contains(v) {
+			t.Errorf("value %d is not found", v)
+		}
+	}
+	for _, v := range []ValueType{0, 3, 9, 21, 99} {
+		if tree.Contains(v) {
+			t.Errorf("value %d is found", v)
+		}
+	}
+}
+
+func TestContainsMatchesQuery(t *testing.T) {
+	var tree Stree
+	tree.AddRange(3, 7)
+	tree.AddRange(5, 5)
+	tree.AddRange(9, 12)
+	tree.AddRange(12, 14)
+	tree.AddRange(20, 20)
+	tree.Build()
+	for v := ValueType(0); v < 25; v++ {
+		if got, want := tree.Contains(v), len(tree.query(v, v)) > 0; got != want {
+			t.Errorf("Contains(%d) = %v, want %v", v, got, want)
+		}
+	}
+}
@@ -42,7 +42,7 @@ func (r *Registry) LoadConfig(cfgFile string) (*Config, error) {
 		fmt.Printf("Failed to read config: %v\n", err)
 		os.Exit(1)
 	} else {
-		fmt.Printf("Using config file: %s\n", viper.ConfigFileUsed())
+		fmt.Fprintf(os.Stderr, "Using config file: %s\n", r.v.ConfigFileUsed())
 	}
 
 	r.v.OnConfigChange(func(e fsnotify.Event) {
@@ -68,3 +68,12 @@ func (r *Registry) LoadConfig(cfgFile string) (*Config, error) {
 func (r *Registry) ConfigFile() string {
 	return r.v.ConfigFileUsed()
 }
+
+// UnmarshalKey decodes the config section at key into v. v is left untouched if the section does not exist.
+func (r *Registry) UnmarshalKey(key string, v any) error {
+	if !r.v.IsSet(key) {
+		return nil
+	}
+
+	return r.v.UnmarshalKey(key, v)
+}
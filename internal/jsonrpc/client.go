@@ -0,0 +1,126 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Client makes JSON-RPC calls to a Server over HTTP.
+type Client struct {
+	url        string
+	httpClient *http.Client
+	nextID     atomic.Int64
+
+	// Header is added to every request made by the client.
+	Header http.Header
+
+	// Session id learned from the server, see SessionIDHeader
+	mSessionID sync.Mutex
+	sessionID  string
+}
+
+func NewClient(url string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		url:        url,
+		httpClient: httpClient,
+		Header:     make(http.Header),
+	}
+}
+
+// post sends the request body, learning the session id of the server first if it requires one.
+func (c *Client) post(body []byte) (*http.Response, error) {
+	for {
+		req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range c.Header {
+			req.Header[k] = v
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		c.mSessionID.Lock()
+		sessionID := c.sessionID
+		c.mSessionID.Unlock()
+		if sessionID != "" {
+			req.Header.Set(SessionIDHeader, sessionID)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		newID := resp.Header.Get(SessionIDHeader)
+		if resp.StatusCode != http.StatusConflict || newID == "" || newID == sessionID {
+			return resp, nil
+		}
+
+		// Retry with the session id given by the server
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		c.mSessionID.Lock()
+		c.sessionID = newID
+		c.mSessionID.Unlock()
+	}
+}
+
+// Call invokes the method with params and decodes the result into result.
+// Errors returned from the server are of type *Error.
+func (c *Client) Call(method string, params, result any) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	id := json.RawMessage(strconv.FormatInt(c.nextID.Add(1), 10))
+	body, err := json.Marshal(&request{
+		Version: version,
+		Method:  method,
+		Params:  b,
+		ID:      id,
+	})
+	if err != nil {
+		return err
+	}
+
+	httpResp, err := c.post(body)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(httpResp.Body, 1024))
+		return fmt.Errorf("jsonrpc: http status %d: %s", httpResp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	var resp response
+	if err = json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return err
+	}
+
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !bytes.Equal(resp.ID, id) {
+		return fmt.Errorf("jsonrpc: response id %s does not match request id %s", resp.ID, id)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(resp.Result, result)
+}
@@ -0,0 +1,46 @@
+// Package jsonrpc implements JSON-RPC 2.0 over HTTP.
+// See https://www.jsonrpc.org/specification for the protocol.
+package jsonrpc
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const version = "2.0"
+
+// Error codes defined by the specification.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	// Errors returned from method handlers.
+	CodeServerError = -32000
+)
+
+// Error is the error object in a JSON-RPC response.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc: %s (code %d)", e.Message, e.Code)
+}
+
+type request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type response struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
@@ -0,0 +1,208 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// Maximum size of a request body. Torrent files are sent in requests so this must be large enough for them.
+const maxRequestSize = 32 << 20
+
+// SessionIDHeader is used for CSRF protection when the server requires a session id.
+// The server sends the session id in a 409 response and clients must echo it in the following requests.
+const SessionIDHeader = "X-Zbittorrent-Session-Id"
+
+type handlerFunc func(params json.RawMessage) (any, error)
+
+// Server is a http.Handler that dispatches JSON-RPC requests to registered methods.
+type Server struct {
+	methods map[string]handlerFunc
+	// Empty if the server does not require a session id
+	sessionID string
+}
+
+func NewServer() *Server {
+	return &Server{
+		methods: make(map[string]handlerFunc),
+	}
+}
+
+// RequireSessionID makes the server reject the requests that do not send the session id in SessionIDHeader.
+// It protects servers that have no other authentication from requests made by web pages in a browser.
+func (s *Server) RequireSessionID() {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	s.sessionID = base64.RawURLEncoding.EncodeToString(b)
+}
+
+// SessionID returns the value that clients must send in SessionIDHeader, empty if it is not required.
+func (s *Server) SessionID() string {
+	return s.sessionID
+}
+
+// Register adds a method to the server.
+// Params of the request are decoded into a new Req and the Resp filled by fn is sent as the result.
+// If fn returns an *Error, it is sent to the client as is, other errors are sent with CodeServerError.
+func Register[Req, Resp any](s *Server, method string, fn func(req *Req, resp *Resp) error) {
+	s.methods[method] = func(params json.RawMessage) (any, error) {
+		req := new(Req)
+		if len(params) > 0 && !bytes.Equal(params, []byte("null")) {
+			if err := json.Unmarshal(params, req); err != nil {
+				return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+			}
+		}
+
+		resp := new(Resp)
+		if err := fn(req, resp); err != nil {
+			return nil, err
+		}
+
+		return resp, nil
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Browsers send cross-site requests without asking only with the content types of HTML forms
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		http.Error(w, "content type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if s.sessionID != "" && r.Header.Get(SessionIDHeader) != s.sessionID {
+		w.Header().Set(SessionIDHeader, s.sessionID)
+		http.Error(w, "invalid or missing "+SessionIDHeader+" header", http.StatusConflict)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result any
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		result, err = s.handleBatch(body)
+	} else {
+		result, err = s.handleSingle(body)
+	}
+
+	if err != nil {
+		result = &response{
+			Version: version,
+			Error:   &Error{Code: CodeParseError, Message: err.Error()},
+			ID:      json.RawMessage("null"),
+		}
+	}
+
+	// Notifications have no response
+	if result == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) handleSingle(body []byte) (any, error) {
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	resp := s.handle(&req)
+	if resp == nil {
+		return nil, nil
+	}
+
+	return resp, nil
+}
+
+func (s *Server) handleBatch(body []byte) (any, error) {
+	var reqs []request
+	if err := json.Unmarshal(body, &reqs); err != nil {
+		return nil, err
+	}
+
+	if len(reqs) == 0 {
+		return &response{
+			Version: version,
+			Error:   &Error{Code: CodeInvalidRequest, Message: "empty batch"},
+			ID:      json.RawMessage("null"),
+		}, nil
+	}
+
+	resps := make([]*response, 0, len(reqs))
+	for i := range reqs {
+		if resp := s.handle(&reqs[i]); resp != nil {
+			resps = append(resps, resp)
+		}
+	}
+
+	if len(resps) == 0 {
+		return nil, nil
+	}
+
+	return resps, nil
+}
+
+func (s *Server) handle(req *request) *response {
+	resp := &response{
+		Version: version,
+		ID:      req.ID,
+	}
+
+	if req.Version != version || req.Method == "" {
+		resp.Error = &Error{Code: CodeInvalidRequest, Message: "invalid request"}
+		if resp.ID == nil {
+			resp.ID = json.RawMessage("null")
+		}
+		return resp
+	}
+
+	fn, ok := s.methods[req.Method]
+	if !ok {
+		resp.Error = &Error{Code: CodeMethodNotFound, Message: "method not found: " + req.Method}
+	} else {
+		result, err := fn(req.Params)
+		if err != nil {
+			resp.Error = toError(err)
+		} else {
+			resp.Result, err = json.Marshal(result)
+			if err != nil {
+				resp.Error = &Error{Code: CodeInternalError, Message: err.Error()}
+			}
+		}
+	}
+
+	// Request without an id is a notification
+	if req.ID == nil {
+		return nil
+	}
+
+	return resp
+}
+
+func toError(err error) *Error {
+	var rerr *Error
+	if errors.As(err, &rerr) {
+		return rerr
+	}
+
+	return &Error{Code: CodeServerError, Message: err.Error()}
+}
@@ -0,0 +1,163 @@
+package jsonrpc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type addRequest struct {
+	A, B int
+}
+
+type addResponse struct {
+	Sum int
+}
+
+func newTestServer() *Server {
+	s := NewServer()
+	Register(s, "add", func(req *addRequest, resp *addResponse) error {
+		resp.Sum = req.A + req.B
+		return nil
+	})
+	Register(s, "fail", func(req *struct{}, resp *struct{}) error {
+		return errors.New("failed")
+	})
+	Register(s, "custom", func(req *struct{}, resp *struct{}) error {
+		return &Error{Code: 7, Message: "custom"}
+	})
+	return s
+}
+
+func post(s *Server, body string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestServerSingle(t *testing.T) {
+	s := newTestServer()
+
+	w := post(s, `{"jsonrpc": "2.0", "method": "add", "params": {"A": 1, "B": 2}, "id": 1}`, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "result": {"Sum": 3}, "id": 1}`, w.Body.String())
+
+	w = post(s, `{"jsonrpc": "2.0", "method": "add", "params": {"A": "x"}, "id": "a"}`, nil)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32602, "message": "json: cannot unmarshal string into Go struct field addRequest.A of type int"}, "id": "a"}`, w.Body.String())
+
+	w = post(s, `{"jsonrpc": "2.0", "method": "fail", "id": 2}`, nil)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32000, "message": "failed"}, "id": 2}`, w.Body.String())
+
+	w = post(s, `{"jsonrpc": "2.0", "method": "custom", "id": 3}`, nil)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": 7, "message": "custom"}, "id": 3}`, w.Body.String())
+
+	w = post(s, `{"jsonrpc": "2.0", "method": "nope", "id": 4}`, nil)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32601, "message": "method not found: nope"}, "id": 4}`, w.Body.String())
+
+	w = post(s, `{"jsonrpc": "1.0", "method": "add", "id": 5}`, nil)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32600, "message": "invalid request"}, "id": 5}`, w.Body.String())
+
+	w = post(s, `{"jsonrpc": `, nil)
+	assert.Contains(t, w.Body.String(), `"code":-32700`)
+
+	// Notifications have no response
+	w = post(s, `{"jsonrpc": "2.0", "method": "add", "params": {"A": 1, "B": 2}}`, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestServerBatch(t *testing.T) {
+	s := newTestServer()
+
+	w := post(s, `[
+		{"jsonrpc": "2.0", "method": "add", "params": {"A": 1, "B": 2}, "id": 1},
+		{"jsonrpc": "2.0", "method": "add", "params": {"A": 5, "B": 5}},
+		{"jsonrpc": "2.0", "method": "nope", "id": 2},
+		{"method": "add"}
+	]`, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"jsonrpc": "2.0", "result": {"Sum": 3}, "id": 1},
+		{"jsonrpc": "2.0", "error": {"code": -32601, "message": "method not found: nope"}, "id": 2},
+		{"jsonrpc": "2.0", "error": {"code": -32600, "message": "invalid request"}, "id": null}
+	]`, w.Body.String())
+
+	w = post(s, `[]`, nil)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32600, "message": "empty batch"}, "id": null}`, w.Body.String())
+
+	// A batch of notifications has no response
+	w = post(s, `[{"jsonrpc": "2.0", "method": "add"}, {"jsonrpc": "2.0", "method": "fail"}]`, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestServerRejectsCrossSiteRequests(t *testing.T) {
+	s := newTestServer()
+	body := `{"jsonrpc": "2.0", "method": "add", "params": {"A": 1, "B": 2}, "id": 1}`
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/rpc", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	// Content types that HTML forms and simple requests of browsers can send
+	for _, ct := range []string{"", "text/plain", "application/x-www-form-urlencoded", "multipart/form-data; boundary=x"} {
+		r := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+		if ct != "" {
+			r.Header.Set("Content-Type", ct)
+		}
+		w = httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code, ct)
+	}
+
+	w = post(s, body, http.Header{"Content-Type": {"application/json; charset=utf-8"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestServerSessionID(t *testing.T) {
+	s := newTestServer()
+	assert.Empty(t, s.SessionID())
+	s.RequireSessionID()
+	require.NotEmpty(t, s.SessionID())
+
+	body := `{"jsonrpc": "2.0", "method": "add", "params": {"A": 1, "B": 2}, "id": 1}`
+	w := post(s, body, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, s.SessionID(), w.Header().Get(SessionIDHeader))
+
+	w = post(s, body, http.Header{SessionIDHeader: {"wrong"}})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = post(s, body, http.Header{SessionIDHeader: {s.SessionID()}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "result": {"Sum": 3}, "id": 1}`, w.Body.String())
+}
+
+func TestClient(t *testing.T) {
+	s := newTestServer()
+	s.RequireSessionID()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	c := NewClient(srv.URL, nil)
+	var resp addResponse
+	// The client learns the session id from the first response and retries
+	require.NoError(t, c.Call("add", addRequest{A: 2, B: 3}, &resp))
+	assert.Equal(t, 5, resp.Sum)
+	require.NoError(t, c.Call("add", addRequest{A: 4, B: 3}, &resp))
+	assert.Equal(t, 7, resp.Sum)
+
+	err := c.Call("custom", nil, nil)
+	var rerr *Error
+	require.ErrorAs(t, err, &rerr)
+	assert.Equal(t, 7, rerr.Code)
+}
@@ -0,0 +1,205 @@
+// Package merkle implements the SHA-256 merkle trees of BitTorrent v2 (BEP 52).
+package merkle
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/bits"
+)
+
+const (
+	// BlockSize is the size of the data blocks hashed into the leaves of the tree.
+	BlockSize = 16 * 1024
+	// HashSize is the length of a node in the tree.
+	HashSize = sha256.Size
+)
+
+// Hash is a node in the tree.
+type Hash = [HashSize]byte
+
+var (
+	errInvalidRange = errors.New("invalid hash range")
+	errNotAvailable = errors.New("hashes are not available")
+)
+
+// HashBlocks returns the leaf hashes of data. The last block may be shorter than BlockSize.
+func HashBlocks(data []byte) []Hash {
+	ret := make([]Hash, 0, (len(data)+BlockSize-1)/BlockSize)
+	for len(data) > 0 {
+		n := min(BlockSize, len(data))
+		ret = append(ret, sha256.Sum256(data[:n]))
+		data = data[n:]
+	}
+	return ret
+}
+
+// NumLeaves returns the number of leaves in the tree of a file with the given length,
+// which is the number of blocks rounded up to a power of two.
+func NumLeaves(length int64) int {
+	blocks := (length + BlockSize - 1) / BlockSize
+	if blocks <= 1 {
+		return 1
+	}
+	return 1 << bits.Len64(uint64(blocks-1))
+}
+
+// PadHash returns the root of a subtree with the given number of zero leaves.
+// leaves must be a power of two.
+func PadHash(leaves int) Hash {
+	var h Hash
+	for ; leaves > 1; leaves /= 2 {
+		h = hashPair(h, h)
+	}
+	return h
+}
+
+// Root returns the root of a tree whose layer has width nodes.
+// Missing nodes at the end of layer are filled with pad.
+// width must be a power of two and not smaller than len(layer).
+func Root(layer []Hash, width int, pad Hash) Hash {
+	if len(layer) == 0 {
+		layer = []Hash{pad}
+	}
+	for width > 1 {
+		next := make([]Hash, (len(layer)+1)/2)
+		for i := range next {
+			right := pad
+			if 2*i+1 < len(layer) {
+				right = layer[2*i+1]
+			}
+			next[i] = hashPair(layer[2*i], right)
+		}
+		layer = next
+		width /= 2
+		pad = hashPair(pad, pad)
+	}
+	return layer[0]
+}
+
+// PieceHash returns the root of the subtree over data with the given number of leaves.
+// Leaves past the end of data are zero.
+func PieceHash(data []byte, leaves int) Hash {
+	return Root(HashBlocks(data), leaves, Hash{})
+}
+
+// FileRoot returns the pieces root of a file from its data.
+func FileRoot(data []byte) Hash {
+	return PieceHash(data, NumLeaves(int64(len(data))))
+}
+
+func hashPair(a, b Hash) Hash {
+	var buf [2 * HashSize]byte
+	copy(buf[:], a[:])
+	copy(buf[HashSize:], b[:])
+	return sha256.Sum256(buf[:])
+}
+
+// Tree keeps the layers of a merkle tree starting at a base layer above the leaves.
+type Tree struct {
+	// Height of the lowest stored layer, 0 is the layer of the blocks.
+	base   int
+	layers [][]Hash
+	pads   []Hash
+}
+
+// NewTree builds a tree from the nodes of the layer at height base.
+// width is the number of nodes in the full layer, a power of two.
+func NewTree(layer []Hash, base, width int) *Tree {
+	pad := PadHash(1 << base)
+	t := &Tree{base: base}
+	for {
+		t.layers = append(t.layers, layer)
+		t.pads = append(t.pads, pad)
+		if width <= 1 {
+			break
+		}
+		next := make([]Hash, (len(layer)+1)/2)
+		for i := range next {
+			right := pad
+			if 2*i+1 < len(layer) {
+				right = layer[2*i+1]
+			}
+			next[i] = hashPair(layer[2*i], right)
+		}
+		layer = next
+		width /= 2
+		pad = hashPair(pad, pad)
+	}
+	return t
+}
+
+// Root returns the root of the tree.
+func (t *Tree) Root() Hash {
+	top := t.layers[len(t.layers)-1]
+	if len(top) == 0 {
+		return t.pads[len(t.pads)-1]
+	}
+	return top[0]
+}
+
+func (t *Tree) node(layer, index int) Hash {
+	if index < len(t.layers[layer]) {
+		return t.layers[layer][index]
+	}
+	return t.pads[layer]
+}
+
+// Proof returns length nodes of the layer at height baseLayer starting from index,
+// followed by up to proofLayers uncle hashes needed to verify them, ordered from bottom to top.
+// The root is never included.
+func (t *Tree) Proof(baseLayer, index, length, proofLayers int) ([]Hash, error) {
+	if length < 1 || length&(length-1) != 0 || index < 0 || index%length != 0 || proofLayers < 0 {
+		return nil, errInvalidRange
+	}
+	layer := baseLayer - t.base
+	if layer < 0 || layer >= len(t.layers) {
+		return nil, errNotAvailable
+	}
+	width := 1 << (len(t.layers) - 1 - layer)
+	if index+length > width {
+		return nil, errInvalidRange
+	}
+
+	ret := make([]Hash, 0, length+proofLayers)
+	for i := index; i < index+length; i++ {
+		ret = append(ret, t.node(layer, i))
+	}
+
+	layer += bits.Len(uint(length)) - 1
+	pos := index / length
+	for ; proofLayers > 0 && layer < len(t.layers)-1; proofLayers-- {
+		ret = append(ret, t.node(layer, pos^1))
+		layer++
+		pos /= 2
+	}
+	return ret, nil
+}
+
+// VerifyProof checks that length nodes at index, followed by the uncle hashes up to the root,
+// are part of the tree with the given root.
+func VerifyProof(root Hash, index, length int, hashes []Hash) bool {
+	if length < 1 || length&(length-1) != 0 || index%length != 0 || len(hashes) < length {
+		return false
+	}
+
+	layer := hashes[:length]
+	for len(layer) > 1 {
+		next := make([]Hash, len(layer)/2)
+		for i := range next {
+			next[i] = hashPair(layer[2*i], layer[2*i+1])
+		}
+		layer = next
+	}
+
+	h := layer[0]
+	pos := index / length
+	for _, uncle := range hashes[length:] {
+		if pos%2 == 0 {
+			h = hashPair(h, uncle)
+		} else {
+			h = hashPair(uncle, h)
+		}
+		pos /= 2
+	}
+	return pos == 0 && h == root
+}
@@ -0,0 +1,70 @@
+package merkle
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNumLeaves(t *testing.T) {
+	assert.Equal(t, 1, NumLeaves(0))
+	assert.Equal(t, 1, NumLeaves(BlockSize))
+	assert.Equal(t, 2, NumLeaves(BlockSize+1))
+	assert.Equal(t, 4, NumLeaves(3*BlockSize))
+	assert.Equal(t, 8, NumLeaves(5*BlockSize))
+}
+
+func TestRoot(t *testing.T) {
+	data := make([]byte, 3*BlockSize+100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	l := HashBlocks(data)
+	require.Len(t, l, 4)
+	assert.Equal(t, sha256.Sum256(data[3*BlockSize:]), l[3])
+
+	root := hashPair(hashPair(l[0], l[1]), hashPair(l[2], l[3]))
+	assert.Equal(t, root, FileRoot(data))
+
+	// Two pieces of 2 blocks each give the same root from the piece layer
+	pieces := []Hash{PieceHash(data[:2*BlockSize], 2), PieceHash(data[2*BlockSize:], 2)}
+	assert.Equal(t, root, Root(pieces, 2, PadHash(2)))
+
+	// A piece layer padded with pad hashes
+	short := data[:BlockSize]
+	assert.Equal(t, hashPair(hashPair(sha256.Sum256(short), Hash{}), PadHash(2)),
+		Root([]Hash{PieceHash(short, 2)}, 2, PadHash(2)))
+	assert.Equal(t, hashPair(Hash{}, Hash{}), PadHash(2))
+}
+
+func TestTreeProof(t *testing.T) {
+	layer := make([]Hash, 6)
+	for i := range layer {
+		layer[i] = sha256.Sum256([]byte{byte(i)})
+	}
+	tree := NewTree(layer, 2, 8)
+	assert.Equal(t, Root(layer, 8, PadHash(4)), tree.Root())
+
+	for index := 0; index < 8; index += 2 {
+		hashes, err := tree.Proof(2, index, 2, 10)
+		require.NoError(t, err)
+		require.Len(t, hashes, 4)
+		assert.True(t, VerifyProof(tree.Root(), index, 2, hashes), "index %d", index)
+		assert.False(t, VerifyProof(tree.Root(), index^2, 2, hashes), "index %d", index)
+	}
+
+	hashes, err := tree.Proof(4, 0, 2, 0)
+	require.NoError(t, err)
+	assert.Len(t, hashes, 2)
+	assert.True(t, VerifyProof(tree.Root(), 0, 2, hashes))
+
+	_, err = tree.Proof(1, 0, 2, 0)
+	assert.Error(t, err)
+	_, err = tree.Proof(2, 1, 2, 0)
+	assert.Error(t, err)
+	_, err = tree.Proof(2, 8, 2, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ContentType of the text exposition format written by Encoder.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Type of a metric family.
+type Type string
+
+const (
+	TypeCounter   Type = "counter"
+	TypeGauge     Type = "gauge"
+	TypeHistogram Type = "histogram"
+)
+
+// Label is a name and value pair that identifies a sample in a family.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Encoder writes metric families in the Prometheus text exposition format.
+// Samples of a family must be written right after its Header.
+// The first write error is kept and returned from Flush, following writes are ignored.
+type Encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// Header starts a new metric family.
+func (e *Encoder) Header(name, help string, typ Type) {
+	e.writeString("# HELP ")
+	e.writeString(name)
+	e.writeString(" ")
+	e.writeString(escapeHelp(help))
+	e.writeString("\n# TYPE ")
+	e.writeString(name)
+	e.writeString(" ")
+	e.writeString(string(typ))
+	e.writeString("\n")
+}
+
+// Sample writes a single value of a counter or gauge.
+func (e *Encoder) Sample(name string, value float64, labels ...Label) {
+	e.writeString(name)
+	e.writeLabels(labels, nil)
+	e.writeString(" ")
+	e.writeString(formatFloat(value))
+	e.writeString("\n")
+}
+
+// Histogram writes the bucket, sum and count samples of h.
+func (e *Encoder) Histogram(name string, h *Histogram, labels ...Label) {
+	var cumulative uint64
+	for i, upper := range h.buckets {
+		cumulative += h.counts[i].Load()
+		e.writeString(name)
+		e.writeString("_bucket")
+		e.writeLabels(labels, &Label{Name: "le", Value: formatFloat(upper)})
+		e.writeString(" ")
+		e.writeString(strconv.FormatUint(cumulative, 10))
+		e.writeString("\n")
+	}
+
+	count := h.count.Load()
+	e.writeString(name)
+	e.writeString("_bucket")
+	e.writeLabels(labels, &Label{Name: "le", Value: "+Inf"})
+	e.writeString(" ")
+	e.writeString(strconv.FormatUint(count, 10))
+	e.writeString("\n")
+
+	e.Sample(name+"_sum", math.Float64frombits(h.sum.Load()), labels...)
+	e.Sample(name+"_count", float64(count), labels...)
+}
+
+// CounterVec writes a sample for each counter in v.
+func (e *Encoder) CounterVec(name string, v *CounterVec) {
+	v.m.RLock()
+	defer v.m.RUnlock()
+	for _, key := range sortedKeys(v.counters) {
+		lc := v.counters[key]
+		e.Sample(name, float64(lc.c.Value()), lc.labels...)
+	}
+}
+
+// HistogramVec writes the samples of each histogram in v.
+func (e *Encoder) HistogramVec(name string, v *HistogramVec) {
+	v.m.RLock()
+	defer v.m.RUnlock()
+	for _, key := range sortedKeys(v.histograms) {
+		lh := v.histograms[key]
+		e.Histogram(name, lh.h, lh.labels...)
+	}
+}
+
+// Flush writes the buffered data to the underlying writer and returns the first error occurred.
+func (e *Encoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+
+	e.err = e.w.Flush()
+	return e.err
+}
+
+func (e *Encoder) writeLabels(labels []Label, extra *Label) {
+	if len(labels) == 0 && extra == nil {
+		return
+	}
+
+	e.writeString("{")
+	for i, l := range labels {
+		if i > 0 {
+			e.writeString(",")
+		}
+		e.writeLabel(l)
+	}
+	if extra != nil {
+		if len(labels) > 0 {
+			e.writeString(",")
+		}
+		e.writeLabel(*extra)
+	}
+	e.writeString("}")
+}
+
+func (e *Encoder) writeLabel(l Label) {
+	e.writeString(l.Name)
+	e.writeString(`="`)
+	e.writeString(escapeLabelValue(l.Value))
+	e.writeString(`"`)
+}
+
+func (e *Encoder) writeString(s string) {
+	if e.err != nil {
+		return
+	}
+
+	_, e.err = e.w.WriteString(s)
+}
+
+var (
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,185 @@
+// Package metrics contains minimal metric types and an encoder for the Prometheus text exposition format.
+//
+// See https://prometheus.io/docs/instrumenting/exposition_formats/ for the format.
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counter is a value that only increases. It is safe for concurrent use.
+type Counter struct {
+	v atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+func (c *Counter) Add(n uint64) {
+	c.v.Add(n)
+}
+
+func (c *Counter) Value() uint64 {
+	return c.v.Load()
+}
+
+// DefaultDurationBuckets are the upper bounds in seconds for histograms of durations of disk and database operations.
+var DefaultDurationBuckets = []float64{.0001, .0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observed values in buckets. It is safe for concurrent use.
+type Histogram struct {
+	// Upper bounds of the buckets in increasing order. The +Inf bucket is implicit.
+	buckets []float64
+	counts  []atomic.Uint64
+	count   atomic.Uint64
+	// Bits of the float64 sum
+	sum atomic.Uint64
+}
+
+func NewHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]atomic.Uint64, len(buckets)),
+	}
+}
+
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.counts) {
+		h.counts[i].Add(1)
+	}
+	h.count.Add(1)
+
+	for {
+		old := h.sum.Load()
+		sum := math.Float64bits(math.Float64frombits(old) + v)
+		if h.sum.CompareAndSwap(old, sum) {
+			return
+		}
+	}
+}
+
+// ObserveSince observes the time elapsed since t in seconds.
+func (h *Histogram) ObserveSince(t time.Time) {
+	h.Observe(time.Since(t).Seconds())
+}
+
+// HistogramVec is a set of histograms that are partitioned by the values of labels.
+type HistogramVec struct {
+	labels     []string
+	buckets    []float64
+	m          sync.RWMutex
+	histograms map[string]*labeledHistogram
+}
+
+type labeledHistogram struct {
+	labels []Label
+	h      *Histogram
+}
+
+func NewHistogramVec(buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{
+		labels:     labels,
+		buckets:    buckets,
+		histograms: make(map[string]*labeledHistogram),
+	}
+}
+
+// With returns the histogram for the label values, creating it if needed.
+// Values must be given in the order of the labels passed to NewHistogramVec.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	key := strings.Join(values, "\xff")
+
+	v.m.RLock()
+	lh, ok := v.histograms[key]
+	v.m.RUnlock()
+	if ok {
+		return lh.h
+	}
+
+	v.m.Lock()
+	defer v.m.Unlock()
+	if lh, ok = v.histograms[key]; ok {
+		return lh.h
+	}
+	lh = &labeledHistogram{
+		labels: makeLabels(v.labels, values),
+		h:      NewHistogram(v.buckets),
+	}
+	v.histograms[key] = lh
+
+	return lh.h
+}
+
+// CounterVec is a set of counters that are partitioned by the values of labels.
+type CounterVec struct {
+	labels   []string
+	m        sync.RWMutex
+	counters map[string]*labeledCounter
+}
+
+type labeledCounter struct {
+	labels []Label
+	c      *Counter
+}
+
+func NewCounterVec(labels ...string) *CounterVec {
+	return &CounterVec{
+		labels:   labels,
+		counters: make(map[string]*labeledCounter),
+	}
+}
+
+// With returns the counter for the label values, creating it if needed.
+// Values must be given in the order of the labels passed to NewCounterVec.
+func (v *CounterVec) With(values ...string) *Counter {
+	key := strings.Join(values, "\xff")
+
+	v.m.RLock()
+	lc, ok := v.counters[key]
+	v.m.RUnlock()
+	if ok {
+		return lc.c
+	}
+
+	v.m.Lock()
+	defer v.m.Unlock()
+	if lc, ok = v.counters[key]; ok {
+		return lc.c
+	}
+	lc = &labeledCounter{
+		labels: makeLabels(v.labels, values),
+		c:      new(Counter),
+	}
+	v.counters[key] = lc
+
+	return lc.c
+}
+
+func makeLabels(names, values []string) []Label {
+	labels := make([]Label, len(names))
+	for i, name := range names {
+		labels[i] = Label{Name: name}
+		if i < len(values) {
+			labels[i].Value = values[i]
+		}
+	}
+
+	return labels
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoder(t *testing.T) {
+	announces := NewCounterVec("host", "result")
+	announces.With("tracker.example.com", "success").Add(3)
+	announces.With("tracker.example.com", "failure").Inc()
+
+	h := NewHistogram([]float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(2)
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.Header("test_bytes_total", "Total bytes.\nSecond line.", TypeCounter)
+	e.Sample("test_bytes_total", 1024, Label{Name: "torrent", Value: `a "quoted" \ name`})
+	e.Header("test_announces_total", "Announces.", TypeCounter)
+	e.CounterVec("test_announces_total", announces)
+	e.Header("test_latency_seconds", "Latency.", TypeHistogram)
+	e.Histogram("test_latency_seconds", h, Label{Name: "op", Value: "read"})
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP test_bytes_total Total bytes.\nSecond line.
+# TYPE test_bytes_total counter
+test_bytes_total{torrent="a \"quoted\" \\ name"} 1024
+# HELP test_announces_total Announces.
+# TYPE test_announces_total counter
+test_announces_total{host="tracker.example.com",result="failure"} 1
+test_announces_total{host="tracker.example.com",result="success"} 3
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{op="read",le="0.1"} 1
+test_latency_seconds_bucket{op="read",le="1"} 2
+test_latency_seconds_bucket{op="read",le="+Inf"} 3
+test_latency_seconds_sum{op="read"} 2.55
+test_latency_seconds_count{op="read"} 3
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestHistogramBucketBoundary(t *testing.T) {
+	h := NewHistogram([]float64{1, 2})
+	// Upper bounds are inclusive
+	h.Observe(1)
+	h.Observe(2)
+	assert.Equal(t, uint64(1), h.counts[0].Load())
+	assert.Equal(t, uint64(1), h.counts[1].Load())
+	assert.Equal(t, uint64(2), h.count.Load())
+}
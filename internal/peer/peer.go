@@ -0,0 +1,32 @@
+package peer
+
+import (
+	"net"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/ratelimit"
+)
+
+// Peer is a remote peer that completed the handshake for a torrent.
+type Peer struct {
+	net.Conn
+	ID          [20]byte
+	Source      Source
+	ConnectedAt time.Time
+
+	// Limiters of the reads and writes of the connection
+	DownloadLimiter *ratelimit.Limiter
+	UploadLimiter   *ratelimit.Limiter
+}
+
+// New returns a Peer with the connection limited by the download and upload limiters.
+func New(conn net.Conn, id [20]byte, source Source, download, upload *ratelimit.Limiter) *Peer {
+	return &Peer{
+		Conn:            ratelimit.NewConn(conn, download, upload),
+		ID:              id,
+		Source:          source,
+		ConnectedAt:     time.Now(),
+		DownloadLimiter: download,
+		UploadLimiter:   upload,
+	}
+}
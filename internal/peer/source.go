@@ -31,3 +31,8 @@ func (s Source) String() string {
 		panic("unhandled source")
 	}
 }
+
+// MarshalText implements encoding.TextMarshaler so Source can be used as a map key in JSON.
+func (s Source) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
@@ -0,0 +1,184 @@
+// Package peerprotocol contains the messages of the peer wire protocol.
+package peerprotocol
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+// MessageID is the first byte of a peer message.
+type MessageID uint8
+
+// Peer message types
+const (
+	Choke MessageID = iota
+	Unchoke
+	Interested
+	NotInterested
+	Have
+	Bitfield
+	Request
+	Piece
+	Cancel
+	Port
+	Suggest     MessageID = 13
+	HaveAll     MessageID = 14
+	HaveNone    MessageID = 15
+	Reject      MessageID = 16
+	AllowedFast MessageID = 17
+	Extension   MessageID = 20
+	HashRequest MessageID = 21
+	Hashes      MessageID = 22
+	HashReject  MessageID = 23
+)
+
+var messageIDStrings = map[MessageID]string{
+	Choke:         "choke",
+	Unchoke:       "unchoke",
+	Interested:    "interested",
+	NotInterested: "not interested",
+	Have:          "have",
+	Bitfield:      "bitfield",
+	Request:       "request",
+	Piece:         "piece",
+	Cancel:        "cancel",
+	Port:          "port",
+	Suggest:       "suggest",
+	HaveAll:       "have all",
+	HaveNone:      "have none",
+	Reject:        "reject",
+	AllowedFast:   "allowed fast",
+	Extension:     "extension",
+	HashRequest:   "hash request",
+	Hashes:        "hashes",
+	HashReject:    "hash reject",
+}
+
+func (m MessageID) String() string {
+	if s, ok := messageIDStrings[m]; ok {
+		return s
+	}
+	return "unknown(" + strconv.Itoa(int(m)) + ")"
+}
+
+// Message is a peer message that can be serialized without the length prefix and the id.
+type Message interface {
+	encoding.BinaryMarshaler
+	ID() MessageID
+}
+
+// MaxHashRequestLength is the maximum number of hashes that can be requested in a single message.
+const MaxHashRequestLength = 512
+
+const hashRangeSize = 32 + 4*4
+
+var (
+	errInvalidLength    = errors.New("invalid message length")
+	errInvalidHashRange = errors.New("invalid hash range")
+)
+
+// HashRange identifies a range of hashes in a layer of a file's merkle tree (BEP 52).
+type HashRange struct {
+	PiecesRoot [32]byte
+	// Layer of the requested hashes, 0 is the layer of 16 KiB blocks.
+	BaseLayer uint32
+	// Index of the first hash in the layer, multiple of Length.
+	Index uint32
+	// Number of hashes, a power of two.
+	Length uint32
+	// Number of uncle hash layers to include in the response.
+	ProofLayers uint32
+}
+
+// Validate returns an error if the range cannot be requested.
+func (r HashRange) Validate() error {
+	if r.Length < 2 || r.Length > MaxHashRequestLength || r.Length&(r.Length-1) != 0 || r.Index%r.Length != 0 {
+		return errInvalidHashRange
+	}
+	return nil
+}
+
+func (r HashRange) appendBinary(b []byte) []byte {
+	b = append(b, r.PiecesRoot[:]...)
+	b = binary.BigEndian.AppendUint32(b, r.BaseLayer)
+	b = binary.BigEndian.AppendUint32(b, r.Index)
+	b = binary.BigEndian.AppendUint32(b, r.Length)
+	return binary.BigEndian.AppendUint32(b, r.ProofLayers)
+}
+
+func (r *HashRange) unmarshalBinary(b []byte) {
+	copy(r.PiecesRoot[:], b)
+	r.BaseLayer = binary.BigEndian.Uint32(b[32:36])
+	r.Index = binary.BigEndian.Uint32(b[36:40])
+	r.Length = binary.BigEndian.Uint32(b[40:44])
+	r.ProofLayers = binary.BigEndian.Uint32(b[44:48])
+}
+
+// HashRequestMessage asks the peer for a range of hashes of a file.
+type HashRequestMessage struct {
+	HashRange
+}
+
+func (m HashRequestMessage) ID() MessageID { return HashRequest }
+
+func (m HashRequestMessage) MarshalBinary() ([]byte, error) {
+	return m.appendBinary(make([]byte, 0, hashRangeSize)), nil
+}
+
+func (m *HashRequestMessage) UnmarshalBinary(b []byte) error {
+	if len(b) != hashRangeSize {
+		return errInvalidLength
+	}
+	m.unmarshalBinary(b)
+	return nil
+}
+
+// HashRejectMessage is sent in response to a HashRequestMessage that cannot be served.
+type HashRejectMessage struct {
+	HashRange
+}
+
+func (m HashRejectMessage) ID() MessageID { return HashReject }
+
+func (m HashRejectMessage) MarshalBinary() ([]byte, error) {
+	return m.appendBinary(make([]byte, 0, hashRangeSize)), nil
+}
+
+func (m *HashRejectMessage) UnmarshalBinary(b []byte) error {
+	if len(b) != hashRangeSize {
+		return errInvalidLength
+	}
+	m.unmarshalBinary(b)
+	return nil
+}
+
+// HashesMessage carries the requested hashes followed by the uncle hashes needed to verify them.
+type HashesMessage struct {
+	HashRange
+	Hashes [][32]byte
+}
+
+func (m HashesMessage) ID() MessageID { return Hashes }
+
+func (m HashesMessage) MarshalBinary() ([]byte, error) {
+	b := m.appendBinary(make([]byte, 0, hashRangeSize+32*len(m.Hashes)))
+	for _, h := range m.Hashes {
+		b = append(b, h[:]...)
+	}
+	return b, nil
+}
+
+func (m *HashesMessage) UnmarshalBinary(b []byte) error {
+	if len(b) < hashRangeSize || (len(b)-hashRangeSize)%32 != 0 {
+		return errInvalidLength
+	}
+	m.unmarshalBinary(b)
+	b = b[hashRangeSize:]
+	m.Hashes = make([][32]byte, len(b)/32)
+	for i := range m.Hashes {
+		copy(m.Hashes[i][:], b[i*32:])
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package peerprotocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashMessages(t *testing.T) {
+	r := HashRange{BaseLayer: 1, Index: 4, Length: 4, ProofLayers: 2}
+	r.PiecesRoot[0] = 0xff
+	require.NoError(t, r.Validate())
+
+	b, err := HashRequestMessage{r}.MarshalBinary()
+	require.NoError(t, err)
+	assert.Len(t, b, 48)
+	var req HashRequestMessage
+	require.NoError(t, req.UnmarshalBinary(b))
+	assert.Equal(t, r, req.HashRange)
+
+	var rej HashRejectMessage
+	require.NoError(t, rej.UnmarshalBinary(b))
+	assert.Equal(t, HashReject, rej.ID())
+	assert.Error(t, rej.UnmarshalBinary(b[:47]))
+
+	m := HashesMessage{HashRange: r, Hashes: [][32]byte{{1}, {2}, {3}}}
+	b, err = m.MarshalBinary()
+	require.NoError(t, err)
+	var m2 HashesMessage
+	require.NoError(t, m2.UnmarshalBinary(b))
+	assert.Equal(t, m, m2)
+	assert.Error(t, m2.UnmarshalBinary(b[:50]))
+
+	assert.Error(t, HashRange{Index: 1, Length: 2}.Validate())
+	assert.Error(t, HashRange{Length: 3}.Validate())
+	assert.Error(t, HashRange{Length: 1024}.Validate())
+	assert.Equal(t, "hash request", HashRequest.String())
+}
@@ -0,0 +1,142 @@
+// Package piece maps the pieces of a torrent to the sections of the files they are stored in.
+package piece
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"hash"
+	"io"
+
+	"github.com/al002/zbittorrent/internal/allocator"
+	"github.com/al002/zbittorrent/internal/merkle"
+	"github.com/al002/zbittorrent/internal/storage"
+	"github.com/al002/zbittorrent/pkg/metainfo"
+)
+
+// Piece of a torrent.
+type Piece struct {
+	Index  uint32
+	Length uint32
+	Hash   []byte
+	// SHA-256 merkle hash of v2 torrents and the number of 16 KiB leaves it covers
+	HashV2   []byte
+	LeavesV2 int
+	// Sections of files that this piece is stored in
+	Data Sections
+}
+
+// Section of a file that a piece is stored in.
+type Section struct {
+	File       storage.File
+	Name       string
+	FileOffset int64
+	Length     int64
+	Padding    bool
+}
+
+// Sections is a list of Section that can be read or written as a whole.
+type Sections []Section
+
+// NewPieces returns the pieces of the torrent, mapped over the allocated files.
+func NewPieces(info *metainfo.Info, files []allocator.File) []Piece {
+	pieces := make([]Piece, info.NumPieces)
+
+	var fileIndex int
+	var fileOffset int64
+	for i := uint32(0); i < info.NumPieces; i++ {
+		length := int64(info.PieceLength)
+		if remaining := info.Length - int64(i)*int64(info.PieceLength); remaining < length {
+			length = remaining
+		}
+
+		p := &pieces[i]
+		p.Index = i
+		p.Length = uint32(length)
+		p.Hash = info.PieceHash(i)
+		p.HashV2, p.LeavesV2 = info.PieceHashV2(i)
+
+		for need := length; need > 0; {
+			// Skip finished and zero-length files
+			for fileOffset == info.Files[fileIndex].Length {
+				fileIndex++
+				fileOffset = 0
+			}
+
+			n := min(need, info.Files[fileIndex].Length-fileOffset)
+			p.Data = append(p.Data, Section{
+				File:       files[fileIndex].Storage,
+				Name:       files[fileIndex].Name,
+				FileOffset: fileOffset,
+				Length:     n,
+				Padding:    files[fileIndex].Padding,
+			})
+			fileOffset += n
+			need -= n
+		}
+	}
+
+	return pieces
+}
+
+// VerifyHash returns true if the hash of buf matches the piece hash.
+// The SHA-1 hash is preferred, v2 only torrents are checked with the merkle hash.
+func (p *Piece) VerifyHash(buf []byte, h hash.Hash) bool {
+	if uint32(len(buf)) != p.Length {
+		return false
+	}
+
+	if p.Hash == nil {
+		return p.verifyHashV2(buf)
+	}
+
+	h.Reset()
+	_, _ = h.Write(buf)
+
+	return bytes.Equal(h.Sum(nil), p.Hash)
+}
+
+func (p *Piece) verifyHashV2(buf []byte) bool {
+	if p.HashV2 == nil {
+		return false
+	}
+
+	// Padding after the end of the file is not part of the merkle tree
+	var n int64
+	for _, sec := range p.Data {
+		if !sec.Padding {
+			n += sec.Length
+		}
+	}
+
+	sum := merkle.PieceHash(buf[:n], p.LeavesV2)
+	return bytes.Equal(sum[:], p.HashV2)
+}
+
+// NewHash returns the hash function that is used for verifying pieces.
+func NewHash() hash.Hash {
+	return sha1.New()
+}
+
+// ReadFull reads len(buf) bytes from the sections into buf.
+func (s Sections) ReadFull(buf []byte) error {
+	var pos int64
+	for _, sec := range s {
+		if pos >= int64(len(buf)) {
+			return nil
+		}
+
+		n := min(sec.Length, int64(len(buf))-pos)
+		_, err := sec.File.ReadAt(buf[pos:pos+n], sec.FileOffset)
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		pos += n
+	}
+
+	if pos < int64(len(buf)) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+// Package piecepicker decides which piece to request from a peer next.
+//
+// Pieces are picked rarest first among the wanted pieces with the highest priority.
+// In sequential mode the piece with the lowest index is picked instead of the rarest one.
+// Pieces with a deadline are picked before all others, earliest deadline first,
+// and may be requested from more than one fast peer when the deadline is near.
+package piecepicker
+
+import (
+	"time"
+
+	"github.com/al002/zbittorrent/internal/bitfield"
+)
+
+const (
+	// DuplicateWindow is the time before a deadline in which a piece may be requested from another fast peer.
+	DuplicateWindow = 5 * time.Second
+	// MaxDuplicateRequests is the number of peers a piece with a near deadline may be requested from at once.
+	MaxDuplicateRequests = 2
+)
+
+type PiecePicker struct {
+	// Pieces we have, shared with the torrent and not modified here
+	have *bitfield.Bitfield
+	// Number of peers that have each piece
+	availability []int
+	// Zero means the piece is not wanted, higher values are picked first
+	priorities []int
+	// Number of peers that each piece is requested from
+	requested []int
+	deadlines map[uint32]time.Time
+
+	sequential bool
+}
+
+// New returns a picker for the pieces that are missing in have. All pieces are wanted with priority 1.
+func New(have *bitfield.Bitfield) *PiecePicker {
+	n := have.Len()
+	p := &PiecePicker{
+		have:         have,
+		availability: make([]int, n),
+		priorities:   make([]int, n),
+		requested:    make([]int, n),
+		deadlines:    make(map[uint32]time.Time),
+	}
+	for i := range p.priorities {
+		p.priorities[i] = 1
+	}
+	return p
+}
+
+// SetPriorities changes the priorities of the pieces, one value for each piece.
+func (p *PiecePicker) SetPriorities(l []int) {
+	copy(p.priorities, l)
+}
+
+// SetSequential makes the picker pick pieces in order of their index instead of rarest first.
+func (p *PiecePicker) SetSequential(value bool) {
+	p.sequential = value
+}
+
+// SetDeadline makes the piece picked before the others. Zero time clears the deadline.
+func (p *PiecePicker) SetDeadline(i uint32, deadline time.Time) {
+	if deadline.IsZero() {
+		delete(p.deadlines, i)
+		return
+	}
+	p.deadlines[i] = deadline
+}
+
+// HandleHave must be called when a peer announces that it has a piece.
+func (p *PiecePicker) HandleHave(i uint32) {
+	p.availability[i]++
+}
+
+// HandleBitfield must be called with the pieces of a new peer.
+func (p *PiecePicker) HandleBitfield(b *bitfield.Bitfield) {
+	for i := range p.availability {
+		if b.Test(uint32(i)) {
+			p.availability[i]++
+		}
+	}
+}
+
+// HandleDisconnect must be called with the pieces of a disconnected peer.
+func (p *PiecePicker) HandleDisconnect(b *bitfield.Bitfield) {
+	for i := range p.availability {
+		if b.Test(uint32(i)) {
+			p.availability[i]--
+		}
+	}
+}
+
+// HandleCancel must be called when a request returned by Pick is cancelled or the peer is gone.
+func (p *PiecePicker) HandleCancel(i uint32) {
+	if p.requested[i] > 0 {
+		p.requested[i]--
+	}
+}
+
+// HandlePieceDone must be called after the piece is written and verified.
+// Duplicate requests of the piece should be cancelled by the caller.
+func (p *PiecePicker) HandlePieceDone(i uint32) {
+	p.requested[i] = 0
+	delete(p.deadlines, i)
+}
+
+// Pick returns the next piece to request from a peer that has the pieces in peer.
+// Fast peers may be given a piece that is already requested from another peer if its deadline is near.
+// Returns false if the peer has no piece that we want.
+func (p *PiecePicker) Pick(peer *bitfield.Bitfield, fast bool, now time.Time) (uint32, bool) {
+	if i, ok := p.pickDeadline(peer, fast, now); ok {
+		p.requested[i]++
+		return i, true
+	}
+
+	var best uint32
+	found := false
+	for j := range p.priorities {
+		i := uint32(j)
+		if !p.available(i, peer) || p.requested[i] > 0 || p.priorities[i] <= 0 {
+			continue
+		}
+		if !found || p.better(i, best) {
+			best = i
+			found = true
+		}
+	}
+	if found {
+		p.requested[best]++
+	}
+	return best, found
+}
+
+func (p *PiecePicker) pickDeadline(peer *bitfield.Bitfield, fast bool, now time.Time) (uint32, bool) {
+	var best uint32
+	var bestDeadline time.Time
+	for i, deadline := range p.deadlines {
+		// Deadlines of pieces that are not wanted are kept in case the priority changes again
+		if !p.available(i, peer) || p.priorities[i] <= 0 {
+			continue
+		}
+		if n := p.requested[i]; n > 0 {
+			duplicate := fast && n < MaxDuplicateRequests && deadline.Sub(now) < DuplicateWindow
+			if !duplicate {
+				continue
+			}
+		}
+		if bestDeadline.IsZero() || deadline.Before(bestDeadline) || (deadline.Equal(bestDeadline) && i < best) {
+			best = i
+			bestDeadline = deadline
+		}
+	}
+	return best, !bestDeadline.IsZero()
+}
+
+// available returns true if the peer has the piece and we don't.
+func (p *PiecePicker) available(i uint32, peer *bitfield.Bitfield) bool {
+	return !p.have.Test(i) && peer.Test(i)
+}
+
+// better returns true if piece i should be picked before piece j.
+func (p *PiecePicker) better(i, j uint32) bool {
+	if p.priorities[i] != p.priorities[j] {
+		return p.priorities[i] > p.priorities[j]
+	}
+	if !p.sequential && p.availability[i] != p.availability[j] {
+		return p.availability[i] < p.availability[j]
+	}
+	return i < j
+}
@@ -0,0 +1,121 @@
+package piecepicker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/bitfield"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBitfield(n uint32, set ...uint32) *bitfield.Bitfield {
+	b := bitfield.New(n)
+	for _, i := range set {
+		b.Set(i)
+	}
+	return b
+}
+
+func pick(t *testing.T, p *PiecePicker, peer *bitfield.Bitfield, fast bool, now time.Time) uint32 {
+	t.Helper()
+	i, ok := p.Pick(peer, fast, now)
+	assert.True(t, ok)
+	return i
+}
+
+func TestRarestFirst(t *testing.T) {
+	p := New(newBitfield(4, 0))
+	p.HandleBitfield(newBitfield(4, 0, 1, 2, 3))
+	p.HandleBitfield(newBitfield(4, 1, 3))
+	p.HandleHave(1)
+
+	peer := newBitfield(4, 0, 1, 2, 3)
+	now := time.Now()
+	// Piece 0 is ours, 2 has the lowest availability, then 3
+	assert.Equal(t, uint32(2), pick(t, p, peer, false, now))
+	assert.Equal(t, uint32(3), pick(t, p, peer, false, now))
+	assert.Equal(t, uint32(1), pick(t, p, peer, false, now))
+	_, ok := p.Pick(peer, false, now)
+	assert.False(t, ok)
+
+	// Cancelled requests can be picked again
+	p.HandleCancel(3)
+	assert.Equal(t, uint32(3), pick(t, p, peer, false, now))
+
+	p.HandleDisconnect(newBitfield(4, 1, 3))
+	p.HandleCancel(1)
+	p.HandleCancel(2)
+	p.HandleCancel(3)
+	assert.Equal(t, uint32(2), pick(t, p, peer, false, now))
+}
+
+func TestPriorities(t *testing.T) {
+	p := New(newBitfield(4))
+	p.HandleBitfield(newBitfield(4, 0, 1, 2))
+	p.SetPriorities([]int{1, 2, 1, 0})
+
+	peer := newBitfield(4, 0, 1, 2, 3)
+	now := time.Now()
+	assert.Equal(t, uint32(1), pick(t, p, peer, false, now))
+	assert.Equal(t, uint32(0), pick(t, p, peer, false, now))
+	assert.Equal(t, uint32(2), pick(t, p, peer, false, now))
+	// Piece 3 is not wanted
+	_, ok := p.Pick(peer, false, now)
+	assert.False(t, ok)
+}
+
+func TestSequential(t *testing.T) {
+	p := New(newBitfield(4, 1))
+	p.HandleBitfield(newBitfield(4, 0, 1))
+	p.SetSequential(true)
+
+	peer := newBitfield(4, 0, 1, 2, 3)
+	now := time.Now()
+	assert.Equal(t, uint32(0), pick(t, p, peer, false, now))
+	assert.Equal(t, uint32(2), pick(t, p, peer, false, now))
+	assert.Equal(t, uint32(3), pick(t, p, peer, false, now))
+}
+
+func TestDeadline(t *testing.T) {
+	p := New(newBitfield(6))
+	p.SetSequential(true)
+	now := time.Now()
+	p.SetDeadline(4, now.Add(time.Minute))
+	p.SetDeadline(5, now.Add(2*time.Second))
+	p.SetDeadline(3, now.Add(time.Hour))
+	p.SetDeadline(3, time.Time{})
+
+	peer := newBitfield(6, 0, 1, 2, 3, 4, 5)
+	assert.Equal(t, uint32(5), pick(t, p, peer, false, now))
+	assert.Equal(t, uint32(4), pick(t, p, peer, false, now))
+	assert.Equal(t, uint32(0), pick(t, p, peer, false, now))
+
+	// Only a fast peer gets a duplicate request of the piece with a near deadline, and only once
+	assert.Equal(t, uint32(5), pick(t, p, peer, true, now))
+	assert.Equal(t, uint32(1), pick(t, p, peer, true, now))
+
+	p.HandlePieceDone(5)
+	p.HandleCancel(4)
+	assert.Equal(t, uint32(4), pick(t, p, peer, true, now))
+
+	// Deadlines are not picked from peers that don't have the piece
+	p.SetDeadline(2, now)
+	assert.Equal(t, uint32(3), pick(t, p, newBitfield(6, 3), true, now))
+}
+
+func TestDeadlineNotWanted(t *testing.T) {
+	p := New(newBitfield(3))
+	now := time.Now()
+	p.SetDeadline(2, now.Add(time.Second))
+	p.SetPriorities([]int{1, 1, 0})
+
+	peer := newBitfield(3, 0, 1, 2)
+	assert.Equal(t, uint32(0), pick(t, p, peer, true, now))
+	assert.Equal(t, uint32(1), pick(t, p, peer, true, now))
+	_, ok := p.Pick(peer, true, now)
+	assert.False(t, ok)
+
+	// The deadline applies again when the piece is wanted
+	p.SetPriorities([]int{1, 1, 1})
+	assert.Equal(t, uint32(2), pick(t, p, peer, true, now))
+}
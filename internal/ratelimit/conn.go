@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+)
+
+// Conn is a net.Conn whose reads and writes are limited.
+type Conn struct {
+	net.Conn
+	read, write *Limiter
+	ctx         context.Context
+	cancel      context.CancelFunc
+}
+
+// NewConn returns a Conn that limits reads with read and writes with write. Limiters may be nil.
+func NewConn(conn net.Conn, read, write *Limiter) *Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Conn{
+		Conn:   conn,
+		read:   read,
+		write:  write,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Read reads from the connection and waits until the read bytes are allowed by the limiter.
+func (c *Conn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 && c.read != nil {
+		if werr := c.read.WaitN(c.ctx, n); werr != nil && err == nil {
+			err = net.ErrClosed
+		}
+	}
+	return n, err
+}
+
+// Write waits until len(p) bytes are allowed by the limiter and writes them.
+func (c *Conn) Write(p []byte) (int, error) {
+	if c.write != nil {
+		if err := c.write.WaitN(c.ctx, len(p)); err != nil {
+			return 0, net.ErrClosed
+		}
+	}
+	return c.Conn.Write(p)
+}
+
+// Close stops waiting for the limiters and closes the connection.
+func (c *Conn) Close() error {
+	c.cancel()
+	return c.Conn.Close()
+}
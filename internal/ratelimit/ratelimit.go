@@ -0,0 +1,81 @@
+// Package ratelimit limits transfer rates in a hierarchy of limiters.
+// Bytes that pass a Limiter also count against all of its parents,
+// so a peer is limited by its own limit, the torrent limit and the global limit.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter limits the rate of bytes. The zero limit means unlimited.
+// Limits can be changed while the Limiter is in use.
+type Limiter struct {
+	parent  *Limiter
+	limiter atomic.Pointer[rate.Limiter]
+	limit   atomic.Int64
+	mu      sync.Mutex
+}
+
+// New returns a Limiter with the limit in bytes per second. Parent may be nil.
+func New(parent *Limiter, bytesPerSecond int64) *Limiter {
+	l := &Limiter{parent: parent}
+	l.SetLimit(bytesPerSecond)
+	return l
+}
+
+// SetLimit changes the limit in bytes per second. Zero or negative means unlimited.
+// Up to one second of transfer is allowed at once.
+func (l *Limiter) SetLimit(bytesPerSecond int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bytesPerSecond = max(bytesPerSecond, 0)
+	old := l.limit.Swap(bytesPerSecond)
+	switch {
+	case bytesPerSecond == 0:
+		l.limiter.Store(rate.NewLimiter(rate.Inf, 0))
+	case old == 0 || l.limiter.Load() == nil:
+		// A new limiter starts with full burst
+		l.limiter.Store(rate.NewLimiter(rate.Limit(bytesPerSecond), int(bytesPerSecond)))
+	default:
+		lim := l.limiter.Load()
+		lim.SetLimit(rate.Limit(bytesPerSecond))
+		lim.SetBurst(int(bytesPerSecond))
+	}
+}
+
+// Limit returns the limit in bytes per second. Zero means unlimited.
+func (l *Limiter) Limit() int64 {
+	return l.limit.Load()
+}
+
+// WaitN blocks until n bytes can be transferred by the Limiter and all of its parents.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	for ; l != nil; l = l.parent {
+		if err := l.waitN(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitN waits for n bytes in chunks of at most the burst size.
+func (l *Limiter) waitN(ctx context.Context, n int) error {
+	for n > 0 {
+		lim := l.limiter.Load()
+		if lim.Limit() == rate.Inf {
+			return nil
+		}
+
+		m := min(n, max(lim.Burst(), 1))
+		if err := lim.WaitN(ctx, m); err != nil {
+			return err
+		}
+		n -= m
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allowed returns true if n bytes can be transferred without waiting.
+func allowed(l *Limiter, n int) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	return l.WaitN(ctx, n) == nil
+}
+
+func TestUnlimited(t *testing.T) {
+	l := New(nil, 0)
+	assert.Equal(t, int64(0), l.Limit())
+	assert.True(t, allowed(l, 1<<30))
+}
+
+func TestHierarchy(t *testing.T) {
+	global := New(nil, 0)
+	torrent := New(global, 1000)
+	peer := New(torrent, 0)
+
+	// The burst of the torrent limiter is used by the peer
+	assert.True(t, allowed(peer, 1000))
+	assert.False(t, allowed(peer, 1000))
+	assert.True(t, allowed(global, 1<<20))
+
+	// Changing the limit of a parent is reflected in the children
+	torrent.SetLimit(0)
+	assert.True(t, allowed(peer, 1<<20))
+
+	global.SetLimit(2000)
+	peer.SetLimit(500)
+	assert.Equal(t, int64(500), peer.Limit())
+	assert.True(t, allowed(peer, 500))
+	assert.False(t, allowed(peer, 500))
+	// Bytes of the peer are counted in the global limiter
+	assert.True(t, allowed(global, 1500))
+	assert.False(t, allowed(global, 1000))
+}
+
+func TestLargerThanBurst(t *testing.T) {
+	l := New(nil, 100000)
+	start := time.Now()
+	require.NoError(t, l.WaitN(context.Background(), 120000))
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestConn(t *testing.T) {
+	client, server := net.Pipe()
+	write := New(nil, 1000)
+	c := NewConn(client, nil, write)
+
+	go func() {
+		_, _ = io.Copy(io.Discard, server)
+	}()
+
+	_, err := c.Write(make([]byte, 1000))
+	require.NoError(t, err)
+
+	// Close interrupts the write that waits for the limiter
+	errC := make(chan error, 1)
+	go func() {
+		_, err := c.Write(make([]byte, 1000))
+		errC <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	c.Close()
+	select {
+	case err = <-errC:
+		assert.ErrorIs(t, err, net.ErrClosed)
+	case <-time.After(time.Second):
+		t.Fatal("write is not interrupted")
+	}
+}
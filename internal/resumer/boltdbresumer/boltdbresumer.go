@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"go.etcd.io/bbolt"
+	berrors "go.etcd.io/bbolt/errors"
 )
 
 const LatestVersion = 1
@@ -19,8 +20,11 @@ type StorageKeys struct {
 	Trackers          []byte
 	URLList           []byte
 	FixedPeers        []byte
+	AllowedIPs        []byte
+	DeniedIPs         []byte
 	Dest              []byte
 	Info              []byte
+	PieceLayers       []byte
 	Bitfield          []byte
 	AddedAt           []byte
 	BytesDownloaded   []byte
@@ -30,6 +34,10 @@ type StorageKeys struct {
 	Started           []byte
 	StopAfterDownload []byte
 	StopAfterMetadata []byte
+	SpeedLimits       []byte
+	SeedLimits        []byte
+	FilePriorities    []byte
+	Sequential        []byte
 	Version           []byte
 }
 
@@ -40,8 +48,11 @@ var Keys = StorageKeys{
 	Trackers:          []byte("trackers"),
 	URLList:           []byte("url_list"),
 	FixedPeers:        []byte("fixed_peers"),
+	AllowedIPs:        []byte("allowed_ips"),
+	DeniedIPs:         []byte("denied_ips"),
 	Dest:              []byte("dest"),
 	Info:              []byte("info"),
+	PieceLayers:       []byte("piece_layers"),
 	Bitfield:          []byte("bitfield"),
 	AddedAt:           []byte("added_at"),
 	BytesDownloaded:   []byte("bytes_downloaded"),
@@ -51,6 +62,10 @@ var Keys = StorageKeys{
 	Started:           []byte("started"),
 	StopAfterDownload: []byte("stop_after_download"),
 	StopAfterMetadata: []byte("stop_after_metadata"),
+	SpeedLimits:       []byte("speed_limits"),
+	SeedLimits:        []byte("seed_limits"),
+	FilePriorities:    []byte("file_priorities"),
+	Sequential:        []byte("sequential"),
 	Version:           []byte("version"),
 }
 
@@ -91,6 +106,31 @@ func (r *Resumer) Write(torrentID string, spec *Spec) error {
 		return err
 	}
 
+	allowedIPs, err := json.Marshal(spec.AllowedIPs)
+	if err != nil {
+		return err
+	}
+
+	deniedIPs, err := json.Marshal(spec.DeniedIPs)
+	if err != nil {
+		return err
+	}
+
+	speedLimits, err := json.Marshal(spec.SpeedLimits)
+	if err != nil {
+		return err
+	}
+
+	seedLimits, err := json.Marshal(spec.SeedLimits)
+	if err != nil {
+		return err
+	}
+
+	filePriorities, err := json.Marshal(spec.FilePriorities)
+	if err != nil {
+		return err
+	}
+
 	version := LatestVersion
 	if spec.Version != 0 {
 		version = spec.Version
@@ -108,7 +148,10 @@ func (r *Resumer) Write(torrentID string, spec *Spec) error {
 		_ = b.Put(Keys.Trackers, trackers)
 		_ = b.Put(Keys.URLList, urlList)
 		_ = b.Put(Keys.FixedPeers, fixedPeers)
+		_ = b.Put(Keys.AllowedIPs, allowedIPs)
+		_ = b.Put(Keys.DeniedIPs, deniedIPs)
 		_ = b.Put(Keys.Info, spec.Info)
+		_ = b.Put(Keys.PieceLayers, spec.PieceLayers)
 		_ = b.Put(Keys.Bitfield, spec.Bitfield)
 		_ = b.Put(Keys.AddedAt, []byte(spec.AddedAt.Format(time.RFC3339)))
 		_ = b.Put(Keys.BytesDownloaded, []byte(strconv.FormatInt(spec.BytesDownloaded, 10)))
@@ -118,6 +161,10 @@ func (r *Resumer) Write(torrentID string, spec *Spec) error {
 		_ = b.Put(Keys.Started, []byte(strconv.FormatBool(spec.Started)))
 		_ = b.Put(Keys.StopAfterDownload, []byte(strconv.FormatBool(spec.StopAfterDownload)))
 		_ = b.Put(Keys.StopAfterMetadata, []byte(strconv.FormatBool(spec.StopAfterMetadata)))
+		_ = b.Put(Keys.SpeedLimits, speedLimits)
+		_ = b.Put(Keys.SeedLimits, seedLimits)
+		_ = b.Put(Keys.FilePriorities, filePriorities)
+		_ = b.Put(Keys.Sequential, []byte(strconv.FormatBool(spec.Sequential)))
 		_ = b.Put(Keys.Version, []byte(strconv.Itoa(version)))
 		return nil
 
@@ -154,6 +201,123 @@ func (r *Resumer) WriteStarted(torrentID string, value bool) error {
 	})
 }
 
+// WriteStopAfterDownload saves whether the torrent is stopped when its download is completed.
+func (r *Resumer) WriteStopAfterDownload(torrentID string, value bool) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.StopAfterDownload, []byte(strconv.FormatBool(value)))
+	})
+}
+
+// WriteStats saves the transfer counters of the torrent.
+func (r *Resumer) WriteStats(torrentID string, value Stats) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		_ = b.Put(Keys.BytesDownloaded, []byte(strconv.FormatInt(value.BytesDownloaded, 10)))
+		_ = b.Put(Keys.BytesUploaded, []byte(strconv.FormatInt(value.BytesUploaded, 10)))
+		_ = b.Put(Keys.BytesWasted, []byte(strconv.FormatInt(value.BytesWasted, 10)))
+		return b.Put(Keys.SeededFor, []byte(value.SeededFor.String()))
+	})
+}
+
+// WriteIPFilter saves the allow and deny rules of the torrent.
+func (r *Resumer) WriteIPFilter(torrentID string, allowed, denied []string) error {
+	allowedIPs, err := json.Marshal(allowed)
+	if err != nil {
+		return err
+	}
+	deniedIPs, err := json.Marshal(denied)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		if err := b.Put(Keys.AllowedIPs, allowedIPs); err != nil {
+			return err
+		}
+		return b.Put(Keys.DeniedIPs, deniedIPs)
+	})
+}
+
+// WriteSpeedLimits saves the speed limits of the torrent.
+func (r *Resumer) WriteSpeedLimits(torrentID string, value SpeedLimits) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.SpeedLimits, data)
+	})
+}
+
+// WriteSeedLimits saves the seed limits of the torrent.
+func (r *Resumer) WriteSeedLimits(torrentID string, value SeedLimits) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.SeedLimits, data)
+	})
+}
+
+// WriteFilePriorities saves the priorities of the files in the torrent.
+func (r *Resumer) WriteFilePriorities(torrentID string, value []string) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.FilePriorities, data)
+	})
+}
+
+// WriteSequential saves whether the pieces of the torrent are downloaded in order.
+func (r *Resumer) WriteSequential(torrentID string, value bool) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.Sequential, []byte(strconv.FormatBool(value)))
+	})
+}
+
+func (r *Resumer) Delete(torrentID string) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		err := tx.Bucket(r.bucket).DeleteBucket([]byte(torrentID))
+		if err == berrors.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
 func (r *Resumer) Read(torrentID string) (spec *Spec, err error) {
 	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
 	defer func() {
@@ -212,12 +376,34 @@ func (r *Resumer) Read(torrentID string) (spec *Spec, err error) {
 			}
 		}
 
+		value = b.Get(Keys.AllowedIPs)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.AllowedIPs)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.DeniedIPs)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.DeniedIPs)
+			if err != nil {
+				return err
+			}
+		}
+
 		value = b.Get(Keys.Info)
 		if value != nil {
 			spec.Info = make([]byte, len(value))
 			copy(spec.Info, value)
 		}
 
+		value = b.Get(Keys.PieceLayers)
+		if value != nil {
+			spec.PieceLayers = make([]byte, len(value))
+			copy(spec.PieceLayers, value)
+		}
+
 		value = b.Get(Keys.Bitfield)
 		if value != nil {
 			spec.Bitfield = make([]byte, len(value))
@@ -288,6 +474,38 @@ func (r *Resumer) Read(torrentID string) (spec *Spec, err error) {
 			}
 		}
 
+		value = b.Get(Keys.SpeedLimits)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.SpeedLimits)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.SeedLimits)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.SeedLimits)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.FilePriorities)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.FilePriorities)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.Sequential)
+		if value != nil {
+			spec.Sequential, err = strconv.ParseBool(string(value))
+			if err != nil {
+				return err
+			}
+		}
+
 		value = b.Get(Keys.Version)
 		if value != nil {
 			spec.Version, err = strconv.Atoi(string(value))
@@ -6,6 +6,31 @@ import (
 	"time"
 )
 
+// SpeedLimits of a torrent in KB/s. Zero means unlimited.
+type SpeedLimits struct {
+	Download     int64
+	Upload       int64
+	PeerDownload int64
+	PeerUpload   int64
+}
+
+// SeedLimits of a torrent. Zero values are taken from the session, negative values disable a limit.
+type SeedLimits struct {
+	Ratio    float64
+	Time     time.Duration
+	IdleTime time.Duration
+	// "stop", "remove" or empty for the action of the session
+	Action string
+}
+
+// Stats are the transfer counters of a torrent that change while it is running.
+type Stats struct {
+	BytesDownloaded int64
+	BytesUploaded   int64
+	BytesWasted     int64
+	SeededFor       time.Duration
+}
+
 type Spec struct {
 	InfoHash          []byte
 	Port              int
@@ -13,7 +38,10 @@ type Spec struct {
 	Trackers          [][]string
 	URLList           []string
 	FixedPeers        []string
+	AllowedIPs        []string
+	DeniedIPs         []string
 	Info              []byte
+	PieceLayers       []byte
 	Bitfield          []byte
 	AddedAt           time.Time
 	BytesDownloaded   int64
@@ -23,7 +51,12 @@ type Spec struct {
 	Started           bool
 	StopAfterDownload bool
 	StopAfterMetadata bool
-	Version           int
+	SpeedLimits       SpeedLimits
+	SeedLimits        SeedLimits
+	// "skip", "low", "normal" or "high" for each file that is not padding
+	FilePriorities []string
+	Sequential     bool
+	Version        int
 }
 
 type jsonSpec struct {
@@ -32,6 +65,8 @@ type jsonSpec struct {
 	Trackers          [][]string
 	URLList           []string
 	FixedPeers        []string
+	AllowedIPs        []string
+	DeniedIPs         []string
 	AddedAt           time.Time
 	BytesDownloaded   int64
 	BytesUploaded     int64
@@ -39,13 +74,18 @@ type jsonSpec struct {
 	Started           bool
 	StopAfterDownload bool
 	StopAfterMetadata bool
+	SpeedLimits       SpeedLimits
+	SeedLimits        SeedLimits
+	FilePriorities    []string
+	Sequential        bool
 	Version           int
 
 	// JSON unsafe types
-	InfoHash  string
-	Info      string
-	Bitfield  string
-	SeededFor int64
+	InfoHash    string
+	Info        string
+	PieceLayers string
+	Bitfield    string
+	SeededFor   int64
 }
 
 func (s Spec) MarshalJSON() ([]byte, error) {
@@ -55,6 +95,8 @@ func (s Spec) MarshalJSON() ([]byte, error) {
 		Trackers:          s.Trackers,
 		URLList:           s.URLList,
 		FixedPeers:        s.FixedPeers,
+		AllowedIPs:        s.AllowedIPs,
+		DeniedIPs:         s.DeniedIPs,
 		AddedAt:           s.AddedAt,
 		BytesDownloaded:   s.BytesDownloaded,
 		BytesUploaded:     s.BytesUploaded,
@@ -62,12 +104,17 @@ func (s Spec) MarshalJSON() ([]byte, error) {
 		Started:           s.Started,
 		StopAfterDownload: s.StopAfterDownload,
 		StopAfterMetadata: s.StopAfterMetadata,
+		SpeedLimits:       s.SpeedLimits,
+		SeedLimits:        s.SeedLimits,
+		FilePriorities:    s.FilePriorities,
+		Sequential:        s.Sequential,
 		Version:           s.Version,
 
-		InfoHash:  base64.StdEncoding.EncodeToString(s.InfoHash),
-		Info:      base64.StdEncoding.EncodeToString(s.Info),
-		Bitfield:  base64.StdEncoding.EncodeToString(s.Bitfield),
-		SeededFor: int64(s.SeededFor),
+		InfoHash:    base64.StdEncoding.EncodeToString(s.InfoHash),
+		Info:        base64.StdEncoding.EncodeToString(s.Info),
+		PieceLayers: base64.StdEncoding.EncodeToString(s.PieceLayers),
+		Bitfield:    base64.StdEncoding.EncodeToString(s.Bitfield),
+		SeededFor:   int64(s.SeededFor),
 	}
 	return json.Marshal(j)
 }
@@ -86,6 +133,10 @@ func (s *Spec) UnmarshalJSON(b []byte) error {
 	if err != nil {
 		return err
 	}
+	s.PieceLayers, err = base64.StdEncoding.DecodeString(j.PieceLayers)
+	if err != nil {
+		return err
+	}
 	s.Bitfield, err = base64.StdEncoding.DecodeString(j.Bitfield)
 	if err != nil {
 		return err
@@ -96,6 +147,8 @@ func (s *Spec) UnmarshalJSON(b []byte) error {
 	s.Trackers = j.Trackers
 	s.URLList = j.URLList
 	s.FixedPeers = j.FixedPeers
+	s.AllowedIPs = j.AllowedIPs
+	s.DeniedIPs = j.DeniedIPs
 	s.AddedAt = j.AddedAt
 	s.BytesDownloaded = j.BytesDownloaded
 	s.BytesUploaded = j.BytesUploaded
@@ -103,6 +156,10 @@ func (s *Spec) UnmarshalJSON(b []byte) error {
 	s.Started = j.Started
 	s.StopAfterDownload = j.StopAfterDownload
 	s.StopAfterMetadata = j.StopAfterMetadata
+	s.SpeedLimits = j.SpeedLimits
+	s.SeedLimits = j.SeedLimits
+	s.FilePriorities = j.FilePriorities
+	s.Sequential = j.Sequential
 	s.Version = j.Version
 	return nil
 }
@@ -0,0 +1,246 @@
+// Package rpcclient provides a client for the JSON-RPC API of the zbittorrent daemon.
+package rpcclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/jsonrpc"
+	"github.com/al002/zbittorrent/internal/rpctypes"
+)
+
+type Options struct {
+	// URL of the JSON-RPC endpoint, e.g. http://127.0.0.1:7246/rpc
+	URL string
+	// If set, requests are sent over this Unix socket and the host in URL is ignored.
+	UnixSocket string
+	// Sent as bearer token if set.
+	Token string
+	// Timeout of each call.
+	Timeout time.Duration
+}
+
+type Client struct {
+	client *jsonrpc.Client
+}
+
+func New(opts *Options) *Client {
+	httpClient := &http.Client{Timeout: opts.Timeout}
+	if opts.UnixSocket != "" {
+		httpClient.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", opts.UnixSocket)
+			},
+		}
+	}
+
+	c := jsonrpc.NewClient(opts.URL, httpClient)
+	if opts.Token != "" {
+		c.Header.Set("Authorization", "Bearer "+opts.Token)
+	}
+
+	return &Client{client: c}
+}
+
+func (c *Client) AddTorrent(r io.Reader, opts *rpctypes.AddTorrentOptions) (*rpctypes.Torrent, error) {
+	var buf bytes.Buffer
+	enc := base64.NewEncoder(base64.StdEncoding, &buf)
+	_, err := io.Copy(enc, r)
+	if err != nil {
+		return nil, err
+	}
+	err = enc.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	args := rpctypes.AddTorrentRequest{Torrent: buf.String()}
+	if opts != nil {
+		args.AddTorrentOptions = *opts
+	}
+	var reply rpctypes.AddTorrentResponse
+	return &reply.Torrent, c.client.Call("Session.AddTorrent", args, &reply)
+}
+
+func (c *Client) AddURI(uri string, opts *rpctypes.AddTorrentOptions) (*rpctypes.Torrent, error) {
+	args := rpctypes.AddURIRequest{URI: uri}
+	if opts != nil {
+		args.AddTorrentOptions = *opts
+	}
+	var reply rpctypes.AddURIResponse
+	return &reply.Torrent, c.client.Call("Session.AddURI", args, &reply)
+}
+
+func (c *Client) ListTorrents() ([]rpctypes.Torrent, error) {
+	var reply rpctypes.ListTorrentsResponse
+	return reply.Torrents, c.client.Call("Session.ListTorrents", nil, &reply)
+}
+
+func (c *Client) RemoveTorrent(id string) error {
+	args := rpctypes.RemoveTorrentRequest{ID: id}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.RemoveTorrent", args, &reply)
+}
+
+func (c *Client) GetSessionStats() (*rpctypes.SessionStats, error) {
+	var reply rpctypes.GetSessionStatsResponse
+	return &reply.Stats, c.client.Call("Session.GetSessionStats", nil, &reply)
+}
+
+func (c *Client) GetSpeedLimits() (*rpctypes.SpeedLimits, error) {
+	var reply rpctypes.GetSpeedLimitsResponse
+	return &reply.SpeedLimits, c.client.Call("Session.GetSpeedLimits", nil, &reply)
+}
+
+func (c *Client) SetSpeedLimits(limits rpctypes.SpeedLimits) error {
+	args := rpctypes.SetSpeedLimitsRequest{SpeedLimits: limits}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.SetSpeedLimits", args, &reply)
+}
+
+func (c *Client) GetSeedLimits() (*rpctypes.SeedLimits, error) {
+	var reply rpctypes.GetSeedLimitsResponse
+	return &reply.SeedLimits, c.client.Call("Session.GetSeedLimits", nil, &reply)
+}
+
+func (c *Client) SetSeedLimits(limits rpctypes.SeedLimits) error {
+	args := rpctypes.SetSeedLimitsRequest{SeedLimits: limits}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.SetSeedLimits", args, &reply)
+}
+
+func (c *Client) GetTorrentSeedLimits(id string) (*rpctypes.SeedLimits, error) {
+	args := rpctypes.GetTorrentSeedLimitsRequest{ID: id}
+	var reply rpctypes.GetSeedLimitsResponse
+	return &reply.SeedLimits, c.client.Call("Session.GetTorrentSeedLimits", args, &reply)
+}
+
+func (c *Client) SetTorrentSeedLimits(id string, limits rpctypes.SeedLimits) error {
+	args := rpctypes.SetTorrentSeedLimitsRequest{ID: id, SeedLimits: limits}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.SetTorrentSeedLimits", args, &reply)
+}
+
+func (c *Client) GetQueueLimits() (*rpctypes.QueueLimits, error) {
+	var reply rpctypes.GetQueueLimitsResponse
+	return &reply.QueueLimits, c.client.Call("Session.GetQueueLimits", nil, &reply)
+}
+
+func (c *Client) SetQueueLimits(limits rpctypes.QueueLimits) error {
+	args := rpctypes.SetQueueLimitsRequest{QueueLimits: limits}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.SetQueueLimits", args, &reply)
+}
+
+func (c *Client) MoveTorrentInQueue(id, move string) error {
+	args := rpctypes.MoveTorrentInQueueRequest{ID: id, Move: move}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.MoveTorrentInQueue", args, &reply)
+}
+
+func (c *Client) GetAltSpeed() (*rpctypes.AltSpeed, error) {
+	var reply rpctypes.GetAltSpeedResponse
+	return &reply.AltSpeed, c.client.Call("Session.GetAltSpeed", nil, &reply)
+}
+
+func (c *Client) SetAltSpeed(a rpctypes.AltSpeed) error {
+	args := rpctypes.SetAltSpeedRequest{AltSpeed: a}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.SetAltSpeed", args, &reply)
+}
+
+func (c *Client) GetTorrentSpeedLimits(id string) (*rpctypes.SpeedLimits, error) {
+	args := rpctypes.GetTorrentSpeedLimitsRequest{ID: id}
+	var reply rpctypes.GetSpeedLimitsResponse
+	return &reply.SpeedLimits, c.client.Call("Session.GetTorrentSpeedLimits", args, &reply)
+}
+
+func (c *Client) SetTorrentSpeedLimits(id string, limits rpctypes.SpeedLimits) error {
+	args := rpctypes.SetTorrentSpeedLimitsRequest{ID: id, SpeedLimits: limits}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.SetTorrentSpeedLimits", args, &reply)
+}
+
+func (c *Client) StartTorrent(id string) error {
+	args := rpctypes.StartTorrentRequest{ID: id}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.StartTorrent", args, &reply)
+}
+
+func (c *Client) StopTorrent(id string) error {
+	args := rpctypes.StopTorrentRequest{ID: id}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.StopTorrent", args, &reply)
+}
+
+func (c *Client) VerifyTorrent(id string) error {
+	args := rpctypes.VerifyTorrentRequest{ID: id}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.VerifyTorrent", args, &reply)
+}
+
+func (c *Client) GetTorrentStats(id string) (*rpctypes.Stats, error) {
+	args := rpctypes.GetTorrentStatsRequest{ID: id}
+	var reply rpctypes.GetTorrentStatsResponse
+	return &reply.Stats, c.client.Call("Session.GetTorrentStats", args, &reply)
+}
+
+func (c *Client) GetTorrentTrackers(id string) ([]rpctypes.Tracker, error) {
+	args := rpctypes.GetTorrentTrackersRequest{ID: id}
+	var reply rpctypes.GetTorrentTrackersResponse
+	return reply.Trackers, c.client.Call("Session.GetTorrentTrackers", args, &reply)
+}
+
+func (c *Client) GetTorrentPeers(id string) ([]rpctypes.Peer, error) {
+	args := rpctypes.GetTorrentPeersRequest{ID: id}
+	var reply rpctypes.GetTorrentPeersResponse
+	return reply.Peers, c.client.Call("Session.GetTorrentPeers", args, &reply)
+}
+
+func (c *Client) GetTorrentFiles(id string) ([]rpctypes.File, error) {
+	args := rpctypes.GetTorrentFilesRequest{ID: id}
+	var reply rpctypes.GetTorrentFilesResponse
+	return reply.Files, c.client.Call("Session.GetTorrentFiles", args, &reply)
+}
+
+func (c *Client) SetTorrentFilePriorities(id string, priorities []string) error {
+	args := rpctypes.SetTorrentFilePrioritiesRequest{ID: id, Priorities: priorities}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.SetTorrentFilePriorities", args, &reply)
+}
+
+func (c *Client) SetTorrentSequential(id string, sequential bool) error {
+	args := rpctypes.SetTorrentSequentialRequest{ID: id, Sequential: sequential}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.SetTorrentSequential", args, &reply)
+}
+
+func (c *Client) SetTorrentPieceDeadline(id string, piece uint32, deadline time.Time) error {
+	args := rpctypes.SetTorrentPieceDeadlineRequest{ID: id, Piece: piece, Deadline: deadline}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.SetTorrentPieceDeadline", args, &reply)
+}
+
+func (c *Client) GetTorrentIPFilter(id string) (*rpctypes.IPFilter, error) {
+	args := rpctypes.GetTorrentIPFilterRequest{ID: id}
+	var reply rpctypes.GetTorrentIPFilterResponse
+	return &reply.IPFilter, c.client.Call("Session.GetTorrentIPFilter", args, &reply)
+}
+
+func (c *Client) SetTorrentIPFilter(id string, filter rpctypes.IPFilter) error {
+	args := rpctypes.SetTorrentIPFilterRequest{ID: id, IPFilter: filter}
+	var reply rpctypes.EmptyResponse
+	return c.client.Call("Session.SetTorrentIPFilter", args, &reply)
+}
+
+func (c *Client) GetTorrentPieces(id string) (*rpctypes.GetTorrentPiecesResponse, error) {
+	args := rpctypes.GetTorrentPiecesRequest{ID: id}
+	var reply rpctypes.GetTorrentPiecesResponse
+	return &reply, c.client.Call("Session.GetTorrentPieces", args, &reply)
+}
@@ -0,0 +1,340 @@
+// Package rpctypes contains the request and response types of the JSON-RPC API served by the daemon.
+package rpctypes
+
+import "time"
+
+type Torrent struct {
+	ID       string
+	Name     string
+	InfoHash string
+	Port     int
+	AddedAt  time.Time
+	// Position in the queue, starting from zero
+	QueuePosition int
+	// Pieces are downloaded in order instead of rarest first
+	Sequential bool
+}
+
+type Stats struct {
+	Status   string
+	Error    string `json:",omitempty"`
+	Progress float64
+	Pieces   struct {
+		Checked uint32
+		Have    uint32
+		Missing uint32
+		Total   uint32
+	}
+	Bytes struct {
+		Completed  int64
+		Incomplete int64
+		Total      int64
+		Allocated  int64
+		Downloaded int64
+		Uploaded   int64
+		Wasted     int64
+	}
+	Peers struct {
+		Total    int
+		BySource map[string]int
+		// Number of peer addresses rejected by the blocklist and IP filter rules, by reason.
+		Rejected map[string]int
+	}
+	Speed struct {
+		Download int
+		Upload   int
+	}
+	// Seconds remaining to complete download. Nil means unknown.
+	ETA       *int64 `json:",omitempty"`
+	Ratio     float64
+	SeededFor int64
+	// Why the torrent is stopped, empty if it is running
+	StopReason string `json:",omitempty"`
+}
+
+type SessionStats struct {
+	Torrents         int
+	TorrentsByStatus map[string]int
+	Peers            int
+	PortsAvailable   int
+	PortsInUse       int
+	BlockListRules   int
+	BlockListRecency int64
+	BytesDownloaded  int64
+	BytesUploaded    int64
+	BytesWasted      int64
+	SpeedDownload    int
+	SpeedUpload      int
+	AltSpeedActive   bool
+	Uptime           int64
+}
+
+type Tracker struct {
+	URL          string
+	Status       string
+	Leechers     int
+	Seeders      int
+	Error        string `json:",omitempty"`
+	Warning      string `json:",omitempty"`
+	LastAnnounce time.Time
+	NextAnnounce time.Time
+}
+
+type Peer struct {
+	ID          string
+	Addr        string
+	Source      string
+	ConnectedAt time.Time
+}
+
+type File struct {
+	Path           string
+	Length         int64
+	BytesCompleted int64
+	// "skip", "low", "normal" or "high"
+	Priority string
+}
+
+type AddTorrentOptions struct {
+	ID                string `json:",omitempty"`
+	Stopped           bool   `json:",omitempty"`
+	StopAfterDownload bool   `json:",omitempty"`
+	StopAfterMetadata bool   `json:",omitempty"`
+}
+
+type AddTorrentRequest struct {
+	// Content of the torrent file, encoded with standard base64.
+	Torrent string
+	AddTorrentOptions
+}
+
+type AddTorrentResponse struct {
+	Torrent Torrent
+}
+
+type AddURIRequest struct {
+	// HTTP(S) URL of a torrent file or a magnet link.
+	URI string
+	AddTorrentOptions
+}
+
+type AddURIResponse struct {
+	Torrent Torrent
+}
+
+type ListTorrentsRequest struct{}
+
+type ListTorrentsResponse struct {
+	Torrents []Torrent
+}
+
+type TorrentRequest struct {
+	ID string
+}
+
+type EmptyResponse struct{}
+
+type RemoveTorrentRequest = TorrentRequest
+type StartTorrentRequest = TorrentRequest
+type StopTorrentRequest = TorrentRequest
+type VerifyTorrentRequest = TorrentRequest
+
+type GetTorrentStatsRequest = TorrentRequest
+
+type GetTorrentStatsResponse struct {
+	Stats Stats
+}
+
+type GetTorrentTrackersRequest = TorrentRequest
+
+type GetTorrentTrackersResponse struct {
+	Trackers []Tracker
+}
+
+type GetTorrentPeersRequest = TorrentRequest
+
+type GetTorrentPeersResponse struct {
+	Peers []Peer
+}
+
+type GetTorrentFilesRequest = TorrentRequest
+
+type GetTorrentFilesResponse struct {
+	Files []File
+}
+
+type SetTorrentFilePrioritiesRequest struct {
+	ID string
+	// One priority for each file, in the order of GetTorrentFiles
+	Priorities []string
+}
+
+type SetTorrentSequentialRequest struct {
+	ID         string
+	Sequential bool
+}
+
+type SetTorrentPieceDeadlineRequest struct {
+	ID    string
+	Piece uint32
+	// Zero time clears the deadline
+	Deadline time.Time
+}
+
+type GetSessionStatsRequest struct{}
+
+type GetSessionStatsResponse struct {
+	Stats SessionStats
+}
+
+type SpeedLimits struct {
+	// Speed limits in KB/s. Zero means unlimited.
+	Download int64
+	Upload   int64
+	// Limits of each peer
+	PeerDownload int64
+	PeerUpload   int64
+}
+
+type SetSpeedLimitsRequest struct {
+	SpeedLimits
+}
+
+type GetSpeedLimitsRequest struct{}
+
+type GetSpeedLimitsResponse struct {
+	SpeedLimits
+}
+
+type GetTorrentSpeedLimitsRequest = TorrentRequest
+
+type SetTorrentSpeedLimitsRequest struct {
+	ID string
+	SpeedLimits
+}
+
+type SeedLimits struct {
+	// Zero disables a limit of the session. For a torrent, zero takes the limit from the session and negative disables it.
+	Ratio float64
+	// Durations in seconds
+	Time     int64
+	IdleTime int64
+	// "stop", "remove" or empty for the default
+	Action string
+}
+
+type SetSeedLimitsRequest struct {
+	SeedLimits
+}
+
+type GetSeedLimitsRequest struct{}
+
+type GetSeedLimitsResponse struct {
+	SeedLimits
+}
+
+type GetTorrentSeedLimitsRequest = TorrentRequest
+
+type SetTorrentSeedLimitsRequest struct {
+	ID string
+	SeedLimits
+}
+
+type QueueLimits struct {
+	// Zero means unlimited
+	Downloads int
+	Seeds     int
+	Torrents  int
+	// Do not count torrents slower than the rates in KB/s for SlowTime seconds
+	ExcludeSlow      bool
+	SlowDownloadRate int64
+	SlowUploadRate   int64
+	SlowTime         int64
+}
+
+type GetQueueLimitsRequest struct{}
+
+type GetQueueLimitsResponse struct {
+	QueueLimits
+}
+
+type SetQueueLimitsRequest struct {
+	QueueLimits
+}
+
+type MoveTorrentInQueueRequest struct {
+	ID string
+	// One of "up", "down", "top" or "bottom"
+	Move string
+}
+
+type AltSpeedWindow struct {
+	// Days of the week the window starts at, 0 is Sunday. Empty means every day.
+	Days []int `json:",omitempty"`
+	// Time of day in "15:04" format
+	Start string
+	End   string
+}
+
+type AltSpeed struct {
+	Limits   SpeedLimits
+	Schedule []AltSpeedWindow
+	// One of "scheduled", "on" or "off"
+	Mode string
+	// Ignored in SetAltSpeed requests
+	Active bool
+}
+
+type GetAltSpeedRequest struct{}
+
+type GetAltSpeedResponse struct {
+	AltSpeed
+}
+
+type SetAltSpeedRequest struct {
+	AltSpeed
+}
+
+type GetTorrentPiecesRequest = TorrentRequest
+
+type GetTorrentPiecesResponse struct {
+	// One bit for each piece, set if the piece is downloaded and verified. Empty if the pieces are not known yet.
+	Bitfield []byte
+	// Number of pieces in Bitfield.
+	Count uint32
+}
+
+// IPFilter contains the allow and deny rules of a torrent.
+type IPFilter struct {
+	Allow []string
+	Deny  []string
+}
+
+type GetTorrentIPFilterRequest = TorrentRequest
+
+type GetTorrentIPFilterResponse struct {
+	IPFilter
+}
+
+type SetTorrentIPFilterRequest struct {
+	ID string
+	IPFilter
+}
+
+// Event is a change in the Session that is pushed to the clients.
+type Event struct {
+	Type      string
+	Time      time.Time
+	TorrentID string `json:",omitempty"`
+	Error     string `json:",omitempty"`
+	Tracker   string `json:",omitempty"`
+	Warning   string `json:",omitempty"`
+	Piece     uint32 `json:",omitempty"`
+	AltSpeed  bool   `json:",omitempty"`
+}
+
+// TorrentStats is a torrent with its statistics, pushed periodically to the clients.
+type TorrentStats struct {
+	Torrent
+	Stats Stats
+}
@@ -0,0 +1,63 @@
+// Package speedmeter measures transfer speed as an exponentially weighted moving average.
+package speedmeter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	tickInterval = 5 * time.Second
+	// Speed is averaged over a minute
+	averageWindow = time.Minute
+)
+
+var alpha = 1 - math.Exp(-float64(tickInterval)/float64(averageWindow))
+
+// SpeedMeter counts the bytes transferred and reports the average speed in bytes per second.
+type SpeedMeter struct {
+	m         sync.Mutex
+	uncounted int64
+	rate      float64
+	init      bool
+	lastTick  time.Time
+}
+
+func New() *SpeedMeter {
+	return &SpeedMeter{
+		lastTick: time.Now(),
+	}
+}
+
+// Mark records n bytes as transferred.
+func (s *SpeedMeter) Mark(n int64) {
+	s.m.Lock()
+	s.tick()
+	s.uncounted += n
+	s.m.Unlock()
+}
+
+// Rate returns the average speed in bytes per second.
+func (s *SpeedMeter) Rate() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.tick()
+
+	return int(s.rate)
+}
+
+func (s *SpeedMeter) tick() {
+	now := time.Now()
+	for now.Sub(s.lastTick) >= tickInterval {
+		instantRate := float64(s.uncounted) / tickInterval.Seconds()
+		s.uncounted = 0
+		if s.init {
+			s.rate += alpha * (instantRate - s.rate)
+		} else {
+			s.rate = instantRate
+			s.init = true
+		}
+		s.lastTick = s.lastTick.Add(tickInterval)
+	}
+}
@@ -83,6 +83,29 @@ func (s *FileStorage) Open(name string, size int64) (f storage.File, exists bool
 	return
 }
 
+func (s *FileStorage) Exists(name string) (bool, error) {
+	name = filepath.Join(s.dest, filepath.Clean(name))
+
+	_, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (s *FileStorage) Rename(oldname, newname string) error {
+	oldname = filepath.Join(s.dest, filepath.Clean(oldname))
+	newname = filepath.Join(s.dest, filepath.Clean(newname))
+
+	err := os.MkdirAll(filepath.Dir(newname), os.ModeDir|s.perm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(oldname, newname)
+}
+
 func (s *FileStorage) RootDir() string {
 	return s.dest
 }
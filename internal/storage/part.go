@@ -0,0 +1,79 @@
+package storage
+
+import "sync"
+
+// PartFile holds the data of a file that is not wanted.
+// Pieces that straddle the boundary of a wanted and an unwanted file are written here
+// so the unwanted file is never created. The part file itself is created on the first write.
+type PartFile struct {
+	sto    Storage
+	name   string
+	length int64
+
+	m sync.Mutex
+	f File
+}
+
+// NewPartFile returns a part file of the given length named name in sto.
+// The part file is opened immediately if it exists from a previous run.
+func NewPartFile(sto Storage, name string, length int64) (f *PartFile, exists bool, err error) {
+	f = &PartFile{
+		sto:    sto,
+		name:   name,
+		length: length,
+	}
+
+	exists, err = sto.Exists(name)
+	if err != nil || !exists {
+		return f, false, err
+	}
+
+	f.f, _, err = sto.Open(name, length)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return f, true, nil
+}
+
+var _ File = (*PartFile)(nil)
+
+func (f *PartFile) ReadAt(p []byte, off int64) (n int, err error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.f == nil {
+		for i := range p {
+			p[i] = 0
+		}
+
+		return len(p), nil
+	}
+
+	return f.f.ReadAt(p, off)
+}
+
+func (f *PartFile) WriteAt(p []byte, off int64) (n int, err error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.f == nil {
+		f.f, _, err = f.sto.Open(f.name, f.length)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return f.f.WriteAt(p, off)
+}
+
+func (f *PartFile) Close() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.f == nil {
+		return nil
+	}
+
+	return f.f.Close()
+}
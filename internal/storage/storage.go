@@ -5,6 +5,10 @@ import "io"
 
 type Storage interface {
 	Open(name string, size int64) (f File, exists bool, err error)
+	// Exists reports whether the file is present without creating it.
+	Exists(name string) (bool, error)
+	// Rename moves a file that is not open to newname, replacing it if it exists.
+	Rename(oldname, newname string) error
 	RootDir() string
 }
 
@@ -105,7 +105,7 @@ func (t *HTTPTracker) Announce(ctx context.Context, req tracker.AnnounceRequest)
 
 	var response announceResponse
 
-	err = bencode.Unmarshal(body, &response)
+	err = bencode.UnmarshalWithOptions(body, &response, bencode.Untrusted())
 	if err != nil {
 		if code != 200 {
 			return nil, &StatusError{
@@ -137,7 +137,7 @@ func (t *HTTPTracker) Announce(ctx context.Context, req tracker.AnnounceRequest)
 			peers, err = parsePeersDictionary(response.Peers)
 		} else {
 			var b []byte
-			err = bencode.Unmarshal(response.Peers, &b)
+			err = bencode.UnmarshalWithOptions(response.Peers, &b, bencode.Untrusted())
 			if err != nil {
 				return nil, tracker.ErrDecode
 			}
@@ -242,7 +242,7 @@ func parsePeersDictionary(b bencode.Bytes) ([]*net.TCPAddr, error) {
 		IP   string `bencode:"ip"`
 		Port uint16 `bencode:"port"`
 	}
-	err := bencode.Unmarshal(b, &peers)
+	err := bencode.UnmarshalWithOptions(b, &peers, bencode.Untrusted())
 	if err != nil {
 		return nil, tracker.ErrDecode
 	}
@@ -2,10 +2,12 @@ package udptracker
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"net"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/al002/zbittorrent/internal/blocklist"
@@ -29,6 +31,18 @@ type Transport struct {
 	closeC     chan struct{}
 	doneC      chan struct{}
 	log        log.Logger
+
+	// Counters for TransportStats
+	retries  atomic.Uint64
+	timeouts atomic.Uint64
+}
+
+// TransportStats contains counters about the transactions made by the Transport.
+type TransportStats struct {
+	// Number of packets sent again because no response is received for the transaction.
+	Retries uint64
+	// Number of requests that did not get a response before their deadline.
+	Timeouts uint64
 }
 
 func NewTransport(bl *blocklist.Blocklist, dnsTimeout time.Duration, logger log.Logger) *Transport {
@@ -48,13 +62,21 @@ func (t *Transport) Close() {
 	<-t.doneC
 }
 
+// Stats returns the counters of the Transport. It is safe to call concurrently.
+func (t *Transport) Stats() TransportStats {
+	return TransportStats{
+		Retries:  t.retries.Load(),
+		Timeouts: t.timeouts.Load(),
+	}
+}
+
 func (t *Transport) Do(req *transportRequest) ([]byte, error) {
 	var errTransportClosed = errors.New("udp transport closed")
 
 	select {
 	case t.requestC <- req:
 	case <-req.ctx.Done():
-		return nil, req.ctx.Err()
+		return nil, t.contextError(req.ctx)
 	case <-t.closeC:
 		return nil, errTransportClosed
 	}
@@ -64,12 +86,22 @@ func (t *Transport) Do(req *transportRequest) ([]byte, error) {
 	case <-req.done:
 		return req.response, req.err
 	case <-req.ctx.Done():
-		return nil, req.ctx.Err()
+		return nil, t.contextError(req.ctx)
 	case <-t.closeC:
 		return nil, errTransportClosed
 	}
 }
 
+// contextError returns the error of the done request context and counts it if the deadline is exceeded.
+func (t *Transport) contextError(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.timeouts.Add(1)
+	}
+
+	return err
+}
+
 func (t *Transport) Run() {
 	t.log.Debug("Starting udp transport run loop")
 	var listening bool
@@ -119,7 +151,7 @@ func (t *Transport) Run() {
 					conn.SetResponse(nil, err)
 				} else {
 					// sent `connect` action
-					go resolveDestinationAndConnect(trx, req.dest, udpConn, t.dnsTimeout, t.blocklist, connectDone, t.closeC)
+					go t.resolveDestinationAndConnect(trx, req.dest, udpConn, connectDone)
 				}
 			} else {
 				if !conn.connectedAt.IsZero() {
@@ -131,7 +163,7 @@ func (t *Transport) Run() {
 						req.SetResponse(nil, err)
 					} else {
 						// retry connect action
-						go retryTransaction(trx, udpConn, conn.addr)
+						go t.retryTransaction(trx, udpConn, conn.addr)
 					}
 				} else {
 					// connection is in connecting state
@@ -178,7 +210,7 @@ func (t *Transport) Run() {
 				if err != nil {
 					req.SetResponse(nil, err)
 				} else {
-					go retryTransaction(trx, udpConn, conn.addr)
+					go t.retryTransaction(trx, udpConn, conn.addr)
 				}
 			}
 
@@ -218,7 +250,7 @@ func (t *Transport) Run() {
 					RetryIn       string `bencode:"retry in"`
 				}
 
-				err = bencode.Unmarshal(rest, &terr)
+				err = bencode.UnmarshalWithOptions(rest, &terr, bencode.Untrusted())
 				if err != nil {
 					err = tracker.ErrDecode
 				} else {
@@ -290,19 +322,19 @@ type connectionResult struct {
 	connectedAt time.Time
 }
 
-func resolveDestinationAndConnect(trx *transaction, dest string, udpConn *net.UDPConn, dnsTimeout time.Duration, blocklist *blocklist.Blocklist, connectDoneC chan *connectionResult, stopC chan struct{}) {
+func (t *Transport) resolveDestinationAndConnect(trx *transaction, dest string, udpConn *net.UDPConn, connectDoneC chan *connectionResult) {
 	res := &connectionResult{
 		trx:  trx,
 		dest: dest,
 	}
 
-	ip, port, err := resolver.Resolve(trx.ctx, dest, dnsTimeout, blocklist)
+	ip, port, err := resolver.Resolve(trx.ctx, dest, t.dnsTimeout, t.blocklist)
 	if err != nil {
 		res.err = err
 		select {
 		// Trigger connectDone, but has err
 		case connectDoneC <- res:
-		case <-stopC:
+		case <-t.closeC:
 		}
 		return
 	}
@@ -312,7 +344,7 @@ func resolveDestinationAndConnect(trx *transaction, dest string, udpConn *net.UD
 		Port: port,
 	}
 
-	res.id, res.err = sendAndReceiveConnect(trx, udpConn, res.addr)
+	res.id, res.err = t.sendAndReceiveConnect(trx, udpConn, res.addr)
 	if res.err == nil {
 		res.connectedAt = time.Now()
 	}
@@ -320,12 +352,12 @@ func resolveDestinationAndConnect(trx *transaction, dest string, udpConn *net.UD
 	select {
 	// Trigger connectDone, succesful status
 	case connectDoneC <- res:
-	case <-stopC:
+	case <-t.closeC:
 	}
 }
 
-func sendAndReceiveConnect(trx *transaction, conn *net.UDPConn, addr net.Addr) (connectionID int64, err error) {
-	go retryTransaction(trx, conn, addr)
+func (t *Transport) sendAndReceiveConnect(trx *transaction, conn *net.UDPConn, addr net.Addr) (connectionID int64, err error) {
+	go t.retryTransaction(trx, conn, addr)
 
 	select {
 	// Request is done
@@ -352,7 +384,7 @@ func sendAndReceiveConnect(trx *transaction, conn *net.UDPConn, addr net.Addr) (
 	return response.ConnectionID, nil
 }
 
-func retryTransaction(trx *transaction, conn *net.UDPConn, addr net.Addr) {
+func (t *Transport) retryTransaction(trx *transaction, conn *net.UDPConn, addr net.Addr) {
 	// sent transaction with backoff retry
 	var b bytes.Buffer
 	_, _ = trx.request.WriteTo(&b)
@@ -361,10 +393,15 @@ func retryTransaction(trx *transaction, conn *net.UDPConn, addr net.Addr) {
 	ticker := backoff.NewTicker(new(udpBackOff))
 	defer ticker.Stop()
 
+	sent := false
 	for {
 		select {
 		case <-ticker.C:
 			// Sent request
+			if sent {
+				t.retries.Add(1)
+			}
+			sent = true
 			_, _ = conn.WriteTo(data, addr)
 		case <-trx.ctx.Done():
 			return
@@ -57,6 +57,11 @@ func (m *TrackerManager) Close() {
 	m.udpTransport.Close()
 }
 
+// UDPStats returns the counters of the transport shared by UDP trackers.
+func (m *TrackerManager) UDPStats() udptracker.TransportStats {
+	return m.udpTransport.Stats()
+}
+
 func (m *TrackerManager) Get(s string, httpTimeout time.Duration, httpUserAgent string, httpMaxResponseLength int64) (tracker.Tracker, error) {
 	u, err := url.Parse(s)
 	if err != nil {
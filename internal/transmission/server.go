@@ -0,0 +1,122 @@
+// Package transmission implements the HTTP transport of the Transmission RPC protocol.
+//
+// The protocol is described in https://github.com/transmission/transmission/blob/main/docs/rpc-spec.md
+package transmission
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// SessionIDHeader is the header used for CSRF protection.
+// Clients must echo the value they receive in a 409 response in the following requests.
+const SessionIDHeader = "X-Transmission-Session-Id"
+
+// Maximum size of a request body. Torrent files are sent in requests so this must be large enough for them.
+const maxRequestSize = 32 << 20
+
+type handlerFunc func(args json.RawMessage) (any, error)
+
+type request struct {
+	Method    string          `json:"method"`
+	Arguments json.RawMessage `json:"arguments"`
+	Tag       *int64          `json:"tag,omitempty"`
+}
+
+type response struct {
+	Result    string `json:"result"`
+	Arguments any    `json:"arguments"`
+	Tag       *int64 `json:"tag,omitempty"`
+}
+
+// Server is a http.Handler that dispatches Transmission RPC requests to registered methods.
+type Server struct {
+	sessionID string
+	methods   map[string]handlerFunc
+}
+
+func NewServer() *Server {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+
+	return &Server{
+		sessionID: base64.RawURLEncoding.EncodeToString(b),
+		methods:   make(map[string]handlerFunc),
+	}
+}
+
+// SessionID returns the value that clients must send in SessionIDHeader.
+func (s *Server) SessionID() string {
+	return s.sessionID
+}
+
+// Register adds a method to the server.
+// Arguments of the request are decoded into a new Req and the Resp filled by fn is sent as the arguments of the response.
+// If fn returns an error, its message is sent in the result field.
+func Register[Req, Resp any](s *Server, method string, fn func(req *Req, resp *Resp) error) {
+	s.methods[method] = func(args json.RawMessage) (any, error) {
+		req := new(Req)
+		if len(args) > 0 && string(args) != "null" {
+			if err := json.Unmarshal(args, req); err != nil {
+				return nil, err
+			}
+		}
+
+		resp := new(Resp)
+		if err := fn(req, resp); err != nil {
+			return nil, err
+		}
+
+		return resp, nil
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Clients make a request without the header first to learn the session id
+	if r.Header.Get(SessionIDHeader) != s.sessionID {
+		w.Header().Set(SessionIDHeader, s.sessionID)
+		http.Error(w, "409: Conflict\n\nInvalid or missing "+SessionIDHeader+" header", http.StatusConflict)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req request
+	if err = json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := response{
+		Result:    "success",
+		Arguments: struct{}{},
+		Tag:       req.Tag,
+	}
+
+	fn, ok := s.methods[req.Method]
+	if !ok {
+		resp.Result = "method name not recognized"
+	} else if result, err := fn(req.Arguments); err != nil {
+		resp.Result = err.Error()
+	} else {
+		resp.Arguments = result
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(resp)
+}
@@ -0,0 +1,59 @@
+package transmission
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIDs(t *testing.T) {
+	var req TorrentActionRequest
+	err := json.Unmarshal([]byte(`{"ids": [1, "0123456789ABCDEF0123456789abcdef01234567", 3]}`), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []int64{1, 3}, req.IDs.Numbers)
+	hash := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0x01, 0x23, 0x45, 0x67}
+	assert.True(t, req.IDs.Match(2, hash))
+	assert.True(t, req.IDs.Match(3, nil))
+	assert.False(t, req.IDs.Match(2, nil))
+
+	req = TorrentActionRequest{}
+	err = json.Unmarshal([]byte(`{"ids": "recently-active"}`), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, req.IDs.RecentlyActive)
+
+	req = TorrentActionRequest{}
+	err = json.Unmarshal([]byte(`{}`), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, req.IDs)
+}
+
+func TestSessionID(t *testing.T) {
+	s := NewServer()
+	Register(s, "echo", func(req *SessionGetRequest, resp *SessionGetRequest) error {
+		*resp = *req
+		return nil
+	})
+
+	body := `{"method": "echo", "arguments": {"fields": ["version"]}, "tag": 7}`
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/transmission/rpc", strings.NewReader(body)))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, s.SessionID(), w.Header().Get(SessionIDHeader))
+
+	r := httptest.NewRequest(http.MethodPost, "/transmission/rpc", strings.NewReader(body))
+	r.Header.Set(SessionIDHeader, s.SessionID())
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"result": "success", "arguments": {"fields": ["version"]}, "tag": 7}`, w.Body.String())
+}
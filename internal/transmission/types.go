@@ -0,0 +1,195 @@
+package transmission
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Torrent status values of the torrent-get method.
+const (
+	StatusStopped      = 0
+	StatusCheckWait    = 1
+	StatusCheck        = 2
+	StatusDownloadWait = 3
+	StatusDownload     = 4
+	StatusSeedWait     = 5
+	StatusSeed         = 6
+)
+
+// Torrent error values of the torrent-get method.
+const (
+	ErrorNone           = 0
+	ErrorTrackerWarning = 1
+	ErrorTrackerError   = 2
+	ErrorLocal          = 3
+)
+
+// IDs selects the torrents a method operates on.
+// A nil *IDs in a request means all torrents.
+type IDs struct {
+	// Torrents that are active recently.
+	RecentlyActive bool
+	// Numeric ids that are assigned by the server.
+	Numbers []int64
+	// Hex encoded info hashes.
+	Hashes []string
+}
+
+// UnmarshalJSON decodes a single id, a list of ids and hashes, or "recently-active".
+func (ids *IDs) UnmarshalJSON(b []byte) error {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case string:
+		if v == "recently-active" {
+			ids.RecentlyActive = true
+			return nil
+		}
+		return ids.add(v)
+	case []any:
+		for _, e := range v {
+			if err := ids.add(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return ids.add(v)
+	}
+}
+
+func (ids *IDs) add(v any) error {
+	switch v := v.(type) {
+	case float64:
+		ids.Numbers = append(ids.Numbers, int64(v))
+	case string:
+		ids.Hashes = append(ids.Hashes, v)
+	default:
+		return errors.New("invalid torrent id")
+	}
+
+	return nil
+}
+
+type TorrentAddRequest struct {
+	// URL, magnet link or path of a torrent file on the server.
+	Filename string `json:"filename"`
+	// Base64 encoded content of the torrent file.
+	Metainfo    string `json:"metainfo"`
+	Paused      bool   `json:"paused"`
+	DownloadDir string `json:"download-dir"`
+}
+
+type AddedTorrent struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	HashString string `json:"hashString"`
+}
+
+type TorrentAddResponse struct {
+	TorrentAdded     *AddedTorrent `json:"torrent-added,omitempty"`
+	TorrentDuplicate *AddedTorrent `json:"torrent-duplicate,omitempty"`
+}
+
+type TorrentGetRequest struct {
+	IDs    *IDs     `json:"ids"`
+	Fields []string `json:"fields"`
+}
+
+type TorrentGetResponse struct {
+	// Only the requested fields are set in each torrent.
+	Torrents []map[string]any `json:"torrents"`
+	// Ids of the torrents that are removed recently. Only set if "recently-active" is requested.
+	Removed []int64 `json:"removed,omitempty"`
+}
+
+// TorrentActionRequest is the request of torrent-start, torrent-stop and torrent-verify methods.
+type TorrentActionRequest struct {
+	IDs *IDs `json:"ids"`
+}
+
+type TorrentRemoveRequest struct {
+	IDs             *IDs `json:"ids"`
+	DeleteLocalData bool `json:"delete-local-data"`
+}
+
+type EmptyResponse struct{}
+
+type SessionGetRequest struct {
+	Fields []string `json:"fields"`
+}
+
+type SessionGetResponse struct {
+	Version               string `json:"version"`
+	RPCVersion            int    `json:"rpc-version"`
+	RPCVersionMinimum     int    `json:"rpc-version-minimum"`
+	SessionID             string `json:"session-id"`
+	DownloadDir           string `json:"download-dir"`
+	PeerPort              int    `json:"peer-port"`
+	PEXEnabled            bool   `json:"pex-enabled"`
+	DHTEnabled            bool   `json:"dht-enabled"`
+	SpeedLimitDown        int64  `json:"speed-limit-down"`
+	SpeedLimitDownEnabled bool   `json:"speed-limit-down-enabled"`
+	SpeedLimitUp          int64  `json:"speed-limit-up"`
+	SpeedLimitUpEnabled   bool   `json:"speed-limit-up-enabled"`
+	Units                 Units  `json:"units"`
+}
+
+type Units struct {
+	SpeedUnits  []string `json:"speed-units"`
+	SpeedBytes  int      `json:"speed-bytes"`
+	SizeUnits   []string `json:"size-units"`
+	SizeBytes   int      `json:"size-bytes"`
+	MemoryUnits []string `json:"memory-units"`
+	MemoryBytes int      `json:"memory-bytes"`
+}
+
+// SessionSetRequest contains the settings that can be changed. Nil fields are left unchanged.
+type SessionSetRequest struct {
+	SpeedLimitDown        *int64 `json:"speed-limit-down"`
+	SpeedLimitDownEnabled *bool  `json:"speed-limit-down-enabled"`
+	SpeedLimitUp          *int64 `json:"speed-limit-up"`
+	SpeedLimitUpEnabled   *bool  `json:"speed-limit-up-enabled"`
+}
+
+type SessionStatsRequest struct{}
+
+type SessionStatsResponse struct {
+	ActiveTorrentCount int   `json:"activeTorrentCount"`
+	PausedTorrentCount int   `json:"pausedTorrentCount"`
+	TorrentCount       int   `json:"torrentCount"`
+	DownloadSpeed      int   `json:"downloadSpeed"`
+	UploadSpeed        int   `json:"uploadSpeed"`
+	CumulativeStats    Stats `json:"cumulative-stats"`
+	CurrentStats       Stats `json:"current-stats"`
+}
+
+type Stats struct {
+	UploadedBytes   int64 `json:"uploadedBytes"`
+	DownloadedBytes int64 `json:"downloadedBytes"`
+	FilesAdded      int   `json:"filesAdded"`
+	SessionCount    int   `json:"sessionCount"`
+	SecondsActive   int64 `json:"secondsActive"`
+}
+
+// Match returns true if the torrent with the numeric id or info hash is selected.
+func (ids *IDs) Match(id int64, infoHash []byte) bool {
+	for _, n := range ids.Numbers {
+		if n == id {
+			return true
+		}
+	}
+
+	for _, h := range ids.Hashes {
+		if strings.EqualFold(h, hex.EncodeToString(infoHash)) {
+			return true
+		}
+	}
+
+	return false
+}
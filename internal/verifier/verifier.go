@@ -0,0 +1,72 @@
+// Package verifier checks the hashes of pieces that are already present on the disk.
+package verifier
+
+import (
+	"github.com/al002/zbittorrent/internal/bitfield"
+	"github.com/al002/zbittorrent/internal/piece"
+)
+
+type Verifier struct {
+	Bitfield *bitfield.Bitfield
+	Error    error
+
+	closeC chan struct{}
+	doneC  chan struct{}
+}
+
+type Progress struct {
+	Checked uint32
+	// True if the last checked piece, at index Checked-1, passed the hash check.
+	Verified bool
+}
+
+func New() *Verifier {
+	return &Verifier{
+		closeC: make(chan struct{}),
+		doneC:  make(chan struct{}),
+	}
+}
+
+func (v *Verifier) Close() {
+	close(v.closeC)
+	<-v.doneC
+}
+
+func (v *Verifier) Run(pieces []piece.Piece, progressC chan Progress, resultC chan *Verifier) {
+	defer close(v.doneC)
+
+	defer func() {
+		select {
+		case resultC <- v:
+		case <-v.closeC:
+		}
+	}()
+
+	v.Bitfield = bitfield.New(uint32(len(pieces)))
+
+	var buf []byte
+	hash := piece.NewHash()
+	for i := range pieces {
+		p := &pieces[i]
+		if uint32(cap(buf)) < p.Length {
+			buf = make([]byte, p.Length)
+		}
+		buf = buf[:p.Length]
+
+		v.Error = p.Data.ReadFull(buf)
+		if v.Error != nil {
+			return
+		}
+
+		ok := p.VerifyHash(buf, hash)
+		if ok {
+			v.Bitfield.Set(p.Index)
+		}
+
+		select {
+		case progressC <- Progress{Checked: p.Index + 1, Verified: ok}:
+		case <-v.closeC:
+			return
+		}
+	}
+}
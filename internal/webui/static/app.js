@@ -0,0 +1,379 @@
+"use strict";
+
+// Number of events kept in the event log.
+const maxEvents = 100;
+// Interval for refreshing the lists in the detail page.
+const detailRefreshInterval = 2000;
+
+const $ = (id) => document.getElementById(id);
+
+let rpcID = 0;
+// Session id required by the server when it has no token, learned from the first response
+let sessionID = null;
+let torrents = [];
+let detailID = null;
+let detailTimer = null;
+
+async function post(body) {
+  const headers = { "Content-Type": "application/json" };
+  if (sessionID) {
+    headers["X-Zbittorrent-Session-Id"] = sessionID;
+  }
+  return fetch("rpc", { method: "POST", headers: headers, body: body });
+}
+
+async function rpc(method, params) {
+  const body = JSON.stringify({ jsonrpc: "2.0", id: ++rpcID, method: method, params: params || {} });
+  let resp = await post(body);
+  const newID = resp.headers.get("X-Zbittorrent-Session-Id");
+  if (resp.status === 409 && newID) {
+    sessionID = newID;
+    resp = await post(body);
+  }
+  if (!resp.ok) {
+    throw new Error("HTTP " + resp.status + ": " + (await resp.text()));
+  }
+  const body = await resp.json();
+  if (body.error) {
+    throw new Error(body.error.message);
+  }
+  return body.result;
+}
+
+function showError(err) {
+  const el = $("error");
+  el.textContent = err.message || String(err);
+  el.hidden = false;
+}
+
+function call(method, params) {
+  return rpc(method, params).catch(showError);
+}
+
+function formatBytes(n) {
+  const units = ["B", "KiB", "MiB", "GiB", "TiB", "PiB"];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) {
+    n /= 1024;
+    i++;
+  }
+  return (i === 0 ? n : n.toFixed(1)) + " " + units[i];
+}
+
+function formatSpeed(n) {
+  return formatBytes(n) + "/s";
+}
+
+function formatDuration(seconds) {
+  if (seconds == null) {
+    return "-";
+  }
+  const h = Math.floor(seconds / 3600);
+  const m = Math.floor((seconds % 3600) / 60);
+  const s = seconds % 60;
+  return (h ? h + "h" : "") + (h || m ? m + "m" : "") + s + "s";
+}
+
+function formatTime(t) {
+  if (!t || t.startsWith("0001-")) {
+    return "-";
+  }
+  return new Date(t).toLocaleString();
+}
+
+function el(tag, props, children) {
+  const e = document.createElement(tag);
+  Object.assign(e, props || {});
+  for (const c of children || []) {
+    e.append(c);
+  }
+  return e;
+}
+
+function progressBar(progress) {
+  const pct = (progress * 100).toFixed(1) + "%";
+  return el("div", { className: "bar" }, [el("div", { style: "width: " + pct }), el("span", { textContent: pct })]);
+}
+
+function actionButtons(t) {
+  const running = t.Stats.Status !== "stopped" && t.Stats.Status !== "error";
+  const buttons = [];
+  if (running) {
+    buttons.push(el("button", { textContent: "Stop", onclick: () => call("Session.StopTorrent", { ID: t.ID }) }));
+  } else {
+    buttons.push(el("button", { textContent: "Start", onclick: () => call("Session.StartTorrent", { ID: t.ID }) }));
+  }
+  buttons.push(
+    el("button", {
+      textContent: "Remove",
+      onclick: () => {
+        if (confirm("Remove " + (t.Name || t.ID) + "? Downloaded files are kept.")) {
+          call("Session.RemoveTorrent", { ID: t.ID }).then(() => {
+            if (detailID === t.ID) {
+              location.hash = "#/";
+            }
+          });
+        }
+      },
+    })
+  );
+  return buttons;
+}
+
+function renderList() {
+  const tbody = $("torrents");
+  tbody.replaceChildren();
+  $("empty").hidden = torrents.length > 0;
+
+  let down = 0;
+  let up = 0;
+  for (const t of torrents) {
+    const s = t.Stats;
+    down += s.Speed.Download;
+    up += s.Speed.Upload;
+    const status = s.Error ? "error: " + s.Error : s.Status;
+    const row = el("tr", { className: "status-" + s.Status }, [
+      el("td", { className: "name" }, [el("a", { href: "#/torrent/" + encodeURIComponent(t.ID), textContent: t.Name || t.InfoHash })]),
+      el("td", { textContent: status, className: s.Error ? "status-error" : "" }),
+      el("td", {}, [progressBar(s.Progress)]),
+      el("td", { textContent: formatBytes(s.Bytes.Total) }),
+      el("td", { textContent: formatSpeed(s.Speed.Download) }),
+      el("td", { textContent: formatSpeed(s.Speed.Upload) }),
+      el("td", { textContent: s.Peers.Total }),
+      el("td", { textContent: formatDuration(s.ETA) }),
+      el("td", { className: "actions" }, actionButtons(t)),
+    ]);
+    tbody.append(row);
+  }
+
+  $("session-speed").textContent = "↓ " + formatSpeed(down) + "  ↑ " + formatSpeed(up);
+}
+
+function renderDetailStats() {
+  const t = torrents.find((t) => t.ID === detailID);
+  if (!t) {
+    return;
+  }
+
+  const s = t.Stats;
+  $("detail-name").textContent = t.Name || t.InfoHash;
+  $("detail-actions").replaceChildren(...actionButtons(t));
+
+  const items = [
+    ["ID", t.ID],
+    ["Info hash", t.InfoHash],
+    ["Status", s.Error ? s.Status + ": " + s.Error : s.Status],
+    ["Progress", (s.Progress * 100).toFixed(2) + "%"],
+    ["Pieces", s.Pieces.Have + " / " + s.Pieces.Total + (s.Status === "verifying" ? " (checked " + s.Pieces.Checked + ")" : "")],
+    ["Size", formatBytes(s.Bytes.Completed) + " / " + formatBytes(s.Bytes.Total)],
+    ["Downloaded", formatBytes(s.Bytes.Downloaded)],
+    ["Uploaded", formatBytes(s.Bytes.Uploaded)],
+    ["Wasted", formatBytes(s.Bytes.Wasted)],
+    ["Speed", "↓ " + formatSpeed(s.Speed.Download) + "  ↑ " + formatSpeed(s.Speed.Upload)],
+    ["Peers", s.Peers.Total],
+    ["ETA", formatDuration(s.ETA)],
+    ["Ratio", s.Ratio.toFixed(2)],
+    ["Seeded for", formatDuration(s.SeededFor)],
+    ["Port", t.Port],
+    ["Added at", formatTime(t.AddedAt)],
+  ];
+  $("detail-stats").replaceChildren(...items.flatMap(([k, v]) => [el("dt", { textContent: k }), el("dd", { textContent: v })]));
+}
+
+function drawPieces(bitfield, count) {
+  const canvas = $("pieces");
+  const ctx = canvas.getContext("2d");
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  if (!count) {
+    return;
+  }
+
+  const bytes = bitfield ? Uint8Array.from(atob(bitfield), (c) => c.charCodeAt(0)) : new Uint8Array(0);
+  const has = (i) => i >> 3 < bytes.length && (bytes[i >> 3] & (0x80 >> (i & 7))) !== 0;
+
+  // Each column of the canvas shows the ratio of the pieces we have in that range
+  const w = canvas.width;
+  ctx.fillStyle = "#2da44e";
+  for (let x = 0; x < w; x++) {
+    const begin = Math.floor((x * count) / w);
+    const end = Math.max(begin + 1, Math.floor(((x + 1) * count) / w));
+    let n = 0;
+    for (let i = begin; i < end && i < count; i++) {
+      if (has(i)) {
+        n++;
+      }
+    }
+    if (n > 0) {
+      ctx.globalAlpha = n / (end - begin);
+      ctx.fillRect(x, 0, 1, canvas.height);
+    }
+  }
+  ctx.globalAlpha = 1;
+}
+
+async function refreshDetail() {
+  const id = detailID;
+  if (!id) {
+    return;
+  }
+
+  try {
+    const [files, trackers, peers, pieces] = await Promise.all([
+      rpc("Session.GetTorrentFiles", { ID: id }).catch(() => ({ Files: [] })),
+      rpc("Session.GetTorrentTrackers", { ID: id }),
+      rpc("Session.GetTorrentPeers", { ID: id }),
+      rpc("Session.GetTorrentPieces", { ID: id }),
+    ]);
+    if (id !== detailID) {
+      return;
+    }
+
+    $("detail-files").replaceChildren(
+      ...(files.Files || []).map((f) => el("tr", {}, [el("td", { className: "name", textContent: f.Path }), el("td", { textContent: formatBytes(f.Length) })]))
+    );
+    $("detail-trackers").replaceChildren(
+      ...(trackers.Trackers || []).map((tr) =>
+        el("tr", {}, [
+          el("td", { className: "name", textContent: tr.URL }),
+          el("td", { textContent: tr.Status }),
+          el("td", { textContent: tr.Seeders }),
+          el("td", { textContent: tr.Leechers }),
+          el("td", { textContent: formatTime(tr.NextAnnounce) }),
+          el("td", { className: tr.Error ? "status-error" : "", textContent: tr.Error || tr.Warning || "" }),
+        ])
+      )
+    );
+    $("detail-peers").replaceChildren(
+      ...(peers.Peers || []).map((p) =>
+        el("tr", {}, [el("td", { textContent: p.Addr }), el("td", { textContent: p.Source }), el("td", { textContent: formatTime(p.ConnectedAt) })])
+      )
+    );
+    drawPieces(pieces.Bitfield, pieces.Count);
+  } catch (err) {
+    showError(err);
+  }
+}
+
+function route() {
+  const m = location.hash.match(/^#\/torrent\/(.+)$/);
+  clearInterval(detailTimer);
+  detailTimer = null;
+
+  if (m) {
+    detailID = decodeURIComponent(m[1]);
+    $("list-view").hidden = true;
+    $("detail-view").hidden = false;
+    renderDetailStats();
+    refreshDetail();
+    detailTimer = setInterval(refreshDetail, detailRefreshInterval);
+  } else {
+    detailID = null;
+    $("list-view").hidden = false;
+    $("detail-view").hidden = true;
+  }
+}
+
+function addEvent(e) {
+  const parts = [new Date(e.Time).toLocaleTimeString(), e.Type];
+  const t = torrents.find((t) => t.ID === e.TorrentID);
+  if (e.TorrentID) {
+    parts.push(t && t.Name ? t.Name : e.TorrentID);
+  }
+  for (const v of [e.Tracker, e.Warning, e.Error]) {
+    if (v) {
+      parts.push(v);
+    }
+  }
+
+  const list = $("events");
+  list.prepend(el("li", { textContent: parts.join(" "), className: e.Error ? "status-error" : "" }));
+  while (list.children.length > maxEvents) {
+    list.lastChild.remove();
+  }
+}
+
+function connectEvents() {
+  const source = new EventSource("events");
+  source.addEventListener("stats", (msg) => {
+    torrents = JSON.parse(msg.data) || [];
+    renderList();
+    if (detailID) {
+      renderDetailStats();
+    }
+  });
+
+  const types = [
+    "torrent_added",
+    "torrent_removed",
+    "torrent_started",
+    "torrent_stopped",
+    "download_complete",
+    "tracker_error",
+    "tracker_warning",
+    "storage_error",
+    "alt_speed_changed",
+  ];
+  for (const type of types) {
+    source.addEventListener(type, (msg) => addEvent(JSON.parse(msg.data)));
+  }
+}
+
+// Peer limits are not shown, they are sent back unchanged
+let currentLimits = {};
+
+async function loadLimits() {
+  const limits = await call("Session.GetSpeedLimits");
+  if (limits) {
+    currentLimits = limits;
+    $("limit-download").value = limits.Download;
+    $("limit-upload").value = limits.Upload;
+  }
+}
+
+function readFileBase64(file) {
+  return new Promise((resolve, reject) => {
+    const reader = new FileReader();
+    reader.onload = () => resolve(reader.result.substring(reader.result.indexOf(",") + 1));
+    reader.onerror = () => reject(reader.error);
+    reader.readAsDataURL(file);
+  });
+}
+
+$("add-form").addEventListener("submit", async (ev) => {
+  ev.preventDefault();
+  const uri = $("add-uri").value.trim();
+  const file = $("add-file").files[0];
+  const stopped = $("add-stopped").checked;
+
+  try {
+    if (file) {
+      await rpc("Session.AddTorrent", { Torrent: await readFileBase64(file), Stopped: stopped });
+    } else if (uri) {
+      await rpc("Session.AddURI", { URI: uri, Stopped: stopped });
+    } else {
+      return;
+    }
+    ev.target.reset();
+  } catch (err) {
+    showError(err);
+  }
+});
+
+$("limits-form").addEventListener("submit", async (ev) => {
+  ev.preventDefault();
+  await call("Session.SetSpeedLimits", {
+    ...currentLimits,
+    Download: parseInt($("limit-download").value, 10) || 0,
+    Upload: parseInt($("limit-upload").value, 10) || 0,
+  });
+  loadLimits();
+});
+
+$("error").addEventListener("click", (ev) => {
+  ev.target.hidden = true;
+});
+
+window.addEventListener("hashchange", route);
+route();
+loadLimits();
+connectEvents();
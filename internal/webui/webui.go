@@ -0,0 +1,25 @@
+// Package webui contains the web interface of the daemon.
+//
+// The interface is a single page that calls the JSON-RPC API at "rpc" and listens to the server-sent events at "events",
+// relative to the path it is served from.
+package webui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler serves the files of the web interface.
+func Handler() http.Handler {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		// The directory is embedded at build time so it always exists
+		panic(err)
+	}
+
+	return http.FileServer(http.FS(sub))
+}
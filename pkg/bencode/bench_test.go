@@ -0,0 +1,153 @@
+package bencode
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// benchTorrent is a torrent file with 4096 pieces and 100 files.
+func benchTorrent(b *testing.B) []byte {
+	b.Helper()
+	type file struct {
+		Length int64    `bencode:"length"`
+		Path   []string `bencode:"path"`
+	}
+	var files []file
+	for i := 0; i < 100; i++ {
+		files = append(files, file{Length: int64(i) * 1000, Path: []string{"dir", "file" + strconv.Itoa(i)}})
+	}
+	t := map[string]interface{}{
+		"announce": "http://tracker.example/announce",
+		"info": map[string]interface{}{
+			"files":        files,
+			"name":         "bench",
+			"piece length": 1 << 18,
+			"pieces":       strings.Repeat("01234567890123456789", 4096),
+		},
+	}
+	data, err := Marshal(t)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+// benchDict is a flat dict with 10000 integer values.
+func benchDict(b *testing.B) []byte {
+	b.Helper()
+	m := make(map[string]int64)
+	for i := 0; i < 10000; i++ {
+		m["key"+strconv.Itoa(i)] = int64(i)
+	}
+	data, err := Marshal(m)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+func BenchmarkDecodeInterface(b *testing.B) {
+	data := benchTorrent(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var v interface{}
+		if err := Unmarshal(data, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeStruct(b *testing.B) {
+	data := benchTorrent(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var v struct {
+			Announce string `bencode:"announce"`
+			Info     struct {
+				Files []struct {
+					Length int64    `bencode:"length"`
+					Path   []string `bencode:"path"`
+				} `bencode:"files"`
+				Name        string `bencode:"name"`
+				PieceLength int64  `bencode:"piece length"`
+				Pieces      []byte `bencode:"pieces"`
+			} `bencode:"info"`
+		}
+		if err := Unmarshal(data, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeRawInfo(b *testing.B) {
+	data := benchTorrent(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var v struct {
+			Info Bytes `bencode:"info"`
+		}
+		if err := Unmarshal(data, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeMap(b *testing.B) {
+	data := benchDict(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var v map[string]int64
+		if err := Unmarshal(data, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkToken(b *testing.B) {
+	data := benchDict(b)
+	r := bytes.NewReader(data)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(data)
+		d := Decoder{r: r}
+		for d.Depth() > 0 || d.Offset == 0 {
+			if _, err := d.Token(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkSkip(b *testing.B) {
+	data := benchTorrent(b)
+	r := bytes.NewReader(data)
+	d := Decoder{r: r}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(data)
+		if err := d.Skip(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	var v interface{}
+	if err := Unmarshal(benchTorrent(b), &v); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
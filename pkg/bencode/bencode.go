@@ -22,8 +22,21 @@ func Marshal(v interface{}) ([]byte, error) {
 }
 
 func Unmarshal(data []byte, v interface{}) (err error) {
+	return UnmarshalWithOptions(data, v)
+}
+
+// UnmarshalStrict is like Unmarshal but rejects non-canonical input.
+func UnmarshalStrict(data []byte, v interface{}) error {
+	return UnmarshalWithOptions(data, v, WithStrict())
+}
+
+// UnmarshalWithOptions is like Unmarshal with a Decoder configured by opts.
+func UnmarshalWithOptions(data []byte, v interface{}, opts ...DecoderOption) (err error) {
 	buf := bytes.NewReader(data)
 	decoder := Decoder{r: buf}
+	for _, opt := range opts {
+		opt(&decoder)
+	}
 	err = decoder.Decode(v)
 
 	if err != nil {
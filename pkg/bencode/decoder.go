@@ -17,20 +17,54 @@ import (
 
 const DefaultDecodeMaxStrLen = 1<<27 - 1 // ~128MiB
 
+// UntrustedMaxStrLen is the string length limit of the Untrusted option.
+const UntrustedMaxStrLen = 1 << 20
+
 type MaxStrLen = int64
 
 type Decoder struct {
 	MaxStrLen MaxStrLen
-	r         interface {
+	// Strict rejects dicts with unsorted or duplicate keys. Such input is
+	// not canonical (BEP 3) and changes when it is encoded again.
+	Strict bool
+	r      interface {
 		io.ByteScanner
 		io.Reader
 	}
 	Offset int64
 	buf    bytes.Buffer
+	// open dicts and lists
+	stack []frame
+	// bytes of the value read by readRaw
+	raw       []byte
+	capturing bool
+}
+
+// DecoderOption configures a Decoder.
+type DecoderOption func(d *Decoder)
+
+// WithStrict enables the strict mode of the Decoder.
+func WithStrict() DecoderOption {
+	return func(d *Decoder) { d.Strict = true }
+}
+
+// WithMaxStrLen limits the length of strings in the input.
+func WithMaxStrLen(n MaxStrLen) DecoderOption {
+	return func(d *Decoder) { d.MaxStrLen = n }
+}
+
+// Untrusted limits strings to UntrustedMaxStrLen for decoding network
+// input such as tracker responses.
+func Untrusted() DecoderOption {
+	return WithMaxStrLen(UntrustedMaxStrLen)
 }
 
-func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{r: bufio.NewReader(r)}
+func NewDecoder(r io.Reader, opts ...DecoderOption) *Decoder {
+	d := &Decoder{r: bufio.NewReader(r)}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
 func (d *Decoder) Decode(v interface{}) error {
@@ -51,6 +85,7 @@ func (d *Decoder) Decode(v interface{}) error {
 	return nil
 }
 
+// parseValue decodes the next value into v. It returns false at the end of a dict or list.
 func (d *Decoder) parseValue(v reflect.Value) (bool, error) {
 	if v.Kind() == reflect.Ptr {
 		if v.IsNil() {
@@ -69,64 +104,58 @@ func (d *Decoder) parseValue(v reflect.Value) (bool, error) {
 		return true, nil
 	}
 
+	tok, err := d.Token()
+	if err != nil {
+		return false, err
+	}
+
+	if tok.Kind == TokenEnd {
+		return false, nil
+	}
+
 	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
-		iface, ok, err := d.parseValueInterface()
+		iface, err := d.parseInterface(tok)
 		if err != nil {
 			return false, err
 		}
 
 		v.Set(reflect.ValueOf(iface))
-		return ok, nil
-	}
-
-	b, err := d.r.ReadByte()
-	if err != nil {
-		return false, err
+		return true, nil
 	}
 
-	d.Offset++
-
-	switch b {
-	case 'e':
-		return false, nil
-	case 'd':
+	switch tok.Kind {
+	case TokenDictStart:
 		return true, d.parseDict(v)
-	case 'l':
+	case TokenListStart:
 		return true, d.parseList(v)
-	case 'i':
-		return true, d.parseInt(v)
+	case TokenInt:
+		return true, d.parseInt(v, tok)
 	default:
-		if b >= '0' && b <= '9' {
-			d.buf.Reset()
-			d.buf.WriteByte(b)
-			return true, d.parseString(v)
-		}
-
-		return false, d.unknowValueType(b, d.Offset-1)
+		return true, d.parseString(v, tok)
 	}
 }
 
 func (d *Decoder) parseUnmarshaler(v reflect.Value) (bool, error) {
 	if !v.Type().Implements(unmarshalerType) {
-		if v.Addr().Type().Implements(unmarshalerType) {
+		if v.CanAddr() && v.Addr().Type().Implements(unmarshalerType) {
 			v = v.Addr()
 		} else {
 			return false, nil
 		}
 	}
-	d.buf.Reset()
-	ok, err := d.readOneValue()
 
-	if err != nil {
-		return false, err
+	// The end of a list and errors are handled by the caller
+	if b, err := d.peekByte(); err != nil || b == 'e' {
+		return false, nil
 	}
 
-	if !ok {
-		return false, nil
+	raw, err := d.readRaw()
+	if err != nil {
+		return false, err
 	}
 
 	m := v.Interface().(Unmarshaler)
-	err = m.UnmarshalBencode(d.buf.Bytes())
+	err = m.UnmarshalBencode(raw)
 
 	if err != nil {
 		return false, err
@@ -135,92 +164,21 @@ func (d *Decoder) parseUnmarshaler(v reflect.Value) (bool, error) {
 	return true, nil
 }
 
-func (d *Decoder) readOneValue() (bool, error) {
+func (d *Decoder) peekByte() (byte, error) {
 	b, err := d.r.ReadByte()
-
 	if err != nil {
-		return false, err
-	}
-
-	if b == 'e' {
-		err := d.r.UnreadByte()
-
-		if err != nil {
-			return false, err
-		}
-
-		return false, nil
-	} else {
-		d.Offset++
-		d.buf.WriteByte(b)
-	}
-
-	switch b {
-	case 'd', 'l':
-		// read until there is nothing to read
-		for {
-			ok, err := d.readOneValue()
-			if err != nil {
-				return false, err
-			}
-			if !ok {
-				break
-			}
-		}
-		// consume 'e' as well
-		b, err = d.readByte()
-		if err != nil {
-			return false, err
-		}
-		d.buf.WriteByte(b)
-	case 'i':
-		if err := d.readUntil('e'); err != nil {
-			return false, err
-		}
-		d.buf.WriteString("e")
-	default:
-		if b >= '0' && b <= '9' {
-			start := d.buf.Len() - 1
-
-			if err := d.readUntil(':'); err != nil {
-				return false, err
-			}
-
-			length, err := strconv.ParseInt(bytesAsString(d.buf.Bytes()[start:]), 10, 64)
-
-			if err := checkForIntParseError(err, d.Offset-1); err != nil {
-				return false, err
-			}
-
-			d.buf.WriteString(":")
-			n, err := io.CopyN(&d.buf, d.r, length)
-			d.Offset += n
-
-			if err != nil {
-				return false, checkForUnexpectedEOF(err, d.Offset)
-			}
-			break
-		}
-
-		return false, d.unknowValueType(b, d.Offset-1)
+		return 0, err
 	}
-
-	return true, nil
+	return b, d.r.UnreadByte()
 }
 
-func (d *Decoder) parseInt(v reflect.Value) error {
-	start := d.Offset - 1
-
-	if err := d.readInt(); err != nil {
-		return err
-	}
-
-	s := bytesAsString(d.buf.Bytes())
+func (d *Decoder) parseInt(v reflect.Value, tok Token) error {
+	s := bytesAsString(tok.Bytes)
 
 	switch v.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		n, err := strconv.ParseInt(s, 10, 64)
-		if err := checkForIntParseError(err, start); err != nil {
+		if err := checkForIntParseError(err, tok.Offset); err != nil {
 			return err
 		}
 
@@ -233,7 +191,7 @@ func (d *Decoder) parseInt(v reflect.Value) error {
 		v.SetInt(n)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		n, err := strconv.ParseUint(s, 10, 64)
-		if err := checkForIntParseError(err, start); err != nil {
+		if err := checkForIntParseError(err, tok.Offset); err != nil {
 			return err
 		}
 
@@ -253,178 +211,43 @@ func (d *Decoder) parseInt(v reflect.Value) error {
 		}
 	}
 
-	d.buf.Reset()
 	return nil
 }
 
-func (d *Decoder) readInt() error {
-	if err := d.readUntil('e'); err != nil {
-		return err
-	}
-
-	if err := d.checkBufferedInt(); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (d *Decoder) readUntil(c byte) error {
-	for {
-		b, err := d.readByte()
-
-		if err != nil {
-			return err
-		}
-
-		if b == c {
-			return nil
-		}
-
-		d.buf.WriteByte(b)
-	}
-}
-
-func (d *Decoder) readByte() (byte, error) {
-	b, err := d.r.ReadByte()
-	if err != nil {
-		err = checkForUnexpectedEOF(err, d.Offset)
-		return 0, err
-	}
-
-	d.Offset++
-	return b, nil
-}
-
-func (d *Decoder) readBytes(length int) ([]byte, error) {
-	b := make([]byte, length)
-	n, err := io.ReadFull(d.r, b)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if n != length {
-		return nil, fmt.Errorf("read %v bytes expected %v", n, length)
-	}
-
-	return b, nil
-}
-
-func (d *Decoder) checkBufferedInt() error {
-	b := d.buf.Bytes()
-	if len(b) <= 1 {
-		return nil
-	}
-
-	if b[0] == '-' {
-		b = b[1:]
-	}
-
-	if b[0] < '1' || b[0] > '9' {
-		return errors.New("Invalid leading digit")
-	}
-
-	return nil
-}
-
-func (d *Decoder) parseString(v reflect.Value) error {
-	length, err := d.parseStringLength()
-
-	if err != nil {
-		return err
-	}
-
-	defer d.buf.Reset()
-
-	read := func(b []byte) error {
-		n, err := io.ReadFull(d.r, b)
-		d.Offset += int64(n)
-		if err != nil {
-			return checkForUnexpectedEOF(err, d.Offset)
-		}
-
-		return nil
-	}
-
+func (d *Decoder) parseString(v reflect.Value, tok Token) error {
 	switch v.Kind() {
 	case reflect.String:
-		b := make([]byte, length)
-		if err := read(b); err != nil {
-			return err
-		}
-		v.SetString(bytesAsString(b))
+		v.SetString(string(tok.Bytes))
 		return nil
 	case reflect.Slice:
 		if v.Type().Elem().Kind() != reflect.Uint8 {
 			break
 		}
-		b := make([]byte, length)
-		if err := read(b); err != nil {
-			return err
-		}
+		b := make([]byte, len(tok.Bytes))
+		copy(b, tok.Bytes)
 		v.SetBytes(b)
 		return nil
 	case reflect.Array:
 		if v.Type().Elem().Kind() != reflect.Uint8 {
 			break
 		}
-		d.buf.Grow(length)
-		b := d.buf.Bytes()[:length]
-		if err := read(b); err != nil {
-			return err
-		}
-		reflect.Copy(v, reflect.ValueOf(b))
+		reflect.Copy(v, reflect.ValueOf(tok.Bytes))
 		return nil
 	case reflect.Bool:
-		d.buf.Grow(length)
-		b := d.buf.Bytes()[:length]
-		if err := read(b); err != nil {
-			return err
-		}
-		x, err := strconv.ParseBool(bytesAsString(b))
+		x, err := strconv.ParseBool(bytesAsString(tok.Bytes))
 		if err != nil {
-			x = length != 0
+			x = len(tok.Bytes) != 0
 		}
 		v.SetBool(x)
 		return nil
 	}
 
-	d.buf.Grow(length)
-	b := d.buf.Bytes()[:length]
-	if err := read(b); err != nil {
-		return err
-	}
-
 	return &UnmarshalTypeError{
 		BencodeTypeName:     "string",
 		UnmarshalTargetType: v.Type(),
 	}
 }
 
-func (d *Decoder) parseStringLength() (int, error) {
-	start := d.Offset - 1
-
-	if err := d.readUntil(':'); err != nil {
-		return 0, err
-	}
-
-	if err := d.checkBufferedInt(); err != nil {
-		return 0, err
-	}
-
-	length, err := strconv.ParseInt(bytesAsString(d.buf.Bytes()), 10, 0)
-	if err := checkForIntParseError(err, start); err != nil {
-		return 0, err
-	}
-
-	if int64(length) > d.getMaxStrLen() {
-		err = fmt.Errorf("parsed string length %v exceeds limit [%v]", length, DefaultDecodeMaxStrLen)
-	}
-	d.buf.Reset()
-	return int(length), err
-}
-
 func (d *Decoder) getMaxStrLen() int64 {
 	if d.MaxStrLen == 0 {
 		return DefaultDecodeMaxStrLen
@@ -468,14 +291,18 @@ func (d *Decoder) parseList(v reflect.Value) error {
 				break
 			}
 		} else {
-			_, ok, err := d.parseValueInterface()
+			tok, err := d.Token()
 			if err != nil {
 				return err
 			}
 
-			if !ok {
+			if tok.Kind == TokenEnd {
 				break
 			}
+
+			if err := d.skipRest(tok); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -503,40 +330,45 @@ func (d *Decoder) parseDict(v reflect.Value) error {
 	keyType := keyType(v)
 
 	if keyType == nil {
-		return fmt.Errorf("cannot parse dicts into %v", v.Type())
+		// Consume the dict so that the error can be ignored
+		if err := d.skipRest(Token{Kind: TokenDictStart}); err != nil {
+			return err
+		}
+		return &UnmarshalTypeError{
+			BencodeTypeName:     "dict",
+			UnmarshalTargetType: v.Type(),
+		}
 	}
 
 	for {
-		keyValue := reflect.New(keyType).Elem()
-		ok, err := d.parseValue(keyValue)
-
+		tok, err := d.Token()
 		if err != nil {
-			return fmt.Errorf("error parsing dict key: %w", err)
+			return err
 		}
-		if !ok {
+		if tok.Kind == TokenEnd {
 			return nil
 		}
 
+		keyValue := reflect.New(keyType).Elem()
+		if err := d.parseString(keyValue, tok); err != nil {
+			return fmt.Errorf("error parsing dict key: %w", err)
+		}
+
 		df, err := getDictField(v.Type(), keyValue)
 		if err != nil {
 			return fmt.Errorf("parsing bencode dict into %v: %w", v.Type(), err)
 		}
 
 		if df.Type == nil {
-			_, ok, err = d.parseValueInterface()
-			if err != nil {
+			if err := d.Skip(); err != nil {
 				return err
 			}
 
-			if !ok {
-				return fmt.Errorf("missing value for key %q", keyValue)
-			}
-
 			continue
 		}
 
 		setValue := reflect.New(df.Type).Elem()
-		ok, err = d.parseValue(setValue)
+		ok, err := d.parseValue(setValue)
 		if err != nil {
 			var target *UnmarshalTypeError
 			if !(errors.As(err, &target) && df.Tags.IgnoreUnmarshalTypeError()) {
@@ -684,96 +516,58 @@ func (d *Decoder) unknowValueType(b byte, offset int64) *SyntaxError {
 	}
 }
 
-func (d *Decoder) parseValueInterface() (interface{}, bool, error) {
-	b, err := d.r.ReadByte()
-	if err != nil {
-		return nil, false, err
-	}
-
-	d.Offset++
-
-	switch b {
-	case 'e':
-		return nil, false, nil
-	case 'd':
-		val, err := d.parseDictInterface()
-		return val, true, err
-	case 'l':
-		val, err := d.parseListInterface()
-		return val, true, err
-	case 'i':
-		val, err := d.parseIntInterface()
-		return val, true, err
+func (d *Decoder) parseInterface(tok Token) (interface{}, error) {
+	switch tok.Kind {
+	case TokenDictStart:
+		return d.parseDictInterface()
+	case TokenListStart:
+		return d.parseListInterface()
+	case TokenInt:
+		return d.parseIntInterface(tok)
 	default:
-		if b >= '0' && b <= '9' {
-			d.buf.Reset()
-			d.buf.WriteByte(b)
-			val, err := d.parseStringInterface()
-			return val, true, err
-		}
-
-		return nil, false, d.unknowValueType(b, d.Offset-1)
+		return string(tok.Bytes), nil
 	}
 }
 
-func (d *Decoder) parseIntInterface() (ret interface{}, err error) {
-	start := d.Offset - 1
-
-	if err := d.readInt(); err != nil {
-		return nil, err
-	}
-	n, err := strconv.ParseInt(d.buf.String(), 10, 64)
+func (d *Decoder) parseIntInterface(tok Token) (ret interface{}, err error) {
+	s := bytesAsString(tok.Bytes)
+	n, err := strconv.ParseInt(s, 10, 64)
 	if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
 		i := new(big.Int)
-		_, ok := i.SetString(d.buf.String(), 10)
+		_, ok := i.SetString(s, 10)
 		if !ok {
 			return nil, &SyntaxError{
-				Offset: start,
+				Offset: tok.Offset,
 				Err:    errors.New("failed to parse integer"),
 			}
 		}
-		ret = i
-	} else {
-		if err := checkForIntParseError(err, start); err != nil {
-			return nil, err
-		}
-		ret = n
-	}
-
-	d.buf.Reset()
-	return ret, nil
-}
-
-func (d *Decoder) parseStringInterface() (string, error) {
-	length, err := d.parseStringLength()
-
-	if err != nil {
-		return "", err
+		return i, nil
 	}
 
-	b, err := d.readBytes(int(length))
-
-	if err != nil {
-		return "", &SyntaxError{Offset: d.Offset, Err: err}
+	if err := checkForIntParseError(err, tok.Offset); err != nil {
+		return nil, err
 	}
-
-	d.Offset += int64(len(b))
-	return bytesAsString(b), nil
+	return n, nil
 }
 
 func (d *Decoder) parseListInterface() (list []interface{}, err error) {
 	list = []interface{}{}
 
 	for {
-		valuei, ok, err := d.parseValueInterface()
+		tok, err := d.Token()
 		if err != nil {
 			return nil, err
 		}
 
-		if !ok {
+		if tok.Kind == TokenEnd {
 			break
 		}
 
+		valuei, err := d.parseInterface(tok)
+		if err != nil {
+			return nil, err
+		}
+
 		list = append(list, valuei)
 	}
 	return list, nil
@@ -785,39 +579,28 @@ func (d *Decoder) parseDictInterface() (interface{}, error) {
 	lastKeyOk := false
 
 	for {
-		start := d.Offset
-		keyi, ok, err := d.parseValueInterface()
-
+		tok, err := d.Token()
 		if err != nil {
 			return nil, err
 		}
 
-		if !ok {
+		if tok.Kind == TokenEnd {
 			break
 		}
 
-		key, ok := keyi.(string)
-
-		if !ok {
-			return nil, &SyntaxError{
-				Offset: d.Offset,
-				Err:    errors.New("non-string key in a dict"),
-			}
-		}
-
+		key := string(tok.Bytes)
 		if lastKeyOk && key <= lastKey {
-			return nil, d.makeSyntaxError(start, fmt.Errorf("dict keys unsorted: %q <= %q", key, lastKey))
+			return nil, d.makeSyntaxError(tok.Offset, fmt.Errorf("dict keys unsorted: %q <= %q", key, lastKey))
 		}
 
-		start = d.Offset
-		valuei, ok, err := d.parseValueInterface()
-
+		tok, err = d.Token()
 		if err != nil {
 			return nil, err
 		}
 
-		if !ok {
-			return nil, d.makeSyntaxError(start, fmt.Errorf("dict elem missing value [key=%v]", key))
+		valuei, err := d.parseInterface(tok)
+		if err != nil {
+			return nil, err
 		}
 
 		lastKey = key
@@ -858,7 +641,7 @@ func checkForIntParseError(err error, offset int64) error {
 }
 
 func checkForUnexpectedEOF(err error, offset int64) error {
-	if err == io.EOF {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
 		return &SyntaxError{
 			Offset: offset,
 			Err:    io.ErrUnexpectedEOF,
@@ -114,3 +114,34 @@ func TestDecodeInvalidArg(t *testing.T) {
 		t.Fatalf("expected UnmarshalInvalidArgError, got %T", err)
 	}
 }
+
+func TestDecodeInterfaceSlice(t *testing.T) {
+	var result []interface{}
+	if err := Unmarshal([]byte("ll4:hosti1eei2ee"), &result); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []interface{}{[]interface{}{"host", int64(1)}, int64(2)}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected result:\n%#v\ngot:\n%#v", expected, result)
+	}
+}
+
+func TestDecodeStructRawAndUnknownFields(t *testing.T) {
+	var result struct {
+		Info  Bytes  `bencode:"info"`
+		Name  string `bencode:"name"`
+		Count int    `bencode:"count,ignore_unmarshal_type_error"`
+	}
+	input := "d5:countd1:ai1ee4:infod6:lengthi5ee4:name3:foo7:unknownl1:x1:yee"
+	if err := Unmarshal([]byte(input), &result); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if string(result.Info) != "d6:lengthi5ee" {
+		t.Errorf("Unexpected raw info %q", result.Info)
+	}
+	if result.Name != "foo" || result.Count != 0 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
@@ -28,6 +28,10 @@ func (e *Encoder) Encode(v interface{}) error {
 var bigIntType = reflect.TypeOf((*big.Int)(nil)).Elem()
 
 func (e *Encoder) encodeValue(v reflect.Value) error {
+	if ok, err := e.encodeMarshaler(v); ok {
+		return err
+	}
+
 	if v.Type() == bigIntType {
 		if err := e.writeString("i"); err != nil {
 			return err
@@ -123,6 +127,25 @@ func (e *Encoder) encodeValue(v reflect.Value) error {
 	}
 }
 
+// encodeMarshaler writes the output of MarshalBencode verbatim if v implements Marshaler.
+func (e *Encoder) encodeMarshaler(v reflect.Value) (bool, error) {
+	if !v.Type().Implements(marshalerType) {
+		if v.Kind() == reflect.Ptr || !v.CanAddr() || !reflect.PointerTo(v.Type()).Implements(marshalerType) {
+			return false, nil
+		}
+		v = v.Addr()
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return false, nil
+	}
+
+	b, err := v.Interface().(Marshaler).MarshalBencode()
+	if err != nil {
+		return true, err
+	}
+	return true, e.write(b)
+}
+
 func (e *Encoder) encodeString(s string) error {
 	if err := e.writeStringPrefix(int64(len(s))); err != nil {
 		return err
@@ -266,7 +289,7 @@ func (ef encodeFieldsSortType) Swap(i, j int) {
 }
 
 func (ef encodeFieldsSortType) Less(i, j int) bool {
-	return ef[i].tag < ef[i].tag
+	return ef[i].tag < ef[j].tag
 }
 
 var (
@@ -106,7 +106,7 @@ func TestEncode(t *testing.T) {
 				Age    int    `bencode:"age"`
 				OmitMe string `bencode:"omit_me,omitempty"`
 			}{"Alice", 30, ""},
-			expectedOutput: "d4:name5:Alice3:agei30ee",
+			expectedOutput: "d3:agei30e4:name5:Alicee",
 		},
 		{
 			input: struct {
@@ -115,6 +115,12 @@ func TestEncode(t *testing.T) {
 			}{"Bob", 0},
 			expectedOutput: "d4:name3:Bobe",
 		},
+		{
+			input: struct {
+				Info Bytes `bencode:"info"`
+			}{Bytes("d1:ai1ee")},
+			expectedOutput: "d4:infod1:ai1eee",
+		},
 	}
 
 	for i, tc := range testCases {
@@ -0,0 +1,357 @@
+package bencode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Strings that are not valid UTF-8 are converted to JSON objects with a
+// single key: {"$hex": "..."} or {"$base64": "..."}. Dict keys that are not
+// valid UTF-8 are written as "$hex:..." or "$base64:...", and keys of
+// dicts starting with "$" are escaped with another "$". This keeps the
+// conversion lossless for canonical input.
+const (
+	jsonHex     = "$hex"
+	jsonBase64  = "$base64"
+	jsonSummary = "$bytes"
+)
+
+var errSummary = errors.New("summarised string cannot be converted to bencode")
+
+// JSONOptions controls the conversion of bencode to JSON.
+type JSONOptions struct {
+	// Base64 encodes binary strings with base64 instead of hex.
+	Base64 bool
+	// Indent pretty-prints the JSON if not empty.
+	Indent string
+	// If positive, binary strings longer than SummarizeLen are replaced by
+	// {"$bytes": length}. Such output cannot be converted back.
+	SummarizeLen int
+}
+
+// ToJSON converts a bencoded value to JSON. Integers are written as JSON
+// numbers of any size, dicts keep the order of the input.
+func ToJSON(data []byte, opts *JSONOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &JSONOptions{}
+	}
+
+	r := bytes.NewReader(data)
+	d := &Decoder{r: r}
+	var buf bytes.Buffer
+	c := jsonConverter{opts: opts, d: d, buf: &buf}
+
+	tok, err := d.Token()
+	if err == io.EOF {
+		return nil, d.makeSyntaxError(0, io.ErrUnexpectedEOF)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = c.value(tok); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, ErrUnusedTrailingBytes{r.Len()}
+	}
+
+	if opts.Indent == "" {
+		return buf.Bytes(), nil
+	}
+	var out bytes.Buffer
+	if err = json.Indent(&out, buf.Bytes(), "", opts.Indent); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+type jsonConverter struct {
+	opts *JSONOptions
+	d    *Decoder
+	buf  *bytes.Buffer
+}
+
+func (c *jsonConverter) value(tok Token) error {
+	switch tok.Kind {
+	case TokenDictStart:
+		return c.dict()
+	case TokenListStart:
+		return c.list()
+	case TokenInt:
+		c.buf.Write(tok.Bytes)
+		return nil
+	default:
+		c.str(tok.Bytes)
+		return nil
+	}
+}
+
+func (c *jsonConverter) dict() error {
+	c.buf.WriteByte('{')
+	for i := 0; ; i++ {
+		tok, err := c.d.Token()
+		if err != nil {
+			return err
+		}
+		if tok.Kind == TokenEnd {
+			break
+		}
+		if i > 0 {
+			c.buf.WriteByte(',')
+		}
+		c.key(tok.Bytes)
+		c.buf.WriteByte(':')
+
+		tok, err = c.d.Token()
+		if err != nil {
+			return err
+		}
+		if err = c.value(tok); err != nil {
+			return err
+		}
+	}
+	c.buf.WriteByte('}')
+	return nil
+}
+
+func (c *jsonConverter) list() error {
+	c.buf.WriteByte('[')
+	for i := 0; ; i++ {
+		tok, err := c.d.Token()
+		if err != nil {
+			return err
+		}
+		if tok.Kind == TokenEnd {
+			break
+		}
+		if i > 0 {
+			c.buf.WriteByte(',')
+		}
+		if err = c.value(tok); err != nil {
+			return err
+		}
+	}
+	c.buf.WriteByte(']')
+	return nil
+}
+
+func (c *jsonConverter) key(b []byte) {
+	switch {
+	case !utf8.Valid(b):
+		if c.opts.Base64 {
+			writeJSONString(c.buf, jsonBase64+":"+base64.StdEncoding.EncodeToString(b))
+		} else {
+			writeJSONString(c.buf, jsonHex+":"+hex.EncodeToString(b))
+		}
+	case bytes.HasPrefix(b, []byte("$")):
+		writeJSONString(c.buf, "$"+string(b))
+	default:
+		writeJSONString(c.buf, string(b))
+	}
+}
+
+func (c *jsonConverter) str(b []byte) {
+	if utf8.Valid(b) {
+		writeJSONString(c.buf, string(b))
+		return
+	}
+
+	c.buf.WriteByte('{')
+	switch {
+	case c.opts.SummarizeLen > 0 && len(b) > c.opts.SummarizeLen:
+		writeJSONString(c.buf, jsonSummary)
+		c.buf.WriteString(":" + strconv.Itoa(len(b)))
+	case c.opts.Base64:
+		writeJSONString(c.buf, jsonBase64)
+		c.buf.WriteByte(':')
+		writeJSONString(c.buf, base64.StdEncoding.EncodeToString(b))
+	default:
+		writeJSONString(c.buf, jsonHex)
+		c.buf.WriteByte(':')
+		writeJSONString(c.buf, hex.EncodeToString(b))
+	}
+	c.buf.WriteByte('}')
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	_ = e.Encode(s)
+	// Encode appends a newline
+	buf.Truncate(buf.Len() - 1)
+}
+
+// FromJSON converts JSON written by ToJSON back to bencode.
+// Dict keys are sorted, so the output is canonical.
+func FromJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	b, err := jsonValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		return nil, errors.New("bencode: trailing data after JSON value")
+	}
+	return b, nil
+}
+
+func jsonValue(dec *json.Decoder) ([]byte, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := tok.(type) {
+	case string:
+		return appendString(nil, v), nil
+	case json.Number:
+		i, ok := new(big.Int).SetString(v.String(), 10)
+		if !ok {
+			return nil, fmt.Errorf("bencode: JSON number %s is not an integer", v)
+		}
+		return []byte("i" + i.String() + "e"), nil
+	case json.Delim:
+		switch v {
+		case '[':
+			return jsonList(dec)
+		case '{':
+			return jsonObject(dec)
+		}
+	}
+	return nil, fmt.Errorf("bencode: JSON value %v has no bencode representation", tok)
+}
+
+func jsonList(dec *json.Decoder) ([]byte, error) {
+	ret := []byte{'l'}
+	for dec.More() {
+		b, err := jsonValue(dec)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, b...)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return append(ret, 'e'), nil
+}
+
+func jsonObject(dec *json.Decoder) ([]byte, error) {
+	type item struct {
+		key   string
+		value []byte
+	}
+	var items []item
+
+	for i := 0; dec.More(); i++ {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := tok.(string)
+
+		if i == 0 && (key == jsonHex || key == jsonBase64 || key == jsonSummary) {
+			return jsonBinary(dec, key)
+		}
+
+		key, err = jsonKey(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := jsonValue(dec)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item{key, value})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].key < items[j].key })
+	ret := []byte{'d'}
+	for i, it := range items {
+		if i > 0 && it.key == items[i-1].key {
+			return nil, fmt.Errorf("bencode: duplicate key %q in JSON object", it.key)
+		}
+		ret = appendString(ret, it.key)
+		ret = append(ret, it.value...)
+	}
+	return append(ret, 'e'), nil
+}
+
+// jsonBinary reads the rest of a {"$hex": "..."} or {"$base64": "..."} object.
+func jsonBinary(dec *json.Decoder, kind string) ([]byte, error) {
+	if kind == jsonSummary {
+		return nil, errSummary
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	s, ok := tok.(string)
+	if !ok {
+		return nil, fmt.Errorf("bencode: %s value must be a string", kind)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("bencode: %s object must have a single key", kind)
+	}
+	if _, err = dec.Token(); err != nil {
+		return nil, err
+	}
+
+	b, err := decodeJSONBinary(kind, s)
+	if err != nil {
+		return nil, err
+	}
+	return appendString(nil, string(b)), nil
+}
+
+func jsonKey(key string) (string, error) {
+	switch {
+	case strings.HasPrefix(key, "$$"):
+		return key[1:], nil
+	case strings.HasPrefix(key, jsonHex+":"):
+		b, err := decodeJSONBinary(jsonHex, key[len(jsonHex)+1:])
+		return string(b), err
+	case strings.HasPrefix(key, jsonBase64+":"):
+		b, err := decodeJSONBinary(jsonBase64, key[len(jsonBase64)+1:])
+		return string(b), err
+	case strings.HasPrefix(key, "$"):
+		return "", fmt.Errorf("bencode: unknown escaped key %q", key)
+	}
+	return key, nil
+}
+
+func decodeJSONBinary(kind, s string) ([]byte, error) {
+	var b []byte
+	var err error
+	if kind == jsonHex {
+		b, err = hex.DecodeString(s)
+	} else {
+		b, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bencode: invalid %s string: %w", kind, err)
+	}
+	return b, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = strconv.AppendInt(b, int64(len(s)), 10)
+	b = append(b, ':')
+	return append(b, s...)
+}
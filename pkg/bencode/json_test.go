@@ -0,0 +1,110 @@
+package bencode
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		json  string
+	}{
+		{"int", "i-42e", `-42`},
+		{"big int", "i123456789012345678901234567890e", `123456789012345678901234567890`},
+		{"string", "5:a\"<b>", `"a\"<b>"`},
+		{"binary", "3:\x00\xff\x10", `{"$hex":"00ff10"}`},
+		{"list", "li1e0:lee", `[1,"",[]]`},
+		{"dict", "d1:ai1e1:bdee", `{"a":1,"b":{}}`},
+		{"binary key", "d2:\xff\xfei1ee", `{"$hex:fffe":1}`},
+		{"escaped key", "d4:$hex3:\xff\x00\x01e", `{"$$hex":{"$hex":"ff0001"}}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			j, err := ToJSON([]byte(tc.input), nil)
+			if err != nil {
+				t.Fatalf("ToJSON: %v", err)
+			}
+			if string(j) != tc.json {
+				t.Errorf("Expected JSON %s, got %s", tc.json, j)
+			}
+
+			b, err := FromJSON(j)
+			if err != nil {
+				t.Fatalf("FromJSON: %v", err)
+			}
+			if string(b) != tc.input {
+				t.Errorf("Expected bencode %q, got %q", tc.input, b)
+			}
+		})
+	}
+}
+
+func TestJSONTorrentFile(t *testing.T) {
+	data, err := os.ReadFile("../metainfo/testdata/hybrid.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range []*JSONOptions{nil, {Base64: true, Indent: "  "}} {
+		j, err := ToJSON(data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := FromJSON(j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, b) {
+			t.Errorf("Round trip with options %+v changed the torrent file", opts)
+		}
+	}
+
+	j, err := ToJSON(data, &JSONOptions{SummarizeLen: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(j, []byte(`"pieces":{"$bytes":120}`)) {
+		t.Errorf("Expected summarised pieces in %s", j)
+	}
+	if _, err = FromJSON(j); err == nil {
+		t.Error("Expected error converting summarised JSON")
+	}
+}
+
+func TestJSONSortsKeys(t *testing.T) {
+	b, err := FromJSON([]byte(`{"b": [1, 2], "a": "x", "$$c": {"$base64": "AAE="}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "d2:$c2:\x00\x011:a1:x1:bli1ei2eee" {
+		t.Errorf("Unexpected bencode %q", b)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	for _, s := range []string{
+		`1.5`,
+		`1e3`,
+		`true`,
+		`null`,
+		`{"$hex": "zz"}`,
+		`{"$hex": "00", "a": 1}`,
+		`{"a": 1, "$hex": "00"}`,
+		`{"a": 1, "a": 2}`,
+		`[1] [2]`,
+	} {
+		if _, err := FromJSON([]byte(s)); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+
+	for _, s := range []string{"", "i1ei2e", "d1:a"} {
+		if _, err := ToJSON([]byte(s), nil); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
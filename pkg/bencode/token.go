@@ -0,0 +1,386 @@
+package bencode
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// TokenKind is the type of a Token.
+type TokenKind uint8
+
+// Token kinds
+const (
+	TokenDictStart TokenKind = iota + 1
+	TokenListStart
+	TokenKey
+	TokenString
+	TokenInt
+	TokenEnd
+)
+
+var tokenKindStrings = map[TokenKind]string{
+	TokenDictStart: "dict start",
+	TokenListStart: "list start",
+	TokenKey:       "key",
+	TokenString:    "string",
+	TokenInt:       "int",
+	TokenEnd:       "end",
+}
+
+func (k TokenKind) String() string {
+	if s, ok := tokenKindStrings[k]; ok {
+		return s
+	}
+	return "unknown(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Token is a syntactic element of bencoded data.
+// Dict keys are returned as TokenKey, all other strings as TokenString.
+type Token struct {
+	Kind TokenKind
+	// Offset of the first byte of the token in the input
+	Offset int64
+	// Contents of TokenKey and TokenString tokens and the digits of TokenInt tokens.
+	// It is only valid until the next call to the Decoder.
+	Bytes []byte
+}
+
+// Int64 parses the value of a TokenInt token.
+func (t Token) Int64() (int64, error) {
+	if t.Kind != TokenInt {
+		return 0, fmt.Errorf("bencode: %s token is not an int", t.Kind)
+	}
+	return strconv.ParseInt(bytesAsString(t.Bytes), 10, 64)
+}
+
+// frame is an open dict or list.
+type frame struct {
+	dict bool
+	// the next token of the dict is a key
+	key bool
+	// previous key of the dict, only kept in strict mode
+	lastKey    []byte
+	hasLastKey bool
+}
+
+var errExpectedValue = errors.New("expected a value")
+
+// Token returns the next token in the input.
+// It returns io.EOF at the end of the input if no dict or list is open.
+func (d *Decoder) Token() (Token, error) {
+	return d.token(false)
+}
+
+// Depth returns the number of open dicts and lists.
+func (d *Decoder) Depth() int {
+	return len(d.stack)
+}
+
+// Skip reads the next value including everything nested in it without
+// allocating. The skipped strings are not returned.
+func (d *Decoder) Skip() error {
+	tok, err := d.token(true)
+	if err != nil {
+		return err
+	}
+	if tok.Kind == TokenEnd {
+		return d.makeSyntaxError(tok.Offset, errExpectedValue)
+	}
+	return d.skipRest(tok)
+}
+
+// Raw returns the bencoded bytes of the next value.
+func (d *Decoder) Raw() ([]byte, error) {
+	b, err := d.readRaw()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), b...), nil
+}
+
+// readRaw is like Raw but the returned slice is only valid until the next call to the Decoder.
+func (d *Decoder) readRaw() ([]byte, error) {
+	d.raw = d.raw[:0]
+	d.capturing = true
+	err := d.Skip()
+	d.capturing = false
+	return d.raw, err
+}
+
+// skipRest skips the contents of the dict or list started by tok.
+func (d *Decoder) skipRest(tok Token) error {
+	if tok.Kind != TokenDictStart && tok.Kind != TokenListStart {
+		return nil
+	}
+
+	depth := len(d.stack)
+	for len(d.stack) >= depth {
+		if _, err := d.token(true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Decoder) token(skipString bool) (Token, error) {
+	offset := d.Offset
+	b, err := d.r.ReadByte()
+	if err != nil {
+		if err == io.EOF && len(d.stack) > 0 {
+			err = d.makeSyntaxError(offset, io.ErrUnexpectedEOF)
+		}
+		return Token{}, err
+	}
+	d.consumed(b)
+
+	var top *frame
+	if len(d.stack) > 0 {
+		top = &d.stack[len(d.stack)-1]
+	}
+
+	if b == 'e' {
+		switch {
+		case top == nil:
+			return Token{}, d.makeSyntaxError(offset, errors.New("unexpected 'e'"))
+		case top.dict && !top.key:
+			return Token{}, d.makeSyntaxError(offset, errors.New("dict elem missing value"))
+		}
+		d.stack = d.stack[:len(d.stack)-1]
+		d.valueDone()
+		return Token{Kind: TokenEnd, Offset: offset}, nil
+	}
+
+	if top != nil && top.dict && top.key {
+		if b < '0' || b > '9' {
+			return Token{}, d.makeSyntaxError(offset, errors.New("non-string key in a dict"))
+		}
+		tok, err := d.readString(b, offset, false)
+		if err != nil {
+			return tok, err
+		}
+		tok.Kind = TokenKey
+		top.key = false
+		if d.Strict {
+			err = d.checkKeyOrder(top, tok)
+		}
+		return tok, err
+	}
+
+	switch b {
+	case 'd':
+		d.push(true)
+		return Token{Kind: TokenDictStart, Offset: offset}, nil
+	case 'l':
+		d.push(false)
+		return Token{Kind: TokenListStart, Offset: offset}, nil
+	case 'i':
+		tok, err := d.readInt(offset)
+		if err != nil {
+			return tok, err
+		}
+		d.valueDone()
+		return tok, nil
+	}
+
+	if b >= '0' && b <= '9' {
+		tok, err := d.readString(b, offset, skipString)
+		if err != nil {
+			return tok, err
+		}
+		d.valueDone()
+		return tok, nil
+	}
+
+	return Token{}, d.unknowValueType(b, offset)
+}
+
+// push opens a dict or list. The key buffers of closed frames are reused.
+func (d *Decoder) push(dict bool) {
+	if len(d.stack) < cap(d.stack) {
+		d.stack = d.stack[:len(d.stack)+1]
+		f := &d.stack[len(d.stack)-1]
+		*f = frame{dict: dict, key: dict, lastKey: f.lastKey[:0]}
+		return
+	}
+	d.stack = append(d.stack, frame{dict: dict, key: dict})
+}
+
+// checkKeyOrder returns an error if the key is not greater than the previous key of the dict.
+func (d *Decoder) checkKeyOrder(f *frame, tok Token) error {
+	if f.hasLastKey {
+		switch c := bytes.Compare(tok.Bytes, f.lastKey); {
+		case c == 0:
+			return d.makeSyntaxError(tok.Offset, fmt.Errorf("duplicate dict key %q", tok.Bytes))
+		case c < 0:
+			return d.makeSyntaxError(tok.Offset, fmt.Errorf("dict keys unsorted: %q < %q", tok.Bytes, f.lastKey))
+		}
+	}
+	f.lastKey = append(f.lastKey[:0], tok.Bytes...)
+	f.hasLastKey = true
+	return nil
+}
+
+// valueDone is called after a complete value has been read.
+func (d *Decoder) valueDone() {
+	if len(d.stack) > 0 {
+		top := &d.stack[len(d.stack)-1]
+		if top.dict {
+			top.key = true
+		}
+	}
+}
+
+func (d *Decoder) readInt(offset int64) (Token, error) {
+	d.buf.Reset()
+	if err := d.readUntil('e'); err != nil {
+		return Token{}, err
+	}
+
+	b := d.buf.Bytes()
+	digits := b
+	digitsOffset := offset + 1
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+		digitsOffset++
+	}
+	if len(digits) == 0 || !isDigits(digits) {
+		return Token{}, d.makeSyntaxError(offset, fmt.Errorf("invalid integer %q", b))
+	}
+	if len(digits) > 1 && digits[0] == '0' {
+		return Token{}, d.makeSyntaxError(digitsOffset, fmt.Errorf("leading zero in integer %q", b))
+	}
+	if len(digits) < len(b) && digits[0] == '0' {
+		return Token{}, d.makeSyntaxError(digitsOffset, errors.New("negative zero"))
+	}
+
+	return Token{Kind: TokenInt, Offset: offset, Bytes: b}, nil
+}
+
+func (d *Decoder) readString(first byte, offset int64, skip bool) (Token, error) {
+	d.buf.Reset()
+	d.buf.WriteByte(first)
+	if err := d.readUntil(':'); err != nil {
+		return Token{}, err
+	}
+
+	b := d.buf.Bytes()
+	if !isDigits(b) {
+		return Token{}, d.makeSyntaxError(offset, fmt.Errorf("invalid string length %q", b))
+	}
+	if len(b) > 1 && b[0] == '0' {
+		return Token{}, d.makeSyntaxError(offset, fmt.Errorf("non-minimal string length %q", b))
+	}
+	length, err := strconv.ParseInt(bytesAsString(b), 10, 64)
+	if err != nil {
+		return Token{}, d.makeSyntaxError(offset, err)
+	}
+	if length > d.getMaxStrLen() {
+		return Token{}, d.makeSyntaxError(offset, fmt.Errorf("parsed string length %v exceeds limit [%v]", length, d.getMaxStrLen()))
+	}
+
+	tok := Token{Kind: TokenString, Offset: offset}
+	if skip {
+		return tok, d.discard(length)
+	}
+
+	d.buf.Reset()
+	d.buf.Grow(int(length))
+	tok.Bytes = d.buf.Bytes()[:length]
+	if err := d.readFull(tok.Bytes); err != nil {
+		return Token{}, err
+	}
+	return tok, nil
+}
+
+// readUntil appends the bytes before c to d.buf and consumes c.
+func (d *Decoder) readUntil(c byte) error {
+	for {
+		b, err := d.readByte()
+		if err != nil {
+			return err
+		}
+
+		if b == c {
+			return nil
+		}
+
+		d.buf.WriteByte(b)
+	}
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, checkForUnexpectedEOF(err, d.Offset)
+	}
+
+	d.consumed(b)
+	return b, nil
+}
+
+func (d *Decoder) consumed(b byte) {
+	d.Offset++
+	if d.capturing {
+		d.raw = append(d.raw, b)
+	}
+}
+
+func (d *Decoder) readFull(b []byte) error {
+	n, err := io.ReadFull(d.r, b)
+	d.Offset += int64(n)
+	if d.capturing {
+		d.raw = append(d.raw, b[:n]...)
+	}
+	if err != nil {
+		return checkForUnexpectedEOF(err, d.Offset)
+	}
+	return nil
+}
+
+// discard skips n bytes of input without allocating.
+func (d *Decoder) discard(n int64) error {
+	if d.capturing {
+		start := len(d.raw)
+		d.raw = slices.Grow(d.raw, int(n))[:start+int(n)]
+		d.capturing = false
+		err := d.readFull(d.raw[start:])
+		d.capturing = true
+		return err
+	}
+
+	var err error
+	switch r := d.r.(type) {
+	case *bufio.Reader:
+		var m int
+		m, err = r.Discard(int(n))
+		d.Offset += int64(m)
+	case *bytes.Reader:
+		if int64(r.Len()) < n {
+			d.Offset += int64(r.Len())
+			_, _ = r.Seek(0, io.SeekEnd)
+			err = io.EOF
+			break
+		}
+		_, err = r.Seek(n, io.SeekCurrent)
+		d.Offset += n
+	default:
+		var m int64
+		m, err = io.CopyN(io.Discard, d.r, n)
+		d.Offset += m
+	}
+	return checkForUnexpectedEOF(err, d.Offset)
+}
+
+func isDigits(b []byte) bool {
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,258 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+type testToken struct {
+	kind   TokenKind
+	offset int64
+	value  string
+}
+
+func readTokens(t *testing.T, d *Decoder) []testToken {
+	t.Helper()
+	var ret []testToken
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return ret
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		ret = append(ret, testToken{tok.Kind, tok.Offset, string(tok.Bytes)})
+	}
+}
+
+func TestToken(t *testing.T) {
+	input := "d3:fooli-12e0:e3:bard1:x1:yee"
+	got := readTokens(t, NewDecoder(strings.NewReader(input)))
+	expected := []testToken{
+		{TokenDictStart, 0, ""},
+		{TokenKey, 1, "foo"},
+		{TokenListStart, 6, ""},
+		{TokenInt, 7, "-12"},
+		{TokenString, 12, ""},
+		{TokenEnd, 14, ""},
+		{TokenKey, 15, "bar"},
+		{TokenDictStart, 20, ""},
+		{TokenKey, 21, "x"},
+		{TokenString, 24, "y"},
+		{TokenEnd, 27, ""},
+		{TokenEnd, 28, ""},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d: %v", len(expected), len(got), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Token %d: expected %+v, got %+v", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestTokenMultipleValues(t *testing.T) {
+	got := readTokens(t, &Decoder{r: strings.NewReader("i1e2:abde")})
+	kinds := []TokenKind{TokenInt, TokenString, TokenDictStart, TokenEnd}
+	if len(got) != len(kinds) {
+		t.Fatalf("Expected %d tokens, got %v", len(kinds), got)
+	}
+	for i, k := range kinds {
+		if got[i].kind != k {
+			t.Errorf("Token %d: expected %v, got %v", i, k, got[i].kind)
+		}
+	}
+}
+
+func TestTokenErrors(t *testing.T) {
+	testCases := []struct {
+		input  string
+		offset int64
+	}{
+		{"e", 0},
+		{"d1:ae", 4},
+		{"di1e1:ae", 1},
+		{"li1e", 4},
+		{"i01e", 1},
+		{"i-0e", 2},
+		{"i-01e", 2},
+		{"00:", 0},
+		{"ie", 0},
+		{"i1-e", 0},
+		{"-1:a", 0},
+		{"01:a", 0},
+		{"x", 0},
+		{"l5:abc", 6},
+	}
+
+	for _, tc := range testCases {
+		d := NewDecoder(strings.NewReader(tc.input))
+		var err error
+		for err == nil {
+			_, err = d.Token()
+		}
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("%q: expected SyntaxError, got %v", tc.input, err)
+			continue
+		}
+		if se.Offset != tc.offset {
+			t.Errorf("%q: expected offset %d, got %d (%v)", tc.input, tc.offset, se.Offset, se)
+		}
+	}
+}
+
+func TestTokenMaxStrLen(t *testing.T) {
+	d := NewDecoder(strings.NewReader("l3:abc4:abcde"))
+	d.MaxStrLen = 3
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Token(); err == nil {
+		t.Fatal("Expected error for string longer than MaxStrLen")
+	}
+}
+
+func TestSkipAndRaw(t *testing.T) {
+	input := "d1:ad1:bli1ei2eee1:c3:xyz1:di7ee"
+	for _, d := range []*Decoder{
+		NewDecoder(strings.NewReader(input)),
+		{r: bytes.NewReader([]byte(input))},
+		{r: strings.NewReader(input)},
+	} {
+		if tok, err := d.Token(); err != nil || tok.Kind != TokenDictStart {
+			t.Fatalf("Unexpected token %v, error %v", tok, err)
+		}
+		if tok, err := d.Token(); err != nil || string(tok.Bytes) != "a" {
+			t.Fatalf("Unexpected token %v, error %v", tok, err)
+		}
+
+		raw, err := d.Raw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(raw) != "d1:bli1ei2eee" {
+			t.Errorf("Unexpected raw value %q", raw)
+		}
+
+		if tok, err := d.Token(); err != nil || string(tok.Bytes) != "c" {
+			t.Fatalf("Unexpected token %v, error %v", tok, err)
+		}
+		if err := d.Skip(); err != nil {
+			t.Fatal(err)
+		}
+		if d.Offset != 25 {
+			t.Errorf("Expected offset 25 after skip, got %d", d.Offset)
+		}
+
+		tok, err := d.Token()
+		if err != nil || tok.Kind != TokenKey || string(tok.Bytes) != "d" {
+			t.Fatalf("Unexpected token %v, error %v", tok, err)
+		}
+		tok, err = d.Token()
+		if n, _ := tok.Int64(); err != nil || n != 7 {
+			t.Fatalf("Unexpected token %v, error %v", tok, err)
+		}
+		if err := d.Skip(); err == nil {
+			t.Error("Expected error when skipping the end of a dict")
+		}
+		if d.Depth() != 0 {
+			t.Errorf("Expected depth 0, got %d", d.Depth())
+		}
+	}
+}
+
+func TestSkipTruncated(t *testing.T) {
+	d := &Decoder{r: bytes.NewReader([]byte("l10:abce"))}
+	if _, err := d.Token(); err != nil {
+		t.Fatal(err)
+	}
+	var se *SyntaxError
+	if err := d.Skip(); !errors.As(err, &se) || !errors.Is(se.Err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Expected unexpected EOF, got %v", err)
+	}
+}
+
+func TestSkipAllocs(t *testing.T) {
+	input := []byte("d1:ad1:bli1ei2eee1:c3:xyz1:dl100:" + strings.Repeat("x", 100) + "ee")
+	r := bytes.NewReader(input)
+	d := &Decoder{r: r}
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Reset(input)
+		d.Offset = 0
+		if err := d.Skip(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	testCases := []struct {
+		input  string
+		offset int64
+	}{
+		{"d1:bi1e1:ai2ee", 7},
+		{"d1:ai1e1:ai2ee", 7},
+		{"d1:ad1:xi1e1:xi2eee", 11},
+		{"ld1:bi1eed1:bi1e1:ai1eee", 16},
+		{"d1:b0:2:aa0:e", 6},
+	}
+
+	for _, tc := range testCases {
+		var v interface{}
+		err := UnmarshalStrict([]byte(tc.input), &v)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("%q: expected SyntaxError, got %v", tc.input, err)
+			continue
+		}
+		if se.Offset != tc.offset {
+			t.Errorf("%q: expected offset %d, got %d (%v)", tc.input, tc.offset, se.Offset, se)
+		}
+	}
+
+	// Nested dicts track their keys separately and keys compare as bytes
+	var v interface{}
+	if err := UnmarshalStrict([]byte("d1:ad1:zi1ee1:bd1:ai1ee2:bbi1e1:\xffi1ee"), &v); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestStrictStruct(t *testing.T) {
+	var s struct {
+		A int `bencode:"a"`
+		B int `bencode:"b"`
+	}
+	input := []byte("d1:bi2e1:ai1ee")
+	if err := Unmarshal(input, &s); err != nil || s.A != 1 || s.B != 2 {
+		t.Fatalf("Unexpected result %+v, error %v", s, err)
+	}
+	if err := UnmarshalStrict(input, &s); err == nil {
+		t.Fatal("Expected error for unsorted keys in strict mode")
+	}
+}
+
+func TestUntrusted(t *testing.T) {
+	input := "d1:a" + "2000000:" + strings.Repeat("x", 2000000) + "e"
+	var v map[string]string
+	if err := Unmarshal([]byte(input), &v); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := UnmarshalWithOptions([]byte(input), &v, Untrusted()); err == nil {
+		t.Fatal("Expected error for string longer than UntrustedMaxStrLen")
+	}
+	if err := NewDecoder(strings.NewReader(input), WithMaxStrLen(1<<21)).Decode(&v); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -2,8 +2,10 @@ package infohash
 
 import (
 	"crypto/sha1"
+	"encoding/base32"
 	"encoding/hex"
 	"fmt"
+	"strings"
 )
 
 const Size = 20
@@ -26,7 +28,7 @@ func (t T) HexString() string {
 	return fmt.Sprintf("%x", t[:])
 }
 
-func (t T) FromHexString(s string) (err error) {
+func (t *T) FromHexString(s string) (err error) {
 	err = fmt.Errorf("hash hex string has bad length: %d", len(s))
 	if len(s) != 2*Size {
 		return
@@ -53,3 +55,51 @@ func FromHexString(s string) (h T, err error) {
 
 	return h, nil
 }
+
+// FromBase32String parses the 32 character base32 form used by older magnet links.
+func FromBase32String(s string) (h T, err error) {
+	if len(s) != 32 {
+		return T{}, fmt.Errorf("hash base32 string has bad length: %d", len(s))
+	}
+
+	b, err := base32.StdEncoding.DecodeString(strings.ToUpper(s))
+	if err != nil {
+		return T{}, err
+	}
+
+	copy(h[:], b)
+	return h, nil
+}
+
+const V2Size = 32
+
+// 32-byte SHA-256 info hash of v2 torrents (BEP 52).
+type V2 [V2Size]byte
+
+func (t V2) String() string {
+	return t.HexString()
+}
+
+func (t V2) HexString() string {
+	return fmt.Sprintf("%x", t[:])
+}
+
+// Truncate returns the first 20 bytes of the hash, which v2 torrents use
+// in place of the v1 info hash in handshakes and tracker announces.
+func (t V2) Truncate() (h T) {
+	copy(h[:], t[:])
+	return
+}
+
+func V2FromHexString(s string) (h V2, err error) {
+	if len(s) != 2*V2Size {
+		return V2{}, fmt.Errorf("v2 hash hex string has bad length: %d", len(s))
+	}
+
+	_, err = hex.Decode(h[:], []byte(s))
+	if err != nil {
+		return V2{}, err
+	}
+
+	return h, nil
+}
@@ -0,0 +1,288 @@
+// Package magnet parses and builds magnet links.
+package magnet
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/al002/zbittorrent/pkg/infohash"
+	"github.com/al002/zbittorrent/pkg/metainfo"
+)
+
+// Multihash prefix of a 32 byte SHA-256 digest, the only hash function allowed in btmh (BEP 52).
+const sha256Multihash = "1220"
+
+var (
+	errNotMagnet       = errors.New("not a magnet link")
+	errNoInfoHash      = errors.New("no btih or btmh info hash in magnet link")
+	errInvalidInfoHash = errors.New("invalid info hash")
+	errInvalidSelect   = errors.New("invalid file selection")
+)
+
+// Magnet link components.
+type Magnet struct {
+	// v1 info hash, zero if the link has only a v2 hash
+	InfoHash infohash.T
+	// v2 info hash, zero if the link has only a v1 hash
+	InfoHashV2 infohash.V2
+	// Display name (dn)
+	Name string
+	// Trackers (tr), each in a separate tier
+	Trackers [][]string
+	// Web seeds (ws)
+	WebSeeds []string
+	// Total size in bytes (xl), 0 if unknown
+	Length int64
+	// Peer addresses in "host:port" form (x.pe)
+	Peers []string
+	// Indexes of the files to download (so, BEP 53), all files if empty
+	SelectOnly []FileRange
+	// Search keywords (kt)
+	Keywords []string
+}
+
+// FileRange is an inclusive range of file indexes.
+type FileRange struct {
+	First, Last int
+}
+
+// New parses the magnet link given in s.
+// Unknown parameters are ignored, as are malformed tr, ws, xl and x.pe values.
+func New(s string) (*Magnet, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "magnet" {
+		return nil, errNotMagnet
+	}
+
+	params := u.Query()
+
+	var m Magnet
+	for _, xt := range exactTopics(params) {
+		err = m.parseExactTopic(xt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !m.HasV1() && !m.HasV2() {
+		return nil, errNoInfoHash
+	}
+
+	m.Name = params.Get("dn")
+
+	for _, tr := range params["tr"] {
+		if tr != "" {
+			m.Trackers = append(m.Trackers, []string{tr})
+		}
+	}
+
+	for _, ws := range params["ws"] {
+		if ws != "" {
+			m.WebSeeds = append(m.WebSeeds, ws)
+		}
+	}
+
+	if xl, err := strconv.ParseInt(params.Get("xl"), 10, 64); err == nil && xl > 0 {
+		m.Length = xl
+	}
+
+	for _, pe := range params["x.pe"] {
+		if _, _, err := net.SplitHostPort(pe); err == nil {
+			m.Peers = append(m.Peers, pe)
+		}
+	}
+
+	if so := params.Get("so"); so != "" {
+		m.SelectOnly, err = ParseFileRanges(so)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m.Keywords = strings.Fields(params.Get("kt"))
+
+	return &m, nil
+}
+
+// exactTopics returns the values of "xt" and numbered "xt.N" parameters.
+func exactTopics(params url.Values) []string {
+	var keys []string
+	for k := range params {
+		if k == "xt" || strings.HasPrefix(k, "xt.") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var ret []string
+	for _, k := range keys {
+		ret = append(ret, params[k]...)
+	}
+	return ret
+}
+
+func (m *Magnet) parseExactTopic(xt string) error {
+	switch {
+	case strings.HasPrefix(xt, "urn:btih:"):
+		if m.HasV1() {
+			return nil
+		}
+		s := xt[len("urn:btih:"):]
+		var err error
+		switch len(s) {
+		case 2 * infohash.Size:
+			m.InfoHash, err = infohash.FromHexString(s)
+		case 32:
+			m.InfoHash, err = infohash.FromBase32String(s)
+		default:
+			err = errInvalidInfoHash
+		}
+		return err
+	case strings.HasPrefix(xt, "urn:btmh:"):
+		s := xt[len("urn:btmh:"):]
+		if m.HasV2() || !strings.HasPrefix(s, sha256Multihash) {
+			// Other hash functions are not defined for torrents
+			return nil
+		}
+		var err error
+		m.InfoHashV2, err = infohash.V2FromHexString(s[len(sha256Multihash):])
+		return err
+	}
+	return nil
+}
+
+// ParseFileRanges parses a comma separated list of file indexes and ranges like "0,2,4-6".
+func ParseFileRanges(s string) ([]FileRange, error) {
+	var ret []FileRange
+	for _, part := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		a, err := strconv.Atoi(first)
+		if err != nil || a < 0 {
+			return nil, errInvalidSelect
+		}
+		b := a
+		if isRange {
+			b, err = strconv.Atoi(last)
+			if err != nil || b < a {
+				return nil, errInvalidSelect
+			}
+		}
+		ret = append(ret, FileRange{First: a, Last: b})
+	}
+	return ret, nil
+}
+
+// FromMetaInfo returns the magnet link of a torrent file.
+// Hybrid torrents get both info hashes.
+func FromMetaInfo(mi *metainfo.MetaInfo) *Magnet {
+	m := &Magnet{
+		Name:     mi.Info.Name,
+		WebSeeds: mi.URLList,
+	}
+	if !mi.Info.IsV2Only() {
+		m.InfoHash = mi.Info.Hash
+	}
+	if mi.Info.MetaVersion == 2 {
+		m.InfoHashV2 = mi.Info.HashV2
+	}
+	for _, tr := range mi.AnnounceList.DistinctAnnounceList() {
+		m.Trackers = append(m.Trackers, []string{tr})
+	}
+	for _, f := range mi.Info.Files {
+		if !f.Padding {
+			m.Length += f.Length
+		}
+	}
+	return m
+}
+
+// HasV1 returns true if the link has a btih info hash.
+func (m *Magnet) HasV1() bool {
+	return m.InfoHash != infohash.T{}
+}
+
+// HasV2 returns true if the link has a btmh info hash.
+func (m *Magnet) HasV2() bool {
+	return m.InfoHashV2 != infohash.V2{}
+}
+
+// Hash returns the 20 byte hash that identifies the torrent to trackers and peers.
+// It is the truncated v2 hash if the link has no v1 hash.
+func (m *Magnet) Hash() infohash.T {
+	if m.HasV1() {
+		return m.InfoHash
+	}
+	return m.InfoHashV2.Truncate()
+}
+
+// Selected returns true if the file at index should be downloaded according to the so parameter.
+func (m *Magnet) Selected(index int) bool {
+	if len(m.SelectOnly) == 0 {
+		return true
+	}
+	for _, r := range m.SelectOnly {
+		if index >= r.First && index <= r.Last {
+			return true
+		}
+	}
+	return false
+}
+
+// String builds the magnet link.
+func (m *Magnet) String() string {
+	var params []string
+	add := func(key, value string) {
+		params = append(params, key+"="+value)
+	}
+
+	if m.HasV1() {
+		add("xt", "urn:btih:"+m.InfoHash.HexString())
+	}
+	if m.HasV2() {
+		add("xt", "urn:btmh:"+sha256Multihash+m.InfoHashV2.HexString())
+	}
+	if m.Name != "" {
+		add("dn", url.QueryEscape(m.Name))
+	}
+	if m.Length > 0 {
+		add("xl", strconv.FormatInt(m.Length, 10))
+	}
+	for _, tier := range m.Trackers {
+		for _, tr := range tier {
+			add("tr", url.QueryEscape(tr))
+		}
+	}
+	for _, ws := range m.WebSeeds {
+		add("ws", url.QueryEscape(ws))
+	}
+	for _, pe := range m.Peers {
+		add("x.pe", url.QueryEscape(pe))
+	}
+	if len(m.SelectOnly) > 0 {
+		ranges := make([]string, len(m.SelectOnly))
+		for i, r := range m.SelectOnly {
+			ranges[i] = r.String()
+		}
+		add("so", strings.Join(ranges, ","))
+	}
+	if len(m.Keywords) > 0 {
+		add("kt", url.QueryEscape(strings.Join(m.Keywords, " ")))
+	}
+
+	return "magnet:?" + strings.Join(params, "&")
+}
+
+func (r FileRange) String() string {
+	if r.First == r.Last {
+		return strconv.Itoa(r.First)
+	}
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
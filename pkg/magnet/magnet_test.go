@@ -0,0 +1,103 @@
+package magnet
+
+import (
+	"testing"
+
+	"github.com/al002/zbittorrent/pkg/infohash"
+	"github.com/al002/zbittorrent/pkg/metainfo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testHash   = "d2510031c946fa1b376c88c397d72272bd084f21"
+	testHashV2 = "51bc307abfd1b581b2fdcb1e72bc311313d7e9270dbbcdf75f69c537011f8d3f"
+)
+
+func TestParse(t *testing.T) {
+	m, err := New("magnet:?xt=urn:btih:" + testHash +
+		"&xt=urn:btmh:1220" + testHashV2 +
+		"&dn=hello+world&xl=1234" +
+		"&tr=http%3A%2F%2Ftracker.example%2Fannounce&tr=udp%3A%2F%2Fother%3A80" +
+		"&ws=http%3A%2F%2Fseed%2Ffile" +
+		"&x.pe=1.2.3.4%3A6881&x.pe=bad" +
+		"&so=0,2,4-6&kt=foo+bar")
+	require.NoError(t, err)
+
+	assert.Equal(t, testHash, m.InfoHash.HexString())
+	assert.Equal(t, testHashV2, m.InfoHashV2.HexString())
+	assert.Equal(t, "hello world", m.Name)
+	assert.Equal(t, int64(1234), m.Length)
+	assert.Equal(t, [][]string{{"http://tracker.example/announce"}, {"udp://other:80"}}, m.Trackers)
+	assert.Equal(t, []string{"http://seed/file"}, m.WebSeeds)
+	assert.Equal(t, []string{"1.2.3.4:6881"}, m.Peers)
+	assert.Equal(t, []FileRange{{0, 0}, {2, 2}, {4, 6}}, m.SelectOnly)
+	assert.Equal(t, []string{"foo", "bar"}, m.Keywords)
+	assert.Equal(t, m.InfoHash, m.Hash())
+
+	assert.True(t, m.Selected(0))
+	assert.False(t, m.Selected(1))
+	assert.True(t, m.Selected(5))
+	assert.False(t, m.Selected(7))
+}
+
+func TestParseBase32(t *testing.T) {
+	m, err := New("magnet:?xt=urn:btih:2JIQAMOJI35BWN3MRDBZPVZCOK6QQTZB")
+	require.NoError(t, err)
+	assert.Equal(t, testHash, m.InfoHash.HexString())
+	assert.False(t, m.HasV2())
+}
+
+func TestParseV2Only(t *testing.T) {
+	m, err := New("magnet:?xt.1=urn:btmh:1220" + testHashV2)
+	require.NoError(t, err)
+	assert.False(t, m.HasV1())
+	assert.Equal(t, testHashV2[:40], m.Hash().HexString())
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{
+		"http://example.com/",
+		"magnet:?dn=foo",
+		"magnet:?xt=urn:btih:1234",
+		"magnet:?xt=urn:btih:zz510031c946fa1b376c88c397d72272bd084f21",
+		"magnet:?xt=urn:btmh:1220abcd",
+		"magnet:?xt=urn:btih:" + testHash + "&so=3-1",
+		"magnet:?xt=urn:btih:" + testHash + "&so=a",
+	} {
+		_, err := New(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestString(t *testing.T) {
+	h, err := infohash.FromHexString(testHash)
+	require.NoError(t, err)
+	m := Magnet{
+		InfoHash:   h,
+		Name:       "a b&c",
+		Trackers:   [][]string{{"http://t/a"}},
+		Length:     10,
+		SelectOnly: []FileRange{{1, 1}, {3, 5}},
+		Keywords:   []string{"x", "y"},
+	}
+	s := m.String()
+	assert.Equal(t, "magnet:?xt=urn:btih:"+testHash+"&dn=a+b%26c&xl=10&tr=http%3A%2F%2Ft%2Fa&so=1,3-5&kt=x+y", s)
+
+	m2, err := New(s)
+	require.NoError(t, err)
+	assert.Equal(t, &m, m2)
+}
+
+func TestFromMetaInfo(t *testing.T) {
+	mi, err := metainfo.LoadFromFile("../metainfo/testdata/hybrid.torrent")
+	require.NoError(t, err)
+
+	m := FromMetaInfo(mi)
+	assert.Equal(t, testHash, m.InfoHash.HexString())
+	assert.Equal(t, testHashV2, m.InfoHashV2.HexString())
+	assert.Equal(t, "hybridtest", m.Name)
+	assert.Equal(t, int64(100000+32768+20000), m.Length)
+	assert.Equal(t, "magnet:?xt=urn:btih:"+testHash+"&xt=urn:btmh:1220"+testHashV2+
+		"&dn=hybridtest&xl=152768&tr=http%3A%2F%2Ftracker.example%2Fannounce", m.String())
+}
@@ -0,0 +1,346 @@
+package metainfo
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/al002/zbittorrent/pkg/bencode"
+)
+
+const (
+	// MinPieceLength is the smallest piece length chosen by the builder.
+	MinPieceLength = 16 << 10
+	// MaxPieceLength is the largest piece length chosen by the builder.
+	MaxPieceLength = 16 << 20
+
+	// targetPieceCount is the number of pieces the automatic piece length aims for.
+	targetPieceCount = 1500
+)
+
+// ErrNoFiles is returned by Builder.Build when the path contains no regular files.
+var ErrNoFiles = errors.New("no files to add")
+
+// Builder creates a new torrent from a file or directory on disk.
+// Zero values select sensible defaults.
+type Builder struct {
+	// Path is the file or directory to add.
+	Path string
+	// Name overrides the torrent name. Defaults to the base name of Path.
+	Name string
+	// PieceLength must be a power of two and at least 16 KiB. Zero picks one based on the total size.
+	PieceLength int64
+	// Trackers is the list of announce tiers.
+	Trackers [][]string
+	// WebSeeds is the list of BEP 19 web seed URLs.
+	WebSeeds []string
+	// Private sets the BEP 27 private flag.
+	Private   bool
+	Comment   string
+	CreatedBy string
+	// Source is written into the info dictionary, changing the info hash.
+	Source string
+	// CreationDate defaults to the current time. Set NoCreationDate to omit it.
+	CreationDate   time.Time
+	NoCreationDate bool
+	// Padding aligns every file to a piece boundary with BEP 47 padding files.
+	Padding bool
+	// Workers is the number of hashing goroutines. Defaults to the number of CPUs.
+	Workers int
+	// Progress, if set, is called after each hashed piece with the number of bytes hashed so far.
+	Progress func(hashed, total int64)
+}
+
+type builderFile struct {
+	file
+	osPath string
+}
+
+// Build walks the path, hashes its contents and returns the resulting metainfo.
+func (b *Builder) Build() (*MetaInfo, error) {
+	root, err := filepath.Abs(b.Path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	name := b.Name
+	if name == "" {
+		name = fi.Name()
+	}
+
+	files, err := walkFiles(root, fi)
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, f := range files {
+		total += f.Length
+	}
+
+	pieceLength := b.PieceLength
+	if pieceLength == 0 {
+		pieceLength = PieceLengthForSize(total)
+	} else if pieceLength < MinPieceLength || pieceLength > math.MaxUint32 || pieceLength&(pieceLength-1) != 0 {
+		return nil, fmt.Errorf("invalid piece length: %d", pieceLength)
+	}
+
+	it := infoType{
+		PieceLength: uint32(pieceLength),
+		Name:        name,
+		Private:     b.Private,
+		Source:      b.Source,
+	}
+	if fi.IsDir() {
+		if b.Padding {
+			files = addPaddingFiles(files, pieceLength)
+		}
+		it.Files = make([]file, len(files))
+		for i, f := range files {
+			it.Files[i] = f.file
+		}
+	} else {
+		it.Length = total
+	}
+
+	it.Pieces, err = b.hashPieces(files, pieceLength)
+	if err != nil {
+		return nil, err
+	}
+
+	infoBytes, err := bencode.Marshal(it)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := NewInfo(infoBytes, false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	mi := &MetaInfo{
+		Info:      *info,
+		Comment:   b.Comment,
+		CreatedBy: b.CreatedBy,
+		URLList:   b.WebSeeds,
+	}
+	for _, tier := range b.Trackers {
+		if len(tier) > 0 {
+			mi.AnnounceList = append(mi.AnnounceList, tier)
+		}
+	}
+	if !b.NoCreationDate {
+		mi.CreationDate = b.CreationDate
+		if mi.CreationDate.IsZero() {
+			mi.CreationDate = time.Now()
+		}
+	}
+	return mi, nil
+}
+
+// PieceLengthForSize returns a power of two piece length that splits total
+// bytes into about 1500 pieces, clamped between 16 KiB and 16 MiB.
+func PieceLengthForSize(total int64) int64 {
+	pieceLength := int64(MinPieceLength)
+	for pieceLength < MaxPieceLength && total/pieceLength > targetPieceCount {
+		pieceLength *= 2
+	}
+	return pieceLength
+}
+
+func walkFiles(root string, fi os.FileInfo) ([]builderFile, error) {
+	if !fi.IsDir() {
+		if !fi.Mode().IsRegular() {
+			return nil, fmt.Errorf("not a regular file: %s", root)
+		}
+		return []builderFile{{file: file{Length: fi.Size()}, osPath: root}}, nil
+	}
+
+	var files []builderFile
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, builderFile{
+			file: file{
+				Length: fi.Size(),
+				Path:   strings.Split(filepath.ToSlash(rel), "/"),
+			},
+			osPath: path,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNoFiles
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return lessPath(files[i].Path, files[j].Path)
+	})
+	return files, nil
+}
+
+func lessPath(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// addPaddingFiles inserts a BEP 47 padding file after every file that does not
+// end on a piece boundary, except the last one.
+func addPaddingFiles(files []builderFile, pieceLength int64) []builderFile {
+	ret := make([]builderFile, 0, 2*len(files))
+	for i, f := range files {
+		ret = append(ret, f)
+		rem := f.Length % pieceLength
+		if i == len(files)-1 || rem == 0 {
+			continue
+		}
+		pad := pieceLength - rem
+		ret = append(ret, builderFile{file: file{
+			Length: pad,
+			Path:   []string{".pad", strconv.FormatInt(pad, 10)},
+			Attr:   "p",
+		}})
+	}
+	return ret
+}
+
+type hashJob struct {
+	index int
+	buf   []byte
+}
+
+// hashPieces reads the files sequentially as one stream and hashes the pieces in parallel.
+func (b *Builder) hashPieces(files []builderFile, pieceLength int64) ([]byte, error) {
+	var total int64
+	for _, f := range files {
+		total += f.Length
+	}
+	numPieces := int((total + pieceLength - 1) / pieceLength)
+	pieces := make([]byte, numPieces*sha1.Size)
+
+	workers := b.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobC := make(chan hashJob, workers)
+	bufPool := sync.Pool{New: func() any { return make([]byte, pieceLength) }}
+	var hashed int64
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobC {
+				sum := sha1.Sum(job.buf)
+				copy(pieces[job.index*sha1.Size:], sum[:])
+				if b.Progress != nil {
+					mu.Lock()
+					hashed += int64(len(job.buf))
+					b.Progress(hashed, total)
+					mu.Unlock()
+				}
+				bufPool.Put(job.buf[:cap(job.buf)])
+			}
+		}()
+	}
+
+	err := readPieces(files, pieceLength, bufPool.Get, func(index int, buf []byte) {
+		jobC <- hashJob{index: index, buf: buf}
+	})
+	close(jobC)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return pieces, nil
+}
+
+func readPieces(files []builderFile, pieceLength int64, getBuf func() any, fn func(int, []byte)) error {
+	var index int
+	buf := getBuf().([]byte)
+	var n int64
+	for _, f := range files {
+		r, closer, err := openBuilderFile(f)
+		if err != nil {
+			return err
+		}
+		var read int64
+		for {
+			var m int
+			m, err = io.ReadFull(r, buf[n:])
+			n += int64(m)
+			read += int64(m)
+			if n == pieceLength {
+				fn(index, buf)
+				index++
+				buf = getBuf().([]byte)
+				n = 0
+			}
+			if err != nil {
+				break
+			}
+		}
+		closer.Close()
+		if err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		if read != f.Length {
+			return fmt.Errorf("file size changed while hashing: %s", f.osPath)
+		}
+	}
+	if n > 0 {
+		fn(index, buf[:n])
+	}
+	return nil
+}
+
+func openBuilderFile(f builderFile) (io.Reader, io.Closer, error) {
+	if strings.ContainsRune(f.Attr, 'p') {
+		return io.LimitReader(zeroReader{}, f.Length), io.NopCloser(nil), nil
+	}
+	file, err := os.Open(f.osPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return io.LimitReader(file, f.Length), file, nil
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,149 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/al002/zbittorrent/pkg/bencode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, path string, size int, seed byte) []byte {
+	t.Helper()
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = seed + byte(i%251)
+	}
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+	require.NoError(t, os.WriteFile(path, b, 0o640))
+	return b
+}
+
+func pieceHashes(data []byte, pieceLength int) []byte {
+	var ret []byte
+	for len(data) > 0 {
+		n := min(pieceLength, len(data))
+		sum := sha1.Sum(data[:n])
+		ret = append(ret, sum[:]...)
+		data = data[n:]
+	}
+	return ret
+}
+
+func TestBuilderSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	data := writeTestFile(t, filepath.Join(dir, "file.bin"), 100000, 1)
+
+	b := Builder{
+		Path:         filepath.Join(dir, "file.bin"),
+		PieceLength:  MinPieceLength,
+		Trackers:     [][]string{{"http://a/announce", "http://b/announce"}, {"udp://c:80"}},
+		WebSeeds:     []string{"http://seed/file.bin"},
+		Private:      true,
+		Comment:      "comment",
+		CreatedBy:    "test",
+		Source:       "src",
+		CreationDate: time.Unix(1700000000, 0),
+		Workers:      3,
+	}
+	mi, err := b.Build()
+	require.NoError(t, err)
+
+	assert.Equal(t, AnnounceList(b.Trackers), mi.AnnounceList)
+	assert.Equal(t, int64(1700000000), mi.CreationDate.Unix())
+
+	info := &mi.Info
+	assert.Equal(t, "file.bin", info.Name)
+	assert.Equal(t, int64(len(data)), info.Length)
+	assert.Equal(t, "src", info.Source)
+	assert.True(t, info.Private)
+	assert.Equal(t, pieceHashes(data, MinPieceLength), allPieceHashes(info))
+
+	var buf bytes.Buffer
+	require.NoError(t, mi.Write(&buf))
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("d8:announce17:http://a/announce13:announce-list")))
+	loaded, err := New(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, mi.Info.Hash, loaded.Info.Hash)
+	assert.Equal(t, mi.AnnounceList, loaded.AnnounceList)
+	assert.Equal(t, []string{"http://seed/file.bin"}, loaded.URLList)
+	assert.Equal(t, "comment", loaded.Comment)
+	assert.Equal(t, "test", loaded.CreatedBy)
+	assert.Equal(t, mi.CreationDate, loaded.CreationDate)
+}
+
+func allPieceHashes(info *Info) []byte {
+	var ret []byte
+	for i := uint32(0); i < info.NumPieces; i++ {
+		ret = append(ret, info.PieceHash(i)...)
+	}
+	return ret
+}
+
+func TestBuilderDirectoryPadding(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	b1 := writeTestFile(t, filepath.Join(root, "b", "1.bin"), 20000, 2)
+	a := writeTestFile(t, filepath.Join(root, "a.bin"), MinPieceLength, 3)
+	b2 := writeTestFile(t, filepath.Join(root, "b", "2.bin"), 5000, 4)
+
+	mi, err := (&Builder{Path: root, PieceLength: MinPieceLength, Padding: true, NoCreationDate: true}).Build()
+	require.NoError(t, err)
+	assert.True(t, mi.CreationDate.IsZero())
+
+	info := &mi.Info
+	assert.Equal(t, "root", info.Name)
+	pad := int64(2*MinPieceLength - len(b1))
+	assert.Equal(t, []File{
+		{Length: MinPieceLength, Path: filepath.Join("root", "a.bin")},
+		{Length: int64(len(b1)), Path: filepath.Join("root", "b", "1.bin")},
+		{Length: pad, Path: filepath.Join("root", ".pad", "12768"), Padding: true},
+		{Length: int64(len(b2)), Path: filepath.Join("root", "b", "2.bin")},
+	}, info.Files)
+
+	var data []byte
+	data = append(data, a...)
+	data = append(data, b1...)
+	data = append(data, make([]byte, pad)...)
+	data = append(data, b2...)
+	assert.Equal(t, pieceHashes(data, MinPieceLength), allPieceHashes(info))
+}
+
+func TestBuilderCanonicalInfo(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "x", "y.bin"), 1000, 5)
+
+	mi, err := (&Builder{Path: dir, Private: true, Source: "s"}).Build()
+	require.NoError(t, err)
+
+	var v interface{}
+	require.NoError(t, bencode.Unmarshal(mi.Info.Raw, &v))
+	b, err := bencode.Marshal(v)
+	require.NoError(t, err)
+	assert.Equal(t, mi.Info.Raw, b)
+}
+
+func TestBuilderErrors(t *testing.T) {
+	_, err := (&Builder{Path: t.TempDir()}).Build()
+	assert.ErrorIs(t, err, ErrNoFiles)
+
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "f"), 10, 0)
+	_, err = (&Builder{Path: filepath.Join(dir, "f"), PieceLength: 3 * MinPieceLength}).Build()
+	assert.Error(t, err)
+	// Does not fit in the 32 bit piece length of the info dictionary
+	_, err = (&Builder{Path: filepath.Join(dir, "f"), PieceLength: 1 << 32}).Build()
+	assert.Error(t, err)
+}
+
+func TestPieceLengthForSize(t *testing.T) {
+	assert.Equal(t, int64(MinPieceLength), PieceLengthForSize(0))
+	assert.Equal(t, int64(MinPieceLength), PieceLengthForSize(1500*MinPieceLength))
+	assert.Equal(t, int64(2*MinPieceLength), PieceLengthForSize(1500*MinPieceLength+MinPieceLength))
+	assert.Equal(t, int64(MaxPieceLength), PieceLengthForSize(1<<50))
+}
@@ -1,31 +1,343 @@
 package metainfo
 
-type FileInfo struct {
-	Length int64    `bencode:"length"`
-	Path   []string `bencode:"path"` // BEP 3
-}
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/al002/zbittorrent/internal/merkle"
+	"github.com/al002/zbittorrent/pkg/bencode"
+)
+
+var (
+	errInvalidPieceData = errors.New("invalid piece data")
+	errZeroPieceLength  = errors.New("torrent has zero piece length")
+	errZeroPieces       = errors.New("torrent has zero pieces")
+	errPieceLength      = errors.New("piece length must be multiple of 16K")
+	errMetaVersion      = errors.New("unsupported meta version")
+	errPieceLengthV2    = errors.New("piece length of v2 torrent must be a power of two and at least 16K")
+)
 
+// Info is the validated info dictionary of a torrent.
 type Info struct {
-	PieceLength int64      `bencode:"piece length"` // BEP 3
-	Pieces      []byte     `bencode:"pieces,omitempty"`
-	Name        string     `bencode:"name"`
-	NameUtf8    string     `bencode:"name.utf-8,omitempty"`
-	Length      int64      `bencode:"length,omitempty"`
-	Private     *bool      `bencode:"private,omitempty"` // BEP27
-	Files       []FileInfo `bencode:"files,omitempty"`
+	PieceLength uint32
+	Name        string
+	Hash        Hash
+	Length      int64
+	NumPieces   uint32
+	Private     bool
+	// Source tag used by private trackers to change the info hash of cross-seeded torrents.
+	Source string
+	Files  []File
+	// Raw bencoded info dictionary, the info hash is calculated from these bytes.
+	Raw    []byte
+	pieces []byte
+
+	// MetaVersion is 2 for v2 and hybrid torrents (BEP 52), 1 otherwise.
+	MetaVersion int
+	// HashV2 is the SHA-256 hash of the info dictionary, set only when MetaVersion is 2.
+	// Hash is the truncated form of it for v2 only torrents.
+	HashV2 [32]byte
+	// v2 piece hashes, set by SetPieceLayers
+	piecesV2 []pieceV2
+}
+
+// File in a torrent. Path is sanitised and includes the torrent name for multi-file torrents.
+type File struct {
+	Length int64
+	Path   string
+	// https://www.bittorrent.org/beps/bep_0047.html
+	Padding bool
+	// Root of the SHA-256 merkle tree of a v2 file, zero for empty and padding files.
+	PiecesRoot [32]byte
+}
+
+type file struct {
+	Length   int64    `bencode:"length"`
+	Path     []string `bencode:"path"`
+	PathUTF8 []string `bencode:"path.utf-8,omitempty"`
+	Attr     string   `bencode:"attr,omitempty"`
+}
+
+func (f *file) isPadding() bool {
+	// BEP 0047
+	if strings.ContainsRune(f.Attr, 'p') {
+		return true
+	}
+
+	// BitComet convention that do not conform BEP 0047
+	if len(f.Path) > 0 && strings.HasPrefix(f.Path[len(f.Path)-1], "_____padding_file") {
+		return true
+	}
+
+	return false
 }
 
-func (info *Info) FinalName() string {
-	if info.NameUtf8 != "" {
-		return info.NameUtf8
+func (i *infoType) overrideUTF8Keys() {
+	if len(i.NameUTF8) > 0 {
+		i.Name = i.NameUTF8
+	}
+
+	for j := range i.Files {
+		if len(i.Files[j].PathUTF8) > 0 {
+			i.Files[j].Path = i.Files[j].PathUTF8
+		}
 	}
-	return info.Name
 }
 
-func (info *Info) isDir() bool {
-	return info.Length == 0
+type infoType struct {
+	PieceLength uint32 `bencode:"piece length"`
+	Pieces      []byte `bencode:"pieces,omitempty"`
+	Name        string `bencode:"name"`
+	NameUTF8    string `bencode:"name.utf-8,omitempty"`
+	Private     bool   `bencode:"private,omitempty"`
+	Length      int64  `bencode:"length,omitempty"` // Single File Mode
+	Files       []file `bencode:"files,omitempty"`  // Multiple File mode
+	Source      string `bencode:"source,omitempty"`
+
+	// BEP 52
+	MetaVersion int                    `bencode:"meta version,omitempty"`
+	FileTree    map[string]interface{} `bencode:"file tree,omitempty"`
+}
+
+// NewInfo parses and validates the bencoded info dictionary in b.
+// If utf8 is true, the name.utf-8 and path.utf-8 keys take precedence.
+// If pad is true, BEP 47 padding files are marked in the file list.
+func NewInfo(b []byte, utf8 bool, pad bool) (*Info, error) {
+	var it infoType
+	if err := bencode.Unmarshal(b, &it); err != nil {
+		return nil, err
+	}
+
+	if it.PieceLength == 0 {
+		return nil, errZeroPieceLength
+	}
+
+	if len(it.Pieces)%sha1.Size != 0 {
+		return nil, errInvalidPieceData
+	}
+
+	var filesV2 []fileV2
+	switch it.MetaVersion {
+	case 0, 1:
+	case 2:
+		if it.PieceLength < merkle.BlockSize || it.PieceLength&(it.PieceLength-1) != 0 {
+			return nil, errPieceLengthV2
+		}
+		var err error
+		filesV2, err = parseFileTree(it.FileTree)
+		if err != nil {
+			return nil, err
+		}
+		if len(it.Pieces) == 0 {
+			it.setFilesV2(filesV2)
+		}
+	default:
+		return nil, errMetaVersion
+	}
+
+	numPieces := len(it.Pieces) / sha1.Size
+	if len(it.Pieces) == 0 && it.MetaVersion == 2 {
+		numPieces = int((it.totalLength() + int64(it.PieceLength) - 1) / int64(it.PieceLength))
+	}
+	if numPieces == 0 {
+		return nil, errZeroPieces
+	}
+
+	if utf8 {
+		it.overrideUTF8Keys()
+	}
+
+	for _, file := range it.Files {
+		for _, path := range file.Path {
+			if strings.TrimSpace(path) == ".." {
+				return nil, fmt.Errorf("invalid filename: %q", filepath.Join(file.Path...))
+			}
+		}
+	}
+
+	i := Info{
+		PieceLength: it.PieceLength,
+		NumPieces:   uint32(numPieces),
+		Name:        it.Name,
+		Private:     it.Private,
+		Source:      it.Source,
+		pieces:      it.Pieces,
+		MetaVersion: max(it.MetaVersion, 1),
+	}
+
+	i.setLength(it)
+
+	err := i.checkPieceDataLength()
+	if err != nil {
+		return nil, err
+	}
+
+	i.Raw = b
+
+	// info hash
+	i.setHash(b)
+
+	// fill name filed
+	i.setName(it)
+
+	// set files
+	// padding files are always needed to map v2 pieces to files
+	err = i.setFiles(it, pad || i.MetaVersion == 2)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.MetaVersion == 2 {
+		err = i.setPiecesRoots(filesV2)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &i, nil
+}
+
+// PieceHash returns the SHA-1 hash of the piece at index, nil for v2 only torrents.
+func (i *Info) PieceHash(index uint32) []byte {
+	if len(i.pieces) == 0 {
+		return nil
+	}
+
+	begin := index * sha1.Size
+	end := begin + sha1.Size
+
+	return i.pieces[begin:end]
+}
+
+func (i *Info) setLength(it infoType) {
+	i.Length = it.totalLength()
+}
+
+func (it *infoType) totalLength() int64 {
+	if len(it.Files) == 0 {
+		return it.Length
+	}
+
+	var length int64
+	for _, f := range it.Files {
+		length += f.Length
+	}
+	return length
+}
+
+func (i *Info) checkPieceDataLength() error {
+	totalPieceDataLength := int64(i.PieceLength) * int64(i.NumPieces)
+	delta := totalPieceDataLength - i.Length
+
+	if delta >= int64(i.PieceLength) || delta < 0 {
+		return errInvalidPieceData
+	}
+
+	return nil
+}
+
+func (i *Info) setHash(b []byte) {
+	if i.MetaVersion == 2 {
+		i.HashV2 = sha256.Sum256(b)
+	}
+
+	if len(i.pieces) == 0 {
+		copy(i.Hash[:], i.HashV2[:])
+		return
+	}
+
+	hash := sha1.New()
+	_, _ = hash.Write(b)
+	copy(i.Hash[:], hash.Sum(nil))
+}
+
+func (i *Info) setName(it infoType) {
+	switch strings.TrimSpace(it.Name) {
+	case "", ".", "..":
+		i.Name = hex.EncodeToString(i.Hash[:])
+	default:
+		i.Name = it.Name
+	}
+}
+
+func (i *Info) setFiles(it infoType, pad bool) error {
+	multiFile := len(it.Files) > 0
+	if multiFile {
+		i.Files = make([]File, len(it.Files))
+		uniquePaths := make(map[string]interface{}, len(it.Files))
+
+		for j, f := range it.Files {
+			parts := make([]string, 0, len(f.Path)+1)
+			// torrent name as base path
+			parts = append(parts, truncateName(i.Name))
+
+			for _, p := range f.Path {
+				// append filename to parts
+				parts = append(parts, truncateName(p))
+			}
+
+			// get filename path
+			joinedPath := filepath.Join(parts...)
+
+			if _, ok := uniquePaths[joinedPath]; ok {
+				return fmt.Errorf("duplicate filename: %q", joinedPath)
+			} else {
+				uniquePaths[joinedPath] = nil
+			}
+
+			i.Files[j] = File{
+				Path:   joinedPath,
+				Length: f.Length,
+			}
+
+			if pad {
+				i.Files[j].Padding = f.isPadding()
+			}
+		}
+	} else {
+		i.Files = []File{{Path: truncateName(i.Name), Length: i.Length}}
+	}
+
+	return nil
+}
+
+func truncateName(s string) string {
+	return truncateNameN(s, 255)
+}
+
+func truncateNameN(s string, max int) string {
+	s = strings.ToValidUTF8(s, string(unicode.ReplacementChar))
+	s = trimName(s, max)
+	s = strings.ToValidUTF8(s, "")
+
+	return replaceSeparator(s)
+}
+
+func trimName(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+
+	ext := path.Ext(s)
+	if len(ext) > max {
+		return s[:max]
+	}
+
+	return s[:max-len(ext)] + ext
 }
 
-func (info *Info) NumOfPieces() int {
-	return len(info.Pieces) / 20
+func replaceSeparator(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' {
+			return '_'
+		}
+		return r
+	}, s)
 }
@@ -1,172 +1,261 @@
-// from https://github.dev/anacrolix/torrent/blob/master/metainfo/metainfo.go ，logic simplified
+// Package metainfo parses, validates and writes torrent files.
 package metainfo
 
 import (
 	"bufio"
-	"fmt"
+	"errors"
 	"io"
 	"net"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/al002/zbittorrent/pkg/bencode"
 )
 
+var errNoInfo = errors.New("no info dict in torrent file")
+
+// MetaInfo is a validated torrent file.
+// Trackers and web seeds with unsupported schemes are removed.
 type MetaInfo struct {
-	Announce     string        `bencode:"announce,omitempty"`
-	InfoBytes    bencode.Bytes `bencode:"info,omitempty"`                              // BEP 3
-	AnnounceList AnnounceList  `bencode:"announce-list,omitempty"`                     // BEP 12
-	Nodes        []Node        `bencode:"nodes,omitempty,ignore_unmarshal_type_error"` // BEP 5
-	CreationDate int64         `bencode:"creation date,omitempty,ignore_unmarshal_type_error"`
-	Comment      string        `bencode:"comment,omitempty"`
-	CreatedBy    string        `bencode:"created by,omitempty"`
-	Encoding     string        `bencode:"encoding,omitempty"`
-	UrlList      UrlList       `bencode:"url-list,omitempty"` // BEP 19
+	Info         Info
+	AnnounceList AnnounceList
+	URLList      []string
+	// DHT nodes in "host:port" form (BEP 5)
+	Nodes        []string
+	CreationDate time.Time
+	Comment      string
+	CreatedBy    string
+	// Bencoded "piece layers" of v2 torrents (BEP 52)
+	PieceLayers []byte
 }
 
-func (mi *MetaInfo) HashInfo() (Hash, error) {
-	b, err := bencode.Marshal(mi.InfoBytes)
-	if err != nil {
-		return Hash{}, err
+// New reads a torrent file from r and validates it.
+func New(r io.Reader) (*MetaInfo, error) {
+	var ret MetaInfo
+	var t struct {
+		Info         bencode.Bytes `bencode:"info"`
+		Announce     bencode.Bytes `bencode:"announce"`
+		AnnounceList bencode.Bytes `bencode:"announce-list"`
+		URLList      bencode.Bytes `bencode:"url-list"`
+		PieceLayers  bencode.Bytes `bencode:"piece layers"`
+		Nodes        bencode.Bytes `bencode:"nodes"`
+		CreationDate bencode.Bytes `bencode:"creation date"`
+		Comment      bencode.Bytes `bencode:"comment"`
+		CreatedBy    bencode.Bytes `bencode:"created by"`
 	}
 
-	return HashBytes(b), nil
-}
-
-func (mi *MetaInfo) UnmarshalInfo() (info Info, err error) {
-	err = bencode.Unmarshal(mi.InfoBytes, &info)
-	return
-}
-
-func (mi *MetaInfo) HashInfoBytes() (infoHash Hash) {
-	return HashBytes(mi.InfoBytes)
-}
-
-func (mi *MetaInfo) Marshal(w io.Writer) error {
-	return bencode.NewEncoder(w).Encode(mi)
-}
-
-func (mi *MetaInfo) ConvertToAnnounceList() AnnounceList {
-	if mi.AnnounceList.shouldOverrideAnnounce(mi.Announce) {
-		return mi.AnnounceList
+	err := bencode.NewDecoder(r).Decode(&t)
+	if err != nil {
+		return nil, err
 	}
 
-	if mi.Announce != "" {
-		return [][]string{{mi.Announce}}
+	if len(t.Info) == 0 {
+		return nil, errNoInfo
 	}
 
-	return nil
-}
-
-func Load(r io.Reader) (*MetaInfo, error) {
-	var mi MetaInfo
-	d := bencode.NewDecoder(r)
-	err := d.Decode(&mi)
+	info, err := NewInfo(t.Info, true, true)
 	if err != nil {
 		return nil, err
 	}
 
-	err = d.ReadEOF()
+	err = info.SetPieceLayers(t.PieceLayers)
 	if err != nil {
-		err = fmt.Errorf("error after decoding metainfo: %w", err)
+		return nil, err
 	}
 
-	return &mi, err
+	ret.Info = *info
+	ret.PieceLayers = t.PieceLayers
+	ret.AnnounceList = parseAnnounceList(t.Announce, t.AnnounceList)
+	ret.URLList = parseURLList(t.URLList)
+	ret.Nodes = parseNodes(t.Nodes)
+	ret.Comment = parseText(t.Comment)
+	ret.CreatedBy = parseText(t.CreatedBy)
+
+	// Some clients write the creation date as a string or with a wrong type, ignore it.
+	var date int64
+	if len(t.CreationDate) > 0 && bencode.Unmarshal(t.CreationDate, &date) == nil && date > 0 {
+		ret.CreationDate = time.Unix(date, 0)
+	}
+
+	return &ret, nil
 }
 
-func LoadFromFile(filename string) (*MetaInfo, error) {
-	f, err := os.Open(filename)
+// LoadFromFile reads and validates the torrent file at path.
+func LoadFromFile(path string) (*MetaInfo, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-
 	defer f.Close()
 
-	var buf bufio.Reader
-	buf.Reset(f)
-	return Load(&buf)
+	return New(bufio.NewReader(f))
 }
 
-type AnnounceList [][]string
+func parseAnnounceList(announce, announceList []byte) AnnounceList {
+	var ret AnnounceList
+	if len(announceList) > 0 {
+		var ll [][]string
+		if bencode.Unmarshal(announceList, &ll) == nil {
+			for _, tier := range ll {
+				var ti []string
+				for _, t := range tier {
+					if isTrackerSupported(t) {
+						ti = append(ti, t)
+					}
+				}
+				if len(ti) > 0 {
+					ret = append(ret, ti)
+				}
+			}
+		}
+		if len(ret) > 0 {
+			return ret
+		}
+	}
 
-func (al AnnounceList) DistinctAnnounceList() (ret []string) {
-	exists := make(map[string]struct{})
+	var s string
+	if len(announce) > 0 && bencode.Unmarshal(announce, &s) == nil && isTrackerSupported(s) {
+		ret = append(ret, []string{s})
+	}
+	return ret
+}
 
-	for _, tier := range al {
-		for _, v := range tier {
-			if _, ok := exists[v]; !ok {
-				exists[v] = struct{}{}
-				ret = append(ret, v)
-			}
+func parseURLList(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+
+	var l []string
+	if b[0] == 'l' {
+		if bencode.Unmarshal(b, &l) != nil {
+			return nil
+		}
+	} else {
+		var s string
+		if bencode.Unmarshal(b, &s) != nil {
+			return nil
 		}
+		l = []string{s}
 	}
 
-	return
+	var ret []string
+	for _, s := range l {
+		if isWebseedSupported(s) {
+			ret = append(ret, s)
+		}
+	}
+	return ret
 }
 
-func (al AnnounceList) shouldOverrideAnnounce(announce string) bool {
-	for _, tier := range al {
-		for _, url := range tier {
-			if url != "" || announce == "" {
-				return true
+// parseNodes parses the list of [host, port] pairs in the "nodes" key.
+func parseNodes(b []byte) []string {
+	var l []interface{}
+	if len(b) == 0 || bencode.Unmarshal(b, &l) != nil {
+		return nil
+	}
+
+	var ret []string
+	for _, v := range l {
+		switch n := v.(type) {
+		case string:
+			if _, _, err := net.SplitHostPort(n); err == nil {
+				ret = append(ret, n)
+			}
+		case []interface{}:
+			if len(n) != 2 {
+				continue
+			}
+			host, ok := n[0].(string)
+			port, ok2 := n[1].(int64)
+			if !ok || !ok2 || host == "" || port <= 0 || port > 65535 {
+				continue
 			}
+			ret = append(ret, net.JoinHostPort(host, strconv.FormatInt(port, 10)))
 		}
 	}
-	return false
+	return ret
 }
 
-type Node string
+func parseText(b []byte) string {
+	var s string
+	if len(b) == 0 || bencode.Unmarshal(b, &s) != nil {
+		return ""
+	}
+	return strings.ToValidUTF8(s, string(unicode.ReplacementChar))
+}
 
-var _ bencode.Unmarshaler = (*Node)(nil)
+func isTrackerSupported(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "udp://")
+}
 
-func (n *Node) UnmarshalBencode(b []byte) (err error) {
-	var iface interface{}
-	err = bencode.Unmarshal(b, &iface)
+func isWebseedSupported(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
 
-	if err != nil {
-		return
-	}
-
-	switch v := iface.(type) {
-	case string:
-		*n = Node(v)
-	case []interface{}:
-		func() {
-			defer func() {
-				r := recover()
-				if r != nil {
-					err = r.(error)
-				}
-			}()
-			*n = Node(
-				net.JoinHostPort(v[0].(string), strconv.FormatInt(v[1].(int64), 10)),
-			)
-		}()
-	default:
-		err = fmt.Errorf("unsupported type: %T", iface)
+// metaInfoType is the bencoded form of MetaInfo.
+type metaInfoType struct {
+	Announce     string          `bencode:"announce,omitempty"`
+	AnnounceList [][]string      `bencode:"announce-list,omitempty"`
+	Comment      string          `bencode:"comment,omitempty"`
+	CreatedBy    string          `bencode:"created by,omitempty"`
+	CreationDate int64           `bencode:"creation date,omitempty"`
+	Info         bencode.Bytes   `bencode:"info"`
+	Nodes        [][]interface{} `bencode:"nodes,omitempty"`
+	PieceLayers  bencode.Bytes   `bencode:"piece layers,omitempty"`
+	URLList      []string        `bencode:"url-list,omitempty"`
+}
+
+// Write encodes the torrent file to w. The info dictionary is written unchanged.
+func (m *MetaInfo) Write(w io.Writer) error {
+	t := metaInfoType{
+		Comment:     m.Comment,
+		CreatedBy:   m.CreatedBy,
+		Info:        m.Info.Raw,
+		PieceLayers: m.PieceLayers,
+		URLList:     m.URLList,
+	}
+	if len(m.AnnounceList) > 0 {
+		t.Announce = m.AnnounceList[0][0]
+	}
+	if len(m.AnnounceList) > 1 || (len(m.AnnounceList) == 1 && len(m.AnnounceList[0]) > 1) {
+		t.AnnounceList = m.AnnounceList
+	}
+	if !m.CreationDate.IsZero() {
+		t.CreationDate = m.CreationDate.Unix()
+	}
+	for _, n := range m.Nodes {
+		host, port, err := net.SplitHostPort(n)
+		if err != nil {
+			continue
+		}
+		p, err := strconv.ParseInt(port, 10, 64)
+		if err != nil {
+			continue
+		}
+		t.Nodes = append(t.Nodes, []interface{}{host, p})
 	}
 
-	return
+	return bencode.NewEncoder(w).Encode(t)
 }
 
-type UrlList []string
-
-var _ bencode.Unmarshaler = (*UrlList)(nil)
+// AnnounceList is the list of tracker tiers (BEP 12).
+type AnnounceList [][]string
 
-func (me *UrlList) UnmarshalBencode(b []byte) error {
-	if len(b) == 0 {
-		return nil
-	}
+// DistinctAnnounceList returns the tracker URLs in all tiers without duplicates.
+func (al AnnounceList) DistinctAnnounceList() (ret []string) {
+	exists := make(map[string]struct{})
 
-	if b[0] == 'l' {
-		var l []string
-		err := bencode.Unmarshal(b, &l)
-		*me = l
-		return err
+	for _, tier := range al {
+		for _, v := range tier {
+			if _, ok := exists[v]; !ok {
+				exists[v] = struct{}{}
+				ret = append(ret, v)
+			}
+		}
 	}
 
-	var s string
-	err := bencode.Unmarshal(b, &s)
-	*me = []string{s}
-	return err
+	return
 }
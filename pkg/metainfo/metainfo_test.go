@@ -0,0 +1,120 @@
+package metainfo
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/al002/zbittorrent/pkg/bencode"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTorrent(t *testing.T) {
+	f, err := os.Open("testdata/ubuntu-24.04.2-desktop-amd64.iso.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tor, err := New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "ubuntu-24.04.2-desktop-amd64.iso", tor.Info.Name)
+	assert.Equal(t, int64(6343219200), tor.Info.Length)
+	assert.Equal(t, "611f70899d4e1d6a9c39cfc925f103dfef630328", hex.EncodeToString(tor.Info.Hash[:]))
+	assert.Equal(t, AnnounceList{
+		{"https://torrent.ubuntu.com/announce"},
+		{"https://ipv6.torrent.ubuntu.com/announce"},
+	}, tor.AnnounceList)
+	assert.Equal(t, "Ubuntu CD releases.ubuntu.com", tor.Comment)
+	assert.Equal(t, "mktorrent 1.1", tor.CreatedBy)
+	assert.Equal(t, int64(1740054289), tor.CreationDate.Unix())
+	assert.Equal(t, uint32(24198), tor.Info.NumPieces)
+	assert.Len(t, tor.Info.PieceHash(1), 20)
+}
+
+func TestOptionalFields(t *testing.T) {
+	info := map[string]interface{}{
+		"name":         "a",
+		"piece length": int64(16384),
+		"length":       int64(10),
+		"pieces":       strings.Repeat("x", 20),
+		"source":       "SRC",
+	}
+	b, err := bencode.Marshal(map[string]interface{}{
+		"info":          info,
+		"announce":      "udp://tracker:80",
+		"url-list":      "http://seed/a",
+		"creation date": "not a number",
+		"nodes": []interface{}{
+			[]interface{}{"router.example", int64(6881)},
+			[]interface{}{"bad"},
+			"10.0.0.1:1",
+		},
+	})
+	require.NoError(t, err)
+
+	mi, err := New(bytes.NewReader(b))
+	require.NoError(t, err)
+	assert.Equal(t, AnnounceList{{"udp://tracker:80"}}, mi.AnnounceList)
+	assert.Equal(t, []string{"http://seed/a"}, mi.URLList)
+	assert.Equal(t, []string{"router.example:6881", "10.0.0.1:1"}, mi.Nodes)
+	assert.True(t, mi.CreationDate.IsZero())
+	assert.Equal(t, "SRC", mi.Info.Source)
+
+	var buf bytes.Buffer
+	require.NoError(t, mi.Write(&buf))
+	mi2, err := New(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, mi.Info.Hash, mi2.Info.Hash)
+	assert.Equal(t, mi.Nodes, mi2.Nodes)
+	assert.Equal(t, mi.URLList, mi2.URLList)
+}
+
+func TestSanitisation(t *testing.T) {
+	newInfo := func(fn func(info map[string]interface{})) error {
+		info := map[string]interface{}{
+			"name":         "a",
+			"piece length": int64(16384),
+			"pieces":       strings.Repeat("x", 20),
+			"files": []interface{}{
+				map[string]interface{}{"length": int64(5), "path": []interface{}{"b"}},
+			},
+		}
+		fn(info)
+		b, err := bencode.Marshal(info)
+		require.NoError(t, err)
+		_, err = NewInfo(b, true, true)
+		return err
+	}
+
+	assert.NoError(t, newInfo(func(map[string]interface{}) {}))
+	assert.Error(t, newInfo(func(info map[string]interface{}) {
+		info["files"] = []interface{}{map[string]interface{}{"length": int64(5), "path": []interface{}{"..", "b"}}}
+	}))
+	assert.Error(t, newInfo(func(info map[string]interface{}) {
+		info["files"] = []interface{}{
+			map[string]interface{}{"length": int64(5), "path": []interface{}{"b"}},
+			map[string]interface{}{"length": int64(5), "path": []interface{}{"b"}},
+		}
+	}))
+	assert.ErrorIs(t, newInfo(func(info map[string]interface{}) {
+		info["pieces"] = strings.Repeat("x", 40)
+	}), errInvalidPieceData)
+	assert.ErrorIs(t, newInfo(func(info map[string]interface{}) {
+		info["pieces"] = strings.Repeat("x", 19)
+	}), errInvalidPieceData)
+
+	b, err := bencode.Marshal(map[string]interface{}{
+		"name": "..", "piece length": int64(16384), "pieces": strings.Repeat("x", 20), "length": int64(1),
+	})
+	require.NoError(t, err)
+	info, err := NewInfo(b, true, true)
+	require.NoError(t, err)
+	assert.Equal(t, info.Hash.HexString(), info.Name)
+}
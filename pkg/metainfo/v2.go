@@ -0,0 +1,242 @@
+package metainfo
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/al002/zbittorrent/internal/merkle"
+	"github.com/al002/zbittorrent/pkg/bencode"
+)
+
+var (
+	errFileTree          = errors.New("invalid file tree")
+	errFileListMismatch  = errors.New("v1 file list does not match v2 file tree")
+	errMissingPieceLayer = errors.New("missing piece layer")
+	errInvalidPieceLayer = errors.New("invalid piece layer")
+)
+
+// fileV2 is a file in the "file tree" of a v2 torrent.
+type fileV2 struct {
+	Path       []string
+	Length     int64
+	PiecesRoot [32]byte
+}
+
+type pieceV2 struct {
+	hash   [32]byte
+	leaves int
+}
+
+// parseFileTree flattens the file tree in the order of its sorted keys.
+func parseFileTree(tree map[string]interface{}) ([]fileV2, error) {
+	if len(tree) == 0 {
+		return nil, errFileTree
+	}
+
+	var files []fileV2
+	err := walkFileTree(tree, nil, &files)
+	return files, err
+}
+
+func walkFileTree(node map[string]interface{}, path []string, files *[]fileV2) error {
+	if v, ok := node[""]; ok {
+		if len(node) != 1 || len(path) == 0 {
+			return errFileTree
+		}
+		f, err := parseFileTreeEntry(v)
+		if err != nil {
+			return fmt.Errorf("%w: %q", err, filepath.Join(path...))
+		}
+		f.Path = path
+		*files = append(*files, f)
+		return nil
+	}
+
+	keys := make([]string, 0, len(node))
+	for k := range node {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		child, ok := node[k].(map[string]interface{})
+		if !ok {
+			return errFileTree
+		}
+		err := walkFileTree(child, append(path[:len(path):len(path)], k), files)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseFileTreeEntry(v interface{}) (fileV2, error) {
+	var f fileV2
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return f, errFileTree
+	}
+
+	length, ok := m["length"].(int64)
+	if !ok || length < 0 {
+		return f, errFileTree
+	}
+	f.Length = length
+
+	root, _ := m["pieces root"].(string)
+	switch {
+	case length == 0 && root == "":
+	case len(root) == len(f.PiecesRoot):
+		copy(f.PiecesRoot[:], root)
+	default:
+		return f, errFileTree
+	}
+
+	return f, nil
+}
+
+// setFilesV2 sets the v1 style file list of a v2 only torrent.
+// Padding files are inserted so each file starts at a piece boundary, as in hybrid torrents.
+func (it *infoType) setFilesV2(files []fileV2) {
+	if len(files) == 1 && len(files[0].Path) == 1 && files[0].Path[0] == it.Name {
+		it.Length = files[0].Length
+		return
+	}
+
+	pieceLength := int64(it.PieceLength)
+	for j, f := range files {
+		it.Files = append(it.Files, file{Length: f.Length, Path: f.Path})
+		rem := f.Length % pieceLength
+		if rem == 0 || j == len(files)-1 {
+			continue
+		}
+		pad := pieceLength - rem
+		it.Files = append(it.Files, file{
+			Length: pad,
+			Path:   []string{".pad", strconv.FormatInt(pad, 10)},
+			Attr:   "p",
+		})
+	}
+}
+
+// setPiecesRoots copies the pieces roots to the files and checks that
+// the v1 file list of hybrid torrents matches the file tree.
+func (i *Info) setPiecesRoots(files []fileV2) error {
+	var j int
+	for k := range i.Files {
+		f := &i.Files[k]
+		if f.Padding {
+			continue
+		}
+		if j >= len(files) || files[j].Length != f.Length {
+			return errFileListMismatch
+		}
+
+		parts := []string{truncateName(i.Name)}
+		if len(i.Files) > 1 || f.Path != parts[0] {
+			for _, p := range files[j].Path {
+				parts = append(parts, truncateName(p))
+			}
+		}
+		if filepath.Join(parts...) != f.Path {
+			return errFileListMismatch
+		}
+
+		f.PiecesRoot = files[j].PiecesRoot
+		j++
+	}
+
+	if j != len(files) {
+		return errFileListMismatch
+	}
+
+	return nil
+}
+
+// IsHybrid returns true if the torrent can be used in both v1 and v2 swarms.
+func (i *Info) IsHybrid() bool {
+	return i.MetaVersion == 2 && len(i.pieces) > 0
+}
+
+// IsV2Only returns true if the torrent has no v1 piece hashes.
+func (i *Info) IsV2Only() bool {
+	return i.MetaVersion == 2 && len(i.pieces) == 0
+}
+
+// TruncatedHashV2 returns the first 20 bytes of the v2 info hash,
+// which is used in place of the v1 info hash for trackers, DHT and the handshake.
+func (i *Info) TruncatedHashV2() Hash {
+	var h Hash
+	copy(h[:], i.HashV2[:])
+	return h
+}
+
+// SetPieceLayers validates the bencoded "piece layers" dictionary against the pieces roots
+// and keeps the SHA-256 hash of each piece. Files not larger than a piece need no layer.
+func (i *Info) SetPieceLayers(b []byte) error {
+	if i.MetaVersion != 2 {
+		return nil
+	}
+
+	var layers map[string]string
+	if len(b) > 0 {
+		if err := bencode.Unmarshal(b, &layers); err != nil {
+			return err
+		}
+	}
+
+	pieceLength := int64(i.PieceLength)
+	leavesPerPiece := int(pieceLength / merkle.BlockSize)
+	pieces := make([]pieceV2, i.NumPieces)
+	var offset int64
+	for _, f := range i.Files {
+		index := offset / pieceLength
+		offset += f.Length
+		if f.Padding || f.Length == 0 {
+			continue
+		}
+
+		if f.Length <= pieceLength {
+			pieces[index] = pieceV2{hash: f.PiecesRoot, leaves: merkle.NumLeaves(f.Length)}
+			continue
+		}
+
+		layer, ok := layers[string(f.PiecesRoot[:])]
+		if !ok {
+			return fmt.Errorf("%w: %q", errMissingPieceLayer, f.Path)
+		}
+		numPieces := (f.Length + pieceLength - 1) / pieceLength
+		if int64(len(layer)) != numPieces*merkle.HashSize {
+			return fmt.Errorf("%w: %q", errInvalidPieceLayer, f.Path)
+		}
+
+		hashes := make([]merkle.Hash, numPieces)
+		for k := range hashes {
+			copy(hashes[k][:], layer[k*merkle.HashSize:])
+			pieces[index+int64(k)] = pieceV2{hash: hashes[k], leaves: leavesPerPiece}
+		}
+		width := merkle.NumLeaves(f.Length) / leavesPerPiece
+		if merkle.Root(hashes, width, merkle.PadHash(leavesPerPiece)) != f.PiecesRoot {
+			return fmt.Errorf("%w: %q", errInvalidPieceLayer, f.Path)
+		}
+	}
+
+	i.piecesV2 = pieces
+	return nil
+}
+
+// PieceHashV2 returns the SHA-256 merkle hash of the piece at index and
+// the number of 16 KiB leaves it covers. It returns nil before SetPieceLayers is called.
+func (i *Info) PieceHashV2(index uint32) ([]byte, int) {
+	if int(index) >= len(i.piecesV2) || i.piecesV2[index].leaves == 0 {
+		return nil, 0
+	}
+
+	p := &i.piecesV2[index]
+	return p.hash[:], p.leaves
+}
@@ -0,0 +1,195 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/al002/zbittorrent/internal/merkle"
+	"github.com/al002/zbittorrent/pkg/bencode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureData returns the contents of the files in the v2 fixtures.
+func fixtureData(size int, seed byte) []byte {
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = byte(i*7) + seed
+	}
+	return b
+}
+
+func loadFixture(t *testing.T, name string) *MetaInfo {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	defer f.Close()
+
+	mi, err := New(f)
+	require.NoError(t, err)
+	return mi
+}
+
+func assertPiecesV2(t *testing.T, info *Info, files map[string][]byte) {
+	t.Helper()
+	pieceLength := int64(info.PieceLength)
+	var offset int64
+	for _, f := range info.Files {
+		start := offset
+		offset += f.Length
+		if f.Padding || f.Length == 0 {
+			continue
+		}
+		data := files[f.Path]
+		require.NotNil(t, data, f.Path)
+		require.Zero(t, start%pieceLength, f.Path)
+		assert.Equal(t, merkle.FileRoot(data), merkle.Hash(f.PiecesRoot), f.Path)
+
+		for k := int64(0); k*pieceLength < int64(len(data)); k++ {
+			index := uint32(start/pieceLength + k)
+			hash, leaves := info.PieceHashV2(index)
+			require.NotNil(t, hash, "piece %d", index)
+			sum := merkle.PieceHash(data[k*pieceLength:min(int64(len(data)), (k+1)*pieceLength)], leaves)
+			assert.Equal(t, sum[:], hash, "piece %d", index)
+		}
+	}
+}
+
+func multiFileFixture() map[string][]byte {
+	return map[string][]byte{
+		"a.bin":     fixtureData(100000, 1),
+		"c.bin":     fixtureData(32768, 3),
+		"dir/b.bin": fixtureData(20000, 2),
+	}
+}
+
+func TestV2Torrent(t *testing.T) {
+	mi := loadFixture(t, "v2.torrent")
+	info := &mi.Info
+
+	assert.Equal(t, 2, info.MetaVersion)
+	assert.True(t, info.IsV2Only())
+	assert.False(t, info.IsHybrid())
+	assert.Equal(t, "2006fb6271cd7a28200026c0c6403b3e13d17ceb63b4518ae34b8227ed796ab5", hex.EncodeToString(info.HashV2[:]))
+	assert.Equal(t, info.TruncatedHashV2(), info.Hash)
+	assert.Equal(t, sha256.Sum256(info.Raw), info.HashV2)
+	assert.Equal(t, uint32(6), info.NumPieces)
+	assert.Nil(t, info.PieceHash(0))
+
+	var paths []string
+	for _, f := range info.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.Equal(t, []string{
+		filepath.Join("v2test", "a.bin"),
+		filepath.Join("v2test", ".pad", "31072"),
+		filepath.Join("v2test", "c.bin"),
+		filepath.Join("v2test", "dir", "b.bin"),
+		filepath.Join("v2test", ".pad", "12768"),
+		filepath.Join("v2test", "dir", "empty"),
+	}, paths)
+	assert.True(t, info.Files[1].Padding)
+	assert.Equal(t, [32]byte{}, info.Files[5].PiecesRoot)
+
+	files := make(map[string][]byte)
+	for k, v := range multiFileFixture() {
+		files[filepath.Join("v2test", filepath.FromSlash(k))] = v
+	}
+	assertPiecesV2(t, info, files)
+}
+
+func TestV2SingleFileTorrent(t *testing.T) {
+	mi := loadFixture(t, "v2-single.torrent")
+	info := &mi.Info
+
+	assert.True(t, info.IsV2Only())
+	assert.Equal(t, "34e6d188f96b799bc2df87a90af6bcc0fa73655851b96fc23bb6d77ba49117d3", hex.EncodeToString(info.HashV2[:]))
+	assert.Equal(t, int64(70000), info.Length)
+	assert.Equal(t, uint32(5), info.NumPieces)
+	require.Len(t, info.Files, 1)
+	assert.Equal(t, "single.bin", info.Files[0].Path)
+	assertPiecesV2(t, info, map[string][]byte{"single.bin": fixtureData(70000, 5)})
+}
+
+func TestHybridTorrent(t *testing.T) {
+	mi := loadFixture(t, "hybrid.torrent")
+	info := &mi.Info
+
+	assert.True(t, info.IsHybrid())
+	assert.Equal(t, "d2510031c946fa1b376c88c397d72272bd084f21", hex.EncodeToString(info.Hash[:]))
+	assert.Equal(t, "51bc307abfd1b581b2fdcb1e72bc311313d7e9270dbbcdf75f69c537011f8d3f", hex.EncodeToString(info.HashV2[:]))
+	assert.Equal(t, uint32(6), info.NumPieces)
+	assert.NotNil(t, info.PieceHash(5))
+
+	files := make(map[string][]byte)
+	for k, v := range multiFileFixture() {
+		files[filepath.Join("hybridtest", filepath.FromSlash(k))] = v
+	}
+	assertPiecesV2(t, info, files)
+
+	single := loadFixture(t, "hybrid-single.torrent")
+	assert.True(t, single.Info.IsHybrid())
+	assert.Equal(t, "bf6819230848954dad92e2b21e747bd3b2020e5b", hex.EncodeToString(single.Info.Hash[:]))
+	assertPiecesV2(t, &single.Info, map[string][]byte{"single.bin": fixtureData(70000, 5)})
+}
+
+// modifyFixture decodes a fixture, calls fn on it and encodes it again.
+func modifyFixture(t *testing.T, name string, fn func(m map[string]interface{})) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	var m map[string]interface{}
+	require.NoError(t, bencode.Unmarshal(b, &m))
+	fn(m)
+	b, err = bencode.Marshal(m)
+	require.NoError(t, err)
+	return b
+}
+
+func TestV2InvalidPieceLayers(t *testing.T) {
+	b := modifyFixture(t, "v2.torrent", func(m map[string]interface{}) {
+		delete(m, "piece layers")
+	})
+	_, err := New(bytes.NewReader(b))
+	assert.ErrorIs(t, err, errMissingPieceLayer)
+
+	b = modifyFixture(t, "v2.torrent", func(m map[string]interface{}) {
+		for k, v := range m["piece layers"].(map[string]interface{}) {
+			layer := []byte(v.(string))
+			layer[0] ^= 1
+			m["piece layers"].(map[string]interface{})[k] = string(layer)
+		}
+	})
+	_, err = New(bytes.NewReader(b))
+	assert.ErrorIs(t, err, errInvalidPieceLayer)
+}
+
+func TestHybridFileListMismatch(t *testing.T) {
+	b := modifyFixture(t, "hybrid.torrent", func(m map[string]interface{}) {
+		info := m["info"].(map[string]interface{})
+		files := info["files"].([]interface{})
+		files[0].(map[string]interface{})["path"] = []interface{}{"other.bin"}
+	})
+	_, err := New(bytes.NewReader(b))
+	assert.ErrorIs(t, err, errFileListMismatch)
+}
+
+func TestV2InvalidFileTree(t *testing.T) {
+	b := modifyFixture(t, "v2.torrent", func(m map[string]interface{}) {
+		info := m["info"].(map[string]interface{})
+		info["file tree"] = map[string]interface{}{"x": map[string]interface{}{"": map[string]interface{}{"length": int64(5)}}}
+	})
+	_, err := New(bytes.NewReader(b))
+	assert.ErrorIs(t, err, errFileTree)
+
+	b = modifyFixture(t, "v2.torrent", func(m map[string]interface{}) {
+		m["info"].(map[string]interface{})["meta version"] = int64(3)
+	})
+	_, err = New(bytes.NewReader(b))
+	assert.ErrorIs(t, err, errMetaVersion)
+}
@@ -27,7 +27,7 @@ type Config struct {
 	// Peer id is prefixed with this string. See BEP 20. Remaining bytes of peer id will be randomized.
 	PrivatePeerIDPrefix                    string `mapstructure:"private_peer_id_prefix"`
 	PrivateExtensionHandshakeClientVersion string `mapstructure:"private_extension_handshake_client_version"`
-	// URL to the blocklist file in CIDR format.
+	// URL to the blocklist file in CIDR, PeerGuardian P2P or eMule DAT format. The file may be gzip or zip compressed.
 	BlocklistURL            string        `mapstructure:"blocklist_url"`
 	BlocklistUpdateInterval time.Duration `mapstructure:"blocklist_update_interval"`
 	BlocklistUpdateTimeout  time.Duration `mapstructure:"blocklist_update_timeout"`
@@ -39,8 +39,8 @@ type Config struct {
 	BlocklistEnabledForIncomingConnections bool `mapstructure:"blocklist_enabled_for_incoming_connections"`
 	// Do not accept response larger than this size
 	BlocklistMaxResponseSize int64 `mapstructure:"blocklist_max_response_size"`
-	//  // Time to wait when adding torrent with AddURI().
-	//  TorrentAddHTTPTimeout time.Duration `mapstructure:"torrent_add_http_timeout"`
+	// Time to wait when adding torrent with AddURI().
+	TorrentAddHTTPTimeout time.Duration `mapstructure:"torrent_add_http_timeout"`
 	// // Maximum allowed size to be received by metadata extension.
 	// MaxMetadataSize uint `mapstructure:"max_metadata_size"`
 	// Maximum allowed size to be read when adding torrent.
@@ -48,9 +48,37 @@ type Config struct {
 	// Time to wait when resolving host names for trackers and peers.
 	DNSResolveTimeout time.Duration `mapstructure:"dns_resolve_timeout"`
 	// Global download speed limit in KB/s.
+	// Limits changed with Session.SetSpeedLimits are saved in the database and override the speed limits in the config.
 	SpeedLimitDownload int64 `mapstructure:"speed_limit_download"`
 	// Global upload speed limit in KB/s.
 	SpeedLimitUpload int64 `mapstructure:"speed_limit_upload"`
+	// Download speed limit of each peer in KB/s.
+	SpeedLimitPeerDownload int64 `mapstructure:"speed_limit_peer_download"`
+	// Upload speed limit of each peer in KB/s.
+	SpeedLimitPeerUpload int64 `mapstructure:"speed_limit_peer_upload"`
+	// Seeding torrents are stopped or removed when their share ratio reaches this value. Zero means no limit.
+	// Limits changed with Session.SetSeedLimits are saved in the database and override the seed limits in the config.
+	SeedRatioLimit float64 `mapstructure:"seed_ratio_limit"`
+	// Seeding torrents are stopped or removed when they are seeded for this duration in total. Zero means no limit.
+	SeedTimeLimit time.Duration `mapstructure:"seed_time_limit"`
+	// Seeding torrents are stopped or removed when they do not upload for this duration. Zero means no limit.
+	SeedIdleLimit time.Duration `mapstructure:"seed_idle_limit"`
+	// Action when a seed limit is reached, "stop" or "remove".
+	SeedLimitAction string `mapstructure:"seed_limit_action"`
+	// Maximum number of running torrents that are not completed. Other started torrents wait in the queue. Zero means no limit.
+	// Limits changed with Session.SetQueueLimits are saved in the database and override the queue limits in the config.
+	QueueMaxActiveDownloads int `mapstructure:"queue_max_active_downloads"`
+	// Maximum number of running torrents that are completed. Zero means no limit.
+	QueueMaxActiveSeeds int `mapstructure:"queue_max_active_seeds"`
+	// Maximum number of running torrents in total. Zero means no limit.
+	QueueMaxActiveTorrents int `mapstructure:"queue_max_active_torrents"`
+	// Do not count the torrents that are slower than the slow rates for the slow time in the limits.
+	QueueExcludeSlowTorrents bool `mapstructure:"queue_exclude_slow_torrents"`
+	// Download and upload rates in KB/s that a torrent must reach to be counted in the limits.
+	QueueSlowDownloadRate int64 `mapstructure:"queue_slow_download_rate"`
+	QueueSlowUploadRate   int64 `mapstructure:"queue_slow_upload_rate"`
+	// Time a torrent runs before it is checked for being slow.
+	QueueSlowTime time.Duration `mapstructure:"queue_slow_time"`
 	// Start torrent automatically if it was running when previous session was closed.
 	ResumeOnStartup bool `mapstructure:"resume_on_startup"`
 	// Check each torrent loop for aliveness. Helps to detect bugs earlier.
@@ -61,6 +89,28 @@ type Config struct {
 	// Effective only when default storage provider is used.
 	FilePermissions fs.FileMode `mapstructure:"file_permissions"`
 
+	// Enable JSON-RPC server for controlling the session remotely.
+	RPCEnabled bool `mapstructure:"rpc_enabled"`
+	// Host to listen for JSON-RPC server.
+	RPCHost string `mapstructure:"rpc_host"`
+	// Listen port for JSON-RPC server.
+	RPCPort int `mapstructure:"rpc_port"`
+	// If set, clients must send this token in "Authorization: Bearer <token>" header.
+	RPCToken string `mapstructure:"rpc_token"`
+	// Host names that clients may use in the Host header when no RPC token is set, in addition to localhost and IP addresses.
+	// Protects the server from DNS rebinding. Patterns like "*.example.com" are allowed.
+	RPCHostWhitelist []string `mapstructure:"rpc_host_whitelist"`
+	// If set, JSON-RPC server also listens on this Unix socket.
+	RPCUnixSocket string `mapstructure:"rpc_unix_socket"`
+	// Serve Transmission compatible RPC at /transmission/rpc on the JSON-RPC server.
+	RPCTransmissionEnabled bool `mapstructure:"rpc_transmission_enabled"`
+	// Serve metrics in Prometheus text format at /metrics on the JSON-RPC server.
+	RPCMetricsEnabled bool `mapstructure:"rpc_metrics_enabled"`
+	// Serve the web interface at / on the JSON-RPC server.
+	RPCWebUIEnabled bool `mapstructure:"rpc_webui_enabled"`
+	// Time to wait for ongoing requests before shutting down JSON-RPC server.
+	RPCShutdownTimeout time.Duration `mapstructure:"rpc_shutdown_timeout"`
+
 	// Number of peer addresses to request in announce request.
 	TrackerNumWant int `mapstructure:"tracker_num_want"`
 	// Time to wait for announcing stopped event.
@@ -99,21 +149,31 @@ var DefaultConfig = Config{
 	BlocklistEnabledForOutgoingConnections: true,
 	BlocklistEnabledForIncomingConnections: true,
 	BlocklistMaxResponseSize:               100 << 20,
-	// TorrentAddHTTPTimeout:                  30 * time.Second,
+	TorrentAddHTTPTimeout:                  30 * time.Second,
 	// MaxMetadataSize:                        30 << 20,
 	MaxTorrentSize: 10 << 20,
 	// MaxPieces:                              64 << 10,
 	DNSResolveTimeout:   5 * time.Second,
+	SeedLimitAction:     "stop",
 	ResumeOnStartup:     true,
 	HealthCheckInterval: 10 * time.Second,
 	HealthCheckTimeout:  60 * time.Second,
 	FilePermissions:     0o750,
 
+	// Queue
+	QueueMaxActiveDownloads: 5,
+	QueueMaxActiveSeeds:     10,
+	QueueSlowDownloadRate:   2,
+	QueueSlowUploadRate:     2,
+	QueueSlowTime:           time.Minute,
+
 	// RPC Server
-	// RPCEnabled:         true,
-	// RPCHost:            "127.0.0.1",
-	// RPCPort:            7246,
-	// RPCShutdownTimeout: 5 * time.Second,
+	RPCEnabled:         true,
+	RPCHost:            "127.0.0.1",
+	RPCPort:            7246,
+	RPCMetricsEnabled:  true,
+	RPCWebUIEnabled:    true,
+	RPCShutdownTimeout: 5 * time.Second,
 
 	// Tracker
 	TrackerNumWant:              200,
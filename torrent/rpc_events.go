@@ -0,0 +1,117 @@
+package torrent
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/rpctypes"
+)
+
+// Interval of the "stats" messages sent to the event stream.
+const eventStreamStatsInterval = time.Second
+
+// eventStream pushes the events of the Session to the clients as server-sent events.
+// Stats of all torrents are sent in a "stats" message periodically so clients can show live progress.
+// Clients may receive only some of the events by listing their names in the comma separated "types" parameter.
+type eventStream struct {
+	session *Session
+	// Closed when the server is shutting down. Streams never end otherwise.
+	closeC chan struct{}
+}
+
+func (s *eventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var types []EventType
+	if q := r.URL.Query().Get("types"); q != "" {
+		for _, name := range strings.Split(q, ",") {
+			var e EventType
+			if err := e.UnmarshalText([]byte(name)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			types = append(types, e)
+		}
+	}
+
+	events := s.session.Subscribe(r.Context(), types...)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(eventStreamStatsInterval)
+	defer ticker.Stop()
+
+	send := func(name string, v any) bool {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return false
+		}
+		_, err = w.Write([]byte("event: " + name + "\ndata: " + string(b) + "\n\n"))
+		if err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	if !send("stats", s.torrentStats()) {
+		return
+	}
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if !send(e.Type.String(), newRPCEvent(e)) {
+				return
+			}
+		case <-ticker.C:
+			if !send("stats", s.torrentStats()) {
+				return
+			}
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
+func (s *eventStream) torrentStats() []rpctypes.TorrentStats {
+	torrents := s.session.ListTorrents()
+	stats := make([]rpctypes.TorrentStats, len(torrents))
+	for i, t := range torrents {
+		stats[i] = rpctypes.TorrentStats{
+			Torrent: newRPCTorrent(t),
+			Stats:   newRPCStats(t.Stats()),
+		}
+	}
+
+	return stats
+}
+
+func newRPCEvent(e Event) rpctypes.Event {
+	r := rpctypes.Event{
+		Type:      e.Type.String(),
+		Time:      e.Time,
+		TorrentID: e.TorrentID,
+		Tracker:   e.Tracker,
+		Warning:   e.Warning,
+		Piece:     e.Piece,
+		AltSpeed:  e.AltSpeedActive,
+	}
+	if e.Error != nil {
+		r.Error = e.Error.Error()
+	}
+
+	return r
+}
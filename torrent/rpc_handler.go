@@ -0,0 +1,531 @@
+package torrent
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/jsonrpc"
+	"github.com/al002/zbittorrent/internal/rpctypes"
+)
+
+type rpcHandler struct {
+	session *Session
+}
+
+func (h *rpcHandler) register(srv *jsonrpc.Server) {
+	jsonrpc.Register(srv, "Session.AddTorrent", h.addTorrent)
+	jsonrpc.Register(srv, "Session.AddURI", h.addURI)
+	jsonrpc.Register(srv, "Session.ListTorrents", h.listTorrents)
+	jsonrpc.Register(srv, "Session.RemoveTorrent", h.removeTorrent)
+	jsonrpc.Register(srv, "Session.GetSessionStats", h.getSessionStats)
+	jsonrpc.Register(srv, "Session.GetSpeedLimits", h.getSpeedLimits)
+	jsonrpc.Register(srv, "Session.SetSpeedLimits", h.setSpeedLimits)
+	jsonrpc.Register(srv, "Session.GetSeedLimits", h.getSeedLimits)
+	jsonrpc.Register(srv, "Session.SetSeedLimits", h.setSeedLimits)
+	jsonrpc.Register(srv, "Session.GetTorrentSeedLimits", h.getTorrentSeedLimits)
+	jsonrpc.Register(srv, "Session.SetTorrentSeedLimits", h.setTorrentSeedLimits)
+	jsonrpc.Register(srv, "Session.GetQueueLimits", h.getQueueLimits)
+	jsonrpc.Register(srv, "Session.SetQueueLimits", h.setQueueLimits)
+	jsonrpc.Register(srv, "Session.MoveTorrentInQueue", h.moveTorrentInQueue)
+	jsonrpc.Register(srv, "Session.GetAltSpeed", h.getAltSpeed)
+	jsonrpc.Register(srv, "Session.SetAltSpeed", h.setAltSpeed)
+	jsonrpc.Register(srv, "Session.GetTorrentSpeedLimits", h.getTorrentSpeedLimits)
+	jsonrpc.Register(srv, "Session.SetTorrentSpeedLimits", h.setTorrentSpeedLimits)
+	jsonrpc.Register(srv, "Session.StartTorrent", h.startTorrent)
+	jsonrpc.Register(srv, "Session.StopTorrent", h.stopTorrent)
+	jsonrpc.Register(srv, "Session.VerifyTorrent", h.verifyTorrent)
+	jsonrpc.Register(srv, "Session.GetTorrentStats", h.getTorrentStats)
+	jsonrpc.Register(srv, "Session.GetTorrentTrackers", h.getTorrentTrackers)
+	jsonrpc.Register(srv, "Session.GetTorrentPeers", h.getTorrentPeers)
+	jsonrpc.Register(srv, "Session.GetTorrentFiles", h.getTorrentFiles)
+	jsonrpc.Register(srv, "Session.SetTorrentFilePriorities", h.setTorrentFilePriorities)
+	jsonrpc.Register(srv, "Session.SetTorrentSequential", h.setTorrentSequential)
+	jsonrpc.Register(srv, "Session.SetTorrentPieceDeadline", h.setTorrentPieceDeadline)
+	jsonrpc.Register(srv, "Session.GetTorrentPieces", h.getTorrentPieces)
+	jsonrpc.Register(srv, "Session.GetTorrentIPFilter", h.getTorrentIPFilter)
+	jsonrpc.Register(srv, "Session.SetTorrentIPFilter", h.setTorrentIPFilter)
+}
+
+func (h *rpcHandler) addTorrent(args *rpctypes.AddTorrentRequest, reply *rpctypes.AddTorrentResponse) error {
+	b, err := base64.StdEncoding.DecodeString(args.Torrent)
+	if err != nil {
+		return err
+	}
+
+	t, err := h.session.AddTorrent(bytes.NewReader(b), newAddTorrentOptions(&args.AddTorrentOptions))
+	if err != nil {
+		return err
+	}
+
+	reply.Torrent = newRPCTorrent(t)
+	return nil
+}
+
+func (h *rpcHandler) addURI(args *rpctypes.AddURIRequest, reply *rpctypes.AddURIResponse) error {
+	t, err := h.session.AddURI(args.URI, newAddTorrentOptions(&args.AddTorrentOptions))
+	if err != nil {
+		return err
+	}
+
+	reply.Torrent = newRPCTorrent(t)
+	return nil
+}
+
+func newAddTorrentOptions(o *rpctypes.AddTorrentOptions) *AddTorrentOptions {
+	return &AddTorrentOptions{
+		ID:                o.ID,
+		Stopped:           o.Stopped,
+		StopAfterDownload: o.StopAfterDownload,
+		StopAfterMetadata: o.StopAfterMetadata,
+	}
+}
+
+func newRPCTorrent(t *Torrent) rpctypes.Torrent {
+	return rpctypes.Torrent{
+		ID:       t.ID(),
+		Name:     t.Name(),
+		InfoHash: hex.EncodeToString(t.InfoHash()),
+		Port:     t.Port(),
+		AddedAt:  t.AddedAt(),
+
+		QueuePosition: t.QueuePosition(),
+		Sequential:    t.Sequential(),
+	}
+}
+
+func (h *rpcHandler) listTorrents(args *rpctypes.ListTorrentsRequest, reply *rpctypes.ListTorrentsResponse) error {
+	torrents := h.session.ListTorrents()
+	reply.Torrents = make([]rpctypes.Torrent, 0, len(torrents))
+	for _, t := range torrents {
+		reply.Torrents = append(reply.Torrents, newRPCTorrent(t))
+	}
+
+	return nil
+}
+
+func (h *rpcHandler) getTorrent(id string) (*Torrent, error) {
+	t := h.session.GetTorrent(id)
+	if t == nil {
+		return nil, ErrTorrentNotFound
+	}
+
+	return t, nil
+}
+
+func (h *rpcHandler) removeTorrent(args *rpctypes.RemoveTorrentRequest, reply *rpctypes.EmptyResponse) error {
+	return h.session.RemoveTorrent(args.ID)
+}
+
+func (h *rpcHandler) startTorrent(args *rpctypes.StartTorrentRequest, reply *rpctypes.EmptyResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	return t.Start()
+}
+
+func (h *rpcHandler) stopTorrent(args *rpctypes.StopTorrentRequest, reply *rpctypes.EmptyResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	return t.Stop()
+}
+
+func (h *rpcHandler) verifyTorrent(args *rpctypes.VerifyTorrentRequest, reply *rpctypes.EmptyResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	return t.Verify()
+}
+
+func (h *rpcHandler) getSessionStats(args *rpctypes.GetSessionStatsRequest, reply *rpctypes.GetSessionStatsResponse) error {
+	s := h.session.Stats()
+	reply.Stats = rpctypes.SessionStats{
+		Torrents:         s.Torrents,
+		TorrentsByStatus: make(map[string]int, len(s.TorrentsByStatus)),
+		Peers:            s.Peers,
+		PortsAvailable:   s.PortsAvailable,
+		PortsInUse:       s.PortsInUse,
+		BlockListRules:   s.BlockListRules,
+		BlockListRecency: int64(s.BlockListRecency / time.Second),
+		BytesDownloaded:  s.BytesDownloaded,
+		BytesUploaded:    s.BytesUploaded,
+		BytesWasted:      s.BytesWasted,
+		SpeedDownload:    s.SpeedDownload,
+		SpeedUpload:      s.SpeedUpload,
+		AltSpeedActive:   s.AltSpeedActive,
+		Uptime:           int64(s.Uptime / time.Second),
+	}
+	for status, n := range s.TorrentsByStatus {
+		reply.Stats.TorrentsByStatus[status.String()] = n
+	}
+
+	return nil
+}
+
+func (h *rpcHandler) getSpeedLimits(args *rpctypes.GetSpeedLimitsRequest, reply *rpctypes.GetSpeedLimitsResponse) error {
+	reply.SpeedLimits = rpctypes.SpeedLimits(h.session.SpeedLimits())
+	return nil
+}
+
+func (h *rpcHandler) setSpeedLimits(args *rpctypes.SetSpeedLimitsRequest, reply *rpctypes.EmptyResponse) error {
+	return h.session.SetSpeedLimits(SpeedLimits(args.SpeedLimits))
+}
+
+func newRPCSeedLimits(l SeedLimits) rpctypes.SeedLimits {
+	return rpctypes.SeedLimits{
+		Ratio:    l.Ratio,
+		Time:     int64(l.Time / time.Second),
+		IdleTime: int64(l.IdleTime / time.Second),
+		Action:   l.Action.String(),
+	}
+}
+
+func parseRPCSeedLimits(r rpctypes.SeedLimits) (SeedLimits, error) {
+	l := SeedLimits{
+		Ratio:    r.Ratio,
+		Time:     time.Duration(r.Time) * time.Second,
+		IdleTime: time.Duration(r.IdleTime) * time.Second,
+	}
+	err := l.Action.UnmarshalText([]byte(r.Action))
+	return l, err
+}
+
+func (h *rpcHandler) getSeedLimits(args *rpctypes.GetSeedLimitsRequest, reply *rpctypes.GetSeedLimitsResponse) error {
+	reply.SeedLimits = newRPCSeedLimits(h.session.SeedLimits())
+	return nil
+}
+
+func (h *rpcHandler) setSeedLimits(args *rpctypes.SetSeedLimitsRequest, reply *rpctypes.EmptyResponse) error {
+	l, err := parseRPCSeedLimits(args.SeedLimits)
+	if err != nil {
+		return err
+	}
+	return h.session.SetSeedLimits(l)
+}
+
+func (h *rpcHandler) getTorrentSeedLimits(args *rpctypes.GetTorrentSeedLimitsRequest, reply *rpctypes.GetSeedLimitsResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	reply.SeedLimits = newRPCSeedLimits(t.SeedLimits())
+	return nil
+}
+
+func (h *rpcHandler) setTorrentSeedLimits(args *rpctypes.SetTorrentSeedLimitsRequest, reply *rpctypes.EmptyResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	l, err := parseRPCSeedLimits(args.SeedLimits)
+	if err != nil {
+		return err
+	}
+	return t.SetSeedLimits(l)
+}
+
+func (h *rpcHandler) getQueueLimits(args *rpctypes.GetQueueLimitsRequest, reply *rpctypes.GetQueueLimitsResponse) error {
+	l := h.session.QueueLimits()
+	reply.QueueLimits = rpctypes.QueueLimits{
+		Downloads:        l.Downloads,
+		Seeds:            l.Seeds,
+		Torrents:         l.Torrents,
+		ExcludeSlow:      l.ExcludeSlow,
+		SlowDownloadRate: l.SlowDownloadRate,
+		SlowUploadRate:   l.SlowUploadRate,
+		SlowTime:         int64(l.SlowTime / time.Second),
+	}
+	return nil
+}
+
+func (h *rpcHandler) setQueueLimits(args *rpctypes.SetQueueLimitsRequest, reply *rpctypes.EmptyResponse) error {
+	return h.session.SetQueueLimits(QueueLimits{
+		Downloads:        args.Downloads,
+		Seeds:            args.Seeds,
+		Torrents:         args.Torrents,
+		ExcludeSlow:      args.ExcludeSlow,
+		SlowDownloadRate: args.SlowDownloadRate,
+		SlowUploadRate:   args.SlowUploadRate,
+		SlowTime:         time.Duration(args.SlowTime) * time.Second,
+	})
+}
+
+func (h *rpcHandler) moveTorrentInQueue(args *rpctypes.MoveTorrentInQueueRequest, reply *rpctypes.EmptyResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	var m QueueMove
+	if err := m.UnmarshalText([]byte(args.Move)); err != nil {
+		return err
+	}
+	return t.MoveInQueue(m)
+}
+
+func (h *rpcHandler) getAltSpeed(args *rpctypes.GetAltSpeedRequest, reply *rpctypes.GetAltSpeedResponse) error {
+	a := h.session.AltSpeed()
+	reply.Limits = rpctypes.SpeedLimits(a.Limits)
+	reply.Mode = a.Mode.String()
+	reply.Active = h.session.AltSpeedActive()
+	reply.Schedule = make([]rpctypes.AltSpeedWindow, len(a.Schedule))
+	for i, w := range a.Schedule {
+		rw := rpctypes.AltSpeedWindow{
+			Start: w.Start.String(),
+			End:   w.End.String(),
+		}
+		for _, d := range w.Days {
+			rw.Days = append(rw.Days, int(d))
+		}
+		reply.Schedule[i] = rw
+	}
+	return nil
+}
+
+func (h *rpcHandler) setAltSpeed(args *rpctypes.SetAltSpeedRequest, reply *rpctypes.EmptyResponse) error {
+	a := AltSpeed{
+		Limits:   SpeedLimits(args.Limits),
+		Schedule: make([]AltSpeedWindow, len(args.Schedule)),
+	}
+	if args.Mode != "" {
+		if err := a.Mode.UnmarshalText([]byte(args.Mode)); err != nil {
+			return err
+		}
+	}
+	for i, rw := range args.Schedule {
+		var w AltSpeedWindow
+		var err error
+		w.Start, err = ParseTimeOfDay(rw.Start)
+		if err != nil {
+			return err
+		}
+		w.End, err = ParseTimeOfDay(rw.End)
+		if err != nil {
+			return err
+		}
+		for _, d := range rw.Days {
+			w.Days = append(w.Days, time.Weekday(d))
+		}
+		a.Schedule[i] = w
+	}
+	return h.session.SetAltSpeed(a)
+}
+
+func (h *rpcHandler) getTorrentSpeedLimits(args *rpctypes.GetTorrentSpeedLimitsRequest, reply *rpctypes.GetSpeedLimitsResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	reply.SpeedLimits = rpctypes.SpeedLimits(t.SpeedLimits())
+	return nil
+}
+
+func (h *rpcHandler) setTorrentSpeedLimits(args *rpctypes.SetTorrentSpeedLimitsRequest, reply *rpctypes.EmptyResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	return t.SetSpeedLimits(SpeedLimits(args.SpeedLimits))
+}
+
+func (h *rpcHandler) getTorrentStats(args *rpctypes.GetTorrentStatsRequest, reply *rpctypes.GetTorrentStatsResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	reply.Stats = newRPCStats(t.Stats())
+	return nil
+}
+
+func newRPCStats(s Stats) rpctypes.Stats {
+	var r rpctypes.Stats
+	r.Status = s.Status.String()
+	if s.Error != nil {
+		r.Error = s.Error.Error()
+	}
+	if s.StopReason != NotStopped {
+		r.StopReason = s.StopReason.String()
+	}
+	r.Progress = s.Progress
+	r.Pieces.Checked = s.Pieces.Checked
+	r.Pieces.Have = s.Pieces.Have
+	r.Pieces.Missing = s.Pieces.Missing
+	r.Pieces.Total = s.Pieces.Total
+	r.Bytes.Completed = s.Bytes.Completed
+	r.Bytes.Incomplete = s.Bytes.Incomplete
+	r.Bytes.Total = s.Bytes.Total
+	r.Bytes.Allocated = s.Bytes.Allocated
+	r.Bytes.Downloaded = s.Bytes.Downloaded
+	r.Bytes.Uploaded = s.Bytes.Uploaded
+	r.Bytes.Wasted = s.Bytes.Wasted
+	r.Peers.Total = s.Peers.Total
+	r.Peers.BySource = make(map[string]int, len(s.Peers.BySource))
+	for src, n := range s.Peers.BySource {
+		r.Peers.BySource[src.String()] = n
+	}
+	r.Peers.Rejected = make(map[string]int, len(s.Peers.Rejected))
+	for reason, n := range s.Peers.Rejected {
+		r.Peers.Rejected[reason.String()] = n
+	}
+	r.Speed.Download = s.Speed.Download
+	r.Speed.Upload = s.Speed.Upload
+	if s.ETA != nil {
+		eta := int64(*s.ETA / time.Second)
+		r.ETA = &eta
+	}
+	r.Ratio = s.Ratio
+	r.SeededFor = int64(s.SeededFor / time.Second)
+	return r
+}
+
+func (h *rpcHandler) getTorrentTrackers(args *rpctypes.GetTorrentTrackersRequest, reply *rpctypes.GetTorrentTrackersResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	trackers := t.Trackers()
+	reply.Trackers = make([]rpctypes.Tracker, len(trackers))
+	for i, tr := range trackers {
+		reply.Trackers[i] = rpctypes.Tracker{
+			URL:          tr.URL,
+			Status:       tr.Status.String(),
+			Leechers:     tr.Leechers,
+			Seeders:      tr.Seeders,
+			Warning:      tr.Warning,
+			LastAnnounce: tr.LastAnnounce,
+			NextAnnounce: tr.NextAnnounce,
+		}
+		if tr.Error != nil {
+			reply.Trackers[i].Error = tr.Error.Message
+			if tr.Error.Unknown {
+				reply.Trackers[i].Error = tr.Error.Err.Error()
+			}
+		}
+	}
+
+	return nil
+}
+
+func (h *rpcHandler) getTorrentPeers(args *rpctypes.GetTorrentPeersRequest, reply *rpctypes.GetTorrentPeersResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	peers := t.Peers()
+	reply.Peers = make([]rpctypes.Peer, len(peers))
+	for i, p := range peers {
+		reply.Peers[i] = rpctypes.Peer{
+			ID:          hex.EncodeToString(p.ID[:]),
+			Addr:        p.Addr.String(),
+			Source:      p.Source.String(),
+			ConnectedAt: p.ConnectedAt,
+		}
+	}
+
+	return nil
+}
+
+func (h *rpcHandler) getTorrentFiles(args *rpctypes.GetTorrentFilesRequest, reply *rpctypes.GetTorrentFilesResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	files, err := t.Files()
+	if err != nil {
+		return err
+	}
+
+	reply.Files = make([]rpctypes.File, len(files))
+	for i, f := range files {
+		reply.Files[i] = rpctypes.File{
+			Path:           f.Path(),
+			Length:         f.Length(),
+			BytesCompleted: f.BytesCompleted(),
+			Priority:       f.Priority().String(),
+		}
+	}
+
+	return nil
+}
+
+func (h *rpcHandler) setTorrentFilePriorities(args *rpctypes.SetTorrentFilePrioritiesRequest, reply *rpctypes.EmptyResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	l := make([]FilePriority, len(args.Priorities))
+	for i, p := range args.Priorities {
+		err = l[i].UnmarshalText([]byte(p))
+		if err != nil {
+			return err
+		}
+	}
+	return t.SetFilePriorities(l)
+}
+
+func (h *rpcHandler) setTorrentSequential(args *rpctypes.SetTorrentSequentialRequest, reply *rpctypes.EmptyResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	return t.SetSequential(args.Sequential)
+}
+
+func (h *rpcHandler) setTorrentPieceDeadline(args *rpctypes.SetTorrentPieceDeadlineRequest, reply *rpctypes.EmptyResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	return t.SetPieceDeadline(args.Piece, args.Deadline)
+}
+
+func (h *rpcHandler) getTorrentPieces(args *rpctypes.GetTorrentPiecesRequest, reply *rpctypes.GetTorrentPiecesResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	reply.Bitfield = t.Bitfield()
+	reply.Count = t.Stats().Pieces.Total
+	return nil
+}
+
+func (h *rpcHandler) getTorrentIPFilter(args *rpctypes.GetTorrentIPFilterRequest, reply *rpctypes.GetTorrentIPFilterResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	f := t.IPFilter()
+	reply.Allow = f.Allow
+	reply.Deny = f.Deny
+	return nil
+}
+
+func (h *rpcHandler) setTorrentIPFilter(args *rpctypes.SetTorrentIPFilterRequest, reply *rpctypes.EmptyResponse) error {
+	t, err := h.getTorrent(args.ID)
+	if err != nil {
+		return err
+	}
+
+	return t.SetIPFilter(IPFilter{Allow: args.Allow, Deny: args.Deny})
+}
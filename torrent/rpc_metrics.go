@@ -0,0 +1,145 @@
+package torrent
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/al002/zbittorrent/internal/metrics"
+	"github.com/al002/zbittorrent/internal/peer"
+)
+
+const metricsPrefix = "zbittorrent_"
+
+// metricsHandler serves the metrics of the Session in Prometheus text exposition format.
+type metricsHandler struct {
+	session *Session
+}
+
+type torrentMetrics struct {
+	id    string
+	name  string
+	stats Stats
+}
+
+func (h *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s := h.session
+	m := s.metrics
+
+	list := s.ListTorrents()
+	torrents := make([]torrentMetrics, len(list))
+	for i, t := range list {
+		torrents[i] = torrentMetrics{id: t.ID(), name: t.Name(), stats: t.Stats()}
+	}
+	sessionStats := s.Stats()
+	udpStats := s.trackerManager.UDPStats()
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	e := metrics.NewEncoder(w)
+
+	e.Header(metricsPrefix+"uptime_seconds", "Time elapsed since the session is started.", metrics.TypeGauge)
+	e.Sample(metricsPrefix+"uptime_seconds", sessionStats.Uptime.Seconds())
+
+	e.Header(metricsPrefix+"torrents", "Number of torrents by status.", metrics.TypeGauge)
+	for status := range Status(len(statusNames)) {
+		e.Sample(metricsPrefix+"torrents", float64(sessionStats.TorrentsByStatus[status]), metrics.Label{Name: "status", Value: status.String()})
+	}
+
+	e.Header(metricsPrefix+"downloaded_bytes_total", "Piece data downloaded from peers by all torrents.", metrics.TypeCounter)
+	e.Sample(metricsPrefix+"downloaded_bytes_total", float64(sessionStats.BytesDownloaded))
+	e.Header(metricsPrefix+"uploaded_bytes_total", "Piece data uploaded to peers by all torrents.", metrics.TypeCounter)
+	e.Sample(metricsPrefix+"uploaded_bytes_total", float64(sessionStats.BytesUploaded))
+	e.Header(metricsPrefix+"wasted_bytes_total", "Downloaded bytes that are discarded by all torrents.", metrics.TypeCounter)
+	e.Sample(metricsPrefix+"wasted_bytes_total", float64(sessionStats.BytesWasted))
+
+	e.Header(metricsPrefix+"download_speed_bytes", "Download speed of all torrents in bytes per second.", metrics.TypeGauge)
+	e.Sample(metricsPrefix+"download_speed_bytes", float64(sessionStats.SpeedDownload))
+	e.Header(metricsPrefix+"upload_speed_bytes", "Upload speed of all torrents in bytes per second.", metrics.TypeGauge)
+	e.Sample(metricsPrefix+"upload_speed_bytes", float64(sessionStats.SpeedUpload))
+
+	peersBySource := make(map[peer.Source]int)
+	for _, t := range torrents {
+		for src, n := range t.stats.Peers.BySource {
+			peersBySource[src] += n
+		}
+	}
+	e.Header(metricsPrefix+"peers", "Number of connected peers by the source they are found from.", metrics.TypeGauge)
+	for src := peer.Tracker; src <= peer.Incoming; src++ {
+		e.Sample(metricsPrefix+"peers", float64(peersBySource[src]), metrics.Label{Name: "source", Value: src.String()})
+	}
+
+	h.writeTorrentMetrics(e, torrents)
+
+	e.Header(metricsPrefix+"tracker_announces_total", "Announces to trackers by host and result.", metrics.TypeCounter)
+	e.CounterVec(metricsPrefix+"tracker_announces_total", m.announces)
+
+	e.Header(metricsPrefix+"udp_tracker_retries_total", "Packets sent again to UDP trackers because no response is received.", metrics.TypeCounter)
+	e.Sample(metricsPrefix+"udp_tracker_retries_total", float64(udpStats.Retries))
+	e.Header(metricsPrefix+"udp_tracker_timeouts_total", "Requests to UDP trackers that did not get a response before their deadline.", metrics.TypeCounter)
+	e.Sample(metricsPrefix+"udp_tracker_timeouts_total", float64(udpStats.Timeouts))
+
+	e.Header(metricsPrefix+"blocklist_rules", "Number of rules in the blocklist.", metrics.TypeGauge)
+	e.Sample(metricsPrefix+"blocklist_rules", float64(sessionStats.BlockListRules))
+	e.Header(metricsPrefix+"blocklist_hits_total", "Addresses that are blocked by the blocklist.", metrics.TypeCounter)
+	e.Sample(metricsPrefix+"blocklist_hits_total", float64(s.blocklist.Hits()))
+
+	e.Header(metricsPrefix+"hash_check_failures_total", "Downloaded pieces that failed the hash check.", metrics.TypeCounter)
+	e.Sample(metricsPrefix+"hash_check_failures_total", float64(m.hashFailures.Value()))
+
+	e.Header(metricsPrefix+"disk_operation_duration_seconds", "Latency of reads and writes to the files of torrents.", metrics.TypeHistogram)
+	e.HistogramVec(metricsPrefix+"disk_operation_duration_seconds", m.diskLatency)
+
+	e.Header(metricsPrefix+"resume_write_duration_seconds", "Duration of writes to the resume database.", metrics.TypeHistogram)
+	e.HistogramVec(metricsPrefix+"resume_write_duration_seconds", m.resumeWrites)
+
+	err := e.Flush()
+	if err != nil {
+		s.log.Debug("cannot write metrics", "err", err.Error())
+	}
+}
+
+func (h *metricsHandler) writeTorrentMetrics(e *metrics.Encoder, torrents []torrentMetrics) {
+	labels := func(t *torrentMetrics) []metrics.Label {
+		return []metrics.Label{{Name: "torrent", Value: t.id}, {Name: "name", Value: t.name}}
+	}
+
+	families := []struct {
+		name  string
+		help  string
+		typ   metrics.Type
+		value func(s *Stats) int64
+	}{
+		{"torrent_downloaded_bytes_total", "Piece data downloaded from peers.", metrics.TypeCounter, func(s *Stats) int64 { return s.Bytes.Downloaded }},
+		{"torrent_uploaded_bytes_total", "Piece data uploaded to peers.", metrics.TypeCounter, func(s *Stats) int64 { return s.Bytes.Uploaded }},
+		{"torrent_wasted_bytes_total", "Downloaded bytes that are discarded.", metrics.TypeCounter, func(s *Stats) int64 { return s.Bytes.Wasted }},
+		{"torrent_completed_bytes", "Bytes of the pieces that are downloaded and verified.", metrics.TypeGauge, func(s *Stats) int64 { return s.Bytes.Completed }},
+		{"torrent_size_bytes", "Total size of the files in the torrent.", metrics.TypeGauge, func(s *Stats) int64 { return s.Bytes.Total }},
+		{"torrent_download_speed_bytes", "Download speed in bytes per second.", metrics.TypeGauge, func(s *Stats) int64 { return int64(s.Speed.Download) }},
+		{"torrent_upload_speed_bytes", "Upload speed in bytes per second.", metrics.TypeGauge, func(s *Stats) int64 { return int64(s.Speed.Upload) }},
+	}
+
+	for _, f := range families {
+		e.Header(metricsPrefix+f.name, f.help, f.typ)
+		for i := range torrents {
+			e.Sample(metricsPrefix+f.name, float64(f.value(&torrents[i].stats)), labels(&torrents[i])...)
+		}
+	}
+
+	e.Header(metricsPrefix+"torrent_peers", "Number of connected peers by the source they are found from.", metrics.TypeGauge)
+	for i := range torrents {
+		t := &torrents[i]
+		for _, src := range sortedSources(t.stats.Peers.BySource) {
+			l := append(labels(t), metrics.Label{Name: "source", Value: src.String()})
+			e.Sample(metricsPrefix+"torrent_peers", float64(t.stats.Peers.BySource[src]), l...)
+		}
+	}
+}
+
+func sortedSources(m map[peer.Source]int) []peer.Source {
+	sources := make([]peer.Source, 0, len(m))
+	for src := range m {
+		sources = append(sources, src)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i] < sources[j] })
+
+	return sources
+}
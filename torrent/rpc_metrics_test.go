@@ -0,0 +1,72 @@
+package torrent
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getMetrics(t *testing.T, s *Session) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	(&metricsHandler{session: s}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+func TestMetricsTorrentsByStatus(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.QueueMaxActiveDownloads = 1
+	s := newTestSession(t, cfg)
+	defer s.Close()
+
+	for _, name := range []string{"first", "second"} {
+		require.NoError(t, addTestTorrent(t, s, testTorrent{Name: name}).Start())
+	}
+	m := getMetrics(t, s)
+	for _, status := range statusNames {
+		assert.Contains(t, m, `zbittorrent_torrents{status="`+status+`"}`, status)
+	}
+	assert.Contains(t, m, `zbittorrent_torrents{status="queued"} 1`+"\n")
+}
+
+// Pieces that are missing when existing files are verified are not hash failures of downloads.
+func TestMetricsHashFailures(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+
+	tor := addTestTorrent(t, s, testTorrent{Complete: true})
+	f, err := os.OpenFile(filepath.Join(s.config.DataDir, "test"), os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteAt(bytes.Repeat([]byte{'b'}, 10), testPieceLength)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	require.NoError(t, tor.Start())
+	waitStatus(t, tor, Downloading)
+	assert.Equal(t, uint32(3), tor.Stats().Pieces.Have)
+	assert.Equal(t, uint64(0), s.metrics.hashFailures.Value())
+	assert.Contains(t, getMetrics(t, s), "zbittorrent_hash_check_failures_total 0\n")
+}
+
+func TestMetricsSessionWrites(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+
+	require.NoError(t, s.SetSpeedLimits(SpeedLimits{Download: 10}))
+	require.NoError(t, s.SetSeedLimits(SeedLimits{Ratio: 2}))
+	require.NoError(t, s.SetAltSpeed(AltSpeed{Limits: SpeedLimits{Download: 5}}))
+	require.NoError(t, s.SetQueueLimits(QueueLimits{Downloads: 1}))
+	require.NoError(t, s.saveBlocklist([]byte("rules"), s.now()))
+
+	m := getMetrics(t, s)
+	for _, op := range []string{"session_speed_limits", "session_seed_limits", "alt_speed", "queue_limits", "blocklist"} {
+		assert.Contains(t, m, `zbittorrent_resume_write_duration_seconds_count{op="`+op+`"} 1`+"\n", op)
+	}
+}
@@ -0,0 +1,201 @@
+package torrent
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/jsonrpc"
+	"github.com/al002/zbittorrent/internal/log"
+	"github.com/al002/zbittorrent/internal/webui"
+)
+
+// rpcServer serves the JSON-RPC API of the Session over HTTP.
+type rpcServer struct {
+	session    *Session
+	mux        *http.ServeMux
+	httpServer *http.Server
+	closeC     chan struct{}
+	log        log.Logger
+}
+
+func newRPCServer(s *Session) *rpcServer {
+	srv := jsonrpc.NewServer()
+	// Without a token any web page could make requests, clients must do the session id handshake instead
+	if s.config.RPCToken == "" {
+		srv.RequireSessionID()
+	}
+	h := &rpcHandler{session: s}
+	h.register(srv)
+
+	closeC := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.Handle("/rpc", srv)
+	mux.Handle("/events", &eventStream{session: s, closeC: closeC})
+	if s.config.RPCTransmissionEnabled {
+		mux.Handle("/transmission/rpc", newTransmissionHandler(s).server)
+	}
+	if s.config.RPCMetricsEnabled {
+		mux.Handle("/metrics", &metricsHandler{session: s})
+	}
+	if s.config.RPCWebUIEnabled {
+		mux.Handle("/", webui.Handler())
+	}
+
+	r := &rpcServer{
+		session: s,
+		mux:     mux,
+		closeC:  closeC,
+		log:     s.log,
+	}
+	r.httpServer = &http.Server{
+		Handler:           r.checkHost(r.authenticate(mux)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return r
+}
+
+// Start listens on the TCP address and, if unixSocket is not empty, on the Unix socket.
+func (s *rpcServer) Start(host string, port int, unixSocket string) error {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	tcpListener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	listeners := []net.Listener{tcpListener}
+	if unixSocket != "" {
+		unixListener, err := listenUnix(unixSocket)
+		if err != nil {
+			tcpListener.Close()
+			return err
+		}
+		listeners = append(listeners, unixListener)
+	}
+
+	for _, l := range listeners {
+		s.log.Info("RPC server is listening on "+l.Addr().Network()+"://"+l.Addr().String(), "addr", l.Addr().String())
+		go func(l net.Listener) {
+			err := s.httpServer.Serve(l)
+			if !errors.Is(err, http.ErrServerClosed) {
+				s.log.Error("RPC server error", "addr", l.Addr().String(), "err", err.Error())
+			}
+		}(l)
+	}
+
+	return nil
+}
+
+// Stop shuts down the server gracefully. Ongoing requests are given timeout duration to finish.
+func (s *rpcServer) Stop(timeout time.Duration) {
+	// End the event streams, they would block the shutdown otherwise
+	close(s.closeC)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		s.log.Error("RPC server shutdown error", "err", err.Error())
+	}
+}
+
+func listenUnix(path string) (net.Listener, error) {
+	// Remove the socket left from a previous run
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the owner of the daemon can connect
+	err = os.Chmod(path, 0o600)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// authenticate requires the RPC token in the Authorization header if it is set in config.
+// The token can also be given as the password of HTTP basic authentication for the clients that do not support bearer tokens.
+func (s *rpcServer) authenticate(h http.Handler) http.Handler {
+	token := s.session.config.RPCToken
+	if token == "" {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			_, given, ok = r.BasicAuth()
+		}
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Add("WWW-Authenticate", `Bearer realm="zbittorrent"`)
+			w.Header().Add("WWW-Authenticate", `Basic realm="zbittorrent"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// checkHost rejects the requests whose Host header is not localhost, an IP address or in RPCHostWhitelist.
+// A web page can point its own domain to the address of the server with DNS rebinding,
+// then it is not a cross-site page anymore and can read the session id.
+// Requests are allowed from any host if the token is set because such a page cannot know the token.
+func (s *rpcServer) checkHost(h http.Handler) http.Handler {
+	if s.session.config.RPCToken != "" {
+		return h
+	}
+
+	whitelist := s.session.config.RPCHostWhitelist
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only the owner of the daemon can connect to the Unix socket
+		if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok && addr.Network() == "unix" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if !isAllowedHost(r.Host, whitelist) {
+			http.Error(w, "host is not allowed, add it to rpc_host_whitelist", http.StatusMisdirectedRequest)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+func isAllowedHost(hostport string, whitelist []string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	host = strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || net.ParseIP(host) != nil {
+		return true
+	}
+	for _, pattern := range whitelist {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return true
+		}
+	}
+
+	return false
+}
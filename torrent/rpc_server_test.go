@@ -0,0 +1,125 @@
+package torrent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/al002/zbittorrent/internal/jsonrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRPCBody = `{"jsonrpc": "2.0", "method": "Session.GetSessionStats", "id": 1}`
+
+func postRPC(h http.Handler, body string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+	r.Host = "127.0.0.1:7246"
+	r.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRPCServerToken(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.RPCToken = "secret"
+	s := newTestSession(t, cfg)
+	defer s.Close()
+	h := newRPCServer(s).httpServer.Handler
+
+	w := postRPC(h, testRPCBody, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Len(t, w.Header().Values("WWW-Authenticate"), 2)
+
+	w = postRPC(h, testRPCBody, http.Header{"Authorization": {"Bearer wrong"}})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = postRPC(h, testRPCBody, http.Header{"Authorization": {"Bearer secret"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"result"`)
+
+	r := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(testRPCBody))
+	r.Header.Set("Content-Type", "application/json")
+	r.SetBasicAuth("anyone", "secret")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The token is enough, no session id is required
+	assert.Empty(t, w.Header().Get(jsonrpc.SessionIDHeader))
+}
+
+func TestRPCServerHost(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.RPCHostWhitelist = []string{"box.lan", "*.example.com"}
+	s := newTestSession(t, cfg)
+	defer s.Close()
+	h := newRPCServer(s).httpServer.Handler
+
+	for host, allowed := range map[string]bool{
+		"localhost:7246":       true,
+		"LOCALHOST":            true,
+		"127.0.0.1":            true,
+		"[::1]:7246":           true,
+		"192.168.1.2:7246":     true,
+		"box.lan:7246":         true,
+		"nas.example.com:7246": true,
+		"example.com:7246":     false,
+		"attacker.test:7246":   false,
+		"localhost.test:7246":  false,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(testRPCBody))
+		r.Host = host
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if allowed {
+			// The session id handshake comes after the host check
+			assert.Equal(t, http.StatusConflict, w.Code, host)
+		} else {
+			assert.Equal(t, http.StatusMisdirectedRequest, w.Code, host)
+			assert.Empty(t, w.Header().Get(jsonrpc.SessionIDHeader), host)
+		}
+	}
+
+	// Web pages of any host cannot guess the token
+	cfg = testConfig(t)
+	cfg.RPCToken = "secret"
+	s2 := newTestSession(t, cfg)
+	defer s2.Close()
+	r := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(testRPCBody))
+	r.Host = "attacker.test"
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	newRPCServer(s2).httpServer.Handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRPCServerSessionID(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+	h := newRPCServer(s).httpServer.Handler
+
+	// A request that a web page of another site could make is rejected
+	r := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(testRPCBody))
+	r.Host = "127.0.0.1:7246"
+	r.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	w = postRPC(h, testRPCBody, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	id := w.Header().Get(jsonrpc.SessionIDHeader)
+	require.NotEmpty(t, id)
+
+	w = postRPC(h, testRPCBody, http.Header{jsonrpc.SessionIDHeader: {id}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"result"`)
+}
@@ -0,0 +1,671 @@
+package torrent
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/transmission"
+	"github.com/al002/zbittorrent/pkg/magnet"
+	"github.com/al002/zbittorrent/pkg/metainfo"
+)
+
+// Versions of the Transmission RPC protocol that are implemented.
+const (
+	transmissionRPCVersion        = 17
+	transmissionRPCVersionMinimum = 14
+)
+
+// Transmission reports the torrents removed in this duration for "recently-active" requests.
+const transmissionRemovedKeep = time.Minute
+
+// Speed limit that is reported when the limit is disabled and no value is set before.
+const transmissionDefaultSpeedLimit = 100
+
+var errDeleteLocalData = errors.New("deleting local data is not supported")
+
+// transmissionHandler implements the methods of Transmission RPC protocol on top of Session.
+type transmissionHandler struct {
+	session *Session
+	server  *transmission.Server
+
+	// Transmission identifies torrents with integers. They are assigned in the order torrents are seen.
+	mIDs    sync.Mutex
+	ids     map[string]int64
+	nextID  int64
+	removed map[int64]time.Time
+
+	// Transmission keeps the value of a speed limit when it is disabled.
+	mSpeedLimits   sync.Mutex
+	speedLimitDown int64
+	speedLimitUp   int64
+}
+
+func newTransmissionHandler(s *Session) *transmissionHandler {
+	h := &transmissionHandler{
+		session:        s,
+		server:         transmission.NewServer(),
+		ids:            make(map[string]int64),
+		nextID:         1,
+		removed:        make(map[int64]time.Time),
+		speedLimitDown: transmissionDefaultSpeedLimit,
+		speedLimitUp:   transmissionDefaultSpeedLimit,
+	}
+
+	limits := s.SpeedLimits()
+	if limits.Download > 0 {
+		h.speedLimitDown = limits.Download
+	}
+	if limits.Upload > 0 {
+		h.speedLimitUp = limits.Upload
+	}
+
+	transmission.Register(h.server, "torrent-add", h.torrentAdd)
+	transmission.Register(h.server, "torrent-get", h.torrentGet)
+	transmission.Register(h.server, "torrent-start", h.torrentStart)
+	transmission.Register(h.server, "torrent-start-now", h.torrentStart)
+	transmission.Register(h.server, "torrent-stop", h.torrentStop)
+	transmission.Register(h.server, "torrent-verify", h.torrentVerify)
+	transmission.Register(h.server, "torrent-remove", h.torrentRemove)
+	transmission.Register(h.server, "queue-move-top", h.queueMove(QueueTop))
+	transmission.Register(h.server, "queue-move-up", h.queueMove(QueueUp))
+	transmission.Register(h.server, "queue-move-down", h.queueMove(QueueDown))
+	transmission.Register(h.server, "queue-move-bottom", h.queueMove(QueueBottom))
+	transmission.Register(h.server, "session-get", h.sessionGet)
+	transmission.Register(h.server, "session-set", h.sessionSet)
+	transmission.Register(h.server, "session-stats", h.sessionStats)
+
+	return h
+}
+
+type transmissionTorrent struct {
+	id int64
+	t  *Torrent
+}
+
+// torrents returns the torrents selected by ids. All torrents are returned if ids is nil.
+func (h *transmissionHandler) torrents(ids *transmission.IDs) []transmissionTorrent {
+	list := h.session.ListTorrents()
+
+	h.mIDs.Lock()
+	defer h.mIDs.Unlock()
+
+	now := time.Now()
+	seen := make(map[string]struct{}, len(list))
+	torrents := make([]transmissionTorrent, 0, len(list))
+	for _, t := range list {
+		seen[t.ID()] = struct{}{}
+		id, ok := h.ids[t.ID()]
+		if !ok {
+			id = h.nextID
+			h.nextID++
+			h.ids[t.ID()] = id
+		}
+
+		if ids == nil || ids.RecentlyActive || ids.Match(id, t.InfoHash()) {
+			torrents = append(torrents, transmissionTorrent{id: id, t: t})
+		}
+	}
+
+	for tid, id := range h.ids {
+		if _, ok := seen[tid]; !ok {
+			delete(h.ids, tid)
+			h.removed[id] = now
+		}
+	}
+	for id, removedAt := range h.removed {
+		if now.Sub(removedAt) > transmissionRemovedKeep {
+			delete(h.removed, id)
+		}
+	}
+
+	return torrents
+}
+
+func (h *transmissionHandler) recentlyRemoved() []int64 {
+	h.mIDs.Lock()
+	defer h.mIDs.Unlock()
+
+	removed := make([]int64, 0, len(h.removed))
+	for id := range h.removed {
+		removed = append(removed, id)
+	}
+	slices.Sort(removed)
+
+	return removed
+}
+
+func (h *transmissionHandler) torrentAdd(args *transmission.TorrentAddRequest, reply *transmission.TorrentAddResponse) error {
+	opts := &AddTorrentOptions{Stopped: true}
+
+	// Content of the torrent file, nil for magnet links
+	var data []byte
+	var infoHash []byte
+	var err error
+	switch {
+	case args.Metainfo != "":
+		data, err = base64.StdEncoding.DecodeString(args.Metainfo)
+	case strings.HasPrefix(args.Filename, "magnet:"):
+		var ma *magnet.Magnet
+		ma, err = magnet.New(args.Filename)
+		if err == nil {
+			hash := ma.Hash()
+			infoHash = hash[:]
+		}
+	case isTransmissionURI(args.Filename):
+		var body io.ReadCloser
+		body, err = h.session.downloadTorrent(args.Filename)
+		if err == nil {
+			data, err = io.ReadAll(io.LimitReader(body, int64(h.session.config.MaxTorrentSize)))
+			body.Close()
+		}
+	case args.Filename != "":
+		// Without a token anyone who can reach the server could read the files of the daemon
+		if h.session.config.RPCToken == "" {
+			return errors.New("local file paths are accepted only if rpc_token is set")
+		}
+		var f *os.File
+		f, err = os.Open(args.Filename)
+		if err == nil {
+			data, err = io.ReadAll(io.LimitReader(f, int64(h.session.config.MaxTorrentSize)))
+			f.Close()
+		}
+	default:
+		return errors.New("no filename or metainfo specified")
+	}
+	if err != nil {
+		return err
+	}
+
+	if data != nil {
+		var mi *metainfo.MetaInfo
+		mi, err = metainfo.New(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		infoHash = mi.Info.Hash[:]
+	}
+
+	// Transmission does not add a torrent twice, it returns the existing one instead
+	for _, other := range h.session.ListTorrents() {
+		if bytes.Equal(other.InfoHash(), infoHash) {
+			reply.TorrentDuplicate = h.addedTorrent(other)
+			return nil
+		}
+	}
+
+	var t *Torrent
+	if data != nil {
+		t, err = h.session.AddTorrent(bytes.NewReader(data), opts)
+	} else {
+		t, err = h.session.AddURI(args.Filename, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !args.Paused {
+		err = t.Start()
+		if err != nil {
+			return err
+		}
+	}
+
+	reply.TorrentAdded = h.addedTorrent(t)
+	return nil
+}
+
+func isTransmissionURI(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "http", "https", "magnet":
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *transmissionHandler) addedTorrent(t *Torrent) *transmission.AddedTorrent {
+	ids := &transmission.IDs{Hashes: []string{hex.EncodeToString(t.InfoHash())}}
+	for _, tt := range h.torrents(ids) {
+		if tt.t.ID() == t.ID() {
+			return &transmission.AddedTorrent{
+				ID:         tt.id,
+				Name:       t.Name(),
+				HashString: hex.EncodeToString(t.InfoHash()),
+			}
+		}
+	}
+
+	return nil
+}
+
+func (h *transmissionHandler) torrentGet(args *transmission.TorrentGetRequest, reply *transmission.TorrentGetResponse) error {
+	torrents := h.torrents(args.IDs)
+
+	reply.Torrents = make([]map[string]any, 0, len(torrents))
+	for _, tt := range torrents {
+		f := &transmissionFields{h: h, tt: tt}
+		m := make(map[string]any, len(args.Fields))
+		for _, name := range args.Fields {
+			// Unknown fields are ignored as Transmission does
+			if fn, ok := transmissionTorrentFields[name]; ok {
+				m[name] = fn(f)
+			}
+		}
+		reply.Torrents = append(reply.Torrents, m)
+	}
+
+	if args.IDs != nil && args.IDs.RecentlyActive {
+		reply.Removed = h.recentlyRemoved()
+	}
+
+	return nil
+}
+
+func (h *transmissionHandler) torrentStart(args *transmission.TorrentActionRequest, reply *transmission.EmptyResponse) error {
+	for _, tt := range h.torrents(args.IDs) {
+		if err := tt.t.Start(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *transmissionHandler) torrentStop(args *transmission.TorrentActionRequest, reply *transmission.EmptyResponse) error {
+	for _, tt := range h.torrents(args.IDs) {
+		if err := tt.t.Stop(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *transmissionHandler) queueMove(m QueueMove) func(*transmission.TorrentActionRequest, *transmission.EmptyResponse) error {
+	return func(args *transmission.TorrentActionRequest, reply *transmission.EmptyResponse) error {
+		torrents := h.torrents(args.IDs)
+		positions := make(map[*Torrent]int, len(torrents))
+		for _, tt := range torrents {
+			positions[tt.t] = tt.t.QueuePosition()
+		}
+
+		// Move the torrents in the order that keeps their relative positions
+		slices.SortFunc(torrents, func(a, b transmissionTorrent) int {
+			if m == QueueDown || m == QueueTop {
+				return positions[b.t] - positions[a.t]
+			}
+			return positions[a.t] - positions[b.t]
+		})
+		for _, tt := range torrents {
+			if err := tt.t.MoveInQueue(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (h *transmissionHandler) torrentVerify(args *transmission.TorrentActionRequest, reply *transmission.EmptyResponse) error {
+	for _, tt := range h.torrents(args.IDs) {
+		if err := tt.t.Verify(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *transmissionHandler) torrentRemove(args *transmission.TorrentRemoveRequest, reply *transmission.EmptyResponse) error {
+	if args.DeleteLocalData {
+		return errDeleteLocalData
+	}
+
+	for _, tt := range h.torrents(args.IDs) {
+		err := h.session.RemoveTorrent(tt.t.ID())
+		if err != nil && err != ErrTorrentNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *transmissionHandler) sessionGet(args *transmission.SessionGetRequest, reply *transmission.SessionGetResponse) error {
+	limits := h.session.SpeedLimits()
+
+	h.mSpeedLimits.Lock()
+	reply.SpeedLimitDown = h.speedLimitDown
+	reply.SpeedLimitUp = h.speedLimitUp
+	h.mSpeedLimits.Unlock()
+
+	reply.Version = "zbittorrent " + Version
+	reply.RPCVersion = transmissionRPCVersion
+	reply.RPCVersionMinimum = transmissionRPCVersionMinimum
+	reply.SessionID = h.server.SessionID()
+	reply.DownloadDir = h.session.config.DataDir
+	reply.PeerPort = int(h.session.config.PortBegin)
+	reply.PEXEnabled = h.session.config.PEXEnabled
+	reply.SpeedLimitDownEnabled = limits.Download > 0
+	reply.SpeedLimitUpEnabled = limits.Upload > 0
+	reply.Units = transmission.Units{
+		SpeedUnits:  []string{"kB/s", "MB/s", "GB/s", "TB/s"},
+		SpeedBytes:  1024,
+		SizeUnits:   []string{"kB", "MB", "GB", "TB"},
+		SizeBytes:   1024,
+		MemoryUnits: []string{"KiB", "MiB", "GiB", "TiB"},
+		MemoryBytes: 1024,
+	}
+
+	return nil
+}
+
+func (h *transmissionHandler) sessionSet(args *transmission.SessionSetRequest, reply *transmission.EmptyResponse) error {
+	limits := h.session.SpeedLimits()
+	down, up := limits.Download, limits.Upload
+
+	h.mSpeedLimits.Lock()
+	defer h.mSpeedLimits.Unlock()
+
+	if args.SpeedLimitDown != nil {
+		h.speedLimitDown = *args.SpeedLimitDown
+		if down > 0 {
+			down = h.speedLimitDown
+		}
+	}
+	if args.SpeedLimitDownEnabled != nil {
+		down = 0
+		if *args.SpeedLimitDownEnabled {
+			down = h.speedLimitDown
+		}
+	}
+	if args.SpeedLimitUp != nil {
+		h.speedLimitUp = *args.SpeedLimitUp
+		if up > 0 {
+			up = h.speedLimitUp
+		}
+	}
+	if args.SpeedLimitUpEnabled != nil {
+		up = 0
+		if *args.SpeedLimitUpEnabled {
+			up = h.speedLimitUp
+		}
+	}
+
+	limits.Download, limits.Upload = down, up
+	return h.session.SetSpeedLimits(limits)
+}
+
+func (h *transmissionHandler) sessionStats(args *transmission.SessionStatsRequest, reply *transmission.SessionStatsResponse) error {
+	s := h.session.Stats()
+
+	reply.TorrentCount = s.Torrents
+	reply.PausedTorrentCount = s.TorrentsByStatus[Stopped] + s.TorrentsByStatus[Error]
+	reply.ActiveTorrentCount = reply.TorrentCount - reply.PausedTorrentCount
+	reply.DownloadSpeed = s.SpeedDownload
+	reply.UploadSpeed = s.SpeedUpload
+	reply.CurrentStats = transmission.Stats{
+		UploadedBytes:   s.BytesUploaded,
+		DownloadedBytes: s.BytesDownloaded,
+		FilesAdded:      s.Torrents,
+		SessionCount:    1,
+		SecondsActive:   int64(s.Uptime / time.Second),
+	}
+	// Counters are not kept across sessions yet
+	reply.CumulativeStats = reply.CurrentStats
+
+	return nil
+}
+
+// transmissionFields computes the values of torrent-get fields. Stats and trackers are requested from the torrent once.
+type transmissionFields struct {
+	h        *transmissionHandler
+	tt       transmissionTorrent
+	stats    *Stats
+	trackers []Tracker
+}
+
+func (f *transmissionFields) getStats() *Stats {
+	if f.stats == nil {
+		s := f.tt.t.Stats()
+		f.stats = &s
+	}
+
+	return f.stats
+}
+
+func (f *transmissionFields) getTrackers() []Tracker {
+	if f.trackers == nil {
+		f.trackers = f.tt.t.Trackers()
+	}
+
+	return f.trackers
+}
+
+func (f *transmissionFields) getFiles() []File {
+	files, _ := f.tt.t.Files()
+	return files
+}
+
+func (f *transmissionFields) status() int {
+	switch f.getStats().Status {
+	case Allocating, Verifying:
+		return transmission.StatusCheck
+	case Downloading:
+		return transmission.StatusDownload
+	case Seeding:
+		return transmission.StatusSeed
+	case Queued:
+		if f.getStats().Progress == 1 {
+			return transmission.StatusSeedWait
+		}
+		return transmission.StatusDownloadWait
+	default:
+		return transmission.StatusStopped
+	}
+}
+
+func (f *transmissionFields) errorCode() (int, string) {
+	s := f.getStats()
+	if s.Status == Error {
+		return transmission.ErrorLocal, s.Error.Error()
+	}
+
+	for _, tr := range f.getTrackers() {
+		if tr.Error != nil {
+			if tr.Error.Unknown {
+				return transmission.ErrorTrackerError, tr.Error.Err.Error()
+			}
+			return transmission.ErrorTrackerError, tr.Error.Message
+		}
+	}
+
+	for _, tr := range f.getTrackers() {
+		if tr.Warning != "" {
+			return transmission.ErrorTrackerWarning, tr.Warning
+		}
+	}
+
+	return transmission.ErrorNone, ""
+}
+
+func (f *transmissionFields) magnetLink() string {
+	var m magnet.Magnet
+	copy(m.InfoHash[:], f.tt.t.InfoHash())
+	m.Name = f.tt.t.Name()
+	for _, tr := range f.getTrackers() {
+		m.Trackers = append(m.Trackers, []string{tr.URL})
+	}
+
+	return m.String()
+}
+
+// filePriority returns the wanted flag and priority of the file in the form of Transmission.
+func filePriority(file File) (wanted bool, priority int) {
+	if file.Priority() == PrioritySkip {
+		return false, int(PriorityNormal)
+	}
+	return true, int(file.Priority())
+}
+
+var transmissionTorrentFields = map[string]func(f *transmissionFields) any{
+	"id":         func(f *transmissionFields) any { return f.tt.id },
+	"name":       func(f *transmissionFields) any { return f.tt.t.Name() },
+	"hashString": func(f *transmissionFields) any { return hex.EncodeToString(f.tt.t.InfoHash()) },
+	"addedDate":  func(f *transmissionFields) any { return f.tt.t.AddedAt().Unix() },
+	"status":     func(f *transmissionFields) any { return f.status() },
+	"error": func(f *transmissionFields) any {
+		code, _ := f.errorCode()
+		return code
+	},
+	"errorString": func(f *transmissionFields) any {
+		_, s := f.errorCode()
+		return s
+	},
+	"percentDone":             func(f *transmissionFields) any { return f.getStats().Progress },
+	"totalSize":               func(f *transmissionFields) any { return f.getStats().Bytes.Total },
+	"sizeWhenDone":            func(f *transmissionFields) any { return f.getStats().Bytes.Total },
+	"leftUntilDone":           func(f *transmissionFields) any { return f.getStats().Bytes.Incomplete },
+	"haveValid":               func(f *transmissionFields) any { return f.getStats().Bytes.Completed },
+	"haveUnchecked":           func(f *transmissionFields) any { return 0 },
+	"downloadedEver":          func(f *transmissionFields) any { return f.getStats().Bytes.Downloaded },
+	"uploadedEver":            func(f *transmissionFields) any { return f.getStats().Bytes.Uploaded },
+	"corruptEver":             func(f *transmissionFields) any { return f.getStats().Bytes.Wasted },
+	"rateDownload":            func(f *transmissionFields) any { return f.getStats().Speed.Download },
+	"rateUpload":              func(f *transmissionFields) any { return f.getStats().Speed.Upload },
+	"peersConnected":          func(f *transmissionFields) any { return f.getStats().Peers.Total },
+	"secondsSeeding":          func(f *transmissionFields) any { return int64(f.getStats().SeededFor / time.Second) },
+	"pieceCount":              func(f *transmissionFields) any { return f.getStats().Pieces.Total },
+	"isFinished":              func(f *transmissionFields) any { return false },
+	"isStalled":               func(f *transmissionFields) any { return false },
+	"queuePosition":           func(f *transmissionFields) any { return f.tt.t.QueuePosition() },
+	"sequentialDownload":      func(f *transmissionFields) any { return f.tt.t.Sequential() },
+	"downloadDir":             func(f *transmissionFields) any { return f.h.session.config.DataDir },
+	"magnetLink":              func(f *transmissionFields) any { return f.magnetLink() },
+	"metadataPercentComplete": func(f *transmissionFields) any { return boolToFloat(f.getFiles() != nil) },
+	"eta": func(f *transmissionFields) any {
+		if eta := f.getStats().ETA; eta != nil {
+			return int64(*eta / time.Second)
+		}
+		return -1
+	},
+	"uploadRatio": func(f *transmissionFields) any {
+		s := f.getStats()
+		if s.Bytes.Downloaded == 0 {
+			return -1
+		}
+		return s.Ratio
+	},
+	"recheckProgress": func(f *transmissionFields) any {
+		s := f.getStats()
+		if s.Status != Verifying || s.Pieces.Total == 0 {
+			return 0
+		}
+		return float64(s.Pieces.Checked) / float64(s.Pieces.Total)
+	},
+	"files": func(f *transmissionFields) any {
+		files := f.getFiles()
+		l := make([]map[string]any, len(files))
+		for i, file := range files {
+			l[i] = map[string]any{
+				"name":           file.Path(),
+				"length":         file.Length(),
+				"bytesCompleted": file.BytesCompleted(),
+			}
+		}
+		return l
+	},
+	"fileStats": func(f *transmissionFields) any {
+		files := f.getFiles()
+		l := make([]map[string]any, len(files))
+		for i, file := range files {
+			wanted, priority := filePriority(file)
+			l[i] = map[string]any{
+				"bytesCompleted": file.BytesCompleted(),
+				"wanted":         wanted,
+				"priority":       priority,
+			}
+		}
+		return l
+	},
+	"trackers": func(f *transmissionFields) any {
+		trackers := f.getTrackers()
+		l := make([]map[string]any, len(trackers))
+		for i, tr := range trackers {
+			l[i] = map[string]any{
+				"id":       i,
+				"announce": tr.URL,
+				"tier":     i,
+			}
+		}
+		return l
+	},
+	"trackerStats": func(f *transmissionFields) any {
+		trackers := f.getTrackers()
+		l := make([]map[string]any, len(trackers))
+		for i, tr := range trackers {
+			var lastResult string
+			switch {
+			case tr.Error != nil && tr.Error.Unknown:
+				lastResult = tr.Error.Err.Error()
+			case tr.Error != nil:
+				lastResult = tr.Error.Message
+			case tr.Status == Working:
+				lastResult = "Success"
+			}
+			l[i] = map[string]any{
+				"id":                    i,
+				"announce":              tr.URL,
+				"tier":                  i,
+				"seederCount":           tr.Seeders,
+				"leecherCount":          tr.Leechers,
+				"lastAnnounceTime":      unixOrZero(tr.LastAnnounce),
+				"nextAnnounceTime":      unixOrZero(tr.NextAnnounce),
+				"lastAnnounceSucceeded": tr.Status == Working,
+				"lastAnnounceResult":    lastResult,
+				"hasAnnounced":          !tr.LastAnnounce.IsZero(),
+			}
+		}
+		return l
+	},
+	"peers": func(f *transmissionFields) any {
+		peers := f.tt.t.Peers()
+		l := make([]map[string]any, len(peers))
+		for i, p := range peers {
+			l[i] = map[string]any{
+				"address": p.Addr.String(),
+				"peerId":  hex.EncodeToString(p.ID[:]),
+			}
+		}
+		return l
+	},
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.Unix()
+}
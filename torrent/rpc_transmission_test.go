@@ -0,0 +1,205 @@
+package torrent
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/al002/zbittorrent/internal/transmission"
+	"github.com/al002/zbittorrent/pkg/magnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// callTransmission makes a request to the handler and decodes the arguments of the response into reply.
+// Returns the result field of the response.
+func callTransmission(t *testing.T, h *transmissionHandler, method string, args any, reply any) string {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{"method": method, "arguments": args})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/transmission/rpc", bytes.NewReader(body))
+	r.Header.Set(transmission.SessionIDHeader, h.server.SessionID())
+	w := httptest.NewRecorder()
+	h.server.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Result    string          `json:"result"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if reply != nil && resp.Result == "success" {
+		require.NoError(t, json.Unmarshal(resp.Arguments, reply))
+	}
+	return resp.Result
+}
+
+func TestTransmissionTorrentAdd(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.RPCToken = "secret"
+	s := newTestSession(t, cfg)
+	defer s.Close()
+	h := newTransmissionHandler(s)
+
+	data := newTestTorrentFile(t, s, testTorrent{})
+	var reply transmission.TorrentAddResponse
+	result := callTransmission(t, h, "torrent-add", transmission.TorrentAddRequest{Metainfo: base64.StdEncoding.EncodeToString(data), Paused: true}, &reply)
+	require.Equal(t, "success", result)
+	require.NotNil(t, reply.TorrentAdded)
+	assert.Nil(t, reply.TorrentDuplicate)
+	assert.Equal(t, int64(1), reply.TorrentAdded.ID)
+	assert.Equal(t, "test", reply.TorrentAdded.Name)
+	added := *reply.TorrentAdded
+
+	tor := s.ListTorrents()[0]
+	assert.Equal(t, Stopped, tor.Stats().Status)
+	assert.Equal(t, hex.EncodeToString(tor.InfoHash()), added.HashString)
+
+	// The same torrent from a file or a magnet link is a duplicate and nothing is added
+	path := filepath.Join(t.TempDir(), "test.torrent")
+	require.NoError(t, os.WriteFile(path, data, 0o640))
+	var m magnet.Magnet
+	copy(m.InfoHash[:], tor.InfoHash())
+	for _, filename := range []string{path, m.String()} {
+		reply = transmission.TorrentAddResponse{}
+		result = callTransmission(t, h, "torrent-add", transmission.TorrentAddRequest{Filename: filename}, &reply)
+		require.Equal(t, "success", result, filename)
+		assert.Nil(t, reply.TorrentAdded, filename)
+		require.NotNil(t, reply.TorrentDuplicate, filename)
+		assert.Equal(t, added, *reply.TorrentDuplicate, filename)
+	}
+	assert.Len(t, s.ListTorrents(), 1)
+	assert.Equal(t, Stopped, tor.Stats().Status)
+
+	// Torrents are started unless paused
+	reply = transmission.TorrentAddResponse{}
+	data = newTestTorrentFile(t, s, testTorrent{Name: "other"})
+	result = callTransmission(t, h, "torrent-add", transmission.TorrentAddRequest{Metainfo: base64.StdEncoding.EncodeToString(data)}, &reply)
+	require.Equal(t, "success", result)
+	require.NotNil(t, reply.TorrentAdded)
+	assert.Equal(t, int64(2), reply.TorrentAdded.ID)
+	assert.Len(t, s.ListTorrents(), 2)
+	assert.NotEqual(t, Stopped, s.GetTorrent(s.ListTorrents()[1].ID()).Stats().Status)
+
+	assert.NotEqual(t, "success", callTransmission(t, h, "torrent-add", transmission.TorrentAddRequest{}, nil))
+	assert.NotEqual(t, "success", callTransmission(t, h, "torrent-add", transmission.TorrentAddRequest{Metainfo: "bm90IGEgdG9ycmVudA=="}, nil))
+	assert.Len(t, s.ListTorrents(), 2)
+}
+
+func TestTransmissionTorrentAddPathWithoutToken(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+	h := newTransmissionHandler(s)
+
+	path := filepath.Join(t.TempDir(), "test.torrent")
+	require.NoError(t, os.WriteFile(path, newTestTorrentFile(t, s, testTorrent{}), 0o640))
+	result := callTransmission(t, h, "torrent-add", transmission.TorrentAddRequest{Filename: path}, nil)
+	assert.Contains(t, result, "rpc_token")
+	assert.Empty(t, s.ListTorrents())
+}
+
+func TestTransmissionHost(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.RPCTransmissionEnabled = true
+	s := newTestSession(t, cfg)
+	defer s.Close()
+	h := newRPCServer(s).httpServer.Handler
+
+	// The session id is not given to pages that use DNS rebinding
+	for host, code := range map[string]int{"127.0.0.1:7246": http.StatusConflict, "attacker.test:7246": http.StatusMisdirectedRequest} {
+		r := httptest.NewRequest(http.MethodPost, "/transmission/rpc", strings.NewReader(`{"method": "session-get"}`))
+		r.Host = host
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, code, w.Code, host)
+		assert.Equal(t, code == http.StatusConflict, w.Header().Get(transmission.SessionIDHeader) != "", host)
+	}
+}
+
+func TestTransmissionTorrentGet(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+	h := newTransmissionHandler(s)
+
+	first := addTestTorrent(t, s, testTorrent{Name: "first"})
+	second := addTestTorrent(t, s, testTorrent{Name: "second"})
+	require.NoError(t, second.SetSequential(true))
+
+	var reply transmission.TorrentGetResponse
+	args := map[string]any{"fields": []string{"id", "name", "sequentialDownload", "noSuchField"}}
+	require.Equal(t, "success", callTransmission(t, h, "torrent-get", args, &reply))
+	require.Len(t, reply.Torrents, 2)
+	// Only the known fields that are requested are returned
+	for _, m := range reply.Torrents {
+		assert.Len(t, m, 3)
+	}
+	ids := map[string]float64{}
+	for _, m := range reply.Torrents {
+		ids[m["name"].(string)] = m["id"].(float64)
+		assert.Equal(t, m["name"] == "second", m["sequentialDownload"])
+	}
+	assert.Len(t, ids, 2)
+
+	// Torrents are selected by their id or info hash
+	reply = transmission.TorrentGetResponse{}
+	args = map[string]any{"ids": []any{ids["first"]}, "fields": []string{"hashString", "totalSize"}}
+	require.Equal(t, "success", callTransmission(t, h, "torrent-get", args, &reply))
+	require.Len(t, reply.Torrents, 1)
+	assert.Equal(t, map[string]any{
+		"hashString": hex.EncodeToString(first.InfoHash()),
+		"totalSize":  float64(4*testPieceLength - 100),
+	}, reply.Torrents[0])
+
+	reply = transmission.TorrentGetResponse{}
+	args = map[string]any{"ids": []any{hex.EncodeToString(second.InfoHash())}, "fields": []string{"id"}}
+	require.Equal(t, "success", callTransmission(t, h, "torrent-get", args, &reply))
+	assert.Equal(t, []map[string]any{{"id": ids["second"]}}, reply.Torrents)
+
+	// Removed torrents are reported with recently-active
+	require.NoError(t, s.RemoveTorrent(first.ID()))
+	reply = transmission.TorrentGetResponse{}
+	args = map[string]any{"ids": "recently-active", "fields": []string{"id"}}
+	require.Equal(t, "success", callTransmission(t, h, "torrent-get", args, &reply))
+	assert.Equal(t, []map[string]any{{"id": ids["second"]}}, reply.Torrents)
+	assert.Equal(t, []int64{int64(ids["first"])}, reply.Removed)
+}
+
+func TestTransmissionSessionSet(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+	h := newTransmissionHandler(s)
+
+	var get transmission.SessionGetResponse
+	require.Equal(t, "success", callTransmission(t, h, "session-get", nil, &get))
+	assert.False(t, get.SpeedLimitDownEnabled)
+	assert.Equal(t, int64(transmissionDefaultSpeedLimit), get.SpeedLimitDown)
+	assert.Equal(t, h.server.SessionID(), get.SessionID)
+
+	// Setting the value of a disabled limit does not enable it
+	require.Equal(t, "success", callTransmission(t, h, "session-set", map[string]any{"speed-limit-down": 50}, nil))
+	assert.Equal(t, int64(0), s.SpeedLimits().Download)
+
+	require.Equal(t, "success", callTransmission(t, h, "session-set", map[string]any{"speed-limit-down-enabled": true, "speed-limit-up": 20, "speed-limit-up-enabled": true}, nil))
+	assert.Equal(t, int64(50), s.SpeedLimits().Download)
+	assert.Equal(t, int64(20), s.SpeedLimits().Upload)
+
+	// The value is kept when the limit is disabled
+	require.Equal(t, "success", callTransmission(t, h, "session-set", map[string]any{"speed-limit-down-enabled": false}, nil))
+	assert.Equal(t, int64(0), s.SpeedLimits().Download)
+	assert.Equal(t, int64(20), s.SpeedLimits().Upload)
+
+	get = transmission.SessionGetResponse{}
+	require.Equal(t, "success", callTransmission(t, h, "session-get", nil, &get))
+	assert.False(t, get.SpeedLimitDownEnabled)
+	assert.Equal(t, int64(50), get.SpeedLimitDown)
+	assert.True(t, get.SpeedLimitUpEnabled)
+	assert.Equal(t, int64(20), get.SpeedLimitUp)
+}
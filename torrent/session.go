@@ -9,13 +9,13 @@ import (
 
 	"github.com/al002/zbittorrent/internal/blocklist"
 	"github.com/al002/zbittorrent/internal/log"
+	"github.com/al002/zbittorrent/internal/ratelimit"
 	"github.com/al002/zbittorrent/internal/resumer/boltdbresumer"
 	"github.com/al002/zbittorrent/internal/storage"
 	"github.com/al002/zbittorrent/internal/trackermanager"
 	"github.com/mitchellh/go-homedir"
 	"go.etcd.io/bbolt"
 	berrors "go.etcd.io/bbolt/errors"
-	"golang.org/x/time/rate"
 )
 
 var (
@@ -31,8 +31,25 @@ type Session struct {
 
 	trackerManager  *trackermanager.TrackerManager
 	log             log.Logger
-	downloadLimiter *rate.Limiter
-	uploadLimiter   *rate.Limiter
+	downloadLimiter *ratelimit.Limiter
+	uploadLimiter   *ratelimit.Limiter
+	mSpeedLimits    sync.RWMutex
+	altSpeed        AltSpeed
+	altSpeedActive  bool
+	// clock of the alternative speed schedule
+	now         func() time.Time
+	mSeedLimits sync.RWMutex
+
+	// IDs of torrents in queue order
+	mQueue       sync.Mutex
+	queue        []string
+	mQueueLimits sync.RWMutex
+	// Serializes the starts and stops of torrents by the queue, held while waiting for the run loops unlike mQueue
+	mQueueUpdate sync.Mutex
+	// Signalled by torrents that complete or stop, so the queue is updated without waiting for the next tick
+	queueUpdateC chan struct{}
+
+	rpc *rpcServer
 
 	mTorrents sync.RWMutex
 	torrents  map[string]*Torrent
@@ -43,11 +60,23 @@ type Session struct {
 	mBlocklist         sync.RWMutex
 	blocklist          *blocklist.Blocklist
 	blocklistTimestamp time.Time
+	mBlocklistReload   sync.Mutex
 
-	closeC chan struct{}
+	events  *eventBus
+	metrics *sessionMetrics
+
+	createdAt time.Time
+	closeC    chan struct{}
+	// goroutines of the Session that must stop before the database is closed
+	backgroundWG sync.WaitGroup
 }
 
 func NewSession(cfg Config, logger log.Logger) (*Session, error) {
+	return newSession(cfg, logger, time.Now)
+}
+
+// newSession creates a Session that reads the current time from now.
+func newSession(cfg Config, logger log.Logger, now func() time.Time) (*Session, error) {
 	if cfg.PortBegin >= cfg.PortEnd {
 		return nil, errors.New("Invalid port range")
 	}
@@ -66,6 +95,11 @@ func NewSession(cfg Config, logger log.Logger) (*Session, error) {
 		return nil, err
 	}
 
+	cfg.RPCUnixSocket, err = homedir.Expand(cfg.RPCUnixSocket)
+	if err != nil {
+		return nil, err
+	}
+
 	err = os.MkdirAll(filepath.Dir(cfg.Database), os.ModeDir|cfg.FilePermissions)
 	if err != nil {
 		return nil, err
@@ -110,6 +144,26 @@ func NewSession(cfg Config, logger log.Logger) (*Session, error) {
 		return nil, err
 	}
 
+	err = loadSpeedLimits(db, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadSeedLimits(db, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	queue, err := loadQueue(db, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	altSpeed, err := loadAltSpeed(db)
+	if err != nil {
+		return nil, err
+	}
+
 	resumer, err := boltdbresumer.New(db, torrentsBucket)
 	if err != nil {
 		return nil, err
@@ -120,6 +174,8 @@ func NewSession(cfg Config, logger log.Logger) (*Session, error) {
 		ports[int(p)] = struct{}{}
 	}
 
+	m := newSessionMetrics()
+
 	bl := blocklist.New()
 	var blTracker *blocklist.Blocklist
 	if cfg.BlocklistEnabledForTrackers {
@@ -131,27 +187,55 @@ func NewSession(cfg Config, logger log.Logger) (*Session, error) {
 		log:            logger,
 		db:             db,
 		resumer:        resumer,
-		storage:        newFileStorageProvider(&cfg),
+		storage:        newFileStorageProvider(&cfg, m.diskLatency),
+		metrics:        m,
 		blocklist:      bl,
 		trackerManager: trackermanager.New(blTracker, cfg.DNSResolveTimeout, !cfg.TrackerHTTPVerifyTLS, logger),
 		torrents:       make(map[string]*Torrent),
 		availablePorts: ports,
+		events:         newEventBus(),
+		createdAt:      time.Now(),
 		closeC:         make(chan struct{}),
+		now:            now,
+		altSpeed:       altSpeed,
+		altSpeedActive: altSpeed.active(now()),
+		queue:          queue,
+		queueUpdateC:   make(chan struct{}, 1),
 	}
 
-	dlSpeed := cfg.SpeedLimitDownload * 1024
-	if cfg.SpeedLimitDownload > 0 {
-		c.downloadLimiter = rate.NewLimiter(rate.Limit(dlSpeed), int(dlSpeed))
-	}
-	ulSpeed := cfg.SpeedLimitUpload * 1024
-	if cfg.SpeedLimitUpload > 0 {
-		c.uploadLimiter = rate.NewLimiter(rate.Limit(ulSpeed), int(ulSpeed))
+	l := c.activeSpeedLimits()
+	c.downloadLimiter = ratelimit.New(nil, l.Download*1024)
+	c.uploadLimiter = ratelimit.New(nil, l.Upload*1024)
+
+	c.loadExistingTorrents(ids)
+
+	c.startBlocklistReloader()
+	c.backgroundWG.Add(2)
+	go c.altSpeedScheduler()
+	go c.queueManager()
+
+	if cfg.RPCEnabled {
+		c.rpc = newRPCServer(c)
+		err = c.rpc.Start(cfg.RPCHost, cfg.RPCPort, cfg.RPCUnixSocket)
+		if err != nil {
+			close(c.closeC)
+			for _, t := range c.torrents {
+				t.torrent.Close()
+			}
+			c.backgroundWG.Wait()
+			c.trackerManager.Close()
+			return nil, err
+		}
 	}
 
 	return c, nil
 }
 
 func (s *Session) Close() {
+	if s.rpc != nil {
+		s.rpc.Stop(s.config.RPCShutdownTimeout)
+	}
+
 	close(s.closeC)
 
 	var wg sync.WaitGroup
@@ -167,7 +251,10 @@ func (s *Session) Close() {
 	s.torrents = nil
 	s.mTorrents.Unlock()
 
+	s.backgroundWG.Wait()
 	s.trackerManager.Close()
+	s.events.close()
+	s.db.Close()
 }
 
 func (s *Session) getTrackerUserAgent(private bool) string {
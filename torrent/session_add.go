@@ -3,18 +3,24 @@ package torrent
 import (
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"time"
 
-	"github.com/al002/zbittorrent/internal/metainfo"
 	"github.com/al002/zbittorrent/internal/resumer/boltdbresumer"
 	"github.com/al002/zbittorrent/internal/storage"
 	"github.com/al002/zbittorrent/internal/tracker"
+	"github.com/al002/zbittorrent/pkg/magnet"
+	"github.com/al002/zbittorrent/pkg/metainfo"
 	"github.com/gofrs/uuid"
 )
 
 type AddTorrentOptions struct {
 	ID string
+	// Do not start torrent automatically after adding.
+	Stopped bool
 
 	StopAfterDownload bool
 	StopAfterMetadata bool
@@ -31,11 +37,157 @@ func (s *Session) AddTorrent(r io.Reader, opts *AddTorrentOptions) (*Torrent, er
 		return nil, err
 	}
 
-	err = t.Start()
+	if !opts.Stopped {
+		err = t.Start()
+	}
+
+	return t, err
+}
+
+// AddURI adds a torrent from a magnet link or from the URL of a torrent file.
+func (s *Session) AddURI(uri string, opts *AddTorrentOptions) (*Torrent, error) {
+	if opts == nil {
+		opts = &AddTorrentOptions{}
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var t *Torrent
+	switch u.Scheme {
+	case "http", "https":
+		t, err = s.addURL(uri, opts)
+	case "magnet":
+		t, err = s.addMagnet(uri, opts)
+	default:
+		return nil, fmt.Errorf("unsupported uri scheme: %s", u.Scheme)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.Stopped {
+		err = t.Start()
+	}
 
 	return t, err
 }
 
+func (s *Session) addURL(u string, opts *AddTorrentOptions) (*Torrent, error) {
+	body, err := s.downloadTorrent(u)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return s.addTorrent(body, opts)
+}
+
+// downloadTorrent requests the torrent file at the URL. The caller must close the returned body.
+func (s *Session) downloadTorrent(u string) (io.ReadCloser, error) {
+	client := http.Client{
+		Timeout: s.config.TorrentAddHTTPTimeout,
+	}
+
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("cannot download torrent file, http status: %d", resp.StatusCode)
+	}
+
+	if resp.ContentLength > int64(s.config.MaxTorrentSize) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("torrent file too large: %d", resp.ContentLength)
+	}
+
+	return resp.Body, nil
+}
+
+func (s *Session) addMagnet(link string, opts *AddTorrentOptions) (*Torrent, error) {
+	ma, err := magnet.New(link)
+	if err != nil {
+		return nil, err
+	}
+
+	id, port, sto, err := s.initTorrent(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			s.releasePort(port)
+		}
+	}()
+
+	infoHash := ma.Hash()
+	name := ma.Name
+	if name == "" {
+		name = infoHash.HexString()
+	}
+
+	t, err := newTorrent(
+		s,
+		id,
+		time.Now(),
+		infoHash[:],
+		nil,
+		name,
+		port,
+		s.parseTrackers(ma.Trackers, false),
+		sto,
+		s.log,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			t.Close()
+		}
+	}()
+
+	// Read by the run loop only after the torrent is started
+	t.stopAfterDownload = opts.StopAfterDownload
+
+	rspec := &boltdbresumer.Spec{
+		InfoHash:          infoHash[:],
+		Port:              port,
+		Name:              name,
+		Trackers:          ma.Trackers,
+		URLList:           ma.WebSeeds,
+		AddedAt:           t.addedAt,
+		StopAfterDownload: opts.StopAfterDownload,
+		StopAfterMetadata: opts.StopAfterMetadata,
+	}
+
+	start := time.Now()
+	err = s.resumer.Write(id, rspec)
+	s.metrics.resumeWrites.With("spec").ObserveSince(start)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.addToQueue(id)
+	if err != nil {
+		return nil, err
+	}
+
+	t2 := s.insertTorrent(t)
+	t.publish(Event{Type: TorrentAdded})
+
+	return t2, nil
+}
+
 func (s *Session) addTorrent(r io.Reader, opts *AddTorrentOptions) (*Torrent, error) {
 	r = io.LimitReader(r, int64(s.config.MaxTorrentSize))
 	mi, err := s.parseMetaInfo(r)
@@ -78,24 +230,36 @@ func (s *Session) addTorrent(r io.Reader, opts *AddTorrentOptions) (*Torrent, er
 		}
 	}()
 
+	// Read by the run loop only after the torrent is started
+	t.stopAfterDownload = opts.StopAfterDownload
+
 	rspec := &boltdbresumer.Spec{
 		InfoHash:          mi.Info.Hash[:],
 		Port:              port,
 		Name:              mi.Info.Name,
 		Trackers:          mi.AnnounceList,
 		URLList:           mi.URLList,
-		Info:              mi.Info.Bytes,
+		Info:              mi.Info.Raw,
+		PieceLayers:       mi.PieceLayers,
 		AddedAt:           t.addedAt,
 		StopAfterDownload: opts.StopAfterDownload,
 		StopAfterMetadata: opts.StopAfterMetadata,
 	}
 
+	start := time.Now()
 	err = s.resumer.Write(id, rspec)
+	s.metrics.resumeWrites.With("spec").ObserveSince(start)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.addToQueue(id)
 	if err != nil {
 		return nil, err
 	}
 
 	t2 := s.insertTorrent(t)
+	t.publish(Event{Type: TorrentAdded})
 
 	return t2, nil
 }
@@ -0,0 +1,267 @@
+package torrent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var altSpeedKey = []byte("alt-speed")
+
+// TimeOfDay is a time in a day in minutes after midnight. It is written as "15:04" in JSON.
+type TimeOfDay int
+
+// ParseTimeOfDay parses a time in "15:04" format.
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return TimeOfDay(t.Hour()*60 + t.Minute()), nil
+}
+
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", int(t)/60, int(t)%60)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t TimeOfDay) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *TimeOfDay) UnmarshalText(b []byte) error {
+	v, err := ParseTimeOfDay(string(b))
+	if err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
+
+// AltSpeedWindow is a time window of the week in which the alternative speed limits are used.
+// A window that ends before it starts continues on the next day, a window that ends when it starts lasts a whole day.
+type AltSpeedWindow struct {
+	// Days of the week the window starts at. Empty means every day.
+	Days  []time.Weekday
+	Start TimeOfDay
+	End   TimeOfDay
+}
+
+func (w AltSpeedWindow) startsOn(d time.Weekday) bool {
+	return len(w.Days) == 0 || slices.Contains(w.Days, d)
+}
+
+// contains returns true if t is in the window in its location.
+func (w AltSpeedWindow) contains(t time.Time) bool {
+	m := TimeOfDay(t.Hour()*60 + t.Minute())
+	day := t.Weekday()
+	if w.Start < w.End {
+		return w.startsOn(day) && m >= w.Start && m < w.End
+	}
+	// The window started today or yesterday
+	return w.startsOn(day) && m >= w.Start || w.startsOn((day+6)%7) && m < w.End
+}
+
+func (w AltSpeedWindow) validate() error {
+	if w.Start < 0 || w.Start >= 24*60 || w.End < 0 || w.End >= 24*60 {
+		return errors.New("time of day must be between 00:00 and 23:59")
+	}
+	for _, d := range w.Days {
+		if d < time.Sunday || d > time.Saturday {
+			return fmt.Errorf("invalid day of week: %d", d)
+		}
+	}
+	return nil
+}
+
+// AltSpeedMode selects when the alternative speed limits are used.
+type AltSpeedMode int
+
+const (
+	// AltSpeedScheduled uses the alternative speed limits in the time windows of the schedule.
+	AltSpeedScheduled AltSpeedMode = iota
+	// AltSpeedOn always uses the alternative speed limits.
+	AltSpeedOn
+	// AltSpeedOff never uses the alternative speed limits.
+	AltSpeedOff
+)
+
+var altSpeedModeNames = [...]string{
+	"scheduled",
+	"on",
+	"off",
+}
+
+func (m AltSpeedMode) String() string {
+	if m < 0 || int(m) >= len(altSpeedModeNames) {
+		return "unknown"
+	}
+	return altSpeedModeNames[m]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m AltSpeedMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *AltSpeedMode) UnmarshalText(b []byte) error {
+	i := slices.Index(altSpeedModeNames[:], string(b))
+	if i < 0 {
+		return fmt.Errorf("invalid alternative speed mode %q", b)
+	}
+	*m = AltSpeedMode(i)
+	return nil
+}
+
+// AltSpeed contains the alternative speed limits and when they are used instead of the normal speed limits.
+type AltSpeed struct {
+	// Replace all limits of the Session while active
+	Limits   SpeedLimits
+	Schedule []AltSpeedWindow
+	// Manual override of the schedule
+	Mode AltSpeedMode
+}
+
+// active returns true if the alternative speed limits are used at time t.
+func (a *AltSpeed) active(t time.Time) bool {
+	switch a.Mode {
+	case AltSpeedOn:
+		return true
+	case AltSpeedOff:
+		return false
+	}
+	for _, w := range a.Schedule {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAltSpeed changes the alternative speed limits and their schedule.
+// The settings are saved in the database and the limits are switched immediately if needed.
+func (s *Session) SetAltSpeed(a AltSpeed) error {
+	if a.Mode < AltSpeedScheduled || a.Mode > AltSpeedOff {
+		return fmt.Errorf("invalid alternative speed mode: %d", a.Mode)
+	}
+	for _, w := range a.Schedule {
+		if err := w.validate(); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	s.mSpeedLimits.Lock()
+	start := time.Now()
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sessionBucket).Put(altSpeedKey, data)
+	})
+	s.metrics.resumeWrites.With("alt_speed").ObserveSince(start)
+	if err == nil {
+		s.altSpeed = a
+	}
+	s.mSpeedLimits.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.updateSpeedLimits(true)
+	return nil
+}
+
+// SetAltSpeedMode overrides the schedule of the alternative speed limits.
+func (s *Session) SetAltSpeedMode(mode AltSpeedMode) error {
+	a := s.AltSpeed()
+	a.Mode = mode
+	return s.SetAltSpeed(a)
+}
+
+// AltSpeed returns the alternative speed limits and their schedule.
+func (s *Session) AltSpeed() AltSpeed {
+	s.mSpeedLimits.RLock()
+	defer s.mSpeedLimits.RUnlock()
+	return s.altSpeed
+}
+
+// AltSpeedActive returns true if the alternative speed limits are used.
+func (s *Session) AltSpeedActive() bool {
+	s.mSpeedLimits.RLock()
+	defer s.mSpeedLimits.RUnlock()
+	return s.altSpeedActive
+}
+
+// activeSpeedLimits returns the speed limits that are in use.
+func (s *Session) activeSpeedLimits() SpeedLimits {
+	s.mSpeedLimits.RLock()
+	defer s.mSpeedLimits.RUnlock()
+
+	if s.altSpeedActive {
+		return s.altSpeed.Limits
+	}
+	return s.speedLimits()
+}
+
+// updateSpeedLimits switches between the normal and the alternative speed limits
+// and applies the limits to the global limiters and the peers of torrents.
+// The limits are applied even if they are not switched when force is true.
+func (s *Session) updateSpeedLimits(force bool) {
+	s.mSpeedLimits.Lock()
+	active := s.altSpeed.active(s.now())
+	changed := active != s.altSpeedActive
+	s.altSpeedActive = active
+	s.mSpeedLimits.Unlock()
+
+	if !changed && !force {
+		return
+	}
+
+	l := s.activeSpeedLimits()
+	s.downloadLimiter.SetLimit(l.Download * 1024)
+	s.uploadLimiter.SetLimit(l.Upload * 1024)
+
+	// Peers of torrents use the default limits of the Session
+	for _, t := range s.ListTorrents() {
+		t.torrent.updatePeerSpeedLimits()
+	}
+
+	if changed {
+		s.log.Info("alternative speed limits switched", "active", active)
+		s.events.publish(Event{Type: AltSpeedChanged, AltSpeedActive: active})
+	}
+}
+
+// altSpeedScheduler checks the schedule of the alternative speed limits at the start of every minute.
+func (s *Session) altSpeedScheduler() {
+	defer s.backgroundWG.Done()
+
+	for {
+		now := s.now()
+		if !s.sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now)) {
+			return
+		}
+		s.updateSpeedLimits(false)
+	}
+}
+
+// loadAltSpeed returns the alternative speed settings saved by SetAltSpeed.
+func loadAltSpeed(db *bbolt.DB) (AltSpeed, error) {
+	var a AltSpeed
+	err := db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(sessionBucket).Get(altSpeedKey)
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &a)
+	})
+	return a, err
+}
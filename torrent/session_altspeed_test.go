@@ -0,0 +1,142 @@
+package torrent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClock struct {
+	m   sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.now
+}
+
+func (c *testClock) Set(t time.Time) {
+	c.m.Lock()
+	c.now = t
+	c.m.Unlock()
+}
+
+func TestAltSpeedWindow(t *testing.T) {
+	// 2024-01-06 is a Saturday
+	at := func(day int, hhmm string) time.Time {
+		tod, err := ParseTimeOfDay(hhmm)
+		require.NoError(t, err)
+		return time.Date(2024, 1, day, int(tod)/60, int(tod)%60, 0, 0, time.UTC)
+	}
+
+	weekend := AltSpeedWindow{Days: []time.Weekday{time.Saturday, time.Sunday}, Start: 9 * 60, End: 17 * 60}
+	assert.False(t, weekend.contains(at(5, "12:00")))
+	assert.False(t, weekend.contains(at(6, "08:59")))
+	assert.True(t, weekend.contains(at(6, "09:00")))
+	assert.True(t, weekend.contains(at(7, "16:59")))
+	assert.False(t, weekend.contains(at(7, "17:00")))
+
+	night := AltSpeedWindow{Days: []time.Weekday{time.Friday}, Start: 22 * 60, End: 6 * 60}
+	assert.False(t, night.contains(at(5, "21:59")))
+	assert.True(t, night.contains(at(5, "23:00")))
+	assert.True(t, night.contains(at(6, "05:59")))
+	assert.False(t, night.contains(at(6, "06:00")))
+	assert.False(t, night.contains(at(6, "23:00")))
+
+	allDay := AltSpeedWindow{Start: 8 * 60, End: 8 * 60}
+	assert.True(t, allDay.contains(at(6, "07:59")))
+	assert.True(t, allDay.contains(at(6, "08:00")))
+}
+
+func TestTimeOfDay(t *testing.T) {
+	tod, err := ParseTimeOfDay("07:30")
+	require.NoError(t, err)
+	assert.Equal(t, TimeOfDay(7*60+30), tod)
+	assert.Equal(t, "07:30", tod.String())
+
+	_, err = ParseTimeOfDay("24:00")
+	assert.Error(t, err)
+	_, err = ParseTimeOfDay("7")
+	assert.Error(t, err)
+}
+
+func TestAltSpeedSchedule(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.SpeedLimitDownload = 1000
+	clock := &testClock{now: time.Date(2024, 1, 6, 8, 0, 0, 0, time.UTC)}
+	s, err := newSession(cfg, testLogger, clock.Now)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := s.Subscribe(ctx)
+
+	alt := AltSpeed{
+		Limits:   SpeedLimits{Download: 10, Upload: 5},
+		Schedule: []AltSpeedWindow{{Start: 9 * 60, End: 17 * 60}},
+	}
+	require.NoError(t, s.SetAltSpeed(alt))
+	assert.False(t, s.AltSpeedActive())
+	assert.Equal(t, int64(1000*1024), s.downloadLimiter.Limit())
+
+	clock.Set(time.Date(2024, 1, 6, 9, 0, 0, 0, time.UTC))
+	s.updateSpeedLimits(false)
+	assert.True(t, s.AltSpeedActive())
+	assert.True(t, s.Stats().AltSpeedActive)
+	assert.Equal(t, int64(10*1024), s.downloadLimiter.Limit())
+	assert.Equal(t, int64(5*1024), s.uploadLimiter.Limit())
+	e := <-events
+	assert.Equal(t, AltSpeedChanged, e.Type)
+	assert.True(t, e.AltSpeedActive)
+
+	// Normal limits are saved while the alternative limits are active
+	require.NoError(t, s.SetSpeedLimits(SpeedLimits{Download: 2000}))
+	assert.Equal(t, int64(10*1024), s.downloadLimiter.Limit())
+
+	// Manual override
+	require.NoError(t, s.SetAltSpeedMode(AltSpeedOff))
+	assert.False(t, s.AltSpeedActive())
+	assert.Equal(t, int64(2000*1024), s.downloadLimiter.Limit())
+	e = <-events
+	assert.False(t, e.AltSpeedActive)
+
+	clock.Set(time.Date(2024, 1, 6, 18, 0, 0, 0, time.UTC))
+	require.NoError(t, s.SetAltSpeedMode(AltSpeedOn))
+	assert.True(t, s.AltSpeedActive())
+	s.Close()
+
+	// Settings are saved in the database
+	s, err = newSession(cfg, testLogger, clock.Now)
+	require.NoError(t, err)
+	defer s.Close()
+	alt.Mode = AltSpeedOn
+	assert.Equal(t, alt, s.AltSpeed())
+	assert.True(t, s.AltSpeedActive())
+	assert.Equal(t, int64(10*1024), s.downloadLimiter.Limit())
+
+	require.NoError(t, s.SetAltSpeedMode(AltSpeedScheduled))
+	assert.False(t, s.AltSpeedActive())
+
+	assert.Error(t, s.SetAltSpeed(AltSpeed{Schedule: []AltSpeedWindow{{Start: 25 * 60}}}))
+}
+
+func TestAltSpeedPeerLimits(t *testing.T) {
+	cfg := testConfig(t)
+	s := newTestSession(t, cfg)
+	defer s.Close()
+	require.NoError(t, s.SetSpeedLimits(SpeedLimits{PeerDownload: 20}))
+	tor := addTestTorrent(t, s, testTorrent{})
+
+	download, _ := tor.torrent.peerSpeedLimits()
+	assert.Equal(t, int64(20*1024), download)
+
+	require.NoError(t, s.SetAltSpeed(AltSpeed{Limits: SpeedLimits{PeerDownload: 2}, Mode: AltSpeedOn}))
+	download, _ = tor.torrent.peerSpeedLimits()
+	assert.Equal(t, int64(2*1024), download)
+}
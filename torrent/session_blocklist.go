@@ -0,0 +1,248 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	blocklistKey          = []byte("blocklist")
+	blocklistTimestampKey = []byte("blocklist-timestamp")
+	blocklistURLHashKey   = []byte("blocklist-url-hash")
+)
+
+// Time to wait before retrying a failed blocklist download.
+// It is doubled after each failure up to BlocklistUpdateInterval.
+const blocklistRetryDelay = time.Minute
+
+var errBlocklistTooLarge = errors.New("blocklist is too large")
+
+// startBlocklistReloader loads the blocklist saved in the database and starts
+// downloading it periodically from BlocklistURL.
+func (s *Session) startBlocklistReloader() {
+	if s.config.BlocklistURL == "" {
+		return
+	}
+
+	err := s.loadBlocklistFromDB()
+	if err != nil {
+		s.log.Error("cannot load blocklist from database", "err", err.Error())
+	}
+
+	s.backgroundWG.Add(1)
+	go s.blocklistReloader()
+}
+
+// blocklistReloader downloads the blocklist when it is older than BlocklistUpdateInterval.
+// The list is only downloaded once if BlocklistUpdateInterval is not positive.
+func (s *Session) blocklistReloader() {
+	defer s.backgroundWG.Done()
+
+	retryDelay := blocklistRetryDelay
+	for {
+		timestamp := s.getBlocklistTimestamp()
+		if !timestamp.IsZero() && s.config.BlocklistUpdateInterval <= 0 {
+			// Periodic updates are disabled
+			return
+		}
+		delay := time.Until(timestamp.Add(s.config.BlocklistUpdateInterval))
+		if !s.sleep(delay) {
+			return
+		}
+
+		// The blocklist may be reloaded with ReloadBlocklist in the meantime
+		if !s.getBlocklistTimestamp().Equal(timestamp) {
+			continue
+		}
+
+		err := s.ReloadBlocklist()
+		if err == nil {
+			retryDelay = blocklistRetryDelay
+			continue
+		}
+
+		s.log.Error("cannot reload blocklist", "url", s.config.BlocklistURL, "retry_in", retryDelay.String(), "err", err.Error())
+		if !s.sleep(retryDelay) {
+			return
+		}
+		retryDelay = min(2*retryDelay, max(s.config.BlocklistUpdateInterval, blocklistRetryDelay))
+	}
+}
+
+// sleep waits for d and returns false if the Session is closed before that.
+func (s *Session) sleep(d time.Duration) bool {
+	if d <= 0 {
+		select {
+		case <-s.closeC:
+			return false
+		default:
+			return true
+		}
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-s.closeC:
+		return false
+	}
+}
+
+func (s *Session) getBlocklistTimestamp() time.Time {
+	s.mBlocklist.RLock()
+	defer s.mBlocklist.RUnlock()
+	return s.blocklistTimestamp
+}
+
+// ReloadBlocklist downloads the blocklist from BlocklistURL and replaces the current rules.
+// The downloaded list is saved in the database to be used on the next start.
+func (s *Session) ReloadBlocklist() error {
+	if s.config.BlocklistURL == "" {
+		return errors.New("blocklist url is not set")
+	}
+
+	s.mBlocklistReload.Lock()
+	defer s.mBlocklistReload.Unlock()
+
+	data, err := s.downloadBlocklist()
+	if err != nil {
+		return err
+	}
+
+	stats, err := s.blocklist.Reload(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = s.saveBlocklist(data, now)
+	if err != nil {
+		s.log.Error("cannot save blocklist to database", "err", err.Error())
+	}
+
+	s.mBlocklist.Lock()
+	s.blocklistTimestamp = now
+	s.mBlocklist.Unlock()
+
+	s.log.Info("blocklist reloaded", "url", s.config.BlocklistURL, "rules", stats.Parsed, "rejected", stats.Rejected, "ignored", stats.Ignored, "size", len(data))
+	return nil
+}
+
+func (s *Session) downloadBlocklist() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.BlocklistUpdateTimeout)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-s.closeC:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.config.BlocklistURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	maxSize := s.config.BlocklistMaxResponseSize
+	if resp.ContentLength > maxSize {
+		return nil, errBlocklistTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, errBlocklistTooLarge
+	}
+
+	return data, nil
+}
+
+func (s *Session) saveBlocklist(data []byte, timestamp time.Time) error {
+	ts, err := timestamp.MarshalText()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(sessionBucket)
+		if err := b.Put(blocklistKey, data); err != nil {
+			return err
+		}
+		if err := b.Put(blocklistTimestampKey, ts); err != nil {
+			return err
+		}
+		return b.Put(blocklistURLHashKey, blocklistURLHash(s.config.BlocklistURL))
+	})
+	s.metrics.resumeWrites.With("blocklist").ObserveSince(start)
+	return err
+}
+
+// loadBlocklistFromDB loads the last downloaded blocklist if it is downloaded from the same URL.
+func (s *Session) loadBlocklistFromDB() error {
+	var data []byte
+	var timestamp time.Time
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(sessionBucket)
+		if !bytes.Equal(b.Get(blocklistURLHashKey), blocklistURLHash(s.config.BlocklistURL)) {
+			return nil
+		}
+
+		value := b.Get(blocklistKey)
+		if value == nil {
+			return nil
+		}
+
+		if err := timestamp.UnmarshalText(b.Get(blocklistTimestampKey)); err != nil {
+			return err
+		}
+
+		// Values are only valid inside the transaction
+		data = bytes.Clone(value)
+		return nil
+	})
+	if err != nil || data == nil {
+		return err
+	}
+
+	stats, err := s.blocklist.Reload(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	s.mBlocklist.Lock()
+	s.blocklistTimestamp = timestamp
+	s.mBlocklist.Unlock()
+
+	s.log.Info("blocklist loaded from database", "rules", stats.Parsed, "rejected", stats.Rejected, "age", time.Since(timestamp).String())
+	return nil
+}
+
+func blocklistURLHash(url string) []byte {
+	h := sha1.Sum([]byte(url))
+	return h[:]
+}
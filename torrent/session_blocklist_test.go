@@ -0,0 +1,98 @@
+package torrent
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlocklistDownloadAndCache(t *testing.T) {
+	var requests atomic.Int32
+	fail := atomic.Bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, "# test list\n10.0.0.0/8\n192.168.1.0/24\n")
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t)
+	cfg.BlocklistURL = srv.URL
+	cfg.BlocklistUpdateInterval = time.Hour
+	s := newTestSession(t, cfg)
+	assert.Eventually(t, func() bool { return s.Stats().BlockListRules == 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, s.blocklist.Blocked(net.ParseIP("10.1.2.3")))
+	assert.False(t, s.blocklist.Blocked(net.ParseIP("192.168.2.1")))
+	s.Close()
+	assert.Equal(t, int32(1), requests.Load())
+
+	// The saved list is used when the server is not reachable
+	fail.Store(true)
+	s = newTestSession(t, cfg)
+	defer s.Close()
+	stats := s.Stats()
+	assert.Equal(t, 2, stats.BlockListRules)
+	assert.Greater(t, stats.BlockListRecency, time.Duration(0))
+	assert.True(t, s.blocklist.Blocked(net.ParseIP("192.168.1.100")))
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Failed downloads keep the current rules
+	assert.Error(t, s.ReloadBlocklist())
+	assert.Equal(t, 2, s.Stats().BlockListRules)
+}
+
+func TestBlocklistURLChange(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/a" {
+			io.WriteString(w, "10.0.0.0/8\n")
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t)
+	cfg.BlocklistURL = srv.URL + "/a"
+	cfg.BlocklistUpdateInterval = time.Hour
+	s := newTestSession(t, cfg)
+	require.NoError(t, s.ReloadBlocklist())
+	s.Close()
+
+	cfg.BlocklistURL = srv.URL + "/b"
+	s = newTestSession(t, cfg)
+	defer s.Close()
+	assert.Equal(t, 0, s.Stats().BlockListRules)
+}
+
+func TestBlocklistMaxResponseSize(t *testing.T) {
+	list := strings.Repeat("10.0.0.0/8\n", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chunked") != "" {
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, list)
+	}))
+	defer srv.Close()
+
+	for _, url := range []string{srv.URL, srv.URL + "?chunked=1"} {
+		cfg := testConfig(t)
+		cfg.BlocklistURL = url
+		cfg.BlocklistUpdateInterval = time.Hour
+		cfg.BlocklistMaxResponseSize = int64(len(list)) - 1
+		s := newTestSession(t, cfg)
+		assert.ErrorIs(t, s.ReloadBlocklist(), errBlocklistTooLarge)
+		assert.Equal(t, 0, s.Stats().BlockListRules)
+		s.Close()
+	}
+}
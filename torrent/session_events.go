@@ -0,0 +1,184 @@
+package torrent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// EventType is the type of an Event.
+type EventType int
+
+const (
+	// TorrentAdded is sent when a torrent is added to the Session.
+	TorrentAdded EventType = iota
+	// TorrentRemoved is sent when a torrent is removed from the Session.
+	TorrentRemoved
+	// TorrentStarted is sent when a torrent starts running.
+	TorrentStarted
+	// TorrentStopped is sent when a torrent stops. Error is set if it is stopped by an error.
+	TorrentStopped
+	// PieceVerified is sent when a piece passes the hash check.
+	PieceVerified
+	// DownloadComplete is sent when all pieces of a torrent are downloaded and verified.
+	DownloadComplete
+	// TrackerError is sent when an announce to a tracker fails.
+	TrackerError
+	// TrackerWarning is sent when a tracker returns a warning message in a successful response.
+	TrackerWarning
+	// StorageError is sent when the files of a torrent cannot be opened, read or written.
+	StorageError
+	// AltSpeedChanged is sent when the Session switches between the normal and the alternative speed limits.
+	AltSpeedChanged
+)
+
+var eventTypeNames = [...]string{
+	"torrent_added",
+	"torrent_removed",
+	"torrent_started",
+	"torrent_stopped",
+	"piece_verified",
+	"download_complete",
+	"tracker_error",
+	"tracker_warning",
+	"storage_error",
+	"alt_speed_changed",
+}
+
+func (e EventType) String() string {
+	return eventTypeNames[e]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e EventType) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *EventType) UnmarshalText(b []byte) error {
+	i := slices.Index(eventTypeNames[:], string(b))
+	if i < 0 {
+		return fmt.Errorf("invalid event type %q", b)
+	}
+	*e = EventType(i)
+	return nil
+}
+
+// Event is a change in the lifecycle of the Session or a torrent in it.
+// Only the fields relevant to the Type are set.
+type Event struct {
+	Type      EventType
+	Time      time.Time
+	TorrentID string
+	// Set for TorrentStopped, TrackerError and StorageError events.
+	Error error
+	// URL of the tracker for TrackerError and TrackerWarning events.
+	Tracker string
+	// Warning message for TrackerWarning events.
+	Warning string
+	// Index of the piece for PieceVerified events.
+	Piece uint32
+	// True if the alternative speed limits are used after an AltSpeedChanged event.
+	AltSpeedActive bool
+}
+
+// Number of events buffered for each subscriber.
+// Events are dropped for subscribers that do not keep up.
+const eventBufferSize = 256
+
+// PieceVerified events are dropped when the buffer of a subscriber is filled up to this size,
+// so that the verification of many pieces does not crowd out the other events.
+const pieceVerifiedBufferSize = eventBufferSize / 2
+
+type eventBus struct {
+	m sync.Mutex
+	// Types of events that are sent to the subscriber, all types if empty
+	subscribers map[chan Event][]EventType
+	closed      bool
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subscribers: make(map[chan Event][]EventType),
+	}
+}
+
+func (b *eventBus) subscribe(types ...EventType) chan Event {
+	ch := make(chan Event, eventBufferSize)
+
+	b.m.Lock()
+	defer b.m.Unlock()
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	b.subscribers[ch] = types
+
+	return ch
+}
+
+func (b *eventBus) unsubscribe(ch chan Event) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish sends the event to the subscribers of its type without blocking.
+func (b *eventBus) publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.m.Lock()
+	defer b.m.Unlock()
+	for ch, types := range b.subscribers {
+		if len(types) > 0 && !slices.Contains(types, e.Type) {
+			continue
+		}
+		if e.Type == PieceVerified && len(ch) >= pieceVerifiedBufferSize {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (b *eventBus) close() {
+	b.m.Lock()
+	defer b.m.Unlock()
+	for ch := range b.subscribers {
+		close(ch)
+	}
+	b.subscribers = nil
+	b.closed = true
+}
+
+// Subscribe returns a channel that receives the events of the Session and its torrents.
+// Only the events of the given types are received, or all events if no type is given.
+// The channel is closed when ctx is done or the Session is closed.
+// Events are dropped if the receiver does not keep up, so that torrents are never blocked by slow subscribers.
+func (s *Session) Subscribe(ctx context.Context, types ...EventType) <-chan Event {
+	ch := s.events.subscribe(types...)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.events.unsubscribe(ch)
+		case <-s.closeC:
+		}
+	}()
+
+	return ch
+}
+
+func (t *torrent) publish(e Event) {
+	e.TorrentID = t.id
+	t.session.events.publish(e)
+}
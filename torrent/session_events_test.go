@@ -0,0 +1,133 @@
+package torrent
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBusDropsEventsOfSlowSubscribers(t *testing.T) {
+	b := newEventBus()
+	slow := b.subscribe()
+	fast := b.subscribe()
+
+	received := 0
+	for i := range eventBufferSize + 10 {
+		b.publish(Event{Type: TrackerWarning, Warning: strconv.Itoa(i)})
+		// Publishing must not block on the subscriber that does not receive
+		e := <-fast
+		assert.Equal(t, strconv.Itoa(i), e.Warning)
+		received++
+	}
+	assert.Equal(t, eventBufferSize+10, received)
+
+	// Only the buffered events are kept for the slow subscriber
+	assert.Len(t, slow, eventBufferSize)
+	for i := range eventBufferSize {
+		e := <-slow
+		assert.Equal(t, strconv.Itoa(i), e.Warning)
+		assert.False(t, e.Time.IsZero())
+	}
+
+	b.close()
+	_, ok := <-slow
+	assert.False(t, ok)
+	_, ok = <-fast
+	assert.False(t, ok)
+}
+
+func TestEventBusFiltersEvents(t *testing.T) {
+	b := newEventBus()
+	all := b.subscribe()
+	stopped := b.subscribe(TorrentStopped)
+
+	// Verified pieces do not crowd out the other events of subscribers that do not keep up
+	for i := range eventBufferSize {
+		b.publish(Event{Type: PieceVerified, Piece: uint32(i)})
+	}
+	b.publish(Event{Type: TorrentStopped})
+	require.Len(t, all, pieceVerifiedBufferSize+1)
+	for range pieceVerifiedBufferSize {
+		assert.Equal(t, PieceVerified, (<-all).Type)
+	}
+	assert.Equal(t, TorrentStopped, (<-all).Type)
+
+	// Only the subscribed types are received
+	require.Len(t, stopped, 1)
+	assert.Equal(t, TorrentStopped, (<-stopped).Type)
+
+	var e EventType
+	require.NoError(t, e.UnmarshalText([]byte("download_complete")))
+	assert.Equal(t, DownloadComplete, e)
+	assert.Error(t, e.UnmarshalText([]byte("unknown")))
+	b.close()
+}
+
+func TestSubscribeUnsubscribesOnContextCancel(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := s.Subscribe(ctx)
+	other := s.Subscribe(context.Background())
+	cancel()
+
+	// The channel is closed after the context is cancelled
+	require.Eventually(t, func() bool {
+		select {
+		case _, ok := <-events:
+			return !ok
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	s.events.m.Lock()
+	for ch := range s.events.subscribers {
+		assert.NotEqual(t, events, (<-chan Event)(ch))
+	}
+	s.events.m.Unlock()
+
+	// Other subscribers still receive events
+	tor := addTestTorrent(t, s, testTorrent{})
+	e := <-other
+	assert.Equal(t, TorrentAdded, e.Type)
+	assert.Equal(t, tor.ID(), e.TorrentID)
+}
+
+func TestTorrentEvents(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+
+	events := s.Subscribe(context.Background())
+	tor := addTestTorrent(t, s, testTorrent{Complete: true, Options: &AddTorrentOptions{}})
+	waitStatus(t, tor, Seeding)
+
+	// Pieces that exist on the disk are verified when the torrent is started
+	var verified []uint32
+	var types []EventType
+	for e := range events {
+		assert.Equal(t, tor.ID(), e.TorrentID)
+		types = append(types, e.Type)
+		if e.Type == PieceVerified {
+			verified = append(verified, e.Piece)
+		}
+		if e.Type == DownloadComplete {
+			break
+		}
+	}
+	assert.Equal(t, []uint32{0, 1, 2, 3}, verified)
+	assert.Equal(t, []EventType{TorrentAdded, TorrentStarted, PieceVerified, PieceVerified, PieceVerified, PieceVerified, DownloadComplete}, types)
+
+	require.NoError(t, s.RemoveTorrent(tor.ID()))
+	for e := range events {
+		if e.Type == TorrentRemoved {
+			assert.Equal(t, tor.ID(), e.TorrentID)
+			break
+		}
+	}
+}
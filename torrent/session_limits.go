@@ -0,0 +1,86 @@
+package torrent
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var speedLimitsKey = []byte("speed-limits")
+
+// SpeedLimits are download and upload speed limits in KB/s. Zero means unlimited.
+// Transfers are limited by the limits of the peer, the torrent and the Session together.
+type SpeedLimits struct {
+	Download int64
+	Upload   int64
+	// Limits of each connected peer
+	PeerDownload int64
+	PeerUpload   int64
+}
+
+// SetSpeedLimits changes the global speed limits and the default limits of each peer.
+// The limits are saved in the database and applied to running torrents without restarting them.
+func (s *Session) SetSpeedLimits(l SpeedLimits) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	s.mSpeedLimits.Lock()
+	start := time.Now()
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sessionBucket).Put(speedLimitsKey, data)
+	})
+	s.metrics.resumeWrites.With("session_speed_limits").ObserveSince(start)
+	if err != nil {
+		s.mSpeedLimits.Unlock()
+		return err
+	}
+	s.config.SpeedLimitDownload = l.Download
+	s.config.SpeedLimitUpload = l.Upload
+	s.config.SpeedLimitPeerDownload = l.PeerDownload
+	s.config.SpeedLimitPeerUpload = l.PeerUpload
+	s.mSpeedLimits.Unlock()
+
+	s.updateSpeedLimits(true)
+	return nil
+}
+
+// SpeedLimits returns the global speed limits and the default limits of each peer.
+// The alternative speed limits are used instead of them while AltSpeedActive returns true.
+func (s *Session) SpeedLimits() SpeedLimits {
+	s.mSpeedLimits.RLock()
+	defer s.mSpeedLimits.RUnlock()
+	return s.speedLimits()
+}
+
+// speedLimits must be called with mSpeedLimits held.
+func (s *Session) speedLimits() SpeedLimits {
+	return SpeedLimits{
+		Download:     s.config.SpeedLimitDownload,
+		Upload:       s.config.SpeedLimitUpload,
+		PeerDownload: s.config.SpeedLimitPeerDownload,
+		PeerUpload:   s.config.SpeedLimitPeerUpload,
+	}
+}
+
+// loadSpeedLimits replaces the limits in the config with the limits saved by SetSpeedLimits.
+func loadSpeedLimits(db *bbolt.DB, cfg *Config) error {
+	return db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(sessionBucket).Get(speedLimitsKey)
+		if data == nil {
+			return nil
+		}
+
+		var l SpeedLimits
+		if err := json.Unmarshal(data, &l); err != nil {
+			return err
+		}
+		cfg.SpeedLimitDownload = l.Download
+		cfg.SpeedLimitUpload = l.Upload
+		cfg.SpeedLimitPeerDownload = l.PeerDownload
+		cfg.SpeedLimitPeerUpload = l.PeerUpload
+		return nil
+	})
+}
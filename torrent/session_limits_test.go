@@ -0,0 +1,84 @@
+package torrent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionSpeedLimits(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.SpeedLimitDownload = 100
+	s := newTestSession(t, cfg)
+	assert.Equal(t, SpeedLimits{Download: 100}, s.SpeedLimits())
+	assert.Equal(t, int64(100*1024), s.downloadLimiter.Limit())
+
+	limits := SpeedLimits{Download: 0, Upload: 50, PeerDownload: 10, PeerUpload: 5}
+	require.NoError(t, s.SetSpeedLimits(limits))
+	assert.Equal(t, limits, s.SpeedLimits())
+	assert.Equal(t, int64(0), s.downloadLimiter.Limit())
+	assert.Equal(t, int64(50*1024), s.uploadLimiter.Limit())
+	s.Close()
+
+	// Saved limits override the config
+	s = newTestSession(t, cfg)
+	defer s.Close()
+	assert.Equal(t, limits, s.SpeedLimits())
+	assert.Equal(t, int64(50*1024), s.uploadLimiter.Limit())
+}
+
+func TestTorrentSpeedLimits(t *testing.T) {
+	cfg := testConfig(t)
+	s := newTestSession(t, cfg)
+	defer s.Close()
+	require.NoError(t, s.SetSpeedLimits(SpeedLimits{Download: 1000, PeerDownload: 20, PeerUpload: 30}))
+
+	tor := addTestTorrent(t, s, testTorrent{})
+	assert.Equal(t, SpeedLimits{}, tor.SpeedLimits())
+
+	limits := SpeedLimits{Download: 100, Upload: 200, PeerUpload: 10}
+	require.NoError(t, tor.SetSpeedLimits(limits))
+	assert.Equal(t, limits, tor.SpeedLimits())
+	assert.Equal(t, int64(100*1024), tor.torrent.downloadLimiter.Limit())
+	assert.Equal(t, int64(200*1024), tor.torrent.uploadLimiter.Limit())
+
+	spec, err := s.resumer.Read(tor.ID())
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), spec.SpeedLimits.Upload)
+	assert.Equal(t, int64(10), spec.SpeedLimits.PeerUpload)
+
+	// Peers are limited by the peer, torrent and global limiters
+	pe := tor.torrent.newPeer(nil, [20]byte{}, 0)
+	tor.torrent.peers[pe] = struct{}{}
+	assert.Equal(t, int64(20*1024), pe.DownloadLimiter.Limit())
+	assert.Equal(t, int64(10*1024), pe.UploadLimiter.Limit())
+
+	// Connected peers get the new limits
+	require.NoError(t, s.SetSpeedLimits(SpeedLimits{PeerDownload: 40}))
+	assert.Equal(t, int64(40*1024), pe.DownloadLimiter.Limit())
+	assert.Equal(t, int64(10*1024), pe.UploadLimiter.Limit())
+	require.NoError(t, tor.SetSpeedLimits(SpeedLimits{}))
+	assert.Equal(t, int64(0), pe.UploadLimiter.Limit())
+}
+
+func TestTorrentSpeedLimitsRestore(t *testing.T) {
+	cfg := testConfig(t)
+	s := newTestSession(t, cfg)
+
+	limits := SpeedLimits{Download: 100, Upload: 200, PeerDownload: 5, PeerUpload: 10}
+	tor := addTestTorrent(t, s, testTorrent{})
+	require.NoError(t, tor.SetSpeedLimits(limits))
+	s.Close()
+
+	s = newTestSession(t, cfg)
+	defer s.Close()
+	loaded := s.GetTorrent(tor.ID())
+	require.NotNil(t, loaded)
+	assert.Equal(t, limits, loaded.SpeedLimits())
+	assert.Equal(t, int64(100*1024), loaded.torrent.downloadLimiter.Limit())
+	assert.Equal(t, int64(200*1024), loaded.torrent.uploadLimiter.Limit())
+	download, upload := loaded.torrent.peerSpeedLimits()
+	assert.Equal(t, int64(5*1024), download)
+	assert.Equal(t, int64(10*1024), upload)
+}
@@ -0,0 +1,27 @@
+package torrent
+
+import "sort"
+
+// ListTorrents returns all torrents in the Session, ordered by the time they are added.
+func (s *Session) ListTorrents() []*Torrent {
+	s.mTorrents.RLock()
+	torrents := make([]*Torrent, 0, len(s.torrents))
+	for _, t := range s.torrents {
+		torrents = append(torrents, t)
+	}
+	s.mTorrents.RUnlock()
+
+	sort.Slice(torrents, func(i, j int) bool {
+		return torrents[i].torrent.addedAt.Before(torrents[j].torrent.addedAt)
+	})
+
+	return torrents
+}
+
+// GetTorrent returns the torrent with id. Returns nil if torrent is not found.
+func (s *Session) GetTorrent(id string) *Torrent {
+	s.mTorrents.RLock()
+	defer s.mTorrents.RUnlock()
+
+	return s.torrents[id]
+}
@@ -0,0 +1,160 @@
+package torrent
+
+import (
+	"fmt"
+
+	"github.com/al002/zbittorrent/internal/resumer/boltdbresumer"
+	"github.com/al002/zbittorrent/pkg/metainfo"
+)
+
+// loadExistingTorrents creates the torrents saved in the database and starts the ones that were started.
+// Torrents that cannot be loaded are logged and skipped, so one broken record does not prevent the Session from starting.
+func (s *Session) loadExistingTorrents(ids []string) {
+	var started []*Torrent
+	for _, id := range ids {
+		t, hasStarted, err := s.loadExistingTorrent(id)
+		if err != nil {
+			s.log.Error("cannot load torrent from database", "torrent", id, "err", err.Error())
+			continue
+		}
+		if hasStarted {
+			started = append(started, t)
+		}
+	}
+	s.log.Info("loaded existing torrents", "count", len(ids), "started", len(started))
+
+	for _, t := range started {
+		s.enqueue(t.torrent)
+	}
+}
+
+func (s *Session) loadExistingTorrent(id string) (tt *Torrent, hasStarted bool, err error) {
+	spec, err := s.resumer.Read(id)
+	if err != nil {
+		return
+	}
+
+	var info *metainfo.Info
+	var private bool
+	if len(spec.Info) > 0 {
+		info, err = metainfo.NewInfo(spec.Info, true, true)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid info: %w", err)
+		}
+		err = info.SetPieceLayers(spec.PieceLayers)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid piece layers: %w", err)
+		}
+		private = info.Private
+	}
+
+	port, err := s.takePort(spec.Port)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			s.releasePort(port)
+		}
+	}()
+
+	sto, err := s.storage.GetStorage(id)
+	if err != nil {
+		return
+	}
+
+	t, err := newTorrent(
+		s,
+		id,
+		spec.AddedAt,
+		spec.InfoHash,
+		info,
+		spec.Name,
+		port,
+		s.parseTrackers(spec.Trackers, private),
+		sto,
+		s.log,
+	)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			t.Close()
+		}
+	}()
+
+	// The run loop reads these only after the torrent is inserted into the Session
+	t.stopAfterDownload = spec.StopAfterDownload
+	t.bytesDownloaded = spec.BytesDownloaded
+	t.bytesUploaded = spec.BytesUploaded
+	t.bytesWasted = spec.BytesWasted
+	t.seededFor = spec.SeededFor
+
+	if len(spec.AllowedIPs) > 0 || len(spec.DeniedIPs) > 0 {
+		var f *ipFilter
+		f, err = newIPFilter(IPFilter{Allow: spec.AllowedIPs, Deny: spec.DeniedIPs})
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid ip filter: %w", err)
+		}
+		t.SetIPFilter(f)
+	}
+
+	if l := SpeedLimits(spec.SpeedLimits); l != (SpeedLimits{}) {
+		t.SetSpeedLimits(l)
+	}
+
+	if spec.SeedLimits != (boltdbresumer.SeedLimits{}) {
+		l := SeedLimits{
+			Ratio:    spec.SeedLimits.Ratio,
+			Time:     spec.SeedLimits.Time,
+			IdleTime: spec.SeedLimits.IdleTime,
+		}
+		err = l.Action.UnmarshalText([]byte(spec.SeedLimits.Action))
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid seed limits: %w", err)
+		}
+		t.SetSeedLimits(l)
+	}
+
+	// Must be set before the torrent is started, skipped files are not created when the files are allocated
+	if len(spec.FilePriorities) > 0 {
+		l := make([]FilePriority, len(spec.FilePriorities))
+		for i, name := range spec.FilePriorities {
+			err = l[i].UnmarshalText([]byte(name))
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid file priorities: %w", err)
+			}
+		}
+		t.SetFilePriorities(l)
+	}
+
+	if spec.Sequential {
+		t.SetSequential(true)
+	}
+
+	// Torrents added before the queue was saved go to the end of it
+	err = s.addToQueue(id)
+	if err != nil {
+		return
+	}
+
+	return s.insertTorrent(t), spec.Started, nil
+}
+
+// takePort returns the port saved for a torrent if it is still free and in the port range, or another free port.
+func (s *Session) takePort(port int) (int, error) {
+	s.mPorts.Lock()
+	_, ok := s.availablePorts[port]
+	if ok {
+		delete(s.availablePorts, port)
+	}
+	s.mPorts.Unlock()
+
+	if ok {
+		return port, nil
+	}
+	return s.getPort()
+}
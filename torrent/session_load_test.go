@@ -0,0 +1,50 @@
+package torrent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadExistingTorrents(t *testing.T) {
+	cfg := testConfig(t)
+	s := newTestSession(t, cfg)
+
+	stopped := addTestTorrent(t, s, testTorrent{Name: "stopped"})
+	seeding := addTestTorrent(t, s, testTorrent{Name: "seeding", Complete: true, Options: &AddTorrentOptions{}})
+	waitStatus(t, seeding, Seeding)
+	require.NoError(t, seeding.MoveInQueue(QueueTop))
+	s.Close()
+
+	s = newTestSession(t, cfg)
+	require.Len(t, s.ListTorrents(), 2)
+
+	for _, tor := range []*Torrent{stopped, seeding} {
+		loaded := s.GetTorrent(tor.ID())
+		require.NotNil(t, loaded)
+		assert.Equal(t, tor.Name(), loaded.Name())
+		assert.Equal(t, tor.InfoHash(), loaded.InfoHash())
+		assert.Equal(t, tor.AddedAt().Unix(), loaded.AddedAt().Unix())
+		files, err := loaded.Files()
+		require.NoError(t, err)
+		assert.Len(t, files, 1)
+	}
+
+	assert.Equal(t, stopped.Port(), s.GetTorrent(stopped.ID()).Port())
+	// Read while the started torrent starts listening
+	assert.Equal(t, seeding.Port(), s.GetTorrent(seeding.ID()).Port())
+
+	// Only the torrent that was started is started again, in its queue position
+	assert.Equal(t, 0, s.GetTorrent(seeding.ID()).QueuePosition())
+	assert.Equal(t, 1, s.GetTorrent(stopped.ID()).QueuePosition())
+	waitStatus(t, s.GetTorrent(seeding.ID()), Seeding)
+	assert.Equal(t, Stopped, s.GetTorrent(stopped.ID()).Stats().Status)
+
+	// Stopped torrents stay stopped after the next restart
+	require.NoError(t, s.GetTorrent(seeding.ID()).Stop())
+	s.Close()
+	s = newTestSession(t, cfg)
+	defer s.Close()
+	assert.Equal(t, Stopped, s.GetTorrent(seeding.ID()).Stats().Status)
+}
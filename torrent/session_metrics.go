@@ -0,0 +1,80 @@
+package torrent
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/metrics"
+	"github.com/al002/zbittorrent/internal/storage"
+)
+
+// sessionMetrics are the metrics that are collected as the events happen.
+// Others are computed from the stats of the Session when they are requested.
+type sessionMetrics struct {
+	// Results of announces to trackers, labeled by "host" and "result".
+	announces *metrics.CounterVec
+	// Number of downloaded pieces that failed the hash check.
+	// Pieces that are missing on the disk when the torrent is verified are not counted.
+	hashFailures metrics.Counter
+	// Latency of file reads and writes, labeled by "op".
+	diskLatency *metrics.HistogramVec
+	// Duration of writes to the resume database, labeled by "op".
+	resumeWrites *metrics.HistogramVec
+}
+
+func newSessionMetrics() *sessionMetrics {
+	return &sessionMetrics{
+		announces:    metrics.NewCounterVec("host", "result"),
+		diskLatency:  metrics.NewHistogramVec(metrics.DefaultDurationBuckets, "op"),
+		resumeWrites: metrics.NewHistogramVec(metrics.DefaultDurationBuckets, "op"),
+	}
+}
+
+func (m *sessionMetrics) countAnnounce(trackerURL string, failed bool) {
+	host := trackerURL
+	if u, err := url.Parse(trackerURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	result := "success"
+	if failed {
+		result = "failure"
+	}
+
+	m.announces.With(host, result).Inc()
+}
+
+// timedStorage measures the latency of the reads and writes to the files opened from Storage.
+type timedStorage struct {
+	storage.Storage
+	latency *metrics.HistogramVec
+}
+
+func (s *timedStorage) Open(name string, size int64) (storage.File, bool, error) {
+	f, exists, err := s.Storage.Open(name, size)
+	if err != nil {
+		return nil, exists, err
+	}
+
+	return &timedFile{
+		File:  f,
+		read:  s.latency.With("read"),
+		write: s.latency.With("write"),
+	}, exists, nil
+}
+
+type timedFile struct {
+	storage.File
+	read  *metrics.Histogram
+	write *metrics.Histogram
+}
+
+func (f *timedFile) ReadAt(p []byte, off int64) (int, error) {
+	defer f.read.ObserveSince(time.Now())
+	return f.File.ReadAt(p, off)
+}
+
+func (f *timedFile) WriteAt(p []byte, off int64) (int, error) {
+	defer f.write.ObserveSince(time.Now())
+	return f.File.WriteAt(p, off)
+}
@@ -0,0 +1,421 @@
+package torrent
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	queueKey       = []byte("queue")
+	queueLimitsKey = []byte("queue-limits")
+)
+
+// Interval of checking the queue for completed, stopped and slow torrents.
+const queueUpdateInterval = 5 * time.Second
+
+// QueueLimits limit the number of torrents that run at the same time. Zero means unlimited.
+// Started torrents that exceed the limits wait in Queued status and are started in the order of their queue positions.
+type QueueLimits struct {
+	// Maximum number of running torrents that are not completed.
+	Downloads int
+	// Maximum number of running torrents that are completed.
+	Seeds int
+	// Maximum number of running torrents in total.
+	Torrents int
+	// Do not count torrents that are running slower than the rates in KB/s for SlowTime, so they do not block the queue.
+	ExcludeSlow      bool
+	SlowDownloadRate int64
+	SlowUploadRate   int64
+	SlowTime         time.Duration
+}
+
+// QueueMove is a change in the queue position of a torrent.
+type QueueMove int
+
+const (
+	// QueueUp moves the torrent one position up.
+	QueueUp QueueMove = iota
+	// QueueDown moves the torrent one position down.
+	QueueDown
+	// QueueTop moves the torrent to the first position.
+	QueueTop
+	// QueueBottom moves the torrent to the last position.
+	QueueBottom
+)
+
+var queueMoveNames = [...]string{
+	"up",
+	"down",
+	"top",
+	"bottom",
+}
+
+func (m QueueMove) String() string {
+	if m < 0 || int(m) >= len(queueMoveNames) {
+		return "unknown"
+	}
+	return queueMoveNames[m]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m QueueMove) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *QueueMove) UnmarshalText(b []byte) error {
+	i := slices.Index(queueMoveNames[:], string(b))
+	if i < 0 {
+		return fmt.Errorf("invalid queue move %q", b)
+	}
+	*m = QueueMove(i)
+	return nil
+}
+
+// queueState is the state of a torrent in the queue of the Session.
+type queueState int
+
+const (
+	// queueStopped torrents are not started or stopped by the user or by themselves.
+	queueStopped queueState = iota
+	// queueWaiting torrents are started but wait for a free slot.
+	queueWaiting
+	// queueActive torrents are running.
+	queueActive
+)
+
+// SetQueueLimits changes the limits of running torrents.
+// The limits are saved in the database and the queue is updated immediately.
+func (s *Session) SetQueueLimits(l QueueLimits) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	s.mQueueLimits.Lock()
+	start := time.Now()
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sessionBucket).Put(queueLimitsKey, data)
+	})
+	s.metrics.resumeWrites.With("queue_limits").ObserveSince(start)
+	if err != nil {
+		s.mQueueLimits.Unlock()
+		return err
+	}
+	s.config.QueueMaxActiveDownloads = l.Downloads
+	s.config.QueueMaxActiveSeeds = l.Seeds
+	s.config.QueueMaxActiveTorrents = l.Torrents
+	s.config.QueueExcludeSlowTorrents = l.ExcludeSlow
+	s.config.QueueSlowDownloadRate = l.SlowDownloadRate
+	s.config.QueueSlowUploadRate = l.SlowUploadRate
+	s.config.QueueSlowTime = l.SlowTime
+	s.mQueueLimits.Unlock()
+
+	s.updateQueue()
+	return nil
+}
+
+// QueueLimits returns the limits of running torrents.
+func (s *Session) QueueLimits() QueueLimits {
+	s.mQueueLimits.RLock()
+	defer s.mQueueLimits.RUnlock()
+
+	return QueueLimits{
+		Downloads:        s.config.QueueMaxActiveDownloads,
+		Seeds:            s.config.QueueMaxActiveSeeds,
+		Torrents:         s.config.QueueMaxActiveTorrents,
+		ExcludeSlow:      s.config.QueueExcludeSlowTorrents,
+		SlowDownloadRate: s.config.QueueSlowDownloadRate,
+		SlowUploadRate:   s.config.QueueSlowUploadRate,
+		SlowTime:         s.config.QueueSlowTime,
+	}
+}
+
+// enqueue starts the torrent if there is a free slot, otherwise it waits in the queue.
+func (s *Session) enqueue(t *torrent) {
+	s.mQueue.Lock()
+	if t.queueState == queueStopped {
+		t.queueState = queueWaiting
+		t.queued.Store(true)
+	}
+	s.mQueue.Unlock()
+
+	s.updateQueue()
+}
+
+// dequeue stops the torrent and starts the next torrent waiting in the queue.
+func (s *Session) dequeue(t *torrent) {
+	s.mQueueUpdate.Lock()
+	s.mQueue.Lock()
+	t.queueState = queueStopped
+	t.queued.Store(false)
+	s.mQueue.Unlock()
+
+	t.Stop()
+	s.mQueueUpdate.Unlock()
+
+	s.updateQueue()
+}
+
+// updateQueue starts the waiting torrents that fit in the limits and queues the running torrents that exceed them.
+// Torrents that are earlier in the queue take the free slots first.
+func (s *Session) updateQueue() {
+	s.mQueueUpdate.Lock()
+	defer s.mQueueUpdate.Unlock()
+
+	// mQueue is not held while waiting for the run loops of the torrents
+	s.mQueue.Lock()
+	var torrents []*torrent
+	for _, t := range s.queuedTorrents() {
+		if t.queueState != queueStopped {
+			torrents = append(torrents, t)
+		}
+	}
+	s.mQueue.Unlock()
+
+	stats := make([]Stats, len(torrents))
+	for i, t := range torrents {
+		stats[i] = t.Stats()
+	}
+
+	limits := s.QueueLimits()
+	now := time.Now()
+	var start, stop []*torrent
+	var downloads, seeds int
+	s.mQueue.Lock()
+	for i, t := range torrents {
+		// Removed from the queue by Stop while the stats are requested
+		if t.queueState == queueStopped {
+			continue
+		}
+
+		if t.queueState == queueActive && (stats[i].Status == Stopped || stats[i].Status == Error) {
+			// Stopped by an error, a seed limit or StopAfterDownload
+			t.queueState = queueStopped
+			continue
+		}
+		if t.queueState == queueActive && limits.ExcludeSlow && isSlow(stats[i], limits, now.Sub(t.queueActiveSince)) {
+			continue
+		}
+
+		// Skipped files are not downloaded, so the bytes of a completed torrent may be incomplete
+		seed := t.completedFlag.Load()
+		fits := limits.Torrents <= 0 || downloads+seeds < limits.Torrents
+		if seed {
+			fits = fits && (limits.Seeds <= 0 || seeds < limits.Seeds)
+		} else {
+			fits = fits && (limits.Downloads <= 0 || downloads < limits.Downloads)
+		}
+
+		switch {
+		case fits && seed:
+			seeds++
+		case fits:
+			downloads++
+		}
+
+		switch {
+		case fits && t.queueState == queueWaiting:
+			s.log.Debug("starting queued torrent", "torrent", t.id)
+			t.queueState = queueActive
+			t.queueActiveSince = now
+			t.queued.Store(false)
+			start = append(start, t)
+		case !fits && t.queueState == queueActive:
+			s.log.Debug("queueing torrent", "torrent", t.id)
+			t.queueState = queueWaiting
+			t.queued.Store(true)
+			stop = append(stop, t)
+		}
+	}
+	s.mQueue.Unlock()
+
+	// Slots are freed before they are taken
+	for _, t := range stop {
+		t.Stop()
+	}
+	for _, t := range start {
+		t.Start()
+	}
+}
+
+// isSlow returns true if a torrent that is running for d is slower than the limits.
+func isSlow(stats Stats, limits QueueLimits, d time.Duration) bool {
+	return d >= limits.SlowTime &&
+		int64(stats.Speed.Download) < limits.SlowDownloadRate*1024 &&
+		int64(stats.Speed.Upload) < limits.SlowUploadRate*1024
+}
+
+// queuedTorrents returns the torrents in the Session in queue order.
+// Must be called with mQueue held.
+func (s *Session) queuedTorrents() []*torrent {
+	s.mTorrents.RLock()
+	defer s.mTorrents.RUnlock()
+
+	torrents := make([]*torrent, 0, len(s.torrents))
+	for _, id := range s.queue {
+		if t, ok := s.torrents[id]; ok {
+			torrents = append(torrents, t.torrent)
+		}
+	}
+	return torrents
+}
+
+// queuePosition returns the position of the torrent in the queue, starting from zero.
+func (s *Session) queuePosition(id string) int {
+	s.mQueue.Lock()
+	defer s.mQueue.Unlock()
+
+	for i, t := range s.queuedTorrents() {
+		if t.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveInQueue changes the queue position of the torrent, saves the queue and updates it.
+func (s *Session) moveInQueue(id string, m QueueMove) error {
+	s.mQueue.Lock()
+	// IDs of torrents that are not in the Session are dropped
+	torrents := s.queuedTorrents()
+	ids := make([]string, len(torrents))
+	for i, t := range torrents {
+		ids[i] = t.id
+	}
+
+	i := slices.Index(ids, id)
+	if i < 0 {
+		s.mQueue.Unlock()
+		return ErrTorrentNotFound
+	}
+
+	switch m {
+	case QueueUp:
+		if i > 0 {
+			ids[i-1], ids[i] = ids[i], ids[i-1]
+		}
+	case QueueDown:
+		if i < len(ids)-1 {
+			ids[i], ids[i+1] = ids[i+1], ids[i]
+		}
+	case QueueTop:
+		ids = slices.Insert(slices.Delete(ids, i, i+1), 0, id)
+	case QueueBottom:
+		ids = append(slices.Delete(ids, i, i+1), id)
+	default:
+		s.mQueue.Unlock()
+		return fmt.Errorf("invalid queue move: %d", m)
+	}
+
+	s.queue = ids
+	err := s.writeQueue()
+	s.mQueue.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.updateQueue()
+	return nil
+}
+
+// addToQueue puts a new torrent to the end of the queue.
+func (s *Session) addToQueue(id string) error {
+	s.mQueue.Lock()
+	defer s.mQueue.Unlock()
+
+	if slices.Contains(s.queue, id) {
+		return nil
+	}
+	s.queue = append(s.queue, id)
+	return s.writeQueue()
+}
+
+// removeFromQueue removes a removed torrent from the queue and starts the next torrent waiting in the queue.
+func (s *Session) removeFromQueue(id string) error {
+	s.mQueue.Lock()
+	s.queue = slices.DeleteFunc(s.queue, func(qid string) bool { return qid == id })
+	err := s.writeQueue()
+	s.mQueue.Unlock()
+
+	s.updateQueue()
+	return err
+}
+
+// writeQueue must be called with mQueue held.
+func (s *Session) writeQueue() error {
+	data, err := json.Marshal(s.queue)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sessionBucket).Put(queueKey, data)
+	})
+	s.metrics.resumeWrites.With("queue").ObserveSince(start)
+	return err
+}
+
+// queueManager updates the queue periodically and when torrents complete or stop by themselves.
+func (s *Session) queueManager() {
+	defer s.backgroundWG.Done()
+
+	ticker := time.NewTicker(queueUpdateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.updateQueue()
+		case <-s.queueUpdateC:
+			s.updateQueue()
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
+// notifyQueue makes the queueManager update the queue without blocking.
+// Notifications that arrive during an update are coalesced into one more update.
+func (s *Session) notifyQueue() {
+	select {
+	case s.queueUpdateC <- struct{}{}:
+	default:
+	}
+}
+
+// loadQueue reads the queue order and replaces the limits in the config with the limits saved by SetQueueLimits.
+func loadQueue(db *bbolt.DB, cfg *Config) (queue []string, err error) {
+	err = db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(sessionBucket)
+		if data := b.Get(queueKey); data != nil {
+			if err := json.Unmarshal(data, &queue); err != nil {
+				return err
+			}
+		}
+
+		data := b.Get(queueLimitsKey)
+		if data == nil {
+			return nil
+		}
+
+		var l QueueLimits
+		if err := json.Unmarshal(data, &l); err != nil {
+			return err
+		}
+		cfg.QueueMaxActiveDownloads = l.Downloads
+		cfg.QueueMaxActiveSeeds = l.Seeds
+		cfg.QueueMaxActiveTorrents = l.Torrents
+		cfg.QueueExcludeSlowTorrents = l.ExcludeSlow
+		cfg.QueueSlowDownloadRate = l.SlowDownloadRate
+		cfg.QueueSlowUploadRate = l.SlowUploadRate
+		cfg.QueueSlowTime = l.SlowTime
+		return nil
+	})
+	return
+}
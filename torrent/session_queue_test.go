@@ -0,0 +1,123 @@
+package torrent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueDownloads(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.QueueMaxActiveDownloads = 1
+	s := newTestSession(t, cfg)
+
+	var torrents []*Torrent
+	for i := 0; i < 3; i++ {
+		tor := addTestTorrent(t, s, testTorrent{})
+		require.NoError(t, tor.Start())
+		assert.Equal(t, i, tor.QueuePosition())
+		torrents = append(torrents, tor)
+	}
+	assert.NotEqual(t, Queued, torrents[0].Stats().Status)
+	assert.Equal(t, Queued, torrents[1].Stats().Status)
+	assert.Equal(t, Queued, torrents[2].Stats().Status)
+
+	// The first torrent in the queue takes the slot
+	require.NoError(t, torrents[2].MoveInQueue(QueueTop))
+	assert.Equal(t, 0, torrents[2].QueuePosition())
+	assert.Equal(t, 1, torrents[0].QueuePosition())
+	assert.Equal(t, Queued, torrents[0].Stats().Status)
+	assert.NotEqual(t, Queued, torrents[2].Stats().Status)
+
+	require.NoError(t, torrents[2].Stop())
+	assert.Equal(t, Stopped, torrents[2].Stats().Status)
+	assert.NotEqual(t, Queued, torrents[0].Stats().Status)
+	assert.Equal(t, Queued, torrents[1].Stats().Status)
+
+	require.NoError(t, torrents[1].MoveInQueue(QueueUp))
+	require.NoError(t, torrents[2].MoveInQueue(QueueBottom))
+	require.NoError(t, torrents[2].MoveInQueue(QueueDown))
+	assert.Equal(t, 0, torrents[1].QueuePosition())
+	assert.Equal(t, 1, torrents[0].QueuePosition())
+	assert.Equal(t, 2, torrents[2].QueuePosition())
+
+	// Stopping a queued torrent does not start it
+	require.NoError(t, torrents[0].Stop())
+	assert.Equal(t, Stopped, torrents[0].Stats().Status)
+	assert.NotEqual(t, Queued, torrents[1].Stats().Status)
+
+	require.NoError(t, s.RemoveTorrent(torrents[1].ID()))
+	assert.Equal(t, 0, torrents[0].QueuePosition())
+	s.Close()
+
+	// Queue order is saved in the database
+	s = newTestSession(t, cfg)
+	defer s.Close()
+	assert.Equal(t, []string{torrents[0].ID(), torrents[2].ID()}, s.queue)
+}
+
+func TestQueueSeeds(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.QueueMaxActiveSeeds = 1
+	s := newTestSession(t, cfg)
+	defer s.Close()
+
+	first := addTestTorrent(t, s, testTorrent{Name: "first.bin", Complete: true, Options: &AddTorrentOptions{}})
+	second := addTestTorrent(t, s, testTorrent{Name: "second.bin", Complete: true, Options: &AddTorrentOptions{}})
+
+	// Both are started to be verified, the second one is queued when it is completed without waiting for the next update
+	require.Eventually(t, func() bool {
+		return first.Stats().Status == Seeding && second.Stats().Status == Queued
+	}, queueUpdateInterval/2, 10*time.Millisecond)
+	assert.Equal(t, 1.0, second.Stats().Progress)
+
+	require.NoError(t, first.Stop())
+	assert.Equal(t, Seeding, second.Stats().Status)
+}
+
+func TestQueueSkippedFiles(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.QueueMaxActiveDownloads = 1
+	s := newTestSession(t, cfg)
+	defer s.Close()
+
+	// The torrent is completed without the skipped file
+	partial := addTestTorrent(t, s, testTorrent{Name: "partial", Sizes: []int{testPieceLength, testPieceLength}, Complete: true})
+	require.NoError(t, partial.SetFilePriorities([]FilePriority{PriorityNormal, PrioritySkip}))
+	require.NoError(t, os.Remove(filepath.Join(s.config.DataDir, "partial", "b.bin")))
+	require.NoError(t, partial.Start())
+	waitStatus(t, partial, Seeding)
+	assert.Less(t, partial.Stats().Progress, 1.0)
+
+	// It does not take the slot of downloads
+	download := addTestTorrent(t, s, testTorrent{Name: "download"})
+	require.NoError(t, download.Start())
+	assert.NotEqual(t, Queued, download.Stats().Status)
+	assert.Equal(t, Seeding, partial.Stats().Status)
+}
+
+func TestQueueExcludeSlow(t *testing.T) {
+	cfg := testConfig(t)
+	s := newTestSession(t, cfg)
+	defer s.Close()
+
+	limits := QueueLimits{Torrents: 1, ExcludeSlow: true, SlowDownloadRate: 1, SlowUploadRate: 1, SlowTime: time.Hour}
+	require.NoError(t, s.SetQueueLimits(limits))
+	assert.Equal(t, limits, s.QueueLimits())
+
+	first := addTestTorrent(t, s, testTorrent{})
+	second := addTestTorrent(t, s, testTorrent{})
+	require.NoError(t, first.Start())
+	require.NoError(t, second.Start())
+	assert.Equal(t, Queued, second.Stats().Status)
+
+	// Torrents without transfers are slow after the slow time
+	limits.SlowTime = 0
+	require.NoError(t, s.SetQueueLimits(limits))
+	assert.NotEqual(t, Queued, first.Stats().Status)
+	assert.NotEqual(t, Queued, second.Stats().Status)
+}
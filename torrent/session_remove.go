@@ -0,0 +1,36 @@
+package torrent
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrTorrentNotFound = errors.New("torrent not found")
+
+// RemoveTorrent stops the torrent and removes it from the Session.
+// Downloaded files are not deleted.
+func (s *Session) RemoveTorrent(id string) error {
+	s.mTorrents.Lock()
+	t, ok := s.torrents[id]
+	if !ok {
+		s.mTorrents.Unlock()
+		return ErrTorrentNotFound
+	}
+	delete(s.torrents, id)
+	s.mTorrents.Unlock()
+
+	t.torrent.Close()
+	s.releasePort(int(t.torrent.port.Load()))
+	// The torrent is gone from the Session even if its record cannot be deleted from the database
+	t.torrent.publish(Event{Type: TorrentRemoved})
+
+	err := s.removeFromQueue(id)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = s.resumer.Delete(id)
+	s.metrics.resumeWrites.With("delete").ObserveSince(start)
+	return err
+}
@@ -0,0 +1,158 @@
+package torrent
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var seedLimitsKey = []byte("seed-limits")
+
+// SeedLimitAction is done to a torrent when one of its seed limits is reached.
+type SeedLimitAction int
+
+const (
+	// SeedLimitDefault uses the action of the Session for a torrent. The Session stops torrents by default.
+	SeedLimitDefault SeedLimitAction = iota
+	// SeedLimitStop stops the torrent.
+	SeedLimitStop
+	// SeedLimitRemove removes the torrent from the Session. Downloaded files are kept.
+	SeedLimitRemove
+)
+
+var seedLimitActionNames = [...]string{
+	"",
+	"stop",
+	"remove",
+}
+
+func (a SeedLimitAction) String() string {
+	if a < 0 || int(a) >= len(seedLimitActionNames) {
+		return "unknown"
+	}
+	return seedLimitActionNames[a]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (a SeedLimitAction) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (a *SeedLimitAction) UnmarshalText(b []byte) error {
+	i := slices.Index(seedLimitActionNames[:], string(b))
+	if i < 0 {
+		return fmt.Errorf("invalid seed limit action %q", b)
+	}
+	*a = SeedLimitAction(i)
+	return nil
+}
+
+// SeedLimits are the goals of seeding a completed torrent. The Action is done when any of the limits is reached.
+// Zero disables a limit of the Session. Zero limits of a torrent are taken from the Session and negative limits disable them.
+type SeedLimits struct {
+	// Uploaded bytes divided by downloaded bytes, or by the completed bytes if nothing is downloaded.
+	Ratio float64
+	// Total time spent in seeding status.
+	Time time.Duration
+	// Time spent in seeding status without uploading.
+	IdleTime time.Duration
+	Action   SeedLimitAction
+}
+
+// merge returns the limits of a torrent with the missing values taken from the limits of the Session in d.
+func (l SeedLimits) merge(d SeedLimits) SeedLimits {
+	if l.Ratio == 0 {
+		l.Ratio = d.Ratio
+	}
+	if l.Time == 0 {
+		l.Time = d.Time
+	}
+	if l.IdleTime == 0 {
+		l.IdleTime = d.IdleTime
+	}
+	if l.Action == SeedLimitDefault {
+		l.Action = d.Action
+	}
+	if l.Action == SeedLimitDefault {
+		l.Action = SeedLimitStop
+	}
+	return l
+}
+
+// SetSeedLimits changes the seed limits of the Session that apply to all torrents without their own limits.
+// The limits are saved in the database and checked for seeding torrents immediately.
+func (s *Session) SetSeedLimits(l SeedLimits) error {
+	if l.Action < SeedLimitDefault || l.Action > SeedLimitRemove {
+		return fmt.Errorf("invalid seed limit action: %d", l.Action)
+	}
+
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	s.mSeedLimits.Lock()
+	start := time.Now()
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sessionBucket).Put(seedLimitsKey, data)
+	})
+	s.metrics.resumeWrites.With("session_seed_limits").ObserveSince(start)
+	if err != nil {
+		s.mSeedLimits.Unlock()
+		return err
+	}
+	s.config.SeedRatioLimit = l.Ratio
+	s.config.SeedTimeLimit = l.Time
+	s.config.SeedIdleLimit = l.IdleTime
+	s.config.SeedLimitAction = l.Action.String()
+	s.mSeedLimits.Unlock()
+
+	for _, t := range s.ListTorrents() {
+		t.torrent.checkSeedLimits()
+	}
+	return nil
+}
+
+// SeedLimits returns the seed limits of the Session.
+func (s *Session) SeedLimits() SeedLimits {
+	s.mSeedLimits.RLock()
+	defer s.mSeedLimits.RUnlock()
+
+	l := SeedLimits{
+		Ratio:    s.config.SeedRatioLimit,
+		Time:     s.config.SeedTimeLimit,
+		IdleTime: s.config.SeedIdleLimit,
+	}
+	// The action is validated when the Session is created
+	_ = l.Action.UnmarshalText([]byte(s.config.SeedLimitAction))
+	return l
+}
+
+// loadSeedLimits replaces the limits in the config with the limits saved by SetSeedLimits.
+func loadSeedLimits(db *bbolt.DB, cfg *Config) error {
+	var action SeedLimitAction
+	if err := action.UnmarshalText([]byte(cfg.SeedLimitAction)); err != nil {
+		return err
+	}
+
+	return db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(sessionBucket).Get(seedLimitsKey)
+		if data == nil {
+			return nil
+		}
+
+		var l SeedLimits
+		if err := json.Unmarshal(data, &l); err != nil {
+			return err
+		}
+		cfg.SeedRatioLimit = l.Ratio
+		cfg.SeedTimeLimit = l.Time
+		cfg.SeedIdleLimit = l.IdleTime
+		cfg.SeedLimitAction = l.Action.String()
+		return nil
+	})
+}
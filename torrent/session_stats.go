@@ -0,0 +1,83 @@
+package torrent
+
+import "time"
+
+// SessionStats contains statistics about Session.
+type SessionStats struct {
+	// Number of torrents in Session.
+	Torrents int
+	// Number of torrents by status.
+	TorrentsByStatus map[Status]int
+	// Total number of connected peers.
+	Peers int
+	// Number of available ports for new torrents.
+	PortsAvailable int
+	// Number of ports that are reserved by torrents in Session.
+	PortsInUse int
+
+	// Number of rules in blocklist.
+	BlockListRules int
+	// Number of lines in the blocklist that cannot be parsed.
+	BlockListRejectedLines int
+	// Time elapsed after the blocklist is loaded. Zero if the blocklist is never loaded.
+	BlockListRecency time.Duration
+
+	// Sum of the transfer counters of all torrents.
+	BytesDownloaded int64
+	BytesUploaded   int64
+	BytesWasted     int64
+
+	// Sum of the speeds of all torrents in bytes per second.
+	SpeedDownload int
+	SpeedUpload   int
+	// True if the alternative speed limits are used instead of the normal speed limits.
+	AltSpeedActive bool
+
+	// Time elapsed after creation of the Session object.
+	Uptime time.Duration
+}
+
+// Stats returns global statistics of the Session.
+func (s *Session) Stats() SessionStats {
+	s.mTorrents.RLock()
+	torrents := make([]*Torrent, 0, len(s.torrents))
+	for _, t := range s.torrents {
+		torrents = append(torrents, t)
+	}
+	s.mTorrents.RUnlock()
+
+	stats := SessionStats{
+		Torrents:         len(torrents),
+		TorrentsByStatus: make(map[Status]int),
+		Uptime:           time.Since(s.createdAt),
+	}
+
+	for _, t := range torrents {
+		ts := t.Stats()
+		stats.TorrentsByStatus[ts.Status]++
+		stats.Peers += ts.Peers.Total
+		stats.BytesDownloaded += ts.Bytes.Downloaded
+		stats.BytesUploaded += ts.Bytes.Uploaded
+		stats.BytesWasted += ts.Bytes.Wasted
+		stats.SpeedDownload += ts.Speed.Download
+		stats.SpeedUpload += ts.Speed.Upload
+	}
+
+	s.mPorts.RLock()
+	stats.PortsAvailable = len(s.availablePorts)
+	s.mPorts.RUnlock()
+	stats.PortsInUse = int(s.config.PortEnd-s.config.PortBegin) - stats.PortsAvailable
+
+	s.mBlocklist.RLock()
+	blStats := s.blocklist.Stats()
+	stats.BlockListRules = blStats.Parsed
+	stats.BlockListRejectedLines = blStats.Rejected
+	if !s.blocklistTimestamp.IsZero() {
+		stats.BlockListRecency = time.Since(s.blocklistTimestamp)
+	}
+	s.mBlocklist.RUnlock()
+
+	stats.AltSpeedActive = s.AltSpeedActive()
+
+	return stats
+}
@@ -0,0 +1,59 @@
+package torrent
+
+import (
+	"testing"
+
+	"github.com/al002/zbittorrent/internal/resumer/boltdbresumer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func TestSessionStats(t *testing.T) {
+	cfg := testConfig(t)
+	s := newTestSession(t, cfg)
+
+	stopped := addTestTorrent(t, s, testTorrent{Name: "stopped"})
+	seeding := addTestTorrent(t, s, testTorrent{Name: "seeding", Complete: true, Options: &AddTorrentOptions{}})
+	waitStatus(t, seeding, Seeding)
+
+	stats := s.Stats()
+	assert.Equal(t, 2, stats.Torrents)
+	assert.Equal(t, map[Status]int{Stopped: 1, Seeding: 1}, stats.TorrentsByStatus)
+	assert.Equal(t, 2, stats.PortsInUse)
+	assert.Equal(t, int(cfg.PortEnd-cfg.PortBegin)-2, stats.PortsAvailable)
+	assert.Equal(t, int64(0), stats.BytesDownloaded)
+	assert.Positive(t, stats.Uptime)
+
+	s.Close()
+
+	// Transfer counters of the torrents are summed
+	db, err := bbolt.Open(cfg.Database, 0o600, nil)
+	require.NoError(t, err)
+	res, err := boltdbresumer.New(db, torrentsBucket)
+	require.NoError(t, err)
+	require.NoError(t, res.WriteStats(stopped.ID(), boltdbresumer.Stats{BytesDownloaded: 100, BytesUploaded: 10, BytesWasted: 1}))
+	require.NoError(t, res.WriteStats(seeding.ID(), boltdbresumer.Stats{BytesDownloaded: 200, BytesUploaded: 20, BytesWasted: 2}))
+	require.NoError(t, db.Close())
+
+	s = newTestSession(t, cfg)
+	defer s.Close()
+	waitStatus(t, s.GetTorrent(seeding.ID()), Seeding)
+	st := s.GetTorrent(stopped.ID()).Stats()
+	assert.Equal(t, int64(100), st.Bytes.Downloaded)
+	assert.Equal(t, int64(10), st.Bytes.Uploaded)
+	assert.Equal(t, int64(1), st.Bytes.Wasted)
+	assert.Equal(t, 0.1, st.Ratio)
+
+	stats = s.Stats()
+	assert.Equal(t, map[Status]int{Stopped: 1, Seeding: 1}, stats.TorrentsByStatus)
+	assert.Equal(t, int64(300), stats.BytesDownloaded)
+	assert.Equal(t, int64(30), stats.BytesUploaded)
+	assert.Equal(t, int64(3), stats.BytesWasted)
+
+	require.NoError(t, s.RemoveTorrent(stopped.ID()))
+	stats = s.Stats()
+	assert.Equal(t, 1, stats.Torrents)
+	assert.Equal(t, 1, stats.PortsInUse)
+	assert.Equal(t, int64(200), stats.BytesDownloaded)
+}
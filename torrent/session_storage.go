@@ -3,6 +3,7 @@ package torrent
 import (
 	"io/fs"
 
+	"github.com/al002/zbittorrent/internal/metrics"
 	"github.com/al002/zbittorrent/internal/storage"
 	"github.com/al002/zbittorrent/internal/storage/filestorage"
 )
@@ -10,17 +11,25 @@ import (
 type fileStorageProvider struct {
 	DataDir         string
 	FilePermissions fs.FileMode
+	// Latency of file operations are observed here
+	DiskLatency *metrics.HistogramVec
 }
 
-func newFileStorageProvider(cfg *Config) *fileStorageProvider {
+func newFileStorageProvider(cfg *Config, diskLatency *metrics.HistogramVec) *fileStorageProvider {
 	return &fileStorageProvider{
 		DataDir:         cfg.DataDir,
 		FilePermissions: cfg.FilePermissions,
+		DiskLatency:     diskLatency,
 	}
 }
 
 func (p *fileStorageProvider) GetStorage(torrentID string) (storage.Storage, error) {
-	return filestorage.New(p.getDataDir(torrentID), p.FilePermissions)
+	sto, err := filestorage.New(p.getDataDir(torrentID), p.FilePermissions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &timedStorage{Storage: sto, latency: p.DiskLatency}, nil
 }
 
 func (p *fileStorageProvider) getDataDir(torrentID string) string {
@@ -0,0 +1,103 @@
+package torrent
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/log"
+	"github.com/al002/zbittorrent/pkg/metainfo"
+	"github.com/stretchr/testify/require"
+)
+
+const testPieceLength = metainfo.MinPieceLength
+
+var testLogger = log.Logger{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+// testConfig returns the default config with the database and the data directory in a temporary directory of the test.
+func testConfig(t *testing.T) Config {
+	cfg := DefaultConfig
+	dir := t.TempDir()
+	cfg.Database = filepath.Join(dir, "session.db")
+	cfg.DataDir = filepath.Join(dir, "data")
+	// Sessions of tests must not compete for the RPC port
+	cfg.RPCEnabled = false
+	return cfg
+}
+
+func newTestSession(t *testing.T, cfg Config) *Session {
+	t.Helper()
+	s, err := NewSession(cfg, testLogger)
+	require.NoError(t, err)
+	return s
+}
+
+// testTorrent describes a torrent created by addTestTorrent.
+type testTorrent struct {
+	// Name of the file, or of the directory if there are multiple files. Default is "test".
+	Name string
+	// Sizes of the files. A single file torrent of 4 pieces is created if empty.
+	// Files of multi file torrents are named a.bin, b.bin and so on.
+	Sizes []int
+	// Padding aligns the files to the piece boundaries.
+	Padding bool
+	// Complete writes the data into the data directory of the session, so the torrent is complete after it is verified.
+	Complete bool
+	// Options of AddTorrent. Default is to add the torrent stopped.
+	Options *AddTorrentOptions
+}
+
+// addTestTorrent writes the files of tt, builds a torrent of them and adds it to the session.
+func addTestTorrent(t *testing.T, s *Session, tt testTorrent) *Torrent {
+	t.Helper()
+	opts := tt.Options
+	if opts == nil {
+		opts = &AddTorrentOptions{Stopped: true}
+	}
+
+	tor, err := s.AddTorrent(bytes.NewReader(newTestTorrentFile(t, s, tt)), opts)
+	require.NoError(t, err)
+	return tor
+}
+
+// newTestTorrentFile writes the files of tt and returns the content of a torrent file of them.
+func newTestTorrentFile(t *testing.T, s *Session, tt testTorrent) []byte {
+	t.Helper()
+	if tt.Name == "" {
+		tt.Name = "test"
+	}
+	if len(tt.Sizes) == 0 {
+		tt.Sizes = []int{4*testPieceLength - 100}
+	}
+
+	dir := t.TempDir()
+	if tt.Complete {
+		dir = s.config.DataDir
+	}
+	path := filepath.Join(dir, tt.Name)
+	if len(tt.Sizes) == 1 {
+		require.NoError(t, os.MkdirAll(dir, 0o750))
+		require.NoError(t, os.WriteFile(path, bytes.Repeat([]byte{'a'}, tt.Sizes[0]), 0o640))
+	} else {
+		require.NoError(t, os.MkdirAll(path, 0o750))
+		for i, size := range tt.Sizes {
+			data := bytes.Repeat([]byte{byte('a' + i)}, size)
+			require.NoError(t, os.WriteFile(filepath.Join(path, string(rune('a'+i))+".bin"), data, 0o640))
+		}
+	}
+
+	mi, err := (&metainfo.Builder{Path: path, PieceLength: testPieceLength, Padding: tt.Padding}).Build()
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, mi.Write(&buf))
+	return buf.Bytes()
+}
+
+func waitStatus(t *testing.T, tor *Torrent, status Status) {
+	t.Helper()
+	require.Eventually(t, func() bool { return tor.Stats().Status == status }, 5*time.Second, 10*time.Millisecond)
+}
@@ -1,10 +1,265 @@
 package torrent
 
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/resumer/boltdbresumer"
+)
+
 type Torrent struct {
 	torrent *torrent
 }
 
+// ID is a unique identifier in the Session.
+func (t *Torrent) ID() string {
+	return t.torrent.id
+}
+
+// Start the torrent. Does not block.
+// The torrent waits in Queued status if the queue limits of the Session are reached.
+// Started torrents are started again when the Session is created.
 func (t *Torrent) Start() error {
-	t.torrent.Start()
+	err := t.writeStarted(true)
+	if err != nil {
+		return err
+	}
+
+	t.torrent.session.enqueue(t.torrent)
+	return nil
+}
+
+// Stop the torrent. Does not block. After Stop is called, the torrent switches into Stopped status.
+func (t *Torrent) Stop() error {
+	err := t.writeStarted(false)
+	if err != nil {
+		return err
+	}
+
+	t.torrent.session.dequeue(t.torrent)
+	return nil
+}
+
+func (t *Torrent) writeStarted(value bool) error {
+	s := t.torrent.session
+	start := time.Now()
+	err := s.resumer.WriteStarted(t.torrent.id, value)
+	s.metrics.resumeWrites.With("started").ObserveSince(start)
+	return err
+}
+
+// QueuePosition returns the position of the torrent in the queue of the Session, starting from zero.
+func (t *Torrent) QueuePosition() int {
+	return t.torrent.session.queuePosition(t.torrent.id)
+}
+
+// MoveInQueue changes the position of the torrent in the queue of the Session and saves the queue in the database.
+func (t *Torrent) MoveInQueue(m QueueMove) error {
+	return t.torrent.session.moveInQueue(t.torrent.id, m)
+}
+
+// Verify checks the hashes of the pieces on the disk again. Does not block.
+func (t *Torrent) Verify() error {
+	t.torrent.Verify()
+	return nil
+}
+
+// Stats returns statistics about the torrent.
+func (t *Torrent) Stats() Stats {
+	return t.torrent.Stats()
+}
+
+// Name of the torrent.
+func (t *Torrent) Name() string {
+	return t.torrent.Name()
+}
+
+// InfoHash returns the 20-bytes SHA-1 hash of the info dictionary of the torrent.
+func (t *Torrent) InfoHash() []byte {
+	return t.torrent.InfoHash()
+}
+
+// AddedAt returns the time that the torrent is added.
+func (t *Torrent) AddedAt() time.Time {
+	return t.torrent.addedAt
+}
+
+// Port returns the TCP port number that the torrent is listening for peers.
+func (t *Torrent) Port() int {
+	return int(t.torrent.port.Load())
+}
+
+// Trackers returns the trackers of the torrent with their announce status.
+func (t *Torrent) Trackers() []Tracker {
+	return t.torrent.Trackers()
+}
+
+// Peers returns the peers connected to the torrent.
+func (t *Torrent) Peers() []Peer {
+	return t.torrent.Peers()
+}
+
+// Files returns the files in the torrent. Returns error if metadata of the torrent is not downloaded yet.
+func (t *Torrent) Files() ([]File, error) {
+	return t.torrent.Files()
+}
+
+// FilePriorities returns the priority of each file in the order of Files().
+// Returns error if metadata of the torrent is not downloaded yet.
+func (t *Torrent) FilePriorities() ([]FilePriority, error) {
+	return t.torrent.FilePriorities()
+}
+
+// SetFilePriorities changes the priorities of the files and saves them in the database.
+// There must be one priority for each file in Files().
+// Skipped files are not created when the files are opened on the first start of the torrent,
+// pieces that they share with other files are kept in a part file next to them.
+// A skipped file that is wanted again is created then, and the data in its part file is moved into it.
+func (t *Torrent) SetFilePriorities(l []FilePriority) error {
+	current, err := t.torrent.FilePriorities()
+	if err != nil {
+		return err
+	}
+	if len(l) != len(current) {
+		return fmt.Errorf("torrent has %d files, got %d priorities", len(current), len(l))
+	}
+
+	names := make([]string, len(l))
+	for i, p := range l {
+		if p < PrioritySkip || p > PriorityHigh {
+			return fmt.Errorf("invalid file priority: %d", p)
+		}
+		names[i] = p.String()
+	}
+
+	s := t.torrent.session
+	start := time.Now()
+	err = s.resumer.WriteFilePriorities(t.torrent.id, names)
+	s.metrics.resumeWrites.With("file_priorities").ObserveSince(start)
+	if err != nil {
+		return err
+	}
+
+	t.torrent.SetFilePriorities(slices.Clone(l))
+	return nil
+}
+
+// Sequential returns true if the pieces of the torrent are downloaded in order instead of rarest first.
+func (t *Torrent) Sequential() bool {
+	return t.torrent.Sequential()
+}
+
+// SetSequential changes whether the pieces are downloaded in order and saves it in the database.
+// File priorities and piece deadlines still take precedence over the order of the pieces.
+func (t *Torrent) SetSequential(value bool) error {
+	s := t.torrent.session
+	start := time.Now()
+	err := s.resumer.WriteSequential(t.torrent.id, value)
+	s.metrics.resumeWrites.With("sequential").ObserveSince(start)
+	if err != nil {
+		return err
+	}
+
+	t.torrent.SetSequential(value)
+	return nil
+}
+
+// SetPieceDeadline makes the piece requested before the other pieces, earliest deadline first.
+// When the deadline is near the piece may be requested from more than one fast peer.
+// Zero time clears the deadline. Deadlines are not saved in the database.
+// Returns error if metadata of the torrent is not downloaded yet or the piece does not exist.
+func (t *Torrent) SetPieceDeadline(piece uint32, deadline time.Time) error {
+	info := t.torrent.info
+	if info == nil {
+		return errMetadataNotReady
+	}
+	if piece >= info.NumPieces {
+		return fmt.Errorf("invalid piece index: %d", piece)
+	}
+
+	t.torrent.SetPieceDeadline(piece, deadline)
+	return nil
+}
+
+// Bitfield returns the pieces that are downloaded and verified, one bit for each piece starting from the high bit of the first byte.
+// Returns nil if the pieces are not known yet.
+func (t *Torrent) Bitfield() []byte {
+	return t.torrent.Bitfield()
+}
+
+// IPFilter returns the allow and deny rules of the torrent.
+func (t *Torrent) IPFilter() IPFilter {
+	return t.torrent.IPFilter()
+}
+
+// SetIPFilter replaces the allow and deny rules of the torrent and saves them in the database.
+// The rules are applied to peer addresses from trackers, DHT and PEX and to incoming connections.
+func (t *Torrent) SetIPFilter(rules IPFilter) error {
+	f, err := newIPFilter(rules)
+	if err != nil {
+		return err
+	}
+
+	s := t.torrent.session
+	start := time.Now()
+	err = s.resumer.WriteIPFilter(t.torrent.id, rules.Allow, rules.Deny)
+	s.metrics.resumeWrites.With("ip_filter").ObserveSince(start)
+	if err != nil {
+		return err
+	}
+
+	t.torrent.SetIPFilter(f)
+	return nil
+}
+
+// SpeedLimits returns the speed limits of the torrent.
+func (t *Torrent) SpeedLimits() SpeedLimits {
+	return t.torrent.SpeedLimits()
+}
+
+// SetSpeedLimits changes the speed limits of the torrent and saves them in the database.
+// Peer limits that are zero are taken from the Session.
+// The limits are applied without restarting the torrent.
+func (t *Torrent) SetSpeedLimits(l SpeedLimits) error {
+	s := t.torrent.session
+	start := time.Now()
+	err := s.resumer.WriteSpeedLimits(t.torrent.id, boltdbresumer.SpeedLimits(l))
+	s.metrics.resumeWrites.With("speed_limits").ObserveSince(start)
+	if err != nil {
+		return err
+	}
+
+	t.torrent.SetSpeedLimits(l)
+	return nil
+}
+
+// SeedLimits returns the seed limits of the torrent.
+func (t *Torrent) SeedLimits() SeedLimits {
+	return t.torrent.SeedLimits()
+}
+
+// SetSeedLimits changes the seed limits of the torrent and saves them in the database.
+// Zero limits are taken from the Session and negative limits are disabled for the torrent.
+// The limits are checked immediately if the torrent is seeding.
+func (t *Torrent) SetSeedLimits(l SeedLimits) error {
+	if l.Action < SeedLimitDefault || l.Action > SeedLimitRemove {
+		return fmt.Errorf("invalid seed limit action: %d", l.Action)
+	}
+
+	s := t.torrent.session
+	start := time.Now()
+	err := s.resumer.WriteSeedLimits(t.torrent.id, boltdbresumer.SeedLimits{
+		Ratio:    l.Ratio,
+		Time:     l.Time,
+		IdleTime: l.IdleTime,
+		Action:   l.Action.String(),
+	})
+	s.metrics.resumeWrites.With("seed_limits").ObserveSince(start)
+	if err != nil {
+		return err
+	}
+
+	t.torrent.SetSeedLimits(l)
 	return nil
 }
@@ -4,16 +4,25 @@ import (
 	"crypto/rand"
 	"errors"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/al002/zbittorrent/internal/acceptor"
 	"github.com/al002/zbittorrent/internal/allocator"
 	"github.com/al002/zbittorrent/internal/announcer"
+	"github.com/al002/zbittorrent/internal/bitfield"
 	"github.com/al002/zbittorrent/internal/log"
-	"github.com/al002/zbittorrent/internal/metainfo"
 	"github.com/al002/zbittorrent/internal/mse"
+	"github.com/al002/zbittorrent/internal/peer"
+	"github.com/al002/zbittorrent/internal/piece"
+	"github.com/al002/zbittorrent/internal/piecepicker"
+	"github.com/al002/zbittorrent/internal/ratelimit"
+	"github.com/al002/zbittorrent/internal/speedmeter"
 	"github.com/al002/zbittorrent/internal/storage"
 	"github.com/al002/zbittorrent/internal/tracker"
+	"github.com/al002/zbittorrent/internal/verifier"
+	"github.com/al002/zbittorrent/pkg/metainfo"
 )
 
 type torrent struct {
@@ -35,10 +44,23 @@ type torrent struct {
 
 	completeC chan struct{}
 
-	port int
+	// Written by the run loop when the acceptor listens on a random port, read by the Session
+	port atomic.Int32
 
 	// last error sent to errC
 	lastError error
+	// Why the torrent is stopped last time
+	stopReason StopReason
+	// Stop the torrent when the download is completed
+	stopAfterDownload bool
+
+	// State in the queue of the Session, guarded by Session.mQueue
+	queueState       queueState
+	queueActiveSince time.Time
+	// Started but waiting in the queue
+	queued atomic.Bool
+	// Same as completed, for reading outside of the run loop
+	completedFlag atomic.Bool
 
 	// Channels for sending a message to run() loop
 	trackersCommandC    chan trackersRequest   // Trackers()
@@ -46,12 +68,28 @@ type torrent struct {
 	stopCommandC        chan struct{}          // Stop()
 	announceCommandC    chan struct{}          // Announce()
 	addTrackersCommandC chan []tracker.Tracker // AddTrackers()
+	statsCommandC       chan statsRequest      // Stats()
+	peersCommandC       chan peersRequest      // Peers()
+	verifyCommandC      chan struct{}          // Verify()
+	bitfieldCommandC    chan bitfieldRequest   // Bitfield()
+
+	peerSpeedLimitsCommandC chan peerSpeedLimitsRequest // SetSpeedLimits() of torrent or Session
+	seedLimitsCommandC      chan seedLimitsRequest      // SetSeedLimits() of torrent or Session
+	filePrioritiesCommandC  chan filePrioritiesRequest  // SetFilePriorities()
+	pickerCommandC          chan pickerRequest          // SetSequential() and SetPieceDeadline()
 
 	// Trackers send announce responses to this channel
 	announcePeersC chan []*net.TCPAddr
 
+	// Allow and deny rules for peer addresses, nil if the torrent has no rules
+	ipFilter atomic.Pointer[ipFilter]
+	// Number of peer addresses rejected by the blocklist and ipFilter
+	rejectedPeers map[FilterReason]int
+
 	// Announces the status of torrent to trackers to get peer addresses periodically.
-	announcers            []*announcer.PeriodicalAnnouncer
+	announcers []*announcer.PeriodicalAnnouncer
+	// Announcers of the v2 swarm of a hybrid torrent
+	announcersV2          []*announcer.PeriodicalAnnouncer
 	stoppedEventAnnouncer *announcer.StopAnnouncer
 
 	// A signal sent to run() loop when announcers are stopped
@@ -73,6 +111,68 @@ type torrent struct {
 	allocatorResultC   chan *allocator.Allocator
 	bytesAllocated     int64
 
+	// Opened files and pieces mapped over them. Set when allocation is done.
+	files  []allocator.File
+	pieces []piece.Piece
+
+	// A worker that checks hashes of the pieces that exist on the disk
+	verifier          *verifier.Verifier
+	verifierProgressC chan verifier.Progress
+	verifierResultC   chan *verifier.Verifier
+	checkedPieces     uint32
+	// Torrent is started only for verification, stop it when the verification is done
+	stopAfterVerify bool
+
+	// Pieces we have. Nil until allocation and verification is done.
+	bitfield *bitfield.Bitfield
+	// All pieces are downloaded and verified
+	completed bool
+
+	// Peers that completed the handshake
+	peers map[*peer.Peer]struct{}
+
+	// Transfer counters and speed meters
+	bytesDownloaded int64
+	bytesUploaded   int64
+	bytesWasted     int64
+	downloadSpeed   *speedmeter.SpeedMeter
+	uploadSpeed     *speedmeter.SpeedMeter
+	// Saves the transfer counters periodically while running
+	statsWriteTicker  *time.Ticker
+	statsWriteTickerC <-chan time.Time
+
+	// Speed limits of the torrent, limited by the global limiters of the Session
+	mSpeedLimits    sync.RWMutex
+	speedLimits     SpeedLimits
+	downloadLimiter *ratelimit.Limiter
+	uploadLimiter   *ratelimit.Limiter
+
+	// Total time spent in seeding status, excluding the current seeding period
+	seededFor time.Duration
+	// Start of the current seeding period, zero if not seeding
+	seedingSince time.Time
+
+	// Seed limits of the torrent, merged with the seed limits of the Session
+	mSeedLimits sync.RWMutex
+	seedLimits  SeedLimits
+	// Checks the seed limits periodically while seeding
+	seedLimitsTicker  *time.Ticker
+	seedLimitsTickerC <-chan time.Time
+	// Last time an upload is seen while seeding, and the upload counter at that time
+	seedIdleSince    time.Time
+	seedIdleUploaded int64
+
+	// Priorities of the files that are not padding, missing entries are PriorityNormal
+	mFilePriorities sync.RWMutex
+	filePriorities  []FilePriority
+
+	// Decides the order of the pieces to download, nil until the pieces that we have are known
+	picker *piecepicker.PiecePicker
+	// Download pieces in order instead of rarest first
+	sequential atomic.Bool
+	// Pieces to download before the others, kept for the next picker when the pieces are verified again
+	pieceDeadlines map[uint32]time.Time
+
 	log log.Logger
 }
 
@@ -103,7 +203,6 @@ func newTorrent(
 		info:                info,
 		trackers:            trackers,
 		name:                name,
-		port:                port,
 		completeC:           make(chan struct{}),
 		closeC:              make(chan struct{}),
 		doneC:               make(chan struct{}),
@@ -112,19 +211,40 @@ func newTorrent(
 		trackersCommandC:    make(chan trackersRequest),
 		addTrackersCommandC: make(chan []tracker.Tracker),
 		announceCommandC:    make(chan struct{}),
-		announcersStoppedC:  make(chan struct{}),
+		statsCommandC:       make(chan statsRequest),
+		peersCommandC:       make(chan peersRequest),
+		verifyCommandC:      make(chan struct{}),
+		bitfieldCommandC:    make(chan bitfieldRequest),
+
+		peerSpeedLimitsCommandC: make(chan peerSpeedLimitsRequest),
+		seedLimitsCommandC:      make(chan seedLimitsRequest),
+		filePrioritiesCommandC:  make(chan filePrioritiesRequest),
+		pickerCommandC:          make(chan pickerRequest),
+		pieceDeadlines:          make(map[uint32]time.Time),
+		announcersStoppedC:      make(chan struct{}),
+		announcePeersC:          make(chan []*net.TCPAddr),
 
 		sKeyHash:      mse.HashSKey(ih[:]),
 		incomingConnC: make(chan net.Conn),
 		peerIDs:       make(map[[20]byte]struct{}),
+		peers:         make(map[*peer.Peer]struct{}),
+		rejectedPeers: make(map[FilterReason]int),
 
 		storage:            sto,
 		allocatorProgressC: make(chan allocator.Progress),
 		allocatorResultC:   make(chan *allocator.Allocator),
+		verifierProgressC:  make(chan verifier.Progress),
+		verifierResultC:    make(chan *verifier.Verifier),
+		downloadSpeed:      speedmeter.New(),
+		uploadSpeed:        speedmeter.New(),
+		downloadLimiter:    ratelimit.New(session.downloadLimiter, 0),
+		uploadLimiter:      ratelimit.New(session.uploadLimiter, 0),
 
 		log: l,
 	}
 
+	t.port.Store(int32(port))
+
 	n := t.copyPeerIDPrefix()
 	_, err := rand.Read(t.peerID[n:])
 	if err != nil {
@@ -152,15 +272,50 @@ func (t *torrent) run() {
 			return
 		case <-t.startCommandC:
 			t.start()
-			// case <-t.stopCommandC:
+		case req := <-t.statsCommandC:
+			req.Response <- t.stats()
+		case req := <-t.trackersCommandC:
+			req.Response <- t.getTrackers()
+		case req := <-t.peersCommandC:
+			req.Response <- t.getPeers()
+		case req := <-t.bitfieldCommandC:
+			req.Response <- t.getBitfield()
+		case p := <-t.allocatorProgressC:
+			t.bytesAllocated = p.AllocatedSize
+		case al := <-t.allocatorResultC:
+			t.handleAllocationDone(al)
+		case p := <-t.verifierProgressC:
+			t.handleVerifierProgress(p)
+		case ve := <-t.verifierResultC:
+			t.handleVerificationDone(ve)
+		case <-t.announcersStoppedC:
+			t.stoppedEventAnnouncer = nil
+		case <-t.stopCommandC:
+			t.stop(nil)
+		case <-t.verifyCommandC:
+			t.verify()
+		case req := <-t.peerSpeedLimitsCommandC:
+			t.handlePeerSpeedLimitsChange()
+			req.Response <- struct{}{}
+		case req := <-t.seedLimitsCommandC:
+			t.handleSeedLimits(time.Now())
+			req.Response <- struct{}{}
+		case now := <-t.seedLimitsTickerC:
+			t.handleSeedLimits(now)
+		case now := <-t.statsWriteTickerC:
+			t.writeStats(now)
+		case req := <-t.filePrioritiesCommandC:
+			t.handleFilePrioritiesChange()
+			req.Response <- struct{}{}
+		case req := <-t.pickerCommandC:
+			t.handlePickerRequest(req)
+			req.Response <- struct{}{}
+		case conn := <-t.incomingConnC:
+			t.handleNewConnection(conn)
+		case addrs := <-t.announcePeersC:
+			t.handleNewPeers(addrs, peer.Tracker)
 			// case <-t.announceCommandC:
-			// case <-t.announcersStoppedC:
-			// case req := <-t.trackersCommandC:
 			// case trackers := <-t.addTrackersCommandC:
-			// case conn := <-t.incomingConnC:
-			//   t.handleNewConnection(conn)
-			// case addrs := <-t.announcePeersC:
-			//   t.handleNewPeers(addrs, peersource.Tracker)
 		}
 	}
 }
@@ -178,7 +333,7 @@ func (t *torrent) InfoHash() []byte {
 
 func (t *torrent) Files() ([]File, error) {
 	if t.info == nil {
-		return nil, errors.New("torrent metadata not ready")
+		return nil, errMetadataNotReady
 	}
 
 	files := make([]File, 0, len(t.info.Files))
@@ -192,18 +347,38 @@ func (t *torrent) Files() ([]File, error) {
 		}
 	}
 
+	var b *bitfield.Bitfield
+	if bf := t.Bitfield(); bf != nil {
+		b, _ = bitfield.NewBytes(bf, t.info.NumPieces)
+	}
+	t.fileProgress(files, b)
+
 	return files, nil
 }
 
 var errClosed = errors.New("torrent is closed")
 
 func (t *torrent) close() {
-	// t.stop(errClosed)
+	t.stop(errClosed)
+
+	// Wait for the stopped event to be sent to the trackers
+	if t.stoppedEventAnnouncer != nil {
+		<-t.announcersStoppedC
+		t.stoppedEventAnnouncer = nil
+	}
+
+	for _, f := range t.files {
+		f.Storage.Close()
+	}
+	t.files = nil
+	t.pieces = nil
 }
 
 type File struct {
-	path   string
-	length int64
+	path           string
+	length         int64
+	priority       FilePriority
+	bytesCompleted int64
 }
 
 func (f File) Path() string {
@@ -213,3 +388,13 @@ func (f File) Path() string {
 func (f File) Length() int64 {
 	return f.length
 }
+
+// Priority returns the download priority of the file.
+func (f File) Priority() FilePriority {
+	return f.priority
+}
+
+// BytesCompleted returns the number of bytes of the file in the pieces that are downloaded and verified.
+func (f File) BytesCompleted() int64 {
+	return f.bytesCompleted
+}
@@ -1,12 +1,17 @@
 package torrent
 
-import "github.com/al002/zbittorrent/internal/tracker"
+import (
+	"errors"
+
+	"github.com/al002/zbittorrent/internal/announcer"
+	"github.com/al002/zbittorrent/internal/tracker"
+)
 
 func (t *torrent) announceGetTorrent() tracker.Torrent {
   tr := tracker.Torrent{
     InfoHash: t.infoHash,
     PeerID: t.peerID,
-    Port: t.port,
+    Port: int(t.port.Load()),
     BytesDownlowded: 0,
     BytesUploaded: 0,
     // BytesDownloaded: t.bytesDownloaded.Count(),
@@ -15,3 +20,32 @@ func (t *torrent) announceGetTorrent() tracker.Torrent {
 
   return tr
 }
+
+// announceGetTorrentV2 returns the torrent with the truncated v2 info hash of a hybrid torrent.
+func (t *torrent) announceGetTorrentV2() tracker.Torrent {
+	tr := t.announceGetTorrent()
+	tr.InfoHash = t.info.TruncatedHashV2()
+	return tr
+}
+
+func (t *torrent) isHybrid() bool {
+	return t.info != nil && t.info.IsHybrid()
+}
+
+// handleAnnouncerEvent is called from announcer goroutines.
+func (t *torrent) handleAnnouncerEvent(e announcer.Event) {
+	t.session.metrics.countAnnounce(e.Tracker, e.Error != nil)
+
+	if e.Error != nil {
+		err := errors.New(e.Error.Message)
+		if e.Error.Unknown {
+			err = e.Error.Err
+		}
+		t.publish(Event{Type: TrackerError, Tracker: e.Tracker, Error: err})
+		return
+	}
+
+	if e.Warning != "" {
+		t.publish(Event{Type: TrackerWarning, Tracker: e.Tracker, Warning: e.Warning})
+	}
+}
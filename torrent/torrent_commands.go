@@ -1,6 +1,12 @@
 package torrent
 
-import "time"
+import (
+	"net"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/announcer"
+	"github.com/al002/zbittorrent/internal/peer"
+)
 
 type TrackerStatus int
 
@@ -20,16 +26,184 @@ type Tracker struct {
 	Status   TrackerStatus
 	Leechers int
 	Seeders  int
-	// Error        *AnnouceError
+	// Set if the last announce has failed
+	Error        *AnnounceError
 	Warning      string
 	LastAnnounce time.Time
 	NextAnnounce time.Time
 }
 
+func (s TrackerStatus) String() string {
+	switch s {
+	case NotContactedYet:
+		return "not contacted yet"
+	case Contacting:
+		return "contacting"
+	case Working:
+		return "working"
+	case NotWorking:
+		return "not working"
+	default:
+		return "unknown"
+	}
+}
+
+// AnnounceError is the error returned from the last announce to a tracker.
+type AnnounceError struct {
+	Err error
+	// Human readable description of Err
+	Message string
+	// Err could not be matched to a known error, Message does not describe it.
+	Unknown bool
+}
+
 type trackersRequest struct {
 	Response chan []Tracker
 }
 
+// Peer is a remote peer connected to the torrent.
+type Peer struct {
+	ID          [20]byte
+	Addr        net.Addr
+	Source      peer.Source
+	ConnectedAt time.Time
+}
+
+type peersRequest struct {
+	Response chan []Peer
+}
+
+type bitfieldRequest struct {
+	Response chan []byte
+}
+
+func (t *torrent) Trackers() []Tracker {
+	var trackers []Tracker
+	req := trackersRequest{
+		Response: make(chan []Tracker, 1),
+	}
+
+	select {
+	case t.trackersCommandC <- req:
+	case <-t.closeC:
+	}
+
+	select {
+	case trackers = <-req.Response:
+	case <-t.closeC:
+	}
+
+	return trackers
+}
+
+func (t *torrent) getTrackers() []Tracker {
+	// Torrent is not running, only URLs of the trackers are known
+	if len(t.announcers) == 0 {
+		trackers := make([]Tracker, len(t.trackers))
+		for i, tr := range t.trackers {
+			trackers[i] = Tracker{URL: tr.URL()}
+		}
+		return trackers
+	}
+
+	trackers := make([]Tracker, len(t.announcers))
+	for i, an := range t.announcers {
+		st := an.Stats()
+		trackers[i] = Tracker{
+			URL:          an.Tracker.URL(),
+			Status:       trackerStatus(st.Status),
+			Leechers:     st.Leechers,
+			Seeders:      st.Seeders,
+			Warning:      st.Warning,
+			LastAnnounce: st.LastAnnounce,
+			NextAnnounce: st.NextAnnounce,
+		}
+		if st.Error != nil {
+			trackers[i].Error = &AnnounceError{
+				Err:     st.Error.Err,
+				Message: st.Error.Message,
+				Unknown: st.Error.Unknown,
+			}
+		}
+	}
+
+	return trackers
+}
+
+func trackerStatus(s announcer.Status) TrackerStatus {
+	switch s {
+	case announcer.Contacting:
+		return Contacting
+	case announcer.Working:
+		return Working
+	case announcer.NotWorking:
+		return NotWorking
+	default:
+		return NotContactedYet
+	}
+}
+
+func (t *torrent) Peers() []Peer {
+	var peers []Peer
+	req := peersRequest{
+		Response: make(chan []Peer, 1),
+	}
+
+	select {
+	case t.peersCommandC <- req:
+	case <-t.closeC:
+	}
+
+	select {
+	case peers = <-req.Response:
+	case <-t.closeC:
+	}
+
+	return peers
+}
+
+func (t *torrent) getPeers() []Peer {
+	peers := make([]Peer, 0, len(t.peers))
+	for p := range t.peers {
+		peers = append(peers, Peer{
+			ID:          p.ID,
+			Addr:        p.RemoteAddr(),
+			Source:      p.Source,
+			ConnectedAt: p.ConnectedAt,
+		})
+	}
+
+	return peers
+}
+
+// Bitfield returns the pieces we have, one bit for each piece. Nil if the pieces are not known yet.
+func (t *torrent) Bitfield() []byte {
+	var b []byte
+	req := bitfieldRequest{
+		Response: make(chan []byte, 1),
+	}
+
+	select {
+	case t.bitfieldCommandC <- req:
+	case <-t.closeC:
+	}
+
+	select {
+	case b = <-req.Response:
+	case <-t.closeC:
+	}
+
+	return b
+}
+
+func (t *torrent) getBitfield() []byte {
+	if t.bitfield == nil {
+		return nil
+	}
+
+	return t.bitfield.Copy().Bytes()
+}
+
 func (t *torrent) Start() {
 	select {
 	case t.startCommandC <- struct{}{}:
@@ -37,6 +211,13 @@ func (t *torrent) Start() {
 	}
 }
 
+func (t *torrent) Stop() {
+	select {
+	case t.stopCommandC <- struct{}{}:
+	case <-t.closeC:
+	}
+}
+
 func (t *torrent) Close() {
   close(t.closeC)
   <-t.doneC
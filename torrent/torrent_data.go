@@ -0,0 +1,128 @@
+package torrent
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/allocator"
+	"github.com/al002/zbittorrent/internal/bitfield"
+	"github.com/al002/zbittorrent/internal/piece"
+	"github.com/al002/zbittorrent/internal/verifier"
+)
+
+func (t *torrent) handleAllocationDone(al *allocator.Allocator) {
+	if t.allocator != al {
+		t.crash("invalid allocator")
+	}
+	t.allocator = nil
+
+	if al.Error != nil {
+		err := fmt.Errorf("file allocation error: %w", al.Error)
+		t.publish(Event{Type: StorageError, Error: err})
+		t.stop(err)
+		return
+	}
+
+	t.files = al.Files
+	t.pieces = piece.NewPieces(t.info, t.files)
+	// Priorities may have changed while the files were allocated
+	if !t.openUnskippedFiles() {
+		return
+	}
+
+	// Files that exist on the disk may contain pieces from a previous run
+	if al.HasExisting {
+		t.startVerifier()
+		return
+	}
+
+	t.bitfield = bitfield.New(t.info.NumPieces)
+	t.startPicker()
+	t.checkCompletion()
+	t.checkStopAfterVerify()
+}
+
+func (t *torrent) handleVerifierProgress(p verifier.Progress) {
+	t.checkedPieces = p.Checked
+	if p.Verified {
+		t.publish(Event{Type: PieceVerified, Piece: p.Checked - 1})
+	}
+}
+
+func (t *torrent) handleVerificationDone(ve *verifier.Verifier) {
+	if t.verifier != ve {
+		t.crash("invalid verifier")
+	}
+	t.verifier = nil
+
+	if ve.Error != nil {
+		err := fmt.Errorf("file verification error: %w", ve.Error)
+		t.publish(Event{Type: StorageError, Error: err})
+		t.stop(err)
+		return
+	}
+
+	t.bitfield = ve.Bitfield
+	start := time.Now()
+	err := t.session.resumer.WriteBitfield(t.id, t.bitfield.Bytes())
+	t.session.metrics.resumeWrites.With("bitfield").ObserveSince(start)
+	if err != nil {
+		t.stop(fmt.Errorf("cannot write bitfield to resume db: %w", err))
+		return
+	}
+
+	if !t.openUnskippedFiles() {
+		return
+	}
+	t.startPicker()
+	t.checkCompletion()
+	t.checkStopAfterVerify()
+}
+
+func (t *torrent) checkCompletion() {
+	if t.completed || !t.haveWantedPieces() {
+		return
+	}
+
+	t.log.Info("download completed", "torrent", t.id)
+	t.completed = true
+	t.completedFlag.Store(true)
+	close(t.completeC)
+	t.publish(Event{Type: DownloadComplete})
+	t.session.notifyQueue()
+
+	if t.errC == nil {
+		return
+	}
+	if t.stopAfterDownload {
+		// Only the first completion stops the torrent, it can be started again for seeding
+		t.stopAfterDownload = false
+		t.writeStopAfterDownload(false)
+		t.stopWithReason(StoppedAfterDownload, nil)
+		return
+	}
+	t.startSeeding(time.Now())
+}
+
+// bytesComplete returns the total length of the pieces we have.
+func (t *torrent) bytesComplete() int64 {
+	if t.bitfield == nil {
+		return 0
+	}
+
+	n := int64(t.bitfield.Count()) * int64(t.info.PieceLength)
+	last := t.info.NumPieces - 1
+	if t.bitfield.Test(last) {
+		n -= int64(t.info.PieceLength)*int64(t.info.NumPieces) - t.info.Length
+	}
+
+	return n
+}
+
+// checkStopAfterVerify stops the torrent if it is started by Verify() only.
+func (t *torrent) checkStopAfterVerify() {
+	if t.stopAfterVerify {
+		t.stopAfterVerify = false
+		t.stopWithReason(StoppedAfterVerify, nil)
+	}
+}
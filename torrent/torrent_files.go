@@ -0,0 +1,263 @@
+package torrent
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/al002/zbittorrent/internal/allocator"
+	"github.com/al002/zbittorrent/internal/bitfield"
+	"github.com/al002/zbittorrent/internal/piece"
+	"github.com/al002/zbittorrent/internal/storage"
+)
+
+// FilePriority is the download priority of a file in a torrent.
+// The values of low, normal and high match the file priorities of Transmission.
+type FilePriority int
+
+const (
+	// PrioritySkip files are not downloaded and not created on the disk.
+	PrioritySkip FilePriority = iota - 2
+	PriorityLow
+	// PriorityNormal is the priority of files that are not given a priority.
+	PriorityNormal
+	PriorityHigh
+)
+
+var errMetadataNotReady = errors.New("torrent metadata not ready")
+
+func (p FilePriority) String() string {
+	switch p {
+	case PrioritySkip:
+		return "skip"
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+func (p FilePriority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *FilePriority) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "skip":
+		*p = PrioritySkip
+	case "low":
+		*p = PriorityLow
+	case "normal", "":
+		*p = PriorityNormal
+	case "high":
+		*p = PriorityHigh
+	default:
+		return fmt.Errorf("invalid file priority: %q", text)
+	}
+	return nil
+}
+
+// FilePriorities returns the priority of each file in the torrent, in the order of Files().
+func (t *torrent) FilePriorities() ([]FilePriority, error) {
+	if t.info == nil {
+		return nil, errMetadataNotReady
+	}
+
+	t.mFilePriorities.RLock()
+	defer t.mFilePriorities.RUnlock()
+
+	l := make([]FilePriority, t.numFiles())
+	copy(l, t.filePriorities)
+	for i := len(t.filePriorities); i < len(l); i++ {
+		l[i] = PriorityNormal
+	}
+	return l, nil
+}
+
+// SetFilePriorities changes the priorities of the files and checks whether the wanted pieces are complete.
+func (t *torrent) SetFilePriorities(l []FilePriority) {
+	t.mFilePriorities.Lock()
+	t.filePriorities = l
+	t.mFilePriorities.Unlock()
+
+	req := filePrioritiesRequest{
+		Response: make(chan struct{}, 1),
+	}
+
+	select {
+	case t.filePrioritiesCommandC <- req:
+	case <-t.closeC:
+		return
+	}
+
+	select {
+	case <-req.Response:
+	case <-t.closeC:
+	}
+}
+
+type filePrioritiesRequest struct {
+	Response chan struct{}
+}
+
+func (t *torrent) handleFilePrioritiesChange() {
+	// Files are reopened after the verifier is done with the current pieces
+	if t.verifier == nil && !t.openUnskippedFiles() {
+		return
+	}
+	t.updatePickerPriorities()
+	if t.bitfield != nil {
+		t.checkCompletion()
+	}
+}
+
+// openUnskippedFiles replaces the part files of the files that are not skipped anymore with the files themselves.
+// Returns false if the torrent is stopped because a file cannot be opened.
+func (t *torrent) openUnskippedFiles() bool {
+	if t.files == nil {
+		return true
+	}
+
+	skip := t.skippedFiles()
+	var changed bool
+	for i, f := range t.files {
+		part, ok := f.Storage.(*storage.PartFile)
+		if !ok || skip[i] {
+			continue
+		}
+
+		sf, err := allocator.OpenUnskipped(t.storage, f.Name, t.info.Files[i].Length, part)
+		if err != nil {
+			err = fmt.Errorf("cannot open unskipped file: %w", err)
+			t.publish(Event{Type: StorageError, Error: err})
+			t.stop(err)
+			return false
+		}
+		t.files[i].Storage = sf
+		changed = true
+	}
+
+	if changed {
+		t.pieces = piece.NewPieces(t.info, t.files)
+	}
+	return true
+}
+
+// numFiles returns the number of files in the torrent without padding files.
+func (t *torrent) numFiles() int {
+	var n int
+	for _, f := range t.info.Files {
+		if !f.Padding {
+			n++
+		}
+	}
+	return n
+}
+
+// filePriority returns the priority of the i'th file in Files().
+// Must be called with mFilePriorities held.
+func (t *torrent) filePriority(i int) FilePriority {
+	if i < len(t.filePriorities) {
+		return t.filePriorities[i]
+	}
+	return PriorityNormal
+}
+
+// skippedFiles returns the files in info.Files that must not be created on the disk.
+func (t *torrent) skippedFiles() []bool {
+	t.mFilePriorities.RLock()
+	defer t.mFilePriorities.RUnlock()
+
+	skip := make([]bool, len(t.info.Files))
+	var i int
+	for j, f := range t.info.Files {
+		if f.Padding {
+			continue
+		}
+		skip[j] = t.filePriority(i) == PrioritySkip
+		i++
+	}
+	return skip
+}
+
+// piecePriorities returns the priority of each piece, which is the highest priority of the files it overlaps.
+// Pieces that only overlap skipped files are not wanted and must not be requested from peers.
+func (t *torrent) piecePriorities() []FilePriority {
+	t.mFilePriorities.RLock()
+	defer t.mFilePriorities.RUnlock()
+
+	l := make([]FilePriority, t.info.NumPieces)
+	for i := range l {
+		l[i] = PrioritySkip
+	}
+
+	pieceLength := int64(t.info.PieceLength)
+	var offset int64
+	var i int
+	for _, f := range t.info.Files {
+		start := offset
+		offset += f.Length
+		if f.Padding {
+			continue
+		}
+		p := t.filePriority(i)
+		i++
+		if f.Length == 0 {
+			continue
+		}
+
+		for j := start / pieceLength; j <= (offset-1)/pieceLength; j++ {
+			l[j] = max(l[j], p)
+		}
+	}
+	return l
+}
+
+// haveWantedPieces returns true if all pieces of the files that are not skipped are downloaded.
+func (t *torrent) haveWantedPieces() bool {
+	if t.bitfield.All() {
+		return true
+	}
+
+	for i, p := range t.piecePriorities() {
+		if p != PrioritySkip && !t.bitfield.Test(uint32(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// fileProgress sets the priority and downloaded bytes of files from the pieces in b.
+func (t *torrent) fileProgress(files []File, b *bitfield.Bitfield) {
+	t.mFilePriorities.RLock()
+	defer t.mFilePriorities.RUnlock()
+
+	pieceLength := int64(t.info.PieceLength)
+	var offset int64
+	var i int
+	for _, f := range t.info.Files {
+		start := offset
+		offset += f.Length
+		if f.Padding {
+			continue
+		}
+
+		file := &files[i]
+		file.priority = t.filePriority(i)
+		i++
+		if b == nil || f.Length == 0 {
+			continue
+		}
+
+		for j := start / pieceLength; j <= (offset-1)/pieceLength; j++ {
+			if !b.Test(uint32(j)) {
+				continue
+			}
+			pieceStart := j * pieceLength
+			file.bytesCompleted += min(offset, pieceStart+pieceLength) - max(start, pieceStart)
+		}
+	}
+}
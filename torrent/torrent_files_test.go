@@ -0,0 +1,173 @@
+package torrent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/al002/zbittorrent/internal/allocator"
+	"github.com/al002/zbittorrent/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testFileSizes are the sizes of 3 files in 7 pieces, the 4th and the 6th pieces are shared by two files.
+var testFileSizes = []int{3*testPieceLength + 100, 2 * testPieceLength, 2*testPieceLength - 100}
+
+func TestFilePriorityText(t *testing.T) {
+	for _, p := range []FilePriority{PrioritySkip, PriorityLow, PriorityNormal, PriorityHigh} {
+		b, err := p.MarshalText()
+		require.NoError(t, err)
+		var p2 FilePriority
+		require.NoError(t, p2.UnmarshalText(b))
+		assert.Equal(t, p, p2)
+	}
+
+	var p FilePriority
+	assert.Error(t, p.UnmarshalText([]byte("urgent")))
+	assert.True(t, PrioritySkip < PriorityLow && PriorityLow < PriorityNormal && PriorityNormal < PriorityHigh)
+}
+
+func TestSetFilePriorities(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+
+	tor := addTestTorrent(t, s, testTorrent{Name: "multi", Sizes: testFileSizes})
+
+	l, err := tor.FilePriorities()
+	require.NoError(t, err)
+	assert.Equal(t, []FilePriority{PriorityNormal, PriorityNormal, PriorityNormal}, l)
+
+	assert.Error(t, tor.SetFilePriorities([]FilePriority{PriorityHigh}))
+	assert.Error(t, tor.SetFilePriorities([]FilePriority{PriorityHigh, PriorityHigh, FilePriority(5)}))
+
+	require.NoError(t, tor.SetFilePriorities([]FilePriority{PriorityLow, PrioritySkip, PriorityHigh}))
+	l, err = tor.FilePriorities()
+	require.NoError(t, err)
+	assert.Equal(t, []FilePriority{PriorityLow, PrioritySkip, PriorityHigh}, l)
+
+	files, err := tor.Files()
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	assert.Equal(t, PrioritySkip, files[1].Priority())
+
+	spec, err := s.resumer.Read(tor.ID())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"low", "skip", "high"}, spec.FilePriorities)
+
+	// Pieces take the highest priority of the files they overlap
+	pp := tor.torrent.piecePriorities()
+	require.Len(t, pp, 7)
+	assert.Equal(t, []FilePriority{PriorityLow, PriorityLow, PriorityLow, PriorityLow, PrioritySkip, PriorityHigh, PriorityHigh}, pp)
+}
+
+func TestSkippedFileNotCreated(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+
+	tor := addTestTorrent(t, s, testTorrent{Name: "multi", Sizes: testFileSizes})
+	require.NoError(t, tor.SetFilePriorities([]FilePriority{PriorityNormal, PrioritySkip, PriorityNormal}))
+	require.NoError(t, tor.Start())
+	waitStatus(t, tor, Downloading)
+
+	root := filepath.Join(s.config.DataDir, "multi")
+	assert.FileExists(t, filepath.Join(root, "a.bin"))
+	assert.FileExists(t, filepath.Join(root, "c.bin"))
+	assert.NoFileExists(t, filepath.Join(root, "b.bin"))
+	// The part file is only created when a piece overlapping the skipped file is written
+	assert.NoFileExists(t, filepath.Join(root, "b.bin"+allocator.PartFileSuffix))
+}
+
+func TestFileProgress(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+
+	// The data of the first and last files exist, the pieces they share with the skipped file are missing
+	tor := addTestTorrent(t, s, testTorrent{Name: "multi", Sizes: testFileSizes, Complete: true})
+	require.NoError(t, os.Remove(filepath.Join(s.config.DataDir, "multi", "b.bin")))
+	require.NoError(t, tor.SetFilePriorities([]FilePriority{PriorityNormal, PrioritySkip, PriorityNormal}))
+	require.NoError(t, tor.Start())
+	waitStatus(t, tor, Downloading)
+
+	files, err := tor.Files()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3*testPieceLength), files[0].BytesCompleted())
+	assert.Equal(t, int64(0), files[1].BytesCompleted())
+	assert.Equal(t, int64(testPieceLength), files[2].BytesCompleted())
+	assert.NoFileExists(t, filepath.Join(s.config.DataDir, "multi", "b.bin"))
+}
+
+func TestSkippedFileCompletes(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+
+	// Padding keeps the pieces of the skipped file apart from the others
+	tor := addTestTorrent(t, s, testTorrent{Name: "multi", Sizes: []int{testPieceLength + 100, testPieceLength, 100}, Padding: true, Complete: true})
+	require.NoError(t, os.Remove(filepath.Join(s.config.DataDir, "multi", "b.bin")))
+	require.NoError(t, tor.SetFilePriorities([]FilePriority{PriorityNormal, PrioritySkip, PriorityNormal}))
+	require.NoError(t, tor.Start())
+	waitStatus(t, tor, Seeding)
+
+	files, err := tor.Files()
+	require.NoError(t, err)
+	assert.Equal(t, files[0].Length(), files[0].BytesCompleted())
+	assert.Equal(t, int64(0), files[1].BytesCompleted())
+	assert.Equal(t, files[2].Length(), files[2].BytesCompleted())
+}
+
+func TestFilePrioritiesRestore(t *testing.T) {
+	cfg := testConfig(t)
+	s := newTestSession(t, cfg)
+
+	priorities := []FilePriority{PriorityHigh, PrioritySkip, PriorityLow}
+	tor := addTestTorrent(t, s, testTorrent{Name: "multi", Sizes: testFileSizes})
+	require.NoError(t, tor.SetFilePriorities(priorities))
+	s.Close()
+
+	s = newTestSession(t, cfg)
+	defer s.Close()
+	loaded := s.GetTorrent(tor.ID())
+	require.NotNil(t, loaded)
+	l, err := loaded.FilePriorities()
+	require.NoError(t, err)
+	assert.Equal(t, priorities, l)
+
+	// The skipped file is not created on the first start after the restart
+	require.NoError(t, loaded.Start())
+	waitStatus(t, loaded, Downloading)
+	root := filepath.Join(cfg.DataDir, "multi")
+	assert.FileExists(t, filepath.Join(root, "a.bin"))
+	assert.FileExists(t, filepath.Join(root, "c.bin"))
+	assert.NoFileExists(t, filepath.Join(root, "b.bin"))
+}
+
+func TestUnskipAfterStart(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+
+	tor := addTestTorrent(t, s, testTorrent{Name: "multi", Sizes: testFileSizes, Complete: true})
+	root := filepath.Join(s.config.DataDir, "multi")
+	data, err := os.ReadFile(filepath.Join(root, "b.bin"))
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filepath.Join(root, "b.bin")))
+	require.NoError(t, tor.SetFilePriorities([]FilePriority{PriorityNormal, PrioritySkip, PriorityNormal}))
+	require.NoError(t, tor.Start())
+	waitStatus(t, tor, Downloading)
+
+	// Downloaded data of the skipped file goes to the part file
+	f := tor.torrent.files[1].Storage
+	require.IsType(t, &storage.PartFile{}, f)
+	_, err = f.WriteAt(data, 0)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(root, "b.bin"+allocator.PartFileSuffix))
+
+	require.NoError(t, tor.SetFilePriorities([]FilePriority{PriorityNormal, PriorityNormal, PriorityNormal}))
+	assert.NoFileExists(t, filepath.Join(root, "b.bin"+allocator.PartFileSuffix))
+	b, err := os.ReadFile(filepath.Join(root, "b.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	// Pieces are read from the file itself
+	require.NoError(t, tor.Verify())
+	waitStatus(t, tor, Seeding)
+}
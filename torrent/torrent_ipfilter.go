@@ -0,0 +1,143 @@
+package torrent
+
+import (
+	"net"
+
+	"github.com/al002/zbittorrent/internal/blocklist"
+	"github.com/al002/zbittorrent/internal/peer"
+)
+
+// IPFilter contains the rules that restrict the peers of a torrent in addition to the blocklist of the Session.
+// Rules are addresses, CIDRs or "first-last" ranges.
+type IPFilter struct {
+	// If not empty, only peers that match one of the rules are allowed.
+	Allow []string
+	// Peers that match one of the rules are not allowed.
+	Deny []string
+}
+
+// FilterReason is the result of checking a peer address with the blocklist and the IPFilter of a torrent.
+type FilterReason int
+
+const (
+	// PeerAllowed indicates that the address passed all checks.
+	PeerAllowed FilterReason = iota
+	// PeerBlocklisted indicates that the address is in the blocklist of the Session.
+	PeerBlocklisted
+	// PeerDenied indicates that the address matches a deny rule of the torrent.
+	PeerDenied
+	// PeerNotAllowed indicates that the torrent has allow rules and the address does not match any of them.
+	PeerNotAllowed
+)
+
+func (r FilterReason) String() string {
+	switch r {
+	case PeerAllowed:
+		return "allowed"
+	case PeerBlocklisted:
+		return "blocklisted"
+	case PeerDenied:
+		return "denied"
+	case PeerNotAllowed:
+		return "not allowed"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler so FilterReason can be used as a map key in JSON.
+func (r FilterReason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// ipFilter is the compiled form of IPFilter.
+type ipFilter struct {
+	rules IPFilter
+	allow *blocklist.Blocklist
+	deny  *blocklist.Blocklist
+}
+
+func newIPFilter(rules IPFilter) (*ipFilter, error) {
+	f := &ipFilter{rules: rules}
+	var err error
+	if len(rules.Allow) > 0 {
+		f.allow, err = blocklist.NewFromRules(rules.Allow)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(rules.Deny) > 0 {
+		f.deny, err = blocklist.NewFromRules(rules.Deny)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// IPFilter returns the rules of the torrent.
+func (t *torrent) IPFilter() IPFilter {
+	f := t.ipFilter.Load()
+	if f == nil {
+		return IPFilter{}
+	}
+	return f.rules
+}
+
+// SetIPFilter replaces the IPFilter of the torrent. Connected peers are not affected.
+func (t *torrent) SetIPFilter(f *ipFilter) {
+	t.ipFilter.Store(f)
+}
+
+// checkPeerIP checks the address with the blocklist of the Session first and then with the deny and allow rules of the torrent.
+func (t *torrent) checkPeerIP(ip net.IP) FilterReason {
+	if t.session.blocklist.Blocked(ip) {
+		return PeerBlocklisted
+	}
+	f := t.ipFilter.Load()
+	if f == nil {
+		return PeerAllowed
+	}
+	if f.deny != nil && f.deny.Blocked(ip) {
+		return PeerDenied
+	}
+	if f.allow != nil && !f.allow.Blocked(ip) {
+		return PeerNotAllowed
+	}
+	return PeerAllowed
+}
+
+// filterPeer returns true if the peer address passes the filters.
+// Rejected addresses are logged and counted in stats.
+func (t *torrent) filterPeer(addr *net.TCPAddr, source peer.Source) bool {
+	reason := t.checkPeerIP(addr.IP)
+	if reason == PeerAllowed {
+		return true
+	}
+
+	t.rejectedPeers[reason]++
+	t.log.Debug("peer address rejected", "torrent", t.id, "addr", addr.String(), "source", source.String(), "reason", reason.String())
+	return false
+}
+
+// handleNewPeers is called with peer addresses from trackers, DHT and PEX.
+func (t *torrent) handleNewPeers(addrs []*net.TCPAddr, source peer.Source) {
+	for _, addr := range addrs {
+		if !t.filterPeer(addr, source) {
+			continue
+		}
+		// Connecting to peers is not implemented yet
+	}
+}
+
+// handleNewConnection is called with the connections accepted by the acceptor.
+func (t *torrent) handleNewConnection(conn net.Conn) {
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if ok && !t.filterPeer(addr, peer.Incoming) {
+		conn.Close()
+		return
+	}
+
+	// The peer handshake is not implemented yet
+	conn.Close()
+}
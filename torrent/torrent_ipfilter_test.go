@@ -0,0 +1,116 @@
+package torrent
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTorrentIPFilter(t *testing.T) {
+	cfg := testConfig(t)
+	s := newTestSession(t, cfg)
+	defer s.Close()
+
+	_, err := s.blocklist.Reload(strings.NewReader("192.168.0.0/16\n"))
+	require.NoError(t, err)
+
+	tor := addTestTorrent(t, s, testTorrent{})
+
+	assert.Error(t, tor.SetIPFilter(IPFilter{Allow: []string{"foo"}}))
+	assert.Equal(t, IPFilter{}, tor.IPFilter())
+
+	rules := IPFilter{
+		Allow: []string{"10.0.0.0/8", "2001:db8::/32", "192.168.1.1"},
+		Deny:  []string{"10.1.0.0-10.1.255.255"},
+	}
+	require.NoError(t, tor.SetIPFilter(rules))
+	assert.Equal(t, rules, tor.IPFilter())
+
+	spec, err := s.resumer.Read(tor.ID())
+	require.NoError(t, err)
+	assert.Equal(t, rules.Allow, spec.AllowedIPs)
+	assert.Equal(t, rules.Deny, spec.DeniedIPs)
+
+	cases := map[string]FilterReason{
+		"10.2.3.4":    PeerAllowed,
+		"2001:db8::1": PeerAllowed,
+		"10.1.2.3":    PeerDenied,
+		"11.1.2.3":    PeerNotAllowed,
+		"2001:db9::1": PeerNotAllowed,
+		"192.168.1.1": PeerBlocklisted,
+	}
+	for ip, reason := range cases {
+		assert.Equal(t, reason, tor.torrent.checkPeerIP(net.ParseIP(ip)), ip)
+	}
+
+	tor.torrent.announcePeersC <- []*net.TCPAddr{
+		{IP: net.ParseIP("10.2.3.4"), Port: 1},
+		{IP: net.ParseIP("10.1.2.3"), Port: 1},
+		{IP: net.ParseIP("10.1.2.4"), Port: 1},
+		{IP: net.ParseIP("11.1.2.3"), Port: 1},
+		{IP: net.ParseIP("192.168.1.1"), Port: 1},
+	}
+	assert.Equal(t, map[FilterReason]int{PeerDenied: 2, PeerNotAllowed: 1, PeerBlocklisted: 1}, tor.Stats().Peers.Rejected)
+
+	// Empty rules allow all peers except the blocklisted ones
+	require.NoError(t, tor.SetIPFilter(IPFilter{}))
+	assert.Equal(t, PeerAllowed, tor.torrent.checkPeerIP(net.ParseIP("11.1.2.3")))
+	assert.Equal(t, PeerBlocklisted, tor.torrent.checkPeerIP(net.ParseIP("192.168.1.1")))
+}
+
+func TestTorrentIPFilterIncoming(t *testing.T) {
+	cfg := testConfig(t)
+	s := newTestSession(t, cfg)
+	defer s.Close()
+
+	tor := addTestTorrent(t, s, testTorrent{})
+	require.NoError(t, tor.SetIPFilter(IPFilter{Deny: []string{"127.0.0.1"}}))
+
+	client, server := net.Pipe()
+	defer client.Close()
+	tor.torrent.incomingConnC <- &tcpConn{Conn: server, remote: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1}}
+	assert.Equal(t, 1, tor.Stats().Peers.Rejected[PeerDenied])
+
+	// The rejected connection is closed
+	_ = client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err := client.Read(make([]byte, 1))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+// tcpConn is a net.Conn with a TCP remote address.
+type tcpConn struct {
+	net.Conn
+	remote *net.TCPAddr
+}
+
+func (c *tcpConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func TestTorrentIPFilterRestore(t *testing.T) {
+	cfg := testConfig(t)
+	s := newTestSession(t, cfg)
+
+	rules := IPFilter{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.1.0.0/16"}}
+	tor := addTestTorrent(t, s, testTorrent{})
+	require.NoError(t, tor.SetIPFilter(rules))
+	other := addTestTorrent(t, s, testTorrent{Name: "other"})
+	s.Close()
+
+	s = newTestSession(t, cfg)
+	defer s.Close()
+	loaded := s.GetTorrent(tor.ID())
+	require.NotNil(t, loaded)
+	assert.Equal(t, rules, loaded.IPFilter())
+	assert.Equal(t, PeerDenied, loaded.torrent.checkPeerIP(net.ParseIP("10.1.2.3")))
+	assert.Equal(t, PeerNotAllowed, loaded.torrent.checkPeerIP(net.ParseIP("11.1.2.3")))
+
+	// Torrents without rules have no filter
+	assert.Nil(t, s.GetTorrent(other.ID()).torrent.ipFilter.Load())
+}
@@ -0,0 +1,83 @@
+package torrent
+
+import (
+	"net"
+
+	"github.com/al002/zbittorrent/internal/peer"
+	"github.com/al002/zbittorrent/internal/ratelimit"
+)
+
+// SpeedLimits returns the speed limits of the torrent.
+func (t *torrent) SpeedLimits() SpeedLimits {
+	t.mSpeedLimits.RLock()
+	defer t.mSpeedLimits.RUnlock()
+	return t.speedLimits
+}
+
+// SetSpeedLimits changes the speed limits of the torrent and its peers.
+func (t *torrent) SetSpeedLimits(l SpeedLimits) {
+	t.mSpeedLimits.Lock()
+	t.speedLimits = l
+	t.mSpeedLimits.Unlock()
+
+	t.downloadLimiter.SetLimit(l.Download * 1024)
+	t.uploadLimiter.SetLimit(l.Upload * 1024)
+	t.updatePeerSpeedLimits()
+}
+
+type peerSpeedLimitsRequest struct {
+	Response chan struct{}
+}
+
+// updatePeerSpeedLimits applies the peer limits to the connected peers.
+func (t *torrent) updatePeerSpeedLimits() {
+	req := peerSpeedLimitsRequest{
+		Response: make(chan struct{}, 1),
+	}
+
+	select {
+	case t.peerSpeedLimitsCommandC <- req:
+	case <-t.closeC:
+		return
+	}
+
+	select {
+	case <-req.Response:
+	case <-t.closeC:
+	}
+}
+
+func (t *torrent) handlePeerSpeedLimitsChange() {
+	download, upload := t.peerSpeedLimits()
+	for pe := range t.peers {
+		pe.DownloadLimiter.SetLimit(download)
+		pe.UploadLimiter.SetLimit(upload)
+	}
+}
+
+// peerSpeedLimits returns the limits of each peer in bytes per second.
+// The peer limits of the torrent take precedence over the peer limits of the Session.
+func (t *torrent) peerSpeedLimits() (download, upload int64) {
+	tl := t.SpeedLimits()
+	sl := t.session.activeSpeedLimits()
+	download, upload = sl.PeerDownload, sl.PeerUpload
+	if tl.PeerDownload > 0 {
+		download = tl.PeerDownload
+	}
+	if tl.PeerUpload > 0 {
+		upload = tl.PeerUpload
+	}
+	return download * 1024, upload * 1024
+}
+
+// newPeer returns a Peer whose transfers are limited by the peer, torrent and global limits.
+func (t *torrent) newPeer(conn net.Conn, id [20]byte, source peer.Source) *peer.Peer {
+	download, upload := t.peerSpeedLimits()
+	return peer.New(
+		conn,
+		id,
+		source,
+		ratelimit.New(t.downloadLimiter, download),
+		ratelimit.New(t.uploadLimiter, upload),
+	)
+}
@@ -0,0 +1,94 @@
+package torrent
+
+import (
+	"time"
+
+	"github.com/al002/zbittorrent/internal/piecepicker"
+)
+
+// Sequential returns true if the pieces of the torrent are downloaded in order.
+func (t *torrent) Sequential() bool {
+	return t.sequential.Load()
+}
+
+// SetSequential changes the order in which the pieces are picked.
+func (t *torrent) SetSequential(value bool) {
+	t.sequential.Store(value)
+	t.sendPickerRequest(pickerRequest{})
+}
+
+// SetPieceDeadline makes the piece requested before the others. Zero time clears the deadline.
+func (t *torrent) SetPieceDeadline(i uint32, deadline time.Time) {
+	t.sendPickerRequest(pickerRequest{
+		Deadline: &pieceDeadline{Piece: i, Time: deadline},
+	})
+}
+
+type pieceDeadline struct {
+	Piece uint32
+	Time  time.Time
+}
+
+type pickerRequest struct {
+	// Nil if only the sequential mode is changed
+	Deadline *pieceDeadline
+	Response chan struct{}
+}
+
+func (t *torrent) sendPickerRequest(req pickerRequest) {
+	req.Response = make(chan struct{}, 1)
+
+	select {
+	case t.pickerCommandC <- req:
+	case <-t.closeC:
+		return
+	}
+
+	select {
+	case <-req.Response:
+	case <-t.closeC:
+	}
+}
+
+func (t *torrent) handlePickerRequest(req pickerRequest) {
+	if d := req.Deadline; d != nil {
+		if d.Time.IsZero() {
+			delete(t.pieceDeadlines, d.Piece)
+		} else {
+			t.pieceDeadlines[d.Piece] = d.Time
+		}
+	}
+
+	if t.picker == nil {
+		return
+	}
+	t.picker.SetSequential(t.sequential.Load())
+	if d := req.Deadline; d != nil {
+		t.picker.SetDeadline(d.Piece, d.Time)
+	}
+}
+
+// startPicker creates the piece picker after the pieces that we have are known.
+func (t *torrent) startPicker() {
+	t.picker = piecepicker.New(t.bitfield)
+	t.picker.SetSequential(t.sequential.Load())
+	t.updatePickerPriorities()
+	for i, deadline := range t.pieceDeadlines {
+		t.picker.SetDeadline(i, deadline)
+	}
+}
+
+// updatePickerPriorities gives the picker the priorities of the pieces from the priorities of the files.
+func (t *torrent) updatePickerPriorities() {
+	if t.picker == nil {
+		return
+	}
+
+	pp := t.piecePriorities()
+	l := make([]int, len(pp))
+	for i, p := range pp {
+		// Skipped pieces become zero which the picker does not want
+		l[i] = int(p - PrioritySkip)
+	}
+	t.picker.SetPriorities(l)
+}
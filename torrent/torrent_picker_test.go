@@ -0,0 +1,96 @@
+package torrent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/al002/zbittorrent/internal/bitfield"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetSequential(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+
+	tor := addTestTorrent(t, s, testTorrent{})
+	assert.False(t, tor.Sequential())
+
+	require.NoError(t, tor.SetSequential(true))
+	assert.True(t, tor.Sequential())
+
+	spec, err := s.resumer.Read(tor.ID())
+	require.NoError(t, err)
+	assert.True(t, spec.Sequential)
+}
+
+func TestSequentialRestore(t *testing.T) {
+	cfg := testConfig(t)
+	s := newTestSession(t, cfg)
+
+	tor := addTestTorrent(t, s, testTorrent{})
+	require.NoError(t, tor.SetSequential(true))
+	other := addTestTorrent(t, s, testTorrent{Name: "other"})
+	s.Close()
+
+	s = newTestSession(t, cfg)
+	defer s.Close()
+	loaded := s.GetTorrent(tor.ID())
+	require.NotNil(t, loaded)
+	assert.True(t, loaded.Sequential())
+	assert.False(t, s.GetTorrent(other.ID()).Sequential())
+
+	// The picker of the loaded torrent picks in order, rarest first would pick the last piece
+	require.NoError(t, loaded.Start())
+	waitStatus(t, loaded, Downloading)
+	p := loaded.torrent.picker
+	require.NotNil(t, p)
+	peer := bitfield.New(4)
+	for i := uint32(0); i < 4; i++ {
+		peer.Set(i)
+	}
+	p.HandleBitfield(peer)
+	common := bitfield.New(4)
+	for i := uint32(0); i < 3; i++ {
+		common.Set(i)
+	}
+	p.HandleBitfield(common)
+	i, ok := p.Pick(peer, false, time.Now())
+	require.True(t, ok)
+	assert.Equal(t, uint32(0), i)
+}
+
+func TestSetPieceDeadline(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+
+	tor := addTestTorrent(t, s, testTorrent{Name: "multi", Sizes: testFileSizes})
+	assert.Error(t, tor.SetPieceDeadline(7, time.Now()))
+
+	deadline := time.Now().Add(time.Minute)
+	require.NoError(t, tor.SetPieceDeadline(6, deadline))
+	require.NoError(t, tor.SetPieceDeadline(5, deadline))
+	require.NoError(t, tor.SetPieceDeadline(5, time.Time{}))
+	require.NoError(t, tor.SetFilePriorities([]FilePriority{PriorityNormal, PrioritySkip, PriorityNormal}))
+	require.NoError(t, tor.SetSequential(true))
+	require.NoError(t, tor.Start())
+	waitStatus(t, tor, Downloading)
+
+	// The picker is created in the run loop after the files are allocated
+	p := tor.torrent.picker
+	require.NotNil(t, p)
+	peer := bitfield.New(7)
+	for i := uint32(0); i < 7; i++ {
+		peer.Set(i)
+	}
+	var picked []uint32
+	for {
+		i, ok := p.Pick(peer, false, time.Now())
+		if !ok {
+			break
+		}
+		picked = append(picked, i)
+	}
+	// Deadline first, then in order without the piece that is only in the skipped file
+	assert.Equal(t, []uint32{6, 0, 1, 2, 3, 5}, picked)
+}
@@ -0,0 +1,63 @@
+package torrent
+
+import (
+	"time"
+
+	"github.com/al002/zbittorrent/internal/resumer/boltdbresumer"
+)
+
+// startStatsWriter starts saving the transfer counters periodically, so they are not lost if the process is killed.
+func (t *torrent) startStatsWriter() {
+	if t.session.config.ResumeWriteInterval <= 0 {
+		return
+	}
+	t.statsWriteTicker = time.NewTicker(t.session.config.ResumeWriteInterval)
+	t.statsWriteTickerC = t.statsWriteTicker.C
+}
+
+func (t *torrent) stopStatsWriter() {
+	if t.statsWriteTicker != nil {
+		t.statsWriteTicker.Stop()
+		t.statsWriteTicker = nil
+		t.statsWriteTickerC = nil
+	}
+}
+
+// writeStats saves the transfer counters and the seeding time, which are the base of the seed limits after a restart.
+func (t *torrent) writeStats(now time.Time) {
+	seededFor := t.seededFor
+	if !t.seedingSince.IsZero() {
+		seededFor += now.Sub(t.seedingSince)
+	}
+
+	start := time.Now()
+	err := t.session.resumer.WriteStats(t.id, boltdbresumer.Stats{
+		BytesDownloaded: t.bytesDownloaded,
+		BytesUploaded:   t.bytesUploaded,
+		BytesWasted:     t.bytesWasted,
+		SeededFor:       seededFor,
+	})
+	t.session.metrics.resumeWrites.With("stats").ObserveSince(start)
+	if err != nil {
+		t.log.Error("cannot write stats to resume db", "torrent", t.id, "err", err.Error())
+	}
+}
+
+// writeStopped saves that the torrent is not started, so it is not started again when the Session is restarted.
+func (t *torrent) writeStopped() {
+	start := time.Now()
+	err := t.session.resumer.WriteStarted(t.id, false)
+	t.session.metrics.resumeWrites.With("started").ObserveSince(start)
+	if err != nil {
+		t.log.Error("cannot write started to resume db", "torrent", t.id, "err", err.Error())
+	}
+}
+
+func (t *torrent) writeStopAfterDownload(value bool) {
+	start := time.Now()
+	err := t.session.resumer.WriteStopAfterDownload(t.id, value)
+	t.session.metrics.resumeWrites.With("stop_after_download").ObserveSince(start)
+	if err != nil {
+		t.log.Error("cannot write stop after download to resume db", "torrent", t.id, "err", err.Error())
+	}
+}
@@ -0,0 +1,182 @@
+package torrent
+
+import (
+	"time"
+)
+
+// Interval of checking the seed limits of a seeding torrent.
+const seedLimitsCheckInterval = 10 * time.Second
+
+// StopReason tells why a torrent is stopped.
+type StopReason int
+
+const (
+	// NotStopped is the reason of a torrent that is running or never started.
+	NotStopped StopReason = iota
+	// StoppedByUser indicates that the torrent is stopped with Stop().
+	StoppedByUser
+	// StoppedByError indicates that the torrent is stopped by an error.
+	StoppedByError
+	// StoppedAfterVerify indicates that the torrent is stopped after a verification started with Verify().
+	StoppedAfterVerify
+	// StoppedAfterDownload indicates that the torrent is stopped when the download is completed because of the StopAfterDownload option.
+	StoppedAfterDownload
+	// StoppedByRatioLimit indicates that the torrent reached its seed ratio limit.
+	StoppedByRatioLimit
+	// StoppedBySeedTimeLimit indicates that the torrent reached its seed time limit.
+	StoppedBySeedTimeLimit
+	// StoppedByIdleLimit indicates that the torrent did not upload for its seed idle limit.
+	StoppedByIdleLimit
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case NotStopped:
+		return "not stopped"
+	case StoppedByUser:
+		return "user"
+	case StoppedByError:
+		return "error"
+	case StoppedAfterVerify:
+		return "verify done"
+	case StoppedAfterDownload:
+		return "download complete"
+	case StoppedByRatioLimit:
+		return "ratio limit"
+	case StoppedBySeedTimeLimit:
+		return "seed time limit"
+	case StoppedByIdleLimit:
+		return "idle limit"
+	default:
+		return "unknown"
+	}
+}
+
+// stoppedByItself returns true if the torrent is stopped by a limit or an option, so it must stay stopped after a restart.
+// Torrents stopped by an error are started again.
+func (r StopReason) stoppedByItself() bool {
+	switch r {
+	case StoppedAfterDownload, StoppedByRatioLimit, StoppedBySeedTimeLimit, StoppedByIdleLimit:
+		return true
+	default:
+		return false
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (r StopReason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// SeedLimits returns the seed limits of the torrent.
+func (t *torrent) SeedLimits() SeedLimits {
+	t.mSeedLimits.RLock()
+	defer t.mSeedLimits.RUnlock()
+	return t.seedLimits
+}
+
+// SetSeedLimits changes the seed limits of the torrent and checks them if the torrent is seeding.
+func (t *torrent) SetSeedLimits(l SeedLimits) {
+	t.mSeedLimits.Lock()
+	t.seedLimits = l
+	t.mSeedLimits.Unlock()
+
+	t.checkSeedLimits()
+}
+
+type seedLimitsRequest struct {
+	Response chan struct{}
+}
+
+// checkSeedLimits checks the seed limits in the run loop after they are changed.
+func (t *torrent) checkSeedLimits() {
+	req := seedLimitsRequest{
+		Response: make(chan struct{}, 1),
+	}
+
+	select {
+	case t.seedLimitsCommandC <- req:
+	case <-t.closeC:
+		return
+	}
+
+	select {
+	case <-req.Response:
+	case <-t.closeC:
+	}
+}
+
+// startSeeding starts counting the seeding time and checking the seed limits.
+func (t *torrent) startSeeding(now time.Time) {
+	t.seedingSince = now
+	t.seedIdleSince = now
+	t.seedIdleUploaded = t.bytesUploaded
+	t.seedLimitsTicker = time.NewTicker(seedLimitsCheckInterval)
+	t.seedLimitsTickerC = t.seedLimitsTicker.C
+}
+
+// stopSeeding adds the current seeding period to the total.
+func (t *torrent) stopSeeding(now time.Time) {
+	t.updateSeedDuration(now)
+	t.seedingSince = time.Time{}
+	if t.seedLimitsTicker != nil {
+		t.seedLimitsTicker.Stop()
+		t.seedLimitsTicker = nil
+		t.seedLimitsTickerC = nil
+	}
+}
+
+// seedRatio returns the share ratio that is compared with the ratio limit.
+// Torrents that are seeded from existing files did not download anything, their ratio is based on the completed bytes.
+func (t *torrent) seedRatio() float64 {
+	downloaded := t.bytesDownloaded
+	if downloaded == 0 {
+		downloaded = t.bytesComplete()
+	}
+	if downloaded == 0 {
+		return 0
+	}
+	return float64(t.bytesUploaded) / float64(downloaded)
+}
+
+// handleSeedLimits stops or removes the torrent if it is seeding and one of its seed limits is reached.
+func (t *torrent) handleSeedLimits(now time.Time) {
+	if t.seedingSince.IsZero() {
+		return
+	}
+
+	if t.bytesUploaded != t.seedIdleUploaded {
+		t.seedIdleUploaded = t.bytesUploaded
+		t.seedIdleSince = now
+	}
+
+	l := t.SeedLimits().merge(t.session.SeedLimits())
+	var reason StopReason
+	switch {
+	case l.Ratio > 0 && t.seedRatio() >= l.Ratio:
+		reason = StoppedByRatioLimit
+	case l.Time > 0 && t.seededFor+now.Sub(t.seedingSince) >= l.Time:
+		reason = StoppedBySeedTimeLimit
+	case l.IdleTime > 0 && now.Sub(t.seedIdleSince) >= l.IdleTime:
+		reason = StoppedByIdleLimit
+	default:
+		return
+	}
+
+	t.log.Info("seed limit reached", "torrent", t.id, "reason", reason.String(), "action", l.Action.String())
+	t.stopWithReason(reason, nil)
+	if l.Action != SeedLimitRemove {
+		return
+	}
+
+	// RemoveTorrent waits for the run loop to exit
+	s := t.session
+	s.backgroundWG.Add(1)
+	go func() {
+		defer s.backgroundWG.Done()
+		err := s.RemoveTorrent(t.id)
+		if err != nil && err != ErrTorrentNotFound {
+			t.log.Error("cannot remove torrent", "torrent", t.id, "err", err.Error())
+		}
+	}()
+}
@@ -0,0 +1,149 @@
+package torrent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopAfterDownload(t *testing.T) {
+	cfg := testConfig(t)
+	s := newTestSession(t, cfg)
+
+	tor := addTestTorrent(t, s, testTorrent{Name: "file.bin", Complete: true, Options: &AddTorrentOptions{StopAfterDownload: true}})
+	waitStatus(t, tor, Stopped)
+	assert.Equal(t, StoppedAfterDownload, tor.Stats().StopReason)
+	assert.Equal(t, 1.0, tor.Stats().Progress)
+
+	// The option is used once and the torrent stays stopped after a restart
+	spec, err := s.resumer.Read(tor.ID())
+	require.NoError(t, err)
+	assert.False(t, spec.StopAfterDownload)
+	assert.False(t, spec.Started)
+
+	// Starting again seeds the torrent
+	require.NoError(t, tor.Start())
+	waitStatus(t, tor, Seeding)
+	assert.Equal(t, NotStopped, tor.Stats().StopReason)
+	s.Close()
+
+	// It is not stopped again after a restart
+	s = newTestSession(t, cfg)
+	defer s.Close()
+	tor = s.GetTorrent(tor.ID())
+	waitStatus(t, tor, Seeding)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, Seeding, tor.Stats().Status)
+
+	require.NoError(t, tor.Stop())
+	assert.Equal(t, StoppedByUser, tor.Stats().StopReason)
+}
+
+func TestSeedTimeLimit(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+
+	tor := addTestTorrent(t, s, testTorrent{Name: "file.bin", Complete: true, Options: &AddTorrentOptions{}})
+	waitStatus(t, tor, Seeding)
+
+	// The ratio is not reached without uploads
+	require.NoError(t, tor.SetSeedLimits(SeedLimits{Ratio: 1}))
+	assert.Equal(t, Seeding, tor.Stats().Status)
+
+	limits := SeedLimits{Ratio: 1, Time: time.Nanosecond}
+	require.NoError(t, tor.SetSeedLimits(limits))
+	stats := tor.Stats()
+	assert.Equal(t, Stopped, stats.Status)
+	assert.Equal(t, StoppedBySeedTimeLimit, stats.StopReason)
+	assert.Equal(t, limits, tor.SeedLimits())
+
+	spec, err := s.resumer.Read(tor.ID())
+	require.NoError(t, err)
+	assert.Equal(t, time.Nanosecond, spec.SeedLimits.Time)
+	// The torrent is not started again after a restart
+	assert.False(t, spec.Started)
+	assert.Positive(t, spec.SeededFor)
+}
+
+func TestSeedTimeSaved(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.ResumeWriteInterval = 10 * time.Millisecond
+	s := newTestSession(t, cfg)
+
+	tor := addTestTorrent(t, s, testTorrent{Complete: true, Options: &AddTorrentOptions{}})
+	waitStatus(t, tor, Seeding)
+
+	// The seeding time is saved while the torrent is running
+	require.Eventually(t, func() bool {
+		spec, err := s.resumer.Read(tor.ID())
+		require.NoError(t, err)
+		return spec.SeededFor > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	before := tor.Stats().SeededFor
+	s.Close()
+
+	s = newTestSession(t, cfg)
+	defer s.Close()
+	loaded := s.GetTorrent(tor.ID())
+	seededFor := loaded.Stats().SeededFor
+	assert.GreaterOrEqual(t, seededFor, before)
+
+	// The time limit includes the time seeded before the restart
+	waitStatus(t, loaded, Seeding)
+	require.NoError(t, loaded.SetSeedLimits(SeedLimits{Time: seededFor}))
+	assert.Equal(t, StoppedBySeedTimeLimit, loaded.Stats().StopReason)
+}
+
+func TestSessionSeedLimits(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.SeedRatioLimit = 2
+	s := newTestSession(t, cfg)
+
+	keep := addTestTorrent(t, s, testTorrent{Name: "keep.bin", Complete: true, Options: &AddTorrentOptions{ID: "keep"}})
+	waitStatus(t, keep, Seeding)
+	require.NoError(t, keep.SetSeedLimits(SeedLimits{IdleTime: -1}))
+
+	remove := addTestTorrent(t, s, testTorrent{Name: "remove.bin", Complete: true, Options: &AddTorrentOptions{ID: "remove"}})
+	waitStatus(t, remove, Seeding)
+
+	limits := SeedLimits{Ratio: 2, IdleTime: time.Nanosecond, Action: SeedLimitRemove}
+	require.NoError(t, s.SetSeedLimits(limits))
+	assert.Equal(t, limits, s.SeedLimits())
+	require.Eventually(t, func() bool { return s.GetTorrent("remove") == nil }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, Seeding, keep.Stats().Status)
+	s.Close()
+
+	// Saved limits override the config
+	s = newTestSession(t, cfg)
+	defer s.Close()
+	assert.Equal(t, limits, s.SeedLimits())
+
+	assert.Error(t, s.SetSeedLimits(SeedLimits{Action: 5}))
+}
+
+func TestSeedLimitsMerge(t *testing.T) {
+	global := SeedLimits{Ratio: 2, Time: time.Hour, IdleTime: time.Minute}
+	assert.Equal(t, SeedLimits{Ratio: 2, Time: time.Hour, IdleTime: time.Minute, Action: SeedLimitStop}, SeedLimits{}.merge(global))
+
+	global.Action = SeedLimitRemove
+	l := SeedLimits{Ratio: -1, Time: 2 * time.Hour}.merge(global)
+	assert.Equal(t, SeedLimits{Ratio: -1, Time: 2 * time.Hour, IdleTime: time.Minute, Action: SeedLimitRemove}, l)
+}
+
+func TestSeedLimitsRestore(t *testing.T) {
+	cfg := testConfig(t)
+	s := newTestSession(t, cfg)
+
+	limits := SeedLimits{Ratio: -1, Time: time.Hour, IdleTime: time.Minute, Action: SeedLimitRemove}
+	tor := addTestTorrent(t, s, testTorrent{})
+	require.NoError(t, tor.SetSeedLimits(limits))
+	s.Close()
+
+	s = newTestSession(t, cfg)
+	defer s.Close()
+	loaded := s.GetTorrent(tor.ID())
+	require.NotNil(t, loaded)
+	assert.Equal(t, limits, loaded.SeedLimits())
+}
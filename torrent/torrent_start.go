@@ -2,11 +2,13 @@ package torrent
 
 import (
 	"net"
+	"time"
 
 	"github.com/al002/zbittorrent/internal/acceptor"
 	"github.com/al002/zbittorrent/internal/allocator"
 	"github.com/al002/zbittorrent/internal/announcer"
 	"github.com/al002/zbittorrent/internal/tracker"
+	"github.com/al002/zbittorrent/internal/verifier"
 )
 
 func (t *torrent) start() {
@@ -21,9 +23,25 @@ func (t *torrent) start() {
 
 	t.errC = make(chan error, 1)
 	t.lastError = nil
+	t.stopReason = NotStopped
 
   t.startAcceptor()
 	t.startAnnouncers()
+	t.startStatsWriter()
+
+	if t.info != nil {
+		if t.pieces == nil {
+			t.startAllocator()
+		} else if t.bitfield == nil {
+			t.startVerifier()
+		}
+	}
+
+	if t.completed {
+		t.startSeeding(time.Now())
+	}
+
+	t.publish(Event{Type: TorrentStarted})
 	// if t.info != nil {
 	//
 	// } else {
@@ -40,18 +58,28 @@ func (t *torrent) startAnnouncers() {
 }
 
 func (t *torrent) startNewAnnouncer(tr tracker.Tracker) {
-	a := announcer.NewPeriodicalAnnouncer(
+	a := t.newAnnouncer(tr, t.announceGetTorrent)
+	t.announcers = append(t.announcers, a)
+	go a.Run()
+
+	// Hybrid torrents join the v2 swarm too
+	if t.isHybrid() {
+		a = t.newAnnouncer(tr, t.announceGetTorrentV2)
+		t.announcersV2 = append(t.announcersV2, a)
+		go a.Run()
+	}
+}
+
+func (t *torrent) newAnnouncer(tr tracker.Tracker, getTorrent func() tracker.Torrent) *announcer.PeriodicalAnnouncer {
+	return announcer.NewPeriodicalAnnouncer(
 		tr,
 		t.session.config.TrackerNumWant,
 		t.session.config.TrackerMinAnnounceInterval,
-		t.announceGetTorrent,
+		getTorrent,
 		t.completeC,
 		t.announcePeersC,
+		t.handleAnnouncerEvent,
 	)
-
-	t.announcers = append(t.announcers, a)
-
-	go a.Run()
 }
 
 func (t *torrent) startAcceptor() {
@@ -62,13 +90,13 @@ func (t *torrent) startAcceptor() {
 	ip := net.ParseIP(t.session.config.Host)
 	listener, err := net.ListenTCP("tcp4", &net.TCPAddr{
 		IP:   ip,
-		Port: t.port,
+		Port: int(t.port.Load()),
 	})
 
 	if err != nil {
 		t.log.Warn(
 			"cannot listen port",
-			"port", t.port,
+			"port", t.port.Load(),
 			"err", err.Error(),
 		)
 	} else {
@@ -76,7 +104,7 @@ func (t *torrent) startAcceptor() {
 			"Listening peers on tcp://"+listener.Addr().String(),
 			"addr", listener.Addr().String(),
 		)
-    t.port = listener.Addr().(*net.TCPAddr).Port
+    t.port.Store(int32(listener.Addr().(*net.TCPAddr).Port))
     t.acceptor = acceptor.New(listener, t.incomingConnC, t.log)
     go t.acceptor.Run()
 	}
@@ -88,5 +116,15 @@ func (t *torrent) startAllocator() {
   }
 
   t.allocator = allocator.New()
-  go t.allocator.Run(t.info, t.storage, t.allocatorProgressC, t.allocatorResultC)
+  go t.allocator.Run(t.info, t.storage, t.skippedFiles(), t.allocatorProgressC, t.allocatorResultC)
+}
+
+func (t *torrent) startVerifier() {
+	if t.verifier != nil {
+		t.crash("verifier exists")
+	}
+
+	t.checkedPieces = 0
+	t.verifier = verifier.New()
+	go t.verifier.Run(t.pieces, t.verifierProgressC, t.verifierResultC)
 }
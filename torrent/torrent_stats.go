@@ -0,0 +1,213 @@
+package torrent
+
+import (
+	"time"
+
+	"github.com/al002/zbittorrent/internal/peer"
+)
+
+// Status of a torrent.
+type Status int
+
+const (
+	// Stopped indicates that the torrent is not running.
+	// No peers are connected and files are not open.
+	Stopped Status = iota
+	// Allocating indicates that the torrent is opening or creating the files on the disk.
+	Allocating
+	// Verifying indicates that the torrent is checking the hashes of the pieces that exist on the disk.
+	Verifying
+	// Downloading indicates that the torrent is in the process of downloading the missing pieces.
+	Downloading
+	// Seeding indicates that the torrent has all the pieces and is serving them to other peers.
+	Seeding
+	// Error indicates that the torrent has stopped because of an error.
+	Error
+	// Queued indicates that the torrent is started but waits in the queue for other torrents.
+	Queued
+)
+
+var statusNames = [...]string{
+	"stopped",
+	"allocating",
+	"verifying",
+	"downloading",
+	"seeding",
+	"error",
+	"queued",
+}
+
+func (s Status) String() string {
+	return statusNames[s]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Stats contains statistics about a Torrent.
+type Stats struct {
+	// Status of the torrent.
+	Status Status
+	// Contains the error if torrent is stopped unexpectedly.
+	Error error
+	// Why the torrent is stopped. NotStopped if the torrent is running.
+	StopReason StopReason
+	// Ratio of the completed bytes to the total bytes, between 0 and 1.
+	Progress float64
+	Pieces   struct {
+		// Number of pieces that are checked when torrent is in "verifying" status.
+		Checked uint32
+		// Number of pieces that we have downloaded and verified.
+		Have uint32
+		// Number of pieces that need to be downloaded.
+		Missing uint32
+		// Number of total pieces in the torrent.
+		Total uint32
+	}
+	Bytes struct {
+		// Bytes that are downloaded and passed the hash check.
+		Completed int64
+		// The number of bytes that is needed to complete all missing pieces.
+		Incomplete int64
+		// The number of total bytes of files in torrent. Total = Completed + Incomplete
+		Total int64
+		// Bytes that are opened or created on the disk.
+		Allocated int64
+		// Downloaded is the number of bytes downloaded from peers.
+		Downloaded int64
+		// Protocol messages are not included, only piece data is counted.
+		Uploaded int64
+		// Bytes downloaded due to duplicate or non-requested pieces, or pieces failed the hash check.
+		Wasted int64
+	}
+	Peers struct {
+		// Number of peers that are connected and handshaked.
+		Total int
+		// Number of connected peers by the source they are found from.
+		BySource map[peer.Source]int
+		// Number of peer addresses rejected by the blocklist and the IPFilter, by reason.
+		Rejected map[FilterReason]int
+	}
+	Speed struct {
+		// Download speed in bytes per second.
+		Download int
+		// Upload speed in bytes per second.
+		Upload int
+	}
+	// Time remaining to complete download. Nil means the download will not complete at the current speed.
+	ETA *time.Duration
+	// Uploaded bytes divided by downloaded bytes.
+	Ratio float64
+	// Total time spent in seeding status.
+	SeededFor time.Duration
+}
+
+type statsRequest struct {
+	Response chan Stats
+}
+
+func (t *torrent) Stats() Stats {
+	var stats Stats
+	req := statsRequest{
+		Response: make(chan Stats, 1),
+	}
+
+	select {
+	case t.statsCommandC <- req:
+	case <-t.closeC:
+	}
+
+	select {
+	case stats = <-req.Response:
+	case <-t.closeC:
+	}
+
+	return stats
+}
+
+func (t *torrent) status() Status {
+	if t.errC == nil {
+		if t.lastError != nil && t.lastError != errClosed {
+			return Error
+		}
+		if t.queued.Load() {
+			return Queued
+		}
+		return Stopped
+	}
+
+	if t.allocator != nil {
+		return Allocating
+	}
+
+	if t.verifier != nil {
+		return Verifying
+	}
+
+	if t.completed {
+		return Seeding
+	}
+
+	return Downloading
+}
+
+func (t *torrent) stats() Stats {
+	var s Stats
+	s.Status = t.status()
+	if s.Status == Error {
+		s.Error = t.lastError
+	}
+	s.StopReason = t.stopReason
+
+	if t.info != nil {
+		s.Bytes.Total = t.info.Length
+		s.Pieces.Total = t.info.NumPieces
+	}
+	s.Bytes.Allocated = t.bytesAllocated
+	s.Bytes.Completed = t.bytesComplete()
+	s.Bytes.Incomplete = s.Bytes.Total - s.Bytes.Completed
+	s.Bytes.Downloaded = t.bytesDownloaded
+	s.Bytes.Uploaded = t.bytesUploaded
+	s.Bytes.Wasted = t.bytesWasted
+
+	if s.Bytes.Total > 0 {
+		s.Progress = float64(s.Bytes.Completed) / float64(s.Bytes.Total)
+	}
+
+	s.Pieces.Checked = t.checkedPieces
+	if t.bitfield != nil {
+		s.Pieces.Have = t.bitfield.Count()
+	}
+	s.Pieces.Missing = s.Pieces.Total - s.Pieces.Have
+
+	s.Peers.Total = len(t.peers)
+	s.Peers.BySource = make(map[peer.Source]int)
+	for p := range t.peers {
+		s.Peers.BySource[p.Source]++
+	}
+	s.Peers.Rejected = make(map[FilterReason]int, len(t.rejectedPeers))
+	for reason, n := range t.rejectedPeers {
+		s.Peers.Rejected[reason] = n
+	}
+
+	s.Speed.Download = t.downloadSpeed.Rate()
+	s.Speed.Upload = t.uploadSpeed.Rate()
+
+	if s.Status == Downloading && s.Speed.Download > 0 {
+		eta := time.Duration(s.Bytes.Incomplete/int64(s.Speed.Download)) * time.Second
+		s.ETA = &eta
+	}
+
+	if s.Bytes.Downloaded > 0 {
+		s.Ratio = float64(s.Bytes.Uploaded) / float64(s.Bytes.Downloaded)
+	}
+
+	s.SeededFor = t.seededFor
+	if !t.seedingSince.IsZero() {
+		s.SeededFor += time.Since(t.seedingSince)
+	}
+
+	return s
+}
@@ -0,0 +1,61 @@
+package torrent
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTorrentStats(t *testing.T) {
+	s := newTestSession(t, testConfig(t))
+	defer s.Close()
+
+	const total = 4*testPieceLength - 100
+	tor := addTestTorrent(t, s, testTorrent{Complete: true})
+
+	st := tor.Stats()
+	assert.Equal(t, Stopped, st.Status)
+	assert.Nil(t, st.Error)
+	assert.Equal(t, uint32(4), st.Pieces.Total)
+	assert.Equal(t, uint32(0), st.Pieces.Have)
+	assert.Equal(t, uint32(4), st.Pieces.Missing)
+	assert.Equal(t, int64(total), st.Bytes.Total)
+	assert.Equal(t, int64(0), st.Bytes.Completed)
+	assert.Equal(t, int64(total), st.Bytes.Incomplete)
+	assert.Equal(t, 0.0, st.Progress)
+
+	// The first piece on the disk does not match its hash
+	path := filepath.Join(s.config.DataDir, "test")
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteAt(bytes.Repeat([]byte{'b'}, 10), 0)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	require.NoError(t, tor.Start())
+	waitStatus(t, tor, Downloading)
+	st = tor.Stats()
+	assert.Equal(t, uint32(4), st.Pieces.Checked)
+	assert.Equal(t, uint32(3), st.Pieces.Have)
+	assert.Equal(t, uint32(1), st.Pieces.Missing)
+	assert.Equal(t, int64(total), st.Bytes.Allocated)
+	assert.Equal(t, int64(total-testPieceLength), st.Bytes.Completed)
+	assert.Equal(t, int64(testPieceLength), st.Bytes.Incomplete)
+	assert.InDelta(t, float64(total-testPieceLength)/total, st.Progress, 1e-9)
+	assert.Equal(t, NotStopped, st.StopReason)
+
+	// The last piece is shorter than the others
+	other := addTestTorrent(t, s, testTorrent{Name: "other", Complete: true, Options: &AddTorrentOptions{}})
+	waitStatus(t, other, Seeding)
+	st = other.Stats()
+	assert.Equal(t, uint32(4), st.Pieces.Have)
+	assert.Equal(t, uint32(0), st.Pieces.Missing)
+	assert.Equal(t, int64(total), st.Bytes.Completed)
+	assert.Equal(t, int64(0), st.Bytes.Incomplete)
+	assert.Equal(t, 1.0, st.Progress)
+	assert.Nil(t, st.ETA)
+}
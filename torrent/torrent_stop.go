@@ -0,0 +1,104 @@
+package torrent
+
+import (
+	"time"
+
+	"github.com/al002/zbittorrent/internal/announcer"
+	"github.com/al002/zbittorrent/internal/tracker"
+)
+
+func (t *torrent) stop(err error) {
+	reason := StoppedByUser
+	if err != nil && err != errClosed {
+		reason = StoppedByError
+	}
+	t.stopWithReason(reason, err)
+}
+
+func (t *torrent) stopWithReason(reason StopReason, err error) {
+	if t.errC == nil {
+		return
+	}
+
+	if err != nil && err != errClosed {
+		t.log.Error("torrent stopped with error", "torrent", t.id, "err", err.Error())
+	} else {
+		t.log.Info("stopping torrent", "torrent", t.id)
+	}
+
+	t.lastError = err
+	t.stopReason = reason
+	t.stopAfterVerify = false
+	t.errC <- err
+	t.errC = nil
+
+	now := time.Now()
+	t.stopSeeding(now)
+	t.stopStatsWriter()
+	t.writeStats(now)
+	if reason.stoppedByItself() {
+		t.writeStopped()
+	}
+
+	if t.acceptor != nil {
+		t.acceptor.Close()
+		t.acceptor = nil
+	}
+
+	if t.allocator != nil {
+		t.allocator.Close()
+		t.allocator = nil
+	}
+
+	if t.verifier != nil {
+		t.verifier.Close()
+		t.verifier = nil
+	}
+
+	t.stopAnnouncers()
+
+	t.publish(Event{Type: TorrentStopped, Error: err})
+	t.session.notifyQueue()
+}
+
+func (t *torrent) stopAnnouncers() {
+	for _, an := range t.announcers {
+		an.Close()
+	}
+	t.announcers = nil
+	for _, an := range t.announcersV2 {
+		an.Close()
+	}
+	t.announcersV2 = nil
+
+	if t.stoppedEventAnnouncer != nil {
+		t.crash("stopped event announcer exists")
+	}
+
+	if len(t.trackers) == 0 {
+		return
+	}
+
+	torrents := []tracker.Torrent{t.announceGetTorrent()}
+	if t.isHybrid() {
+		torrents = append(torrents, t.announceGetTorrentV2())
+	}
+
+	t.stoppedEventAnnouncer = announcer.NewStopAnnouncer(
+		t.trackers,
+		torrents,
+		t.session.config.TrackerStopTimeout,
+		t.announcersStoppedC,
+	)
+	go t.stoppedEventAnnouncer.Run()
+}
+
+// updateSeedDuration adds the duration of current seeding period to the total.
+func (t *torrent) updateSeedDuration(now time.Time) {
+	if t.seedingSince.IsZero() {
+		return
+	}
+
+	t.seededFor += now.Sub(t.seedingSince)
+	t.seedingSince = now
+}
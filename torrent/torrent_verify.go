@@ -0,0 +1,33 @@
+package torrent
+
+// Verify checks the hashes of all pieces on the disk again. Does not block.
+// The torrent is started for verification and stopped again when it is done if it was stopped before.
+func (t *torrent) Verify() {
+	select {
+	case t.verifyCommandC <- struct{}{}:
+	case <-t.closeC:
+	}
+}
+
+func (t *torrent) verify() {
+	if t.info == nil || t.allocator != nil || t.verifier != nil {
+		return
+	}
+
+	wasRunning := t.errC != nil
+	if wasRunning {
+		t.stop(nil)
+	}
+
+	// Forget the pieces we have, they are going to be checked again
+	t.bitfield = nil
+	t.picker = nil
+	if t.completed {
+		t.completed = false
+		t.completedFlag.Store(false)
+		t.completeC = make(chan struct{})
+	}
+
+	t.stopAfterVerify = !wasRunning
+	t.start()
+}